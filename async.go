@@ -0,0 +1,209 @@
+package gobinlog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	errAsyncQueueClosed = errors.New("async queue is closed") //异步队列已经关闭
+)
+
+//AsyncQueue 异步投递事务的有界队列，Send把事务放入队列后立即返回，解析binlog不会被慢的下游阻塞，
+//下游从Transactions中读取事务，处理完毕后调用Ack，事务可以乱序确认，
+//检查点只会推进到连续确认的最后一个事务，重启后从检查点开始dump即可保证至少一次投递。
+//还没有确认的事务数达到队列的容量时Send会阻塞，从而对解析binlog形成背压
+//   q := NewAsyncQueue(1024, saveCheckpoint)
+//   go func() {
+//       for t := range q.Transactions() {
+//           go func(t *AckTransaction) {
+//               if err := apply(t.Transaction); err != nil {
+//                   t.Nack(err)
+//                   return
+//               }
+//               t.Ack()
+//           }(t)
+//       }
+//   }()
+//   err := s.StreamAsync(ctx, q)
+type AsyncQueue struct {
+	saver        checkpointSaver
+	size         int
+	transactions chan *AckTransaction
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tracker *checkpointTracker
+	unacked int //还没有确认的事务数
+	closed  bool
+	err     error
+}
+
+//AckTransaction 异步投递的事务，处理完毕后需要调用Ack，处理失败时调用Nack
+type AckTransaction struct {
+	*Transaction
+	id    uint64
+	q     *AsyncQueue
+	acked int32
+}
+
+//NewAsyncQueue 创建AsyncQueue，size是还没有确认的事务的最大数，
+//checkpoint在检查点推进时被调用，可以为nil，checkpoint在锁之外依次被调用，可以执行耗时的I/O
+func NewAsyncQueue(size int, checkpoint CheckpointFunc) *AsyncQueue {
+	if size < 1 {
+		size = 1
+	}
+	q := &AsyncQueue{
+		saver:        checkpointSaver{fn: checkpoint},
+		size:         size,
+		transactions: make(chan *AckTransaction, size),
+		tracker:      newCheckpointTracker(Position{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+//StreamAsync 将事务异步投递到q中，返回时关闭q
+func (s *Streamer) StreamAsync(ctx context.Context, q *AsyncQueue) error {
+	err := s.Stream(ctx, q.Send)
+	if closeErr := q.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+//Send 将事务放入队列，还没有确认的事务数达到容量时阻塞，
+//可以直接作为SendTransactionFunc注册到Streamer.Stream中，有事务Nack之后返回该错误
+func (q *AsyncQueue) Send(tran *Transaction) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.err == nil && !q.closed && q.unacked >= q.size {
+		q.cond.Wait()
+	}
+	if q.err != nil {
+		return q.err
+	}
+	if q.closed {
+		return errAsyncQueueClosed
+	}
+
+	if tran.spill != nil {
+		tran.spill.retain()
+	}
+	id := q.tracker.track(tran.NowPosition, tran.NextPosition, 1)
+	q.unacked++
+	//未确认的事务数不超过size，所以不会阻塞
+	q.transactions <- &AckTransaction{
+		Transaction: tran,
+		id:          id,
+		q:           q,
+	}
+	return nil
+}
+
+//Transactions 返回读取事务的chan，队列关闭后chan被关闭
+func (q *AsyncQueue) Transactions() <-chan *AckTransaction {
+	return q.transactions
+}
+
+//Close 关闭队列，之后Send返回错误，已经在队列中的事务仍然可以读取以及确认，返回Nack的第一个错误
+func (q *AsyncQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.transactions)
+		q.cond.Broadcast()
+	}
+	return q.err
+}
+
+//Checkpoint 获取当前检查点，该位置之前的事务都已经确认
+func (q *AsyncQueue) Checkpoint() Position {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tracker.checkpoint()
+}
+
+//InFlight 还没有确认的事务数
+func (q *AsyncQueue) InFlight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.unacked
+}
+
+//Err 获取Nack以及保存检查点的第一个错误
+func (q *AsyncQueue) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+func (q *AsyncQueue) finish(t *AckTransaction, err error) {
+	q.mu.Lock()
+	q.unacked--
+	advanced := false
+	if err != nil {
+		q.setErrLocked(err)
+	} else if q.err == nil {
+		_, advanced = q.tracker.done(t.id)
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	if advanced {
+		q.saveCheckpoint()
+	}
+}
+
+//saveCheckpoint 在锁之外调用checkpoint保存最新的检查点
+func (q *AsyncQueue) saveCheckpoint() {
+	err := q.saver.save(func() (Position, bool) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.tracker.checkpoint(), q.err == nil
+	})
+	if err != nil {
+		q.mu.Lock()
+		q.setErrLocked(err)
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+func (q *AsyncQueue) setErrLocked(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+//Ack 确认事务已经处理完毕，重复调用无效
+func (t *AckTransaction) Ack() {
+	if t.release() {
+		t.q.finish(t, nil)
+	}
+}
+
+//Nack 事务处理失败，检查点不会再推进，之后Send返回err，Streamer.Stream随之停止
+func (t *AckTransaction) Nack(err error) {
+	if err == nil {
+		err = errors.New("transaction is not acknowledged")
+	}
+	if t.release() {
+		t.q.finish(t, err)
+	}
+}
+
+//release 第一次确认时释放溢出文件
+func (t *AckTransaction) release() bool {
+	if !atomic.CompareAndSwapInt32(&t.acked, 0, 1) {
+		return false
+	}
+	if t.spill != nil {
+		t.spill.release()
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package gobinlog
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func testAsyncTransaction(offset int64) *Transaction {
+	return &Transaction{
+		NowPosition:  Position{Filename: "binlog.000001", Offset: offset - 100},
+		NextPosition: Position{Filename: "binlog.000001", Offset: offset},
+	}
+}
+
+func TestAsyncQueue(t *testing.T) {
+	var checkpoints []int64
+	q := NewAsyncQueue(2, func(pos Position) error {
+		checkpoints = append(checkpoints, pos.Offset)
+		return nil
+	})
+
+	for _, offset := range []int64{200, 300} {
+		if err := q.Send(testAsyncTransaction(offset)); err != nil {
+			t.Fatalf("Send %v fail. err: %v", offset, err)
+		}
+	}
+
+	sent := make(chan error)
+	go func() {
+		sent <- q.Send(testAsyncTransaction(400))
+	}()
+	select {
+	case err := <-sent:
+		t.Fatalf("Send want blocked when queue is full. err: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first, second := <-q.Transactions(), <-q.Transactions()
+	second.Ack()
+	if err := <-sent; err != nil {
+		t.Fatalf("Send 400 fail. err: %v", err)
+	}
+	third := <-q.Transactions()
+	if q.Checkpoint().Offset != 100 || len(checkpoints) != 0 {
+		t.Fatalf("checkpoint want: 100 [] out: %v %v", q.Checkpoint().Offset, checkpoints)
+	}
+
+	first.Ack()
+	first.Ack()
+	if q.Checkpoint().Offset != 300 || fmt.Sprint(checkpoints) != "[300]" || q.InFlight() != 1 {
+		t.Fatalf("checkpoint want: 300 [300] 1 out: %v %v %v", q.Checkpoint().Offset, checkpoints, q.InFlight())
+	}
+
+	third.Nack(fmt.Errorf("apply fail"))
+	if err := q.Send(testAsyncTransaction(500)); err == nil || err.Error() != "apply fail" {
+		t.Fatalf("Send after Nack want: apply fail out: %v", err)
+	}
+	if q.Checkpoint().Offset != 300 {
+		t.Fatalf("checkpoint want: 300 out: %v", q.Checkpoint().Offset)
+	}
+	if err := q.Close(); err == nil {
+		t.Fatalf("Close want error")
+	}
+	if _, ok := <-q.Transactions(); ok {
+		t.Fatalf("Transactions want closed")
+	}
+}
+
+func TestAsyncQueue_Spill(t *testing.T) {
+	dir, clean := testSpillDir(t)
+	defer clean()
+	f, err := newSpillFile(dir)
+	if err != nil {
+		t.Fatalf("newSpillFile fail. err: %v", err)
+	}
+	if err = f.write(&StreamEvent{Type: StatementInsert, RowValues: []*RowData{{}}}); err != nil {
+		t.Fatalf("write fail. err: %v", err)
+	}
+	if err = f.finish(); err != nil {
+		t.Fatalf("finish fail. err: %v", err)
+	}
+
+	q := NewAsyncQueue(1, nil)
+	tran := testAsyncTransaction(200)
+	tran.spill = f
+	if err = q.Send(tran); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	//Streamer在Send返回后释放溢出文件
+	f.release()
+	if err = q.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+
+	at := <-q.Transactions()
+	cnt := 0
+	if err = at.RangeEvents(func(s *StreamEvent) error {
+		cnt++
+		return nil
+	}); err != nil || cnt != 1 {
+		t.Fatalf("RangeEvents want: 1 <nil> out: %v %v", cnt, err)
+	}
+	at.Ack()
+	if _, err = os.Stat(f.name); !os.IsNotExist(err) {
+		t.Fatalf("spill file %v is not removed. err: %v", f.name, err)
+	}
+	if q.Checkpoint().Offset != 200 {
+		t.Fatalf("checkpoint want: 200 out: %v", q.Checkpoint().Offset)
+	}
+}
+
+func TestAsyncQueue_checkpointReentrant(t *testing.T) {
+	var q *AsyncQueue
+	var checkpoints []int64
+	q = NewAsyncQueue(2, func(pos Position) error {
+		//回调在锁之外执行，可以调用队列的方法
+		if q.Checkpoint().Offset < pos.Offset || q.InFlight() != 0 {
+			return fmt.Errorf("unexpected queue state")
+		}
+		checkpoints = append(checkpoints, pos.Offset)
+		return nil
+	})
+	if err := q.Send(testAsyncTransaction(200)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		(<-q.Transactions()).Ack()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("checkpoint callback deadlocks")
+	}
+	if err := q.Close(); err != nil || fmt.Sprint(checkpoints) != "[200]" {
+		t.Fatalf("want: <nil> [200] out: %v %v", err, checkpoints)
+	}
+}
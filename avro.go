@@ -0,0 +1,507 @@
+package gobinlog
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//avro的基本类型
+const (
+	avroTypeNull   = "null"
+	avroTypeInt    = "int"
+	avroTypeLong   = "long"
+	avroTypeFloat  = "float"
+	avroTypeDouble = "double"
+	avroTypeString = "string"
+	avroTypeBytes  = "bytes"
+)
+
+//avro记录中op的取值
+var avroOps = map[StatementType]int{
+	StatementInsert: 0,
+	StatementUpdate: 1,
+	StatementDelete: 2,
+}
+
+//avroMagic avro Object Container File的文件头
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+//defaultAvroBlockSize 每个数据块的默认记录数
+const defaultAvroBlockSize = 1000
+
+//AvroFileCreator 为表的每一个schema版本创建avro Object Container File的输出，
+//如果返回的io.Writer实现了io.Closer，在schema变化或者AvroEncoder关闭时会被关闭
+type AvroFileCreator func(table MysqlTableName, version int) (io.Writer, error)
+
+//AvroSchemaChange 表的avro schema发生变化，包括第一次出现
+type AvroSchemaChange struct {
+	Table    MysqlTableName //表名
+	Version  int            //schema的版本号，从1开始
+	Schema   []byte         //avro schema
+	Position Position       //第一次使用该schema的事务的位置
+	GTID     string         //第一次使用该schema的事务的GTID
+}
+
+//AvroSchemaChangeFunc 处理schema变化的函数，返回错误时EncodeTransaction返回该错误
+type AvroSchemaChangeFunc func(*AvroSchemaChange) error
+
+//AvroEncoder 将事务中的行数据按表编码为avro记录，每个表的每个schema版本写入一个
+//Object Container File，记录包含op，position，gtid，timestamp以及before/after的行数据。
+//schema由行数据的列名，ColumnType，字符集以及MysqlTable中的无符号属性生成，
+//DDL改变了这些信息后会生成新的版本。非线程安全
+type AvroEncoder struct {
+	tableMapper    MysqlTableMapper
+	create         AvroFileCreator
+	onSchemaChange AvroSchemaChangeFunc
+	blockSize      int
+	tables         map[MysqlTableName]*avroTable
+}
+
+//NewAvroEncoder 创建AvroEncoder，tableMapper用于获取整形列是否是无符号，可以为nil
+func NewAvroEncoder(tableMapper MysqlTableMapper, create AvroFileCreator) *AvroEncoder {
+	return &AvroEncoder{
+		tableMapper: tableMapper,
+		create:      create,
+		blockSize:   defaultAvroBlockSize,
+		tables:      make(map[MysqlTableName]*avroTable),
+	}
+}
+
+//SetBlockSize 设置每个数据块的记录数，达到后写入输出
+func (e *AvroEncoder) SetBlockSize(size int) {
+	if size > 0 {
+		e.blockSize = size
+	}
+}
+
+//SetSchemaChangeFunc 设置处理schema变化的函数
+func (e *AvroEncoder) SetSchemaChangeFunc(f AvroSchemaChangeFunc) {
+	e.onSchemaChange = f
+}
+
+//Schema 获取表当前的schema版本号以及schema，表没有出现过时version为0
+func (e *AvroEncoder) Schema(table MysqlTableName) (version int, schema []byte) {
+	if t, ok := e.tables[table]; ok {
+		return t.version, t.schema
+	}
+	return 0, nil
+}
+
+//EncodeTransaction 编码事务中所有的行数据，sql语句会被忽略
+func (e *AvroEncoder) EncodeTransaction(t *Transaction) error {
+	return t.RangeEvents(func(s *StreamEvent) error {
+		return e.encodeStreamEvent(t, s)
+	})
+}
+
+func (e *AvroEncoder) encodeStreamEvent(t *Transaction, s *StreamEvent) error {
+	op, ok := avroOps[s.Type]
+	if !ok || s.Query.SQL != "" {
+		return nil
+	}
+
+	table, err := e.table(t, s)
+	if err != nil {
+		return err
+	}
+	if table == nil {
+		return nil
+	}
+
+	cnt := len(s.RowValues)
+	if len(s.RowIdentifies) > cnt {
+		cnt = len(s.RowIdentifies)
+	}
+	for i := 0; i < cnt; i++ {
+		var before, after *RowData
+		if i < len(s.RowIdentifies) {
+			before = s.RowIdentifies[i]
+		}
+		if i < len(s.RowValues) {
+			after = s.RowValues[i]
+		}
+		if err = table.append(op, t, s, before, after); err != nil {
+			return newError(err).msgf("table %v avro encode fail.", s.Table.String())
+		}
+		if table.file.count >= e.blockSize {
+			if err = table.file.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//Flush 将所有表未满的数据块写入输出
+func (e *AvroEncoder) Flush() error {
+	for _, t := range e.tables {
+		if err := t.file.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Close 写入所有数据块并关闭所有输出
+func (e *AvroEncoder) Close() error {
+	var err error
+	for name, t := range e.tables {
+		if cerr := t.file.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(e.tables, name)
+	}
+	return err
+}
+
+//table 获取语句对应的表，列信息发生变化时生成新的schema版本
+func (e *AvroEncoder) table(t *Transaction, s *StreamEvent) (*avroTable, error) {
+	var row *RowData
+	switch {
+	case len(s.RowValues) > 0:
+		row = s.RowValues[0]
+	case len(s.RowIdentifies) > 0:
+		row = s.RowIdentifies[0]
+	default:
+		return nil, nil
+	}
+
+	signature := avroSignature(row)
+	old := e.tables[s.Table]
+	if old != nil && old.signature == signature {
+		return old, nil
+	}
+
+	unsigned, err := e.unsignedColumns(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	table := newAvroTable(s.Table, row, unsigned)
+	table.signature = signature
+	table.version = 1
+	if old != nil {
+		if bytes.Equal(old.schema, table.schema) {
+			old.signature = signature
+			return old, nil
+		}
+		table.version = old.version + 1
+		if err = old.file.close(); err != nil {
+			return nil, err
+		}
+		delete(e.tables, s.Table)
+	}
+
+	w, err := e.create(s.Table, table.version)
+	if err != nil {
+		return nil, newError(err).msgf("table %v create avro file fail.", s.Table.String())
+	}
+	if table.file, err = newAvroFile(w, table.schema); err != nil {
+		return nil, err
+	}
+	e.tables[s.Table] = table
+
+	if e.onSchemaChange != nil {
+		err = e.onSchemaChange(&AvroSchemaChange{
+			Table:    s.Table,
+			Version:  table.version,
+			Schema:   table.schema,
+			Position: t.NowPosition,
+			GTID:     t.GTID,
+		})
+	}
+	return table, err
+}
+
+//unsignedColumns 通过tableMapper获取无符号的列
+func (e *AvroEncoder) unsignedColumns(name MysqlTableName) (map[string]bool, error) {
+	unsigned := make(map[string]bool)
+	if e.tableMapper == nil {
+		return unsigned, nil
+	}
+	table, err := e.tableMapper.MysqlTable(name)
+	if err != nil {
+		return nil, newError(err).msgf("table %v MysqlTable fail.", name.String())
+	}
+	for _, c := range table.Columns() {
+		if c.IsUnSignedInt() {
+			unsigned[c.Field()] = true
+		}
+	}
+	return unsigned, nil
+}
+
+//avroSignature 行数据中决定schema的列信息
+func avroSignature(row *RowData) string {
+	var b strings.Builder
+	for _, c := range row.Columns {
+		fmt.Fprintf(&b, "%q:%d:%v,", c.Filed, c.Type, c.Charset == charsetBinary)
+	}
+	return b.String()
+}
+
+//avroColumn 列在avro中的名字和类型
+type avroColumn struct {
+	name string
+	typ  string
+}
+
+type avroTable struct {
+	signature string
+	version   int
+	schema    []byte
+	columns   []avroColumn
+	file      *avroFile
+}
+
+func newAvroTable(name MysqlTableName, row *RowData, unsigned map[string]bool) *avroTable {
+	t := &avroTable{
+		columns: make([]avroColumn, 0, len(row.Columns)),
+	}
+
+	names := make(map[string]bool)
+	fields := make([]interface{}, 0, len(row.Columns))
+	for i, c := range row.Columns {
+		column := avroColumn{
+			name: avroName(c.Filed),
+			typ:  avroColumnType(c, unsigned[c.Filed]),
+		}
+		if names[column.name] {
+			column.name += "_" + strconv.Itoa(i)
+		}
+		names[column.name] = true
+		t.columns = append(t.columns, column)
+
+		fields = append(fields, jsonObject{
+			{"name", column.name},
+			{"type", []string{avroTypeNull, column.typ}},
+			{"default", nil},
+			{"mysqlField", c.Filed},
+			{"mysqlType", c.Type.String()},
+		})
+	}
+
+	schema := jsonObject{
+		{"type", "record"},
+		{"name", avroName(name.TableName)},
+		{"namespace", "gobinlog." + avroName(name.DbName)},
+		{"fields", []interface{}{
+			jsonObject{
+				{"name", "op"},
+				{"type", jsonObject{
+					{"type", "enum"},
+					{"name", "Op"},
+					{"symbols", []string{"INSERT", "UPDATE", "DELETE"}},
+				}},
+			},
+			jsonObject{
+				{"name", "position"},
+				{"type", jsonObject{
+					{"type", "record"},
+					{"name", "Position"},
+					{"fields", []interface{}{
+						jsonObject{{"name", "filename"}, {"type", avroTypeString}},
+						jsonObject{{"name", "offset"}, {"type", avroTypeLong}},
+					}},
+				}},
+			},
+			jsonObject{{"name", "gtid"}, {"type", []string{avroTypeNull, avroTypeString}}},
+			jsonObject{
+				{"name", "timestamp"},
+				{"type", jsonObject{{"type", avroTypeLong}, {"logicalType", "timestamp-millis"}}},
+			},
+			jsonObject{
+				{"name", "before"},
+				{"type", []interface{}{
+					avroTypeNull,
+					jsonObject{{"type", "record"}, {"name", "Row"}, {"fields", fields}},
+				}},
+			},
+			jsonObject{{"name", "after"}, {"type", []string{avroTypeNull, "Row"}}},
+		}},
+	}
+	t.schema, _ = json.Marshal(schema)
+	return t
+}
+
+//avroColumnType 列在avro中的类型，无符号的bigint超过long的范围使用string
+func avroColumnType(c *ColumnData, unsigned bool) string {
+	switch {
+	case c.Type == columnTypeLongLong:
+		if unsigned {
+			return avroTypeString
+		}
+		return avroTypeLong
+	case c.Type == columnTypeLong:
+		return avroTypeLong
+	case c.Type.IsInteger(), c.Type == columnTypeYear:
+		return avroTypeInt
+	case c.Type == columnTypeFloat:
+		return avroTypeFloat
+	case c.Type == columnTypeDouble:
+		return avroTypeDouble
+	case c.Type.IsBit(), c.Type.IsGeometry():
+		return avroTypeBytes
+	case c.Type.IsBlob(), c.Type.IsString():
+		if c.Charset == charsetBinary {
+			return avroTypeBytes
+		}
+	}
+	return avroTypeString
+}
+
+//avroName 将mysql的名字转为avro的名字，只能包含字母，数字以及下划线，不能以数字开头
+func avroName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+//append 写入一条记录
+func (t *avroTable) append(op int, tran *Transaction, s *StreamEvent, before, after *RowData) error {
+	b := appendAvroLong(nil, int64(op))
+	b = appendAvroString(b, []byte(tran.NowPosition.Filename))
+	b = appendAvroLong(b, tran.NowPosition.Offset)
+	if tran.GTID == "" {
+		b = appendAvroLong(b, 0)
+	} else {
+		b = appendAvroLong(b, 1)
+		b = appendAvroString(b, []byte(tran.GTID))
+	}
+	b = appendAvroLong(b, s.Timestamp*1000)
+
+	var err error
+	for _, row := range []*RowData{before, after} {
+		if row == nil {
+			b = appendAvroLong(b, 0)
+			continue
+		}
+		b = appendAvroLong(b, 1)
+		if b, err = t.appendRow(b, row); err != nil {
+			return err
+		}
+	}
+
+	t.file.block.Write(b)
+	t.file.count++
+	return nil
+}
+
+//appendRow 写入行数据，NULL以及没有变化(IsEmpty)的列为null
+func (t *avroTable) appendRow(b []byte, row *RowData) ([]byte, error) {
+	if len(row.Columns) != len(t.columns) {
+		return nil, fmt.Errorf("row has %v columns, schema has %v", len(row.Columns), len(t.columns))
+	}
+	for i, c := range row.Columns {
+		if c.Data == nil {
+			b = appendAvroLong(b, 0)
+			continue
+		}
+		b = appendAvroLong(b, 1)
+
+		switch t.columns[i].typ {
+		case avroTypeInt, avroTypeLong:
+			v, err := strconv.ParseInt(string(c.Data), 10, 64)
+			if err != nil {
+				return nil, newError(err).msgf("column %v parse int fail.", c.Filed)
+			}
+			b = appendAvroLong(b, v)
+		case avroTypeFloat:
+			v, err := strconv.ParseFloat(string(c.Data), 32)
+			if err != nil {
+				return nil, newError(err).msgf("column %v parse float fail.", c.Filed)
+			}
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(v)))
+		case avroTypeDouble:
+			v, err := strconv.ParseFloat(string(c.Data), 64)
+			if err != nil {
+				return nil, newError(err).msgf("column %v parse double fail.", c.Filed)
+			}
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		default:
+			b = appendAvroString(b, c.Data)
+		}
+	}
+	return b, nil
+}
+
+//avroFile avro Object Container File，不压缩
+type avroFile struct {
+	w     io.Writer
+	sync  [16]byte
+	block bytes.Buffer
+	count int
+}
+
+func newAvroFile(w io.Writer, schema []byte) (*avroFile, error) {
+	f := &avroFile{w: w}
+	if _, err := rand.Read(f.sync[:]); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, avroMagic...)
+	header = appendAvroLong(header, 2)
+	header = appendAvroString(header, []byte("avro.schema"))
+	header = appendAvroString(header, schema)
+	header = appendAvroString(header, []byte("avro.codec"))
+	header = appendAvroString(header, []byte("null"))
+	header = appendAvroLong(header, 0)
+	header = append(header, f.sync[:]...)
+	if _, err := w.Write(header); err != nil {
+		return nil, newError(err).msgf("write avro header fail.")
+	}
+	return f, nil
+}
+
+//flush 写入一个数据块
+func (f *avroFile) flush() error {
+	if f.count == 0 {
+		return nil
+	}
+	b := appendAvroLong(nil, int64(f.count))
+	b = appendAvroLong(b, int64(f.block.Len()))
+	b = append(b, f.block.Bytes()...)
+	b = append(b, f.sync[:]...)
+	if _, err := f.w.Write(b); err != nil {
+		return newError(err).msgf("write avro block fail.")
+	}
+	f.block.Reset()
+	f.count = 0
+	return nil
+}
+
+func (f *avroFile) close() error {
+	err := f.flush()
+	if c, ok := f.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+//appendAvroLong 写入zigzag编码的long，int同样使用该编码
+func appendAvroLong(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(v<<1)^uint64(v>>63))
+	return append(b, buf[:n]...)
+}
+
+//appendAvroString 写入string或bytes
+func appendAvroString(b []byte, v []byte) []byte {
+	b = appendAvroLong(b, int64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,259 @@
+package gobinlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+type testAvroWriter struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *testAvroWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+type testAvroMapper struct {
+	table *mysqlTableInfo
+}
+
+func (m *testAvroMapper) MysqlTable(name MysqlTableName) (MysqlTable, error) {
+	return m.table, nil
+}
+
+//testAvroReader 读取avro Object Container File，只支持AvroEncoder生成的schema
+type testAvroReader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (r *testAvroReader) long() int64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.t.Fatalf("read long fail. n: %v", n)
+	}
+	r.data = r.data[n:]
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *testAvroReader) bytes() []byte {
+	n := r.long()
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *testAvroReader) fixed(n int) []byte {
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+//file 读取文件头以及所有的记录
+func (r *testAvroReader) file() (schema map[string]interface{}, records [][]interface{}) {
+	if !bytes.Equal(r.fixed(4), avroMagic) {
+		r.t.Fatalf("invalid avro magic")
+	}
+	meta := make(map[string]string)
+	for n := r.long(); n != 0; n = r.long() {
+		for i := int64(0); i < n; i++ {
+			k := string(r.bytes())
+			meta[k] = string(r.bytes())
+		}
+	}
+	if meta["avro.codec"] != "null" {
+		r.t.Fatalf("avro.codec want null out: %v", meta["avro.codec"])
+	}
+	if err := json.Unmarshal([]byte(meta["avro.schema"]), &schema); err != nil {
+		r.t.Fatalf("avro.schema Unmarshal fail. err: %v", err)
+	}
+	sync := r.fixed(16)
+
+	var columns []string
+	fields := schema["fields"].([]interface{})
+	row := fields[4].(map[string]interface{})["type"].([]interface{})[1].(map[string]interface{})
+	for _, f := range row["fields"].([]interface{}) {
+		columns = append(columns, f.(map[string]interface{})["type"].([]interface{})[1].(string))
+	}
+
+	for len(r.data) > 0 {
+		cnt := r.long()
+		size := r.long()
+		end := len(r.data) - int(size)
+		for i := int64(0); i < cnt; i++ {
+			records = append(records, r.record(columns))
+		}
+		if len(r.data) != end || !bytes.Equal(r.fixed(16), sync) {
+			r.t.Fatalf("invalid avro block")
+		}
+	}
+	return
+}
+
+func (r *testAvroReader) record(columns []string) []interface{} {
+	record := []interface{}{r.long(), string(r.bytes()), r.long()}
+	if r.long() == 1 {
+		record = append(record, string(r.bytes()))
+	} else {
+		record = append(record, nil)
+	}
+	record = append(record, r.long())
+	for i := 0; i < 2; i++ {
+		if r.long() == 0 {
+			record = append(record, nil)
+			continue
+		}
+		var row []interface{}
+		for _, typ := range columns {
+			if r.long() == 0 {
+				row = append(row, nil)
+				continue
+			}
+			switch typ {
+			case avroTypeInt, avroTypeLong:
+				row = append(row, r.long())
+			case avroTypeFloat:
+				row = append(row, math.Float32frombits(binary.LittleEndian.Uint32(r.fixed(4))))
+			case avroTypeDouble:
+				row = append(row, math.Float64frombits(binary.LittleEndian.Uint64(r.fixed(8))))
+			case avroTypeString:
+				row = append(row, string(r.bytes()))
+			case avroTypeBytes:
+				row = append(row, r.bytes())
+			}
+		}
+		record = append(record, row)
+	}
+	return record
+}
+
+func TestAvroEncoder_EncodeTransaction(t *testing.T) {
+	table := MysqlTableName{DbName: "db", TableName: "t-1"}
+	mapper := &testAvroMapper{table: &mysqlTableInfo{
+		name: table,
+		columns: []MysqlColumn{
+			&mysqlColumnAttribute{field: "id", typ: "int(11)", key: "PRI"},
+			&mysqlColumnAttribute{field: "big", typ: "bigint(20) unsigned"},
+		},
+	}}
+	row := func(id, big string, name []byte, raw []byte, f, d string) *RowData {
+		return &RowData{Columns: []*ColumnData{
+			{Filed: "id", Type: columnTypeLong, Data: []byte(id)},
+			{Filed: "big", Type: columnTypeLongLong, Data: []byte(big)},
+			{Filed: "t_中文", Type: columnTypeVarchar, Charset: "utf8mb4", Data: name},
+			{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: raw},
+			{Filed: "f", Type: columnTypeFloat, Data: []byte(f)},
+			{Filed: "d", Type: columnTypeDouble, Data: []byte(d)},
+		}}
+	}
+	tran := &Transaction{
+		NowPosition: Position{Filename: "binlog.000001", Offset: 4},
+		GTID:        "uuid:1",
+		Events: []*StreamEvent{
+			{
+				Type:      StatementInsert,
+				Table:     table,
+				Timestamp: 10,
+				RowValues: []*RowData{row("1", "18446744073709551615", []byte("a"), []byte{0xff}, "0.5", "1.25")},
+			},
+			{Type: StatementAlter, Query: replication.Query{SQL: "alter table `t-1` add column c int"}},
+			{
+				Type:          StatementUpdate,
+				Table:         table,
+				Timestamp:     11,
+				RowIdentifies: []*RowData{row("1", "18446744073709551615", []byte("a"), []byte{0xff}, "0.5", "1.25")},
+				RowValues:     []*RowData{row("1", "0", nil, []byte{}, "0.5", "-2")},
+			},
+			{
+				Type:          StatementDelete,
+				Table:         table,
+				Timestamp:     12,
+				RowIdentifies: []*RowData{row("1", "0", nil, []byte{}, "0.5", "-2")},
+			},
+		},
+	}
+
+	var writers []*testAvroWriter
+	e := NewAvroEncoder(mapper, func(name MysqlTableName, version int) (io.Writer, error) {
+		if name != table || version != len(writers)+1 {
+			t.Fatalf("create want %v %v out: %v %v", table, len(writers)+1, name, version)
+		}
+		writers = append(writers, &testAvroWriter{})
+		return writers[len(writers)-1], nil
+	})
+	e.SetBlockSize(2)
+	var changes []*AvroSchemaChange
+	e.SetSchemaChangeFunc(func(c *AvroSchemaChange) error {
+		changes = append(changes, c)
+		return nil
+	})
+	if err := e.EncodeTransaction(tran); err != nil {
+		t.Fatalf("EncodeTransaction fail. err: %v", err)
+	}
+
+	//新增列后生成新的schema版本
+	tran2 := &Transaction{
+		NowPosition: Position{Filename: "binlog.000001", Offset: 400},
+		Events: []*StreamEvent{
+			{
+				Type:      StatementInsert,
+				Table:     table,
+				Timestamp: 13,
+				RowValues: []*RowData{{Columns: append(row("2", "1", []byte("b"), nil, "1", "1").Columns,
+					&ColumnData{Filed: "c", Type: columnTypeLong, Data: []byte("3")})}},
+			},
+		},
+	}
+	if err := e.EncodeTransaction(tran2); err != nil {
+		t.Fatalf("EncodeTransaction fail. err: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+
+	if len(writers) != 2 || !writers[0].closed || !writers[1].closed {
+		t.Fatalf("want 2 closed writers out: %v", len(writers))
+	}
+	if len(changes) != 2 || changes[0].Version != 1 || changes[1].Version != 2 ||
+		changes[1].Position != tran2.NowPosition || changes[0].GTID != "uuid:1" {
+		t.Fatalf("schema changes out: %+v", changes)
+	}
+
+	schema, records := (&testAvroReader{t: t, data: writers[0].Bytes()}).file()
+	if schema["name"] != "t_1" || schema["namespace"] != "gobinlog.db" {
+		t.Fatalf("schema name out: %v %v", schema["name"], schema["namespace"])
+	}
+	var changed map[string]interface{}
+	if err := json.Unmarshal(changes[0].Schema, &changed); err != nil || !reflect.DeepEqual(changed, schema) {
+		t.Fatalf("schema want: %v out: %s err: %v", schema, changes[0].Schema, err)
+	}
+	before := []interface{}{int64(1), "18446744073709551615", "a", []byte{0xff}, float32(0.5), 1.25}
+	after := []interface{}{int64(1), "0", nil, []byte{}, float32(0.5), float64(-2)}
+	want := [][]interface{}{
+		{int64(0), "binlog.000001", int64(4), "uuid:1", int64(10000), nil, before},
+		{int64(1), "binlog.000001", int64(4), "uuid:1", int64(11000), before, after},
+		{int64(2), "binlog.000001", int64(4), "uuid:1", int64(12000), after, nil},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("want != out\nwant: %v\nout:  %v", want, records)
+	}
+
+	schema, records = (&testAvroReader{t: t, data: writers[1].Bytes()}).file()
+	fields := schema["fields"].([]interface{})
+	row1 := fields[4].(map[string]interface{})["type"].([]interface{})[1].(map[string]interface{})
+	if n := len(row1["fields"].([]interface{})); n != 7 || len(records) != 1 {
+		t.Fatalf("version 2 want 7 fields and 1 record out: %v %v", n, len(records))
+	}
+	if name := row1["fields"].([]interface{})[2].(map[string]interface{})["name"]; name != "t_______" {
+		t.Fatalf("avro name want t_______ out: %v", name)
+	}
+}
@@ -0,0 +1,350 @@
+package gobinlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//canal中type的取值，ddl语句对应isDdl为true
+var canalTypes = map[StatementType]string{
+	StatementInsert:   "INSERT",
+	StatementUpdate:   "UPDATE",
+	StatementDelete:   "DELETE",
+	StatementCreate:   "CREATE",
+	StatementAlter:    "ALTER",
+	StatementDrop:     "ERASE",
+	StatementTruncate: "TRUNCATE",
+	StatementRename:   "RENAME",
+	StatementSet:      "QUERY",
+}
+
+//canalSQLTypes mysql列类型对应的java.sql.Types，与canal相同
+var canalSQLTypes = map[string]int{
+	"bit":        -7,
+	"tinyint":    -6,
+	"smallint":   5,
+	"mediumint":  4,
+	"int":        4,
+	"integer":    4,
+	"bigint":     -5,
+	"float":      7,
+	"double":     8,
+	"decimal":    3,
+	"date":       91,
+	"time":       92,
+	"datetime":   93,
+	"timestamp":  93,
+	"year":       12,
+	"char":       1,
+	"varchar":    12,
+	"binary":     -2,
+	"varbinary":  -3,
+	"tinytext":   2005,
+	"text":       2005,
+	"mediumtext": 2005,
+	"longtext":   2005,
+	"tinyblob":   2004,
+	"blob":       2004,
+	"mediumblob": 2004,
+	"longblob":   2004,
+	"json":       12,
+	"enum":       4,
+	"set":        -7,
+	"geometry":   -2,
+}
+
+//canalUnsignedSQLTypes 无符号整形对应的java.sql.Types
+var canalUnsignedSQLTypes = map[string]int{
+	"tinyint":   5,
+	"smallint":  4,
+	"mediumint": 4,
+	"int":       -5,
+	"integer":   -5,
+	"bigint":    3,
+}
+
+//CanalEncoder 将语句编码为canal的flat message，每个语句对应一条消息，
+//列的值都为字符串，bit列为十进制数字，其他二进制列按照ISO-8859-1转换为字符串，与canal相同
+type CanalEncoder struct {
+	tableMapper MysqlTableMapper
+	id          int64
+	now         func() time.Time
+}
+
+//NewCanalEncoder 创建CanalEncoder，tableMapper用于获取列的定义类型以及是否是无符号，可以为nil，
+//为nil或者列没有实现MysqlTypeColumn时mysqlType通过binlog中的列类型推断
+func NewCanalEncoder(tableMapper MysqlTableMapper) *CanalEncoder {
+	return &CanalEncoder{
+		tableMapper: tableMapper,
+		now:         time.Now,
+	}
+}
+
+//EncodeTransaction 编码事务中的所有语句，同一个事务中的消息id相同，
+//没有行数据的dml语句会被忽略
+func (e *CanalEncoder) EncodeTransaction(t *Transaction) ([][]byte, error) {
+	e.id++
+	var messages [][]byte
+	err := t.RangeEvents(func(s *StreamEvent) error {
+		m, err := e.message(s)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			messages = append(messages, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+//EncodeStreamEvent 编码单个语句，不需要输出的语句返回nil
+func (e *CanalEncoder) EncodeStreamEvent(s *StreamEvent) ([]byte, error) {
+	e.id++
+	return e.message(s)
+}
+
+func (e *CanalEncoder) message(s *StreamEvent) ([]byte, error) {
+	typ, ok := canalTypes[s.Type]
+	if !ok {
+		return nil, nil
+	}
+	ts := e.now().UnixNano() / int64(time.Millisecond)
+	if s.Query.SQL != "" {
+		if !s.Type.IsDDL() && s.Type != StatementSet {
+			return nil, nil
+		}
+		return json.Marshal(jsonObject{
+			{"data", nil},
+			{"database", s.Query.Database},
+			{"es", s.Timestamp * 1000},
+			{"id", e.id},
+			{"isDdl", s.Type.IsDDL()},
+			{"mysqlType", nil},
+			{"old", nil},
+			{"pkNames", nil},
+			{"sql", s.Query.SQL},
+			{"sqlType", nil},
+			{"table", ""},
+			{"ts", ts},
+			{"type", typ},
+		})
+	}
+
+	rows := s.RowValues
+	if s.Type == StatementDelete {
+		rows = s.RowIdentifies
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	types, err := e.mysqlTypes(s.Table, rows[0])
+	if err != nil {
+		return nil, newError(err).msgf("table %v canal mysqlType fail.", s.Table.String())
+	}
+
+	var pkNames []string
+	mysqlType := make(jsonObject, 0, len(rows[0].Columns))
+	sqlType := make(jsonObject, 0, len(rows[0].Columns))
+	for _, c := range rows[0].Columns {
+		if c.IsPrimaryKey {
+			pkNames = append(pkNames, c.Filed)
+		}
+		mysqlType = append(mysqlType, jsonField{c.Filed, types[c.Filed]})
+		sqlType = append(sqlType, jsonField{c.Filed, canalSQLType(types[c.Filed])})
+	}
+
+	data := make([]jsonObject, 0, len(rows))
+	for _, r := range rows {
+		data = append(data, canalRow(r, nil))
+	}
+	var old []jsonObject
+	if s.Type == StatementUpdate {
+		for i, r := range s.RowIdentifies {
+			var after *RowData
+			if i < len(s.RowValues) {
+				after = s.RowValues[i]
+			}
+			old = append(old, canalRow(r, after))
+		}
+	}
+
+	return json.Marshal(jsonObject{
+		{"data", data},
+		{"database", s.Table.DbName},
+		{"es", s.Timestamp * 1000},
+		{"id", e.id},
+		{"isDdl", false},
+		{"mysqlType", mysqlType},
+		{"old", old},
+		{"pkNames", pkNames},
+		{"sql", ""},
+		{"sqlType", sqlType},
+		{"table", s.Table.TableName},
+		{"ts", ts},
+		{"type", typ},
+	})
+}
+
+//mysqlTypes 列名对应的列定义类型，优先使用tableMapper中的类型
+func (e *CanalEncoder) mysqlTypes(name MysqlTableName, row *RowData) (map[string]string, error) {
+	types := make(map[string]string, len(row.Columns))
+	unsigned := make(map[string]bool)
+	if e.tableMapper != nil {
+		table, err := e.tableMapper.MysqlTable(name)
+		if err != nil {
+			return nil, newError(err).msgf("table %v MysqlTable fail.", name.String())
+		}
+		for _, c := range table.Columns() {
+			if tc, ok := c.(MysqlTypeColumn); ok && tc.Type() != "" {
+				types[c.Field()] = tc.Type()
+			}
+			unsigned[c.Field()] = c.IsUnSignedInt()
+		}
+	}
+	for _, c := range row.Columns {
+		if _, ok := types[c.Filed]; !ok {
+			types[c.Filed] = c.mysqlType(unsigned[c.Filed])
+		}
+	}
+	return types, nil
+}
+
+//canalRow 行数据中所有列的字符串值，没有记录(IsEmpty)的列不输出，
+//changed不为nil时只输出与changed中不同的列
+func canalRow(r *RowData, changed *RowData) jsonObject {
+	o := make(jsonObject, 0, len(r.Columns))
+	for i, c := range r.Columns {
+		if c.IsEmpty {
+			continue
+		}
+		if changed != nil && i < len(changed.Columns) && !c.isChanged(changed.Columns[i]) {
+			continue
+		}
+		o = append(o, jsonField{c.Filed, canalValue(c)})
+	}
+	return o
+}
+
+//canalValue 列的字符串值，NULL为nil
+func canalValue(c *ColumnData) interface{} {
+	switch {
+	case c.Data == nil:
+		return nil
+	case c.Type.IsBit():
+		return strconv.FormatUint(c.bitValue(), 10)
+	case c.isBinary():
+		r := make([]rune, len(c.Data))
+		for i, b := range c.Data {
+			r[i] = rune(b)
+		}
+		return string(r)
+	}
+	return string(c.Data)
+}
+
+//canalSQLType 列定义类型对应的java.sql.Types，未知的类型为varchar
+func canalSQLType(mysqlType string) int {
+	typ := strings.ToLower(mysqlType)
+	base := typ
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	if strings.Contains(typ, "unsigned") {
+		if t, ok := canalUnsignedSQLTypes[base]; ok {
+			return t
+		}
+	}
+	if t, ok := canalSQLTypes[base]; ok {
+		return t
+	}
+	return canalSQLTypes["varchar"]
+}
+
+//mysqlType 通过binlog中的列类型推断列定义类型，不包含长度以及精度
+func (c *ColumnData) mysqlType(unsigned bool) string {
+	binary := c.Charset == charsetBinary
+	var typ string
+	switch c.Type {
+	case columnTypeTiny:
+		typ = "tinyint"
+	case columnTypeShort:
+		typ = "smallint"
+	case columnTypeInt24:
+		typ = "mediumint"
+	case columnTypeLong:
+		typ = "int"
+	case columnTypeLongLong:
+		typ = "bigint"
+	case columnTypeFloat:
+		return "float"
+	case columnTypeDouble:
+		return "double"
+	case columnTypeDecimal, columnTypeNewDecimal:
+		return "decimal"
+	case columnTypeDate, columnTypeNewDate:
+		return "date"
+	case columnTypeTime, columnTypeTime2:
+		return "time"
+	case columnTypeDateTime, columnTypeDateTime2:
+		return "datetime"
+	case columnTypeTimestamp, columnTypeTimestamp2:
+		return "timestamp"
+	case columnTypeYear:
+		return "year"
+	case columnTypeVarchar, columnTypeVarString:
+		if binary {
+			return "varbinary"
+		}
+		return "varchar"
+	case columnTypeString:
+		if binary {
+			return "binary"
+		}
+		return "char"
+	case columnTypeTinyBlob, columnTypeMediumBlob, columnTypeLongBlob, columnTypeBlob:
+		if binary {
+			return "blob"
+		}
+		return "text"
+	case columnTypeJSON:
+		return "json"
+	case columnTypeEnum:
+		return "enum"
+	case columnTypeSet:
+		return "set"
+	case columnTypeBit:
+		return "bit"
+	case columnTypeGeometry:
+		return "geometry"
+	default:
+		return "varchar"
+	}
+	if unsigned {
+		typ += " unsigned"
+	}
+	return typ
+}
+
+//bitValue bit列大端存储的值
+func (c *ColumnData) bitValue() uint64 {
+	var v uint64
+	for _, b := range c.Data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+//isChanged 与另一个镜像中的同一列相比是否有变化，没有记录(IsEmpty)的列认为没有变化
+func (c *ColumnData) isChanged(other *ColumnData) bool {
+	if c.IsEmpty || other.IsEmpty {
+		return false
+	}
+	return (c.Data == nil) != (other.Data == nil) || !bytes.Equal(c.Data, other.Data)
+}
@@ -0,0 +1,104 @@
+package gobinlog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestCanalEncoder_EncodeTransaction(t *testing.T) {
+	table := MysqlTableName{DbName: "db", TableName: "t"}
+	mapper := &testAvroMapper{table: &mysqlTableInfo{
+		name: table,
+		columns: []MysqlColumn{
+			&mysqlColumnAttribute{field: "id", typ: "int(11)", key: "PRI"},
+			&mysqlColumnAttribute{field: "name"},
+			&mysqlColumnAttribute{field: "amount", typ: "bigint(20) unsigned"},
+			&mysqlColumnAttribute{field: "raw"},
+			&mysqlColumnAttribute{field: "flag"},
+		},
+	}}
+	row := func(name []byte, empty bool) *RowData {
+		return &RowData{Columns: []*ColumnData{
+			{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, IsEmpty: empty, Data: []byte("1")},
+			{Filed: "name", Type: columnTypeVarchar, Data: name},
+			{Filed: "amount", Type: columnTypeLongLong, IsEmpty: empty, Data: []byte("18446744073709551615")},
+			{Filed: "raw", Type: columnTypeBlob, Charset: "binary", IsEmpty: empty, Data: []byte{0xff}},
+			{Filed: "flag", Type: columnTypeBit, IsEmpty: empty, Data: []byte{0x01, 0x00}},
+		}}
+	}
+	tran := &Transaction{
+		Events: []*StreamEvent{
+			{Type: StatementAlter, Timestamp: 9,
+				Query: replication.Query{Database: "db", SQL: "alter table t add column c int"}},
+			{Type: StatementInsert, Table: table, Timestamp: 10, RowValues: []*RowData{row([]byte("a"), false)}},
+			{Type: StatementUpdate, Table: table, Timestamp: 11,
+				RowIdentifies: []*RowData{row([]byte("a"), false)}, RowValues: []*RowData{row(nil, true)}},
+			{Type: StatementDelete, Table: table, Timestamp: 12, RowIdentifies: []*RowData{row(nil, false)}},
+			{Type: StatementInsert, Timestamp: 12, Query: replication.Query{SQL: "insert into t values(1)"}},
+		},
+	}
+
+	types := func(old string) string {
+		return `"mysqlType":{"id":"int(11)","name":"varchar","amount":"bigint(20) unsigned","raw":"blob",` +
+			`"flag":"bit"},"old":` + old + `,"pkNames":["id"],"sql":"",` +
+			`"sqlType":{"id":4,"name":12,"amount":3,"raw":2004,"flag":-7},`
+	}
+	want := []string{
+		`{"data":null,"database":"db","es":9000,"id":1,"isDdl":true,"mysqlType":null,"old":null,"pkNames":null,` +
+			`"sql":"alter table t add column c int","sqlType":null,"table":"","ts":1000,"type":"ALTER"}`,
+		`{"data":[{"id":"1","name":"a","amount":"18446744073709551615","raw":"ÿ","flag":"256"}],` +
+			`"database":"db","es":10000,"id":1,"isDdl":false,` + types("null") +
+			`"table":"t","ts":1000,"type":"INSERT"}`,
+		`{"data":[{"name":null}],"database":"db","es":11000,"id":1,"isDdl":false,` +
+			types(`[{"name":"a"}]`) + `"table":"t","ts":1000,"type":"UPDATE"}`,
+		`{"data":[{"id":"1","name":null,"amount":"18446744073709551615","raw":"ÿ","flag":"256"}],` +
+			`"database":"db","es":12000,"id":1,"isDdl":false,` + types("null") +
+			`"table":"t","ts":1000,"type":"DELETE"}`,
+	}
+
+	e := NewCanalEncoder(mapper)
+	e.now = func() time.Time { return time.Unix(1, 0) }
+	out, err := e.EncodeTransaction(tran)
+	if err != nil {
+		t.Fatalf("EncodeTransaction fail. err: %v", err)
+	}
+	if len(out) != len(want) {
+		t.Fatalf("len want: %v out: %v", len(want), len(out))
+	}
+	for i, v := range want {
+		if string(out[i]) != v {
+			t.Fatalf("%v want != out\nwant: %v\nout:  %s", i, v, out[i])
+		}
+	}
+
+	out2, err := e.EncodeStreamEvent(tran.Events[0])
+	if err != nil {
+		t.Fatalf("EncodeStreamEvent fail. err: %v", err)
+	}
+	if want := strings.Replace(want[0], `"id":1`, `"id":2`, 1); string(out2) != want {
+		t.Fatalf("want != out\nwant: %v\nout:  %s", want, out2)
+	}
+}
+
+func TestCanalSQLType(t *testing.T) {
+	testCases := []struct {
+		mysqlType string
+		want      int
+	}{
+		{"int(11)", 4},
+		{"INT(10) UNSIGNED", -5},
+		{"tinyint(1) unsigned", 5},
+		{"decimal(10,2)", 3},
+		{"varbinary(16)", -3},
+		{"datetime(6)", 93},
+		{"unknown", 12},
+	}
+	for _, v := range testCases {
+		if out := canalSQLType(v.mysqlType); out != v.want {
+			t.Fatalf("%v want != out want: %v out: %v", v.mysqlType, v.want, out)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package gobinlog
+
+import (
+	"strings"
+
+	"github.com/Breeze0806/gobinlog/replication"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+//mysql的字符集
+const (
+	charsetBinary  = "binary"
+	charsetUTF8MB4 = "utf8mb4"
+)
+
+//MysqlCollationColumn 用于提供列排序规则的接口，MysqlColumn可以选择实现该接口，
+//未实现或者返回空时使用TableMap中的排序规则(binlog_row_metadata=FULL或MINIMAL)
+type MysqlCollationColumn interface {
+	Collation() string //排序规则名，如latin1_swedish_ci，也可以是字符集名，如gbk
+}
+
+//charsetEncodings 常用字符集对应的编码，utf8，utf8mb4和ascii不需要转换
+var charsetEncodings = map[string]encoding.Encoding{
+	"latin1":   charmap.Windows1252, //mysql的latin1实际上是cp1252
+	"latin2":   charmap.ISO8859_2,
+	"latin5":   charmap.ISO8859_9,
+	"latin7":   charmap.ISO8859_13,
+	"greek":    charmap.ISO8859_7,
+	"hebrew":   charmap.ISO8859_8,
+	"koi8r":    charmap.KOI8R,
+	"koi8u":    charmap.KOI8U,
+	"cp850":    charmap.CodePage850,
+	"cp852":    charmap.CodePage852,
+	"cp866":    charmap.CodePage866,
+	"cp1250":   charmap.Windows1250,
+	"cp1251":   charmap.Windows1251,
+	"cp1256":   charmap.Windows1256,
+	"cp1257":   charmap.Windows1257,
+	"tis620":   charmap.Windows874,
+	"macroman": charmap.Macintosh,
+	"gbk":      simplifiedchinese.GBK,
+	"gb2312":   simplifiedchinese.GBK, //gbk是gb2312的超集
+	"gb18030":  simplifiedchinese.GB18030,
+	"big5":     traditionalchinese.Big5,
+	"sjis":     japanese.ShiftJIS,
+	"cp932":    japanese.ShiftJIS,
+	"ujis":     japanese.EUCJP,
+	"eucjpms":  japanese.EUCJP,
+	"euckr":    korean.EUCKR,
+	"ucs2":     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"utf16":    unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"utf16le":  unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf32":    utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM),
+}
+
+//collationCharsets 排序规则编号对应的字符集，只包含常用字符集
+var collationCharsets = map[uint64]string{
+	1: "big5", 84: "big5",
+	2: "latin2", 9: "latin2", 21: "latin2", 27: "latin2", 77: "latin2",
+	4: "cp850", 80: "cp850",
+	5: "latin1", 8: "latin1", 15: "latin1", 31: "latin1", 47: "latin1", 48: "latin1", 49: "latin1", 94: "latin1",
+	7: "koi8r", 74: "koi8r",
+	11: "ascii", 65: "ascii",
+	12: "ujis", 91: "ujis",
+	13: "sjis", 88: "sjis",
+	14: "cp1251", 23: "cp1251", 50: "cp1251", 51: "cp1251", 52: "cp1251",
+	16: "hebrew", 71: "hebrew",
+	18: "tis620", 89: "tis620",
+	19: "euckr", 85: "euckr",
+	20: "latin7", 41: "latin7", 42: "latin7", 79: "latin7",
+	22: "koi8u", 75: "koi8u",
+	24: "gb2312", 86: "gb2312",
+	25: "greek", 70: "greek",
+	26: "cp1250", 34: "cp1250", 44: "cp1250", 66: "cp1250", 99: "cp1250",
+	28: "gbk", 87: "gbk",
+	29: "cp1257", 58: "cp1257", 59: "cp1257",
+	30: "latin5", 78: "latin5",
+	33: "utf8", 76: "utf8", 83: "utf8", 223: "utf8",
+	35: "ucs2", 90: "ucs2", 159: "ucs2",
+	36: "cp866", 68: "cp866",
+	39: "macroman", 53: "macroman",
+	40: "cp852", 81: "cp852",
+	45: "utf8mb4", 46: "utf8mb4",
+	54: "utf16", 55: "utf16",
+	56: "utf16le", 62: "utf16le",
+	57: "cp1256", 67: "cp1256",
+	60: "utf32", 61: "utf32",
+	63: charsetBinary,
+	95: "cp932", 96: "cp932",
+	97: "eucjpms", 98: "eucjpms",
+	248: "gb18030", 249: "gb18030", 250: "gb18030",
+}
+
+//collationRanges 连续编号的排序规则对应的字符集
+var collationRanges = []struct {
+	first, last uint64
+	charset     string
+}{
+	{101, 124, "utf16"},
+	{128, 151, "ucs2"},
+	{160, 183, "utf32"},
+	{192, 215, "utf8"},
+	{224, 247, "utf8mb4"},
+	{255, 323, "utf8mb4"},
+}
+
+//CollationCharset 根据排序规则编号获取字符集名，未知时返回空
+func CollationCharset(id uint64) string {
+	if charset, ok := collationCharsets[id]; ok {
+		return charset
+	}
+	for _, r := range collationRanges {
+		if id >= r.first && id <= r.last {
+			return r.charset
+		}
+	}
+	return ""
+}
+
+//CollationNameCharset 根据排序规则名获取字符集名，如gbk_chinese_ci的字符集为gbk
+func CollationNameCharset(name string) string {
+	name = strings.ToLower(name)
+	if i := strings.IndexByte(name, '_'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+//SetConvertToUTF8 设置是否将字符列的ColumnData.Data从列的字符集转换为utf8，
+//默认不转换，binary字符集以及未知字符集的列不会转换，需要在Stream之前设置
+func (s *Streamer) SetConvertToUTF8(convert bool) {
+	s.convertToUTF8 = convert
+}
+
+//columnCharsets 获取表中每一列的字符集，非字符列为空
+func columnCharsets(tm *replication.TableMap, table MysqlTable) []string {
+	charsets := make([]string, len(tm.Types))
+	for c := range charsets {
+		if !tm.IsCharacterColumn(c) {
+			continue
+		}
+		if cc, ok := table.Columns()[c].(MysqlCollationColumn); ok && cc.Collation() != "" {
+			charsets[c] = CollationNameCharset(cc.Collation())
+		} else if c < len(tm.Collations) {
+			charsets[c] = CollationCharset(tm.Collations[c])
+		}
+	}
+	return charsets
+}
+
+//UTF8Data 获取转换为utf8的Data，binary字符集，未知字符集以及非字符列返回原始的Data
+func (c *ColumnData) UTF8Data() ([]byte, error) {
+	enc, ok := charsetEncodings[c.Charset]
+	if !ok || c.Data == nil {
+		return c.Data, nil
+	}
+	data, err := enc.NewDecoder().Bytes(c.Data)
+	if err != nil {
+		return nil, newError(err).msgf("column %v convert %v to utf8 fail.", c.Filed, c.Charset)
+	}
+	return data, nil
+}
+
+//toUTF8 将Data转换为utf8，并将Charset改为utf8mb4
+func (c *ColumnData) toUTF8() error {
+	if _, ok := charsetEncodings[c.Charset]; !ok {
+		return nil
+	}
+	data, err := c.UTF8Data()
+	if err != nil {
+		return err
+	}
+	c.Data = data
+	c.Charset = charsetUTF8MB4
+	return nil
+}
@@ -0,0 +1,127 @@
+package gobinlog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+type mysqlCollationColumnAttribute struct {
+	mysqlColumnAttribute
+	collation string
+}
+
+func (m *mysqlCollationColumnAttribute) Collation() string {
+	return m.collation
+}
+
+func TestCollationCharset(t *testing.T) {
+	testCases := map[uint64]string{
+		8:   "latin1",
+		28:  "gbk",
+		33:  "utf8",
+		45:  "utf8mb4",
+		63:  "binary",
+		255: "utf8mb4",
+		200: "utf8",
+		3:   "",
+		400: "",
+	}
+	for input, want := range testCases {
+		if out := CollationCharset(input); out != want {
+			t.Fatalf("want != out input: %v want: %v out: %v", input, want, out)
+		}
+	}
+
+	names := map[string]string{
+		"latin1_swedish_ci":  "latin1",
+		"GBK_CHINESE_CI":     "gbk",
+		"utf8mb4_0900_ai_ci": "utf8mb4",
+		"binary":             "binary",
+		"":                   "",
+	}
+	for input, want := range names {
+		if out := CollationNameCharset(input); out != want {
+			t.Fatalf("want != out input: %v want: %v out: %v", input, want, out)
+		}
+	}
+}
+
+func TestColumnCharsets(t *testing.T) {
+	tm := &replication.TableMap{
+		Types: []byte{
+			replication.TypeLong,
+			replication.TypeVarchar,
+			replication.TypeVarchar,
+			replication.TypeBlob,
+			replication.TypeString,
+		},
+		Metadata:   []uint16{0, 40, 40, 2, uint16(replication.TypeEnum)<<8 | 1},
+		Collations: []uint64{0, 8, 8, 63, 0},
+	}
+	table := &mysqlTableInfo{
+		columns: []MysqlColumn{
+			&mysqlColumnAttribute{field: "id"},
+			&mysqlColumnAttribute{field: "name"},
+			&mysqlCollationColumnAttribute{mysqlColumnAttribute: mysqlColumnAttribute{field: "title"}, collation: "gbk_chinese_ci"},
+			&mysqlColumnAttribute{field: "content"},
+			&mysqlCollationColumnAttribute{mysqlColumnAttribute: mysqlColumnAttribute{field: "kind"}, collation: "gbk_chinese_ci"},
+		},
+	}
+	want := []string{"", "latin1", "gbk", "binary", ""}
+	if out := columnCharsets(tm, table); !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out want: %v out: %v", want, out)
+	}
+
+	tm.Collations = nil
+	want = []string{"", "", "gbk", "", ""}
+	if out := columnCharsets(tm, table); !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out want: %v out: %v", want, out)
+	}
+}
+
+func TestColumnData_UTF8Data(t *testing.T) {
+	testCases := []struct {
+		input *ColumnData
+		want  string
+	}{
+		{
+			input: &ColumnData{Charset: "latin1", Data: []byte{'c', 'a', 'f', 0xe9}},
+			want:  "café",
+		},
+		{
+			input: &ColumnData{Charset: "gbk", Data: []byte{0xd6, 0xd0, 0xce, 0xc4}},
+			want:  "中文",
+		},
+		{
+			input: &ColumnData{Charset: "utf8mb4", Data: []byte("中文")},
+			want:  "中文",
+		},
+		{
+			input: &ColumnData{Charset: "binary", Data: []byte{0xd6, 0xd0}},
+			want:  "\xd6\xd0",
+		},
+		{
+			input: &ColumnData{Data: []byte{0xe9}},
+			want:  "\xe9",
+		},
+	}
+	for _, v := range testCases {
+		out, err := v.input.UTF8Data()
+		if err != nil {
+			t.Fatalf("%v UTF8Data fail. err: %v", v.input.Charset, err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("%v want != out want: %q out: %q", v.input.Charset, v.want, out)
+		}
+	}
+
+	c := &ColumnData{Charset: "latin1", Data: []byte{0xe9}}
+	if err := c.toUTF8(); err != nil || string(c.Data) != "é" || c.Charset != "utf8mb4" {
+		t.Fatalf("toUTF8 out: %+v err: %v", c, err)
+	}
+	if out, err := (&ColumnData{Charset: "gbk"}).UTF8Data(); err != nil || out != nil {
+		t.Fatalf("null UTF8Data want nil out: %v err: %v", out, err)
+	}
+}
@@ -0,0 +1,100 @@
+package gobinlog
+
+import "sync"
+
+//CheckpointFunc 检查点回调函数，pos之前的事务都已经处理完毕，可以安全地从pos开始重新dump
+type CheckpointFunc func(pos Position) error
+
+//trackedTransaction 被跟踪的事务
+type trackedTransaction struct {
+	next   Position //事务的下一个位置
+	remain int      //剩余未完成的部分
+}
+
+//checkpointTracker 按照事务进入的顺序跟踪事务的完成情况，事务可以乱序完成，
+//但检查点只会推进到连续完成的最后一个事务的NextPosition。
+//checkpointTracker不是并发安全的，调用者需要自己加锁
+type checkpointTracker struct {
+	base     uint64                //pending[0]的序号
+	pending  []*trackedTransaction //还没有推进到检查点的事务
+	position Position              //当前检查点
+}
+
+//newCheckpointTracker 创建checkpointTracker，start为初始检查点
+func newCheckpointTracker(start Position) *checkpointTracker {
+	return &checkpointTracker{
+		position: start,
+	}
+}
+
+//track 登记一个由parts个部分组成的事务，返回事务的序号，
+//now是事务的当前位置，在还没有任何检查点时用作初始检查点
+func (c *checkpointTracker) track(now, next Position, parts int) uint64 {
+	if c.position.IsZero() && len(c.pending) == 0 {
+		c.position = now
+	}
+	id := c.base + uint64(len(c.pending))
+	c.pending = append(c.pending, &trackedTransaction{
+		next:   next,
+		remain: parts,
+	})
+	return id
+}
+
+//done 标记序号为id的事务的一个部分已经完成，如果检查点向前推进了，返回新的检查点以及true
+func (c *checkpointTracker) done(id uint64) (Position, bool) {
+	if id >= c.base && id-c.base < uint64(len(c.pending)) {
+		c.pending[id-c.base].remain--
+	}
+	return c.advance()
+}
+
+//advance 将检查点推进到连续完成的最后一个事务
+func (c *checkpointTracker) advance() (Position, bool) {
+	advanced := false
+	for len(c.pending) > 0 && c.pending[0].remain <= 0 {
+		c.position = c.pending[0].next
+		c.pending[0] = nil
+		c.pending = c.pending[1:]
+		c.base++
+		advanced = true
+	}
+	return c.position, advanced
+}
+
+//checkpoint 当前检查点
+func (c *checkpointTracker) checkpoint() Position {
+	return c.position
+}
+
+//inFlight 还没有完成的事务数
+func (c *checkpointTracker) inFlight() int {
+	return len(c.pending)
+}
+
+//checkpointSaver 在调用者的锁之外串行地调用CheckpointFunc，回调中可以执行耗时的I/O，
+//也可以调用分发器或者队列的方法。多个goroutine同时推进检查点时只保存最新的检查点，
+//所以回调收到的检查点是递增的，中间的检查点可能被跳过
+type checkpointSaver struct {
+	fn    CheckpointFunc
+	mu    sync.Mutex
+	saved Position //最后一次保存成功的检查点
+}
+
+//save 保存latest返回的最新检查点，latest需要自己加锁，返回false时不再保存，如已经出错
+func (c *checkpointSaver) save(latest func() (Position, bool)) error {
+	if c.fn == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pos, ok := latest()
+	if !ok || pos == c.saved {
+		return nil
+	}
+	if err := c.fn(pos); err != nil {
+		return err
+	}
+	c.saved = pos
+	return nil
+}
@@ -0,0 +1,76 @@
+package gobinlog
+
+import (
+	"testing"
+)
+
+func testPosition(offset int64) Position {
+	return Position{
+		Filename: "binlog.000001",
+		Offset:   offset,
+	}
+}
+
+func TestCheckpointTracker(t *testing.T) {
+	c := newCheckpointTracker(Position{})
+	first := c.track(testPosition(4), testPosition(100), 1)
+	second := c.track(testPosition(100), testPosition(200), 2)
+	third := c.track(testPosition(200), testPosition(300), 1)
+
+	if c.checkpoint() != testPosition(4) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(4), c.checkpoint())
+	}
+
+	testCases := []struct {
+		id       uint64
+		want     Position
+		advanced bool
+	}{
+		{
+			id:       third,
+			want:     testPosition(4),
+			advanced: false,
+		},
+		{
+			id:       second,
+			want:     testPosition(4),
+			advanced: false,
+		},
+		{
+			id:       first,
+			want:     testPosition(100),
+			advanced: true,
+		},
+		{
+			id:       second,
+			want:     testPosition(300),
+			advanced: true,
+		},
+	}
+
+	for i, v := range testCases {
+		pos, advanced := c.done(v.id)
+		if pos != v.want || advanced != v.advanced {
+			t.Fatalf("%d want: %v %v out: %v %v", i, v.want, v.advanced, pos, advanced)
+		}
+	}
+
+	if c.inFlight() != 0 {
+		t.Fatalf("inFlight want: 0 out: %v", c.inFlight())
+	}
+}
+
+func TestCheckpointTracker_start(t *testing.T) {
+	c := newCheckpointTracker(testPosition(50))
+	id := c.track(testPosition(100), testPosition(200), 0)
+	if c.checkpoint() != testPosition(50) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(50), c.checkpoint())
+	}
+	if pos, advanced := c.advance(); !advanced || pos != testPosition(200) {
+		t.Fatalf("advance want: %v true out: %v %v", testPosition(200), pos, advanced)
+	}
+	//重复或者未知的序号不会影响检查点
+	if pos, advanced := c.done(id); advanced || pos != testPosition(200) {
+		t.Fatalf("done want: %v false out: %v %v", testPosition(200), pos, advanced)
+	}
+}
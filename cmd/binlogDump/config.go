@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 
+	"github.com/Breeze0806/gobinlog"
 	mylog "github.com/Breeze0806/go/log"
 )
 
@@ -15,6 +17,48 @@ type config struct {
 	LogLevel  string `json:"logLevel"`
 	ServerID  uint32 `json:"serverID"`
 	LogStdOut bool   `json:"logStdOut"`
+
+	ConvertToUTF8 bool   `json:"convertToUTF8"`
+	MetricsAddr   string `json:"metricsAddr"`
+
+	Format   string         `json:"format"`
+	JSON     jsonConfig     `json:"json"`
+	Debezium debeziumConfig `json:"debezium"`
+	Maxwell  maxwellConfig  `json:"maxwell"`
+
+	Masking []maskingRuleConfig `json:"masking"`
+}
+
+type maskingRuleConfig struct {
+	Database   string `json:"database"`
+	Table      string `json:"table"`
+	Column     string `json:"column"`
+	Action     string `json:"action"`
+	Key        string `json:"key"`
+	KeyEnv     string `json:"keyEnv"`
+	KeepPrefix int    `json:"keepPrefix"`
+	KeepSuffix int    `json:"keepSuffix"`
+	MaskChar   string `json:"maskChar"`
+}
+
+type debeziumConfig struct {
+	ServerName        string `json:"serverName"`
+	DisableTombstones bool   `json:"disableTombstones"`
+}
+
+type maxwellConfig struct {
+	OutputBinlogPosition bool `json:"outputBinlogPosition"`
+	OutputServerID       bool `json:"outputServerID"`
+	OutputGTID           bool `json:"outputGTID"`
+	OutputPrimaryKeys    bool `json:"outputPrimaryKeys"`
+}
+
+type jsonConfig struct {
+	Schema           string `json:"schema"`
+	TimeFormat       string `json:"timeFormat"`
+	BinaryEncoding   string `json:"binaryEncoding"`
+	NativeNumbers    bool   `json:"nativeNumbers"`
+	OmitEmptyColumns bool   `json:"omitEmptyColumns"`
 }
 
 var levelMap = map[string]mylog.Level{
@@ -23,10 +67,79 @@ var levelMap = map[string]mylog.Level{
 	"error": mylog.ErrorLevel,
 }
 
+var timeFormatMap = map[string]gobinlog.JSONTimeFormat{
+	"":            gobinlog.JSONTimeLocal,
+	"local":       gobinlog.JSONTimeLocal,
+	"rfc3339":     gobinlog.JSONTimeRFC3339,
+	"epochMillis": gobinlog.JSONTimeEpochMillis,
+}
+
+var binaryEncodingMap = map[string]gobinlog.JSONBinaryEncoding{
+	"":       gobinlog.JSONBinaryString,
+	"string": gobinlog.JSONBinaryString,
+	"base64": gobinlog.JSONBinaryBase64,
+	"hex":    gobinlog.JSONBinaryHex,
+}
+
+var maskActionMap = map[string]gobinlog.MaskAction{
+	"drop":     gobinlog.MaskDrop,
+	"null":     gobinlog.MaskNull,
+	"hash":     gobinlog.MaskHash,
+	"partial":  gobinlog.MaskPartial,
+	"tokenize": gobinlog.MaskTokenize,
+}
+
 func (c *config) logLevel() mylog.Level {
 	return levelMap[c.LogLevel]
 }
 
+func (c *config) jsonEncoder() *gobinlog.JSONEncoder {
+	e := gobinlog.NewJSONEncoder()
+	e.SetSchema(gobinlog.JSONSchema(c.JSON.Schema))
+	e.SetTimeFormat(timeFormatMap[c.JSON.TimeFormat])
+	e.SetBinaryEncoding(binaryEncodingMap[c.JSON.BinaryEncoding])
+	e.SetNativeNumbers(c.JSON.NativeNumbers)
+	e.SetOmitEmptyColumns(c.JSON.OmitEmptyColumns)
+	return e
+}
+
+func (c *config) maxwellEncoder() *gobinlog.MaxwellEncoder {
+	e := gobinlog.NewMaxwellEncoder()
+	e.SetOutputBinlogPosition(c.Maxwell.OutputBinlogPosition)
+	e.SetOutputServerID(c.Maxwell.OutputServerID)
+	e.SetOutputGTID(c.Maxwell.OutputGTID)
+	e.SetOutputPrimaryKeys(c.Maxwell.OutputPrimaryKeys)
+	return e
+}
+
+//masker 没有配置脱敏规则时返回nil，keyEnv配置时从环境变量中读取密钥
+func (c *config) masker() (*gobinlog.Masker, error) {
+	if len(c.Masking) == 0 {
+		return nil, nil
+	}
+	rules := make([]gobinlog.MaskRule, 0, len(c.Masking))
+	for _, m := range c.Masking {
+		r := gobinlog.MaskRule{
+			Database:   m.Database,
+			Table:      m.Table,
+			Column:     m.Column,
+			Action:     maskActionMap[m.Action],
+			Key:        []byte(m.Key),
+			KeepPrefix: m.KeepPrefix,
+			KeepSuffix: m.KeepSuffix,
+		}
+		if m.KeyEnv != "" {
+			r.Key = []byte(os.Getenv(m.KeyEnv))
+		}
+		for _, ch := range m.MaskChar {
+			r.MaskChar = ch
+			break
+		}
+		rules = append(rules, r)
+	}
+	return gobinlog.NewMasker(rules...)
+}
+
 func newConfig(filename string) (*config, error) {
 	buf, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -40,5 +153,19 @@ func newConfig(filename string) (*config, error) {
 	if _, ok := levelMap[c.LogLevel]; !ok {
 		return nil, fmt.Errorf("logLevel is invalid. level: %v", c.LogLevel)
 	}
+	if _, ok := formatMap[c.Format]; !ok {
+		return nil, fmt.Errorf("format is invalid. format: %v", c.Format)
+	}
+	if _, ok := timeFormatMap[c.JSON.TimeFormat]; !ok {
+		return nil, fmt.Errorf("json.timeFormat is invalid. format: %v", c.JSON.TimeFormat)
+	}
+	if _, ok := binaryEncodingMap[c.JSON.BinaryEncoding]; !ok {
+		return nil, fmt.Errorf("json.binaryEncoding is invalid. encoding: %v", c.JSON.BinaryEncoding)
+	}
+	for i, m := range c.Masking {
+		if _, ok := maskActionMap[m.Action]; !ok {
+			return nil, fmt.Errorf("masking[%v].action is invalid. action: %v", i, m.Action)
+		}
+	}
 	return c, nil
 }
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
 
 	"github.com/Breeze0806/gobinlog"
@@ -19,6 +21,13 @@ type environment struct {
 	out         *os.File
 	streamer    *gobinlog.Streamer
 	tableMapper *mysqlTableMapper
+	encoder     *gobinlog.JSONEncoder
+	debezium    *gobinlog.DebeziumEncoder
+	canal       *gobinlog.CanalEncoder
+	maxwell     *gobinlog.MaxwellEncoder
+	metrics     net.Listener
+	write       transactionWriter
+	flashback   bool
 	err         error
 }
 
@@ -29,7 +38,7 @@ func newEnvironment(filename string) *environment {
 }
 
 func (e *environment) build() error {
-	return e.initLogger().initOut().initDb().initTableMapper().initStreamer().err
+	return e.initLogger().initOut().initDb().initTableMapper().initStreamer().initMetrics().err
 }
 
 func (e *environment) initLogger() *environment {
@@ -110,10 +119,55 @@ func (e *environment) initStreamer() *environment {
 		return e
 	}
 	e.streamer.SetBinlogPosition(pos)
+	masker, err := e.masker()
+	if err != nil {
+		e.err = err
+		return e
+	}
+	if masker != nil {
+		e.streamer.UseEventMiddleware(masker.EventMiddleware())
+	}
+	e.streamer.SetConvertToUTF8(e.config.ConvertToUTF8)
+	e.encoder = e.config.jsonEncoder()
+	e.debezium = gobinlog.NewDebeziumEncoder(e.config.Debezium.ServerName)
+	e.debezium.SetTombstoneOnDelete(!e.config.Debezium.DisableTombstones)
+	e.canal = gobinlog.NewCanalEncoder(e.tableMapper)
+	e.maxwell = e.config.maxwellEncoder()
+	e.write = formatMap[e.config.Format]
+	return e
+}
+
+//masker flashback需要原始的值生成回滚的sql，脱敏后的值会覆盖真实数据，所以配置了脱敏规则时返回错误
+func (e *environment) masker() (*gobinlog.Masker, error) {
+	if e.flashback && len(e.config.Masking) > 0 {
+		return nil, fmt.Errorf("flashback can not run with masking, remove masking from the config")
+	}
+	return e.config.masker()
+}
+
+//initMetrics 配置metricsAddr时通过http的/metrics输出prometheus格式的统计信息
+func (e *environment) initMetrics() *environment {
+	if e.err != nil || e.config.MetricsAddr == "" {
+		return e
+	}
+	e.metrics, e.err = net.Listen("tcp", e.config.MetricsAddr)
+	if e.err != nil {
+		return e
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", gobinlog.NewPrometheusExporter(e.streamer))
+	go func() {
+		if err := http.Serve(e.metrics, mux); err != nil {
+			log.Printf("metrics server stopped. err: %v", err)
+		}
+	}()
 	return e
 }
 
 func (e *environment) close() {
+	if e.metrics != nil {
+		e.metrics.Close()
+	}
 	if e.db != nil {
 		e.db.Close()
 	}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestEnvironment_masker(t *testing.T) {
+	rules := []maskingRuleConfig{{Database: "db", Table: "t", Column: "c", Action: "null"}}
+	testCases := []struct {
+		flashback bool
+		masking   []maskingRuleConfig
+		masker    bool
+		fail      bool
+	}{
+		{flashback: false, masking: rules, masker: true},
+		{flashback: false, masking: nil, masker: false},
+		{flashback: true, masking: rules, fail: true},
+		{flashback: true, masking: nil, masker: false},
+	}
+
+	for i, v := range testCases {
+		e := &environment{config: &config{Masking: v.masking}, flashback: v.flashback}
+		m, err := e.masker()
+		if v.fail {
+			if err == nil || m != nil {
+				t.Fatalf("%v flashback with masking want error out: %v", i, m)
+			}
+			continue
+		}
+		if err != nil || (m != nil) != v.masker {
+			t.Fatalf("%v want masker: %v out: %v err: %v", i, v.masker, m, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Breeze0806/gobinlog"
+)
+
+//flashbackTimeLayout 开始和结束时间的格式，使用本地时区
+const flashbackTimeLayout = "2006-01-02 15:04:05"
+
+var insertModeMap = map[string]gobinlog.SQLInsertMode{
+	"":                     gobinlog.SQLInsert,
+	"insert":               gobinlog.SQLInsert,
+	"replace":              gobinlog.SQLReplace,
+	"insertIgnore":         gobinlog.SQLInsertIgnore,
+	"onDuplicateKeyUpdate": gobinlog.SQLInsertOnDuplicateKeyUpdate,
+}
+
+type flashbackFlags struct {
+	config     string
+	startFile  string
+	startPos   int64
+	stopFile   string
+	stopPos    int64
+	startTime  string
+	stopTime   string
+	tables     string
+	insertMode string
+}
+
+//runFlashback binlogDump flashback子命令，将一段binlog中的变更反转为sql写入outFile
+func runFlashback(args []string) {
+	f := flashbackFlags{}
+	fs := flag.NewFlagSet("flashback", flag.ExitOnError)
+	fs.StringVar(&f.config, "c", "", "config")
+	fs.StringVar(&f.startFile, "start-file", "", "start binlog file")
+	fs.Int64Var(&f.startPos, "start-pos", 4, "start binlog position")
+	fs.StringVar(&f.stopFile, "stop-file", "", "stop binlog file, default is the current master status")
+	fs.Int64Var(&f.stopPos, "stop-pos", 0, "stop binlog position")
+	fs.StringVar(&f.startTime, "start-time", "", "start time, format: "+flashbackTimeLayout)
+	fs.StringVar(&f.stopTime, "stop-time", "", "stop time, format: "+flashbackTimeLayout)
+	fs.StringVar(&f.tables, "tables", "", "tables to flashback, such as db1.t1,db2.*")
+	fs.StringVar(&f.insertMode, "insert-mode", "", "insert/replace/insertIgnore/onDuplicateKeyUpdate")
+	fs.Parse(args)
+
+	if f.config == "" {
+		log.Fatalf("config is empty")
+	}
+	if f.startFile == "" {
+		log.Fatalf("start-file is empty")
+	}
+	if f.stopFile != "" && f.stopPos == 0 {
+		log.Fatalf("stop-pos is empty")
+	}
+	mode, ok := insertModeMap[f.insertMode]
+	if !ok {
+		log.Fatalf("insert-mode is invalid. mode: %v", f.insertMode)
+	}
+	startTime, err := parseFlashbackTime(f.startTime)
+	if err != nil {
+		log.Fatalf("start-time is invalid. err: %v", err)
+	}
+	stopTime, err := parseFlashbackTime(f.stopTime)
+	if err != nil {
+		log.Fatalf("stop-time is invalid. err: %v", err)
+	}
+
+	e := newEnvironment(f.config)
+	e.flashback = true
+	defer e.close()
+	if err = e.build(); err != nil {
+		log.Fatalf("build fail. err: %v", err)
+	}
+
+	start := gobinlog.Position{Filename: f.startFile, Offset: f.startPos}
+	stop := gobinlog.Position{Filename: f.stopFile, Offset: f.stopPos}
+	if f.stopFile == "" {
+		if stop, err = e.tableMapper.GetBinlogPosition(); err != nil {
+			log.Fatalf("GetBinlogPosition fail. err: %v", err)
+		}
+	}
+	if start.Compare(stop) >= 0 {
+		log.Fatalf("start position %+v is not before stop position %+v", start, stop)
+	}
+
+	fb := gobinlog.NewFlashback()
+	fb.SetStopPosition(stop)
+	fb.SetTimeRange(startTime, stopTime)
+	fb.SetTableFilter(flashbackTableFilter(f.tables))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e.streamer.SetBinlogPosition(start)
+	e.streamer.SetJSONFormat(gobinlog.JSONFormatRFC8259)
+	err = e.streamer.Stream(ctx, func(t *gobinlog.Transaction) error {
+		if err := fb.Send(t); err != nil {
+			return err
+		}
+		if fb.Done() {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Stream fail. err: %v", err)
+	}
+	if err = e.streamer.Error(); err != nil {
+		log.Fatalf("Stream fail. err: %v", err)
+	}
+
+	g := gobinlog.NewSQLGenerator()
+	g.SetInsertMode(mode)
+	sqls, err := fb.SQL(g)
+	if err != nil {
+		log.Fatalf("flashback sql fail. err: %v", err)
+	}
+	for _, sql := range sqls {
+		if _, err = fmt.Fprintln(e.out, sql+";"); err != nil {
+			log.Fatalf("write sql fail. err: %v", err)
+		}
+	}
+}
+
+//parseFlashbackTime 解析本地时区的时间，为空时返回0
+func parseFlashbackTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.ParseInLocation(flashbackTimeLayout, s, time.Local)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+//flashbackTableFilter 通过db.table的列表过滤表，table为*时代表该库的所有表，为空时不过滤
+func flashbackTableFilter(tables string) func(name gobinlog.MysqlTableName) bool {
+	if tables == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, t := range strings.Split(tables, ",") {
+		set[strings.TrimSpace(t)] = true
+	}
+	return func(name gobinlog.MysqlTableName) bool {
+		return set[name.DbName+"."+name.TableName] || set[name.DbName+".*"]
+	}
+}
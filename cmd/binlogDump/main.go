@@ -13,6 +13,11 @@ import (
 var filename = flag.String("c", "", "config")
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "flashback" {
+		runFlashback(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 	if *filename == "" {
 		log.Fatalf("config is empty")
@@ -37,8 +42,7 @@ func main() {
 	}()
 
 	err := e.streamer.Stream(ctx, func(t *gobinlog.Transaction) error {
-		showTransaction(t, e.out)
-		return nil
+		return e.write(e, t)
 	})
 
 	if err != nil {
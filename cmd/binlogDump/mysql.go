@@ -3,20 +3,21 @@ package main
 import (
 	"database/sql"
 	"fmt"
-	"io"
 	"strings"
 
 	"github.com/Breeze0806/gobinlog"
 )
 
 const (
-	mysqlUnsigned = "unsigned" //无符号
+	mysqlUnsigned   = "unsigned" //无符号
+	mysqlPrimaryKey = "PRI"      //主键
 )
 
 //列属性
 type mysqlColumnAttribute struct {
 	field         string //列名
 	typ           string //列类型
+	collation     string //排序规则，非字符列为空
 	null          string //是否为空
 	key           string //PRI代表主键，UNI代表唯一索引
 	columnDefault []byte //默认值
@@ -31,6 +32,18 @@ func (m *mysqlColumnAttribute) IsUnSignedInt() bool {
 	return strings.Contains(strings.ToLower(m.typ), mysqlUnsigned)
 }
 
+func (m *mysqlColumnAttribute) IsPrimaryKey() bool {
+	return m.key == mysqlPrimaryKey
+}
+
+func (m *mysqlColumnAttribute) Collation() string {
+	return m.collation
+}
+
+func (m *mysqlColumnAttribute) Type() string {
+	return m.typ
+}
+
 type mysqlTableInfo struct {
 	name    gobinlog.MysqlTableName
 	columns []gobinlog.MysqlColumn
@@ -82,7 +95,7 @@ func (m *mysqlTableMapper) MysqlTable(name gobinlog.MysqlTableName) (gobinlog.My
 		columns: make([]gobinlog.MysqlColumn, 0, 10),
 	}
 
-	query := "desc " + name.String()
+	query := "SHOW FULL COLUMNS FROM " + name.String()
 	rows, err := m.db.Query(query)
 	if err != nil {
 		return info, fmt.Errorf("query failed query: %s, error: %v", query, err)
@@ -91,20 +104,16 @@ func (m *mysqlTableMapper) MysqlTable(name gobinlog.MysqlTableName) (gobinlog.My
 
 	for i := 0; rows.Next(); i++ {
 		column := &mysqlColumnAttribute{}
-		err = rows.Scan(&column.field, &column.typ, &column.null, &column.key, &column.columnDefault, &column.extra)
+		var collation sql.NullString
+		var privileges, comment string
+		err = rows.Scan(&column.field, &column.typ, &collation, &column.null, &column.key, &column.columnDefault,
+			&column.extra, &privileges, &comment)
 		if err != nil {
 			return info, err
 		}
+		column.collation = collation.String
 		info.columns = append(info.columns, column)
 	}
 	m.info = info
 	return info, nil
 }
-
-func showTransaction(t *gobinlog.Transaction, w io.Writer) {
-	b, err := t.MarshalJSON()
-	if err != nil {
-		return
-	}
-	fmt.Fprintln(w, string(b))
-}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Breeze0806/gobinlog"
+)
+
+//transactionWriter 按照输出格式写入事务
+type transactionWriter func(e *environment, t *gobinlog.Transaction) error
+
+var formatMap = map[string]transactionWriter{
+	"":         showTransaction,
+	"json":     showTransaction,
+	"protobuf": writeProtoTransaction,
+	"debezium": writeDebeziumTransaction,
+	"canal":    writeCanalTransaction,
+	"maxwell":  writeMaxwellTransaction,
+}
+
+//showTransaction 每一行输出一个json
+func showTransaction(e *environment, t *gobinlog.Transaction) error {
+	b, err := e.encoder.EncodeTransaction(t)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(e.out, string(b))
+	return err
+}
+
+//writeProtoTransaction 输出varint长度前缀加protobuf的帧
+func writeProtoTransaction(e *environment, t *gobinlog.Transaction) error {
+	return gobinlog.WriteProtoFrame(e.out, t)
+}
+
+//debeziumLine debezium格式的一行输出
+type debeziumLine struct {
+	Topic string          `json:"topic"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+//writeDebeziumTransaction 每一行输出一条debezium消息的主题，key以及value
+func writeDebeziumTransaction(e *environment, t *gobinlog.Transaction) error {
+	records, err := e.debezium.EncodeTransaction(t)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		line := debeziumLine{Topic: r.Topic, Key: r.Key, Value: r.Value}
+		if line.Key == nil {
+			line.Key = json.RawMessage("null")
+		}
+		if line.Value == nil {
+			line.Value = json.RawMessage("null")
+		}
+		b, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintln(e.out, string(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//writeCanalTransaction 每一行输出一条canal的flat message
+func writeCanalTransaction(e *environment, t *gobinlog.Transaction) error {
+	messages, err := e.canal.EncodeTransaction(t)
+	if err != nil {
+		return err
+	}
+	return writeLines(e, messages)
+}
+
+//writeMaxwellTransaction 每一行输出一条maxwell消息
+func writeMaxwellTransaction(e *environment, t *gobinlog.Transaction) error {
+	messages, err := e.maxwell.EncodeTransaction(t)
+	if err != nil {
+		return err
+	}
+	return writeLines(e, messages)
+}
+
+func writeLines(e *environment, lines [][]byte) error {
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(e.out, string(l)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
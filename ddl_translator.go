@@ -0,0 +1,1222 @@
+package gobinlog
+
+import (
+	"fmt"
+	"strings"
+)
+
+//SQLDialect 目标库的sql方言
+type SQLDialect int
+
+//目标库的sql方言
+const (
+	SQLDialectMySQL      SQLDialect = iota //mysql
+	SQLDialectSQLite                       //sqlite 3.35+
+	SQLDialectPostgreSQL                   //postgresql 10+
+)
+
+var sqlDialectStrings = map[SQLDialect]string{
+	SQLDialectMySQL:      "mysql",
+	SQLDialectSQLite:     "sqlite",
+	SQLDialectPostgreSQL: "postgresql",
+}
+
+//String 打印
+func (d SQLDialect) String() string {
+	if s, ok := sqlDialectStrings[d]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+//ddlType mysql列类型在sqlite以及postgresql中对应的类型
+type ddlType struct {
+	sqlite           string
+	postgres         string
+	postgresUnsigned string //无符号时postgresql中的类型，为空时与postgres相同
+	keepArgs         bool   //postgresql中是否保留长度以及精度等参数
+}
+
+//ddlTypes mysql列类型的映射表，binlog中enum是序号，set是位图，bit是整数，都映射为整数类型
+var ddlTypes = map[string]ddlType{
+	"tinyint":          {"INTEGER", "SMALLINT", "", false},
+	"bool":             {"INTEGER", "SMALLINT", "", false},
+	"boolean":          {"INTEGER", "SMALLINT", "", false},
+	"smallint":         {"INTEGER", "SMALLINT", "INTEGER", false},
+	"mediumint":        {"INTEGER", "INTEGER", "", false},
+	"int":              {"INTEGER", "INTEGER", "BIGINT", false},
+	"integer":          {"INTEGER", "INTEGER", "BIGINT", false},
+	"bigint":           {"INTEGER", "BIGINT", "NUMERIC(20)", false},
+	"bit":              {"INTEGER", "BIGINT", "", false},
+	"float":            {"REAL", "REAL", "", false},
+	"double":           {"REAL", "DOUBLE PRECISION", "", false},
+	"double precision": {"REAL", "DOUBLE PRECISION", "", false},
+	"real":             {"REAL", "DOUBLE PRECISION", "", false},
+	"decimal":          {"NUMERIC", "NUMERIC", "", true},
+	"dec":              {"NUMERIC", "NUMERIC", "", true},
+	"numeric":          {"NUMERIC", "NUMERIC", "", true},
+	"fixed":            {"NUMERIC", "NUMERIC", "", true},
+	"date":             {"TEXT", "DATE", "", false},
+	"time":             {"TEXT", "TIME", "", true},
+	"datetime":         {"TEXT", "TIMESTAMP", "", true},
+	"timestamp":        {"TEXT", "TIMESTAMP", "", true},
+	"year":             {"INTEGER", "SMALLINT", "", false},
+	"char":             {"TEXT", "CHAR", "", true},
+	"varchar":          {"TEXT", "VARCHAR", "", true},
+	"tinytext":         {"TEXT", "TEXT", "", false},
+	"text":             {"TEXT", "TEXT", "", false},
+	"mediumtext":       {"TEXT", "TEXT", "", false},
+	"longtext":         {"TEXT", "TEXT", "", false},
+	"binary":           {"BLOB", "BYTEA", "", false},
+	"varbinary":        {"BLOB", "BYTEA", "", false},
+	"tinyblob":         {"BLOB", "BYTEA", "", false},
+	"blob":             {"BLOB", "BYTEA", "", false},
+	"mediumblob":       {"BLOB", "BYTEA", "", false},
+	"longblob":         {"BLOB", "BYTEA", "", false},
+	"json":             {"TEXT", "JSON", "", false},
+	"enum":             {"INTEGER", "INTEGER", "", false},
+	"set":              {"INTEGER", "BIGINT", "", false},
+}
+
+//UnsupportedDDLError 目标库不支持或者无法翻译的DDL
+type UnsupportedDDLError struct {
+	SQL       string     //原始的sql
+	Construct string     //不支持的语法
+	Dialect   SQLDialect //目标库的sql方言
+}
+
+//Error 错误信息
+func (e *UnsupportedDDLError) Error() string {
+	return fmt.Sprintf("unsupported ddl for %v: %v. sql: %v", e.Dialect, e.Construct, e.SQL)
+}
+
+//DDLTranslator 将mysql的DDL翻译为sqlite或者postgresql的DDL，支持常见的CREATE TABLE，ALTER TABLE，
+//DROP TABLE，RENAME TABLE，TRUNCATE TABLE，CREATE INDEX以及DROP INDEX，
+//存储引擎，字符集，注释以及列的位置(FIRST/AFTER)等不影响数据的选项会被忽略，
+//无法翻译的语法返回*UnsupportedDDLError，
+//mysql中索引名在表内唯一，目标库中索引名为"表名_索引名"。
+//SQLApplier以及SQLGenerator设置相同的方言时会使用DDLTranslator翻译DDL
+type DDLTranslator struct {
+	dialect SQLDialect
+	rename  func(name MysqlTableName) MysqlTableName
+}
+
+//NewDDLTranslator 创建DDLTranslator
+func NewDDLTranslator(dialect SQLDialect) *DDLTranslator {
+	return &DDLTranslator{dialect: dialect}
+}
+
+//SetRename 设置表名的映射，为nil时postgresql中不修改，sqlite中去掉数据库名，
+//sqlite中需要保留数据库名时只能映射为ATTACH的数据库，不同数据库的同名表也需要通过映射区分
+func (t *DDLTranslator) SetRename(rename func(name MysqlTableName) MysqlTableName) {
+	t.rename = rename
+}
+
+//Translate 翻译语句中的DDL，没有数据库名的表使用Query.Database，非DDL语句返回nil
+func (t *DDLTranslator) Translate(s *StreamEvent) ([]string, error) {
+	if !s.Type.IsDDL() || s.Query.SQL == "" {
+		return nil, nil
+	}
+	if t.dialect != SQLDialectSQLite && t.dialect != SQLDialectPostgreSQL {
+		return nil, &UnsupportedDDLError{SQL: s.Query.SQL, Construct: "translation", Dialect: t.dialect}
+	}
+	tokens, err := lexDDL(s.Query.SQL)
+	if err != nil {
+		return nil, newError(err).msgf("lex ddl %v fail.", s.Query.SQL)
+	}
+	p := &ddlParser{
+		t:        t,
+		tokens:   tokens,
+		sql:      s.Query.SQL,
+		database: s.Query.Database,
+		rename:   t.rename,
+	}
+	return p.statement()
+}
+
+type ddlTokenKind int
+
+const (
+	ddlTokenEOF    ddlTokenKind = iota
+	ddlTokenWord                //关键字或者没有引号的标识符
+	ddlTokenQuoted              //反引号括起来的标识符
+	ddlTokenString              //字符串，text为转义后的值
+	ddlTokenNumber
+	ddlTokenSymbol
+)
+
+type ddlToken struct {
+	kind ddlTokenKind
+	text string
+}
+
+//lexDDL 将sql拆分为token，忽略注释，/*!50100 ... */中的内容会保留
+func lexDDL(sql string) ([]ddlToken, error) {
+	var tokens []ddlToken
+	versioned := 0
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(sql[i:], "/*!"):
+			i += 3
+			for i < len(sql) && sql[i] >= '0' && sql[i] <= '9' {
+				i++
+			}
+			versioned++
+		case strings.HasPrefix(sql[i:], "*/") && versioned > 0:
+			i += 2
+			versioned--
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '#' || strings.HasPrefix(sql[i:], "-- "):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '`':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(sql) {
+					return nil, fmt.Errorf("unterminated identifier")
+				}
+				if sql[i] == '`' {
+					if i+1 < len(sql) && sql[i+1] == '`' {
+						b.WriteByte('`')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(sql[i])
+				i++
+			}
+			tokens = append(tokens, ddlToken{ddlTokenQuoted, b.String()})
+		case c == '\'' || c == '"':
+			s, n, err := lexDDLString(sql[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, ddlToken{ddlTokenString, s})
+			i += n
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9':
+			start := i
+			for i < len(sql) && (isDDLWordByte(sql[i]) || sql[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, ddlToken{ddlTokenNumber, sql[start:i]})
+		case isDDLWordByte(c):
+			start := i
+			for i < len(sql) && isDDLWordByte(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, ddlToken{ddlTokenWord, sql[start:i]})
+		default:
+			tokens = append(tokens, ddlToken{ddlTokenSymbol, string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isDDLWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$' || c >= 0x80
+}
+
+//ddlStringEscapes mysql字符串中反斜杠转义的字符
+var ddlStringEscapes = map[byte]byte{
+	'0': 0, 'n': '\n', 'r': '\r', 't': '\t', 'b': '\b', 'Z': 0x1a,
+}
+
+//lexDDLString 解析单引号或者双引号括起来的字符串，返回转义后的值以及占用的长度
+func lexDDLString(sql string) (string, int, error) {
+	quote := sql[0]
+	var b strings.Builder
+	for i := 1; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\\' && i+1 < len(sql):
+			i++
+			if e, ok := ddlStringEscapes[sql[i]]; ok {
+				b.WriteByte(e)
+			} else {
+				b.WriteByte(sql[i])
+			}
+		case c == quote && i+1 < len(sql) && sql[i+1] == quote:
+			b.WriteByte(quote)
+			i++
+		case c == quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+//ddlColumn 列定义
+type ddlColumn struct {
+	name          string
+	typ           string
+	args          []string
+	unsigned      bool
+	notNull       bool
+	def           string //默认值的sql，没有默认值时为空
+	autoIncrement bool
+	primaryKey    bool
+	unique        bool
+}
+
+//ddlIndex 索引定义
+type ddlIndex struct {
+	name    string
+	primary bool
+	unique  bool
+	columns []string
+}
+
+type ddlParser struct {
+	t        *DDLTranslator
+	tokens   []ddlToken
+	pos      int
+	sql      string
+	database string
+	rename   func(name MysqlTableName) MysqlTableName
+}
+
+func (p *ddlParser) peek() ddlToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ddlToken{}
+}
+
+func (p *ddlParser) next() ddlToken {
+	tok := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return tok
+}
+
+//isWords 之后的token是否依次为这些关键字
+func (p *ddlParser) isWords(words ...string) bool {
+	for i, w := range words {
+		if p.pos+i >= len(p.tokens) {
+			return false
+		}
+		tok := p.tokens[p.pos+i]
+		if tok.kind != ddlTokenWord || !strings.EqualFold(tok.text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+//acceptWords 之后的token依次为这些关键字时跳过这些token
+func (p *ddlParser) acceptWords(words ...string) bool {
+	if !p.isWords(words...) {
+		return false
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *ddlParser) isSymbol(s string) bool {
+	tok := p.peek()
+	return tok.kind == ddlTokenSymbol && tok.text == s
+}
+
+func (p *ddlParser) acceptSymbol(s string) bool {
+	if !p.isSymbol(s) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *ddlParser) expectSymbol(s string) error {
+	if !p.acceptSymbol(s) {
+		return p.syntaxError("expect " + s)
+	}
+	return nil
+}
+
+//atEnd 是否已经到达语句结尾
+func (p *ddlParser) atEnd() bool {
+	return p.peek().kind == ddlTokenEOF || p.isSymbol(";")
+}
+
+func (p *ddlParser) syntaxError(msg string) error {
+	near := "end"
+	if tok := p.peek(); tok.kind != ddlTokenEOF {
+		near = tok.text
+	}
+	return newError(fmt.Errorf("%v near %q", msg, near)).msgf("parse ddl %v fail.", p.sql)
+}
+
+func (p *ddlParser) unsupported(construct string) error {
+	return &UnsupportedDDLError{SQL: p.sql, Construct: construct, Dialect: p.t.dialect}
+}
+
+func (p *ddlParser) identifier() (string, error) {
+	tok := p.peek()
+	if tok.kind != ddlTokenWord && tok.kind != ddlTokenQuoted {
+		return "", p.syntaxError("expect identifier")
+	}
+	p.pos++
+	return tok.text, nil
+}
+
+//tableName 解析表名，没有数据库名时使用默认数据库，并按照rename映射
+func (p *ddlParser) tableName() (MysqlTableName, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return MysqlTableName{}, err
+	}
+	table := NewMysqlTableName(p.database, name)
+	if p.acceptSymbol(".") {
+		if table.TableName, err = p.identifier(); err != nil {
+			return MysqlTableName{}, err
+		}
+		table.DbName = name
+	}
+	if p.rename != nil {
+		table = p.rename(table)
+	} else if p.t.dialect == SQLDialectSQLite {
+		//sqlite中表名前的数据库名只能是ATTACH的数据库，默认都放在main中
+		table.DbName = ""
+	}
+	return table, nil
+}
+
+//skipParens 跳过括号中的内容，当前token需要是(
+func (p *ddlParser) skipParens() error {
+	depth := 0
+	for {
+		switch tok := p.next(); {
+		case tok.kind == ddlTokenEOF:
+			return p.syntaxError("unbalanced parentheses")
+		case tok.kind == ddlTokenSymbol && tok.text == "(":
+			depth++
+		case tok.kind == ddlTokenSymbol && tok.text == ")":
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func (p *ddlParser) statement() ([]string, error) {
+	var sqls []string
+	var err error
+	switch {
+	case p.acceptWords("CREATE", "TABLE"):
+		sqls, err = p.createTable()
+	case p.acceptWords("CREATE", "INDEX"):
+		sqls, err = p.createIndex(false)
+	case p.acceptWords("CREATE", "UNIQUE", "INDEX"), p.acceptWords("CREATE", "UNIQUE", "KEY"):
+		sqls, err = p.createIndex(true)
+	case p.acceptWords("ALTER", "TABLE"), p.acceptWords("ALTER", "IGNORE", "TABLE"):
+		sqls, err = p.alterTable()
+	case p.acceptWords("DROP", "TABLE"):
+		sqls, err = p.dropTable()
+	case p.acceptWords("DROP", "INDEX"):
+		sqls, err = p.dropIndex()
+	case p.acceptWords("RENAME", "TABLE"):
+		sqls, err = p.renameTable()
+	case p.acceptWords("TRUNCATE"):
+		p.acceptWords("TABLE")
+		sqls, err = p.truncateTable()
+	default:
+		var words []string
+		for i := 0; i < 2 && p.pos+i < len(p.tokens); i++ {
+			words = append(words, strings.ToUpper(p.tokens[p.pos+i].text))
+		}
+		return nil, p.unsupported(strings.Join(words, " "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.atEnd() {
+		return nil, p.syntaxError("unexpected token")
+	}
+	return sqls, nil
+}
+
+func (p *ddlParser) createTable() ([]string, error) {
+	ifNotExists := p.acceptWords("IF", "NOT", "EXISTS")
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	if p.isWords("LIKE") || p.isWords("AS") || p.isWords("SELECT") {
+		return nil, p.unsupported("CREATE TABLE " + strings.ToUpper(p.peek().text))
+	}
+	if err = p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	var columns []*ddlColumn
+	var indexes []*ddlIndex
+	for {
+		if index, ok, err := p.indexDefinition(); err != nil {
+			return nil, err
+		} else if ok {
+			indexes = append(indexes, index)
+		} else {
+			c, err := p.columnDefinition()
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, c)
+		}
+		if p.acceptSymbol(")") {
+			break
+		}
+		if err = p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.tableOptions(); err != nil {
+		return nil, err
+	}
+	return p.t.createTableSQL(p, table, ifNotExists, columns, indexes)
+}
+
+//tableOptions 跳过ENGINE，CHARSET等表选项，分区等会影响数据的选项返回错误
+func (p *ddlParser) tableOptions() error {
+	for !p.atEnd() {
+		switch {
+		case p.isWords("PARTITION"):
+			return p.unsupported("PARTITION BY")
+		case p.isWords("AS"), p.isWords("SELECT"), p.isWords("IGNORE"), p.isWords("REPLACE"):
+			return p.unsupported("CREATE TABLE ... SELECT")
+		case p.isSymbol("("):
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		default:
+			p.next()
+		}
+	}
+	return nil
+}
+
+//indexDefinition 解析CREATE TABLE中的索引定义，不是索引定义时返回false
+func (p *ddlParser) indexDefinition() (*ddlIndex, bool, error) {
+	start := p.pos
+	if p.acceptWords("CONSTRAINT") {
+		if !p.isWords("PRIMARY") && !p.isWords("UNIQUE") && !p.isWords("FOREIGN") && !p.isWords("CHECK") {
+			if _, err := p.identifier(); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	index := &ddlIndex{}
+	switch {
+	case p.acceptWords("PRIMARY", "KEY"):
+		index.primary = true
+	case p.acceptWords("UNIQUE"):
+		index.unique = true
+		if !p.acceptWords("KEY") {
+			p.acceptWords("INDEX")
+		}
+	case p.acceptWords("KEY"), p.acceptWords("INDEX"):
+	case p.isWords("FOREIGN"), p.isWords("CHECK"), p.isWords("FULLTEXT"), p.isWords("SPATIAL"):
+		return nil, false, p.unsupported(strings.ToUpper(p.peek().text) + " constraint")
+	default:
+		if p.pos != start {
+			return nil, false, p.syntaxError("expect constraint")
+		}
+		return nil, false, nil
+	}
+
+	if !index.primary && !p.isSymbol("(") && !p.isWords("USING") {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, false, err
+		}
+		index.name = name
+	}
+	var err error
+	if index.columns, err = p.keyParts(index.primary || index.unique); err != nil {
+		return nil, false, err
+	}
+	if index.name == "" && !index.primary {
+		index.name = strings.Trim(strings.SplitN(index.columns[0], " ", 2)[0], `"`)
+	}
+	return index, true, nil
+}
+
+//keyParts 解析索引的列，忽略USING以及COMMENT等索引选项，非唯一索引中的前缀长度会被去掉
+func (p *ddlParser) keyParts(unique bool) ([]string, error) {
+	p.skipIndexOptions()
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var columns []string
+	for {
+		if p.isSymbol("(") {
+			return nil, p.unsupported("functional key part")
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		if p.acceptSymbol("(") {
+			if unique {
+				return nil, p.unsupported("prefix length in unique key")
+			}
+			p.next()
+			if err = p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+		}
+		column := quoteDDLIdentifier(name)
+		switch {
+		case p.acceptWords("ASC"):
+			column += " ASC"
+		case p.acceptWords("DESC"):
+			column += " DESC"
+		}
+		columns = append(columns, column)
+		if p.acceptSymbol(")") {
+			break
+		}
+		if err = p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+	p.skipIndexOptions()
+	return columns, nil
+}
+
+//skipIndexOptions 跳过USING BTREE，COMMENT，KEY_BLOCK_SIZE以及VISIBLE等索引选项
+func (p *ddlParser) skipIndexOptions() {
+	for {
+		switch {
+		case p.acceptWords("USING"), p.acceptWords("COMMENT"), p.acceptWords("KEY_BLOCK_SIZE"):
+			p.acceptSymbol("=")
+			p.next()
+		case p.acceptWords("VISIBLE"), p.acceptWords("INVISIBLE"):
+		default:
+			return
+		}
+	}
+}
+
+//columnDefinition 解析列定义
+func (p *ddlParser) columnDefinition() (*ddlColumn, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	c := &ddlColumn{name: name}
+	if c.typ, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	c.typ = strings.ToLower(c.typ)
+	if c.typ == "double" && p.acceptWords("PRECISION") {
+		c.typ = "double precision"
+	}
+	if p.acceptSymbol("(") {
+		for !p.acceptSymbol(")") {
+			tok := p.next()
+			switch tok.kind {
+			case ddlTokenEOF:
+				return nil, p.syntaxError("unbalanced parentheses")
+			case ddlTokenNumber, ddlTokenString:
+				c.args = append(c.args, tok.text)
+			}
+		}
+	}
+
+	for !p.atEnd() && !p.isSymbol(",") && !p.isSymbol(")") && !p.isWords("FIRST") && !p.isWords("AFTER") {
+		switch {
+		case p.acceptWords("UNSIGNED"):
+			c.unsigned = true
+		case p.acceptWords("SIGNED"), p.acceptWords("ZEROFILL"), p.acceptWords("NULL"),
+			p.acceptWords("VISIBLE"):
+		case p.acceptWords("NOT", "NULL"):
+			c.notNull = true
+		case p.acceptWords("CHARACTER", "SET"), p.acceptWords("CHARSET"), p.acceptWords("COLLATE"),
+			p.acceptWords("COMMENT"), p.acceptWords("COLUMN_FORMAT"), p.acceptWords("STORAGE"):
+			p.acceptSymbol("=")
+			p.next()
+		case p.acceptWords("DEFAULT"):
+			if c.def, err = p.defaultValue(); err != nil {
+				return nil, err
+			}
+		case p.acceptWords("AUTO_INCREMENT"):
+			c.autoIncrement = true
+		case p.acceptWords("PRIMARY", "KEY"), p.acceptWords("KEY"):
+			c.primaryKey = true
+		case p.acceptWords("UNIQUE"):
+			p.acceptWords("KEY")
+			c.unique = true
+		case p.isWords("ON", "UPDATE"):
+			return nil, p.unsupported("ON UPDATE")
+		case p.isWords("GENERATED"), p.isWords("AS"):
+			return nil, p.unsupported("generated column")
+		default:
+			return nil, p.unsupported("column attribute " + strings.ToUpper(p.peek().text))
+		}
+	}
+	return c, nil
+}
+
+//defaultValue 解析默认值，返回标准sql的字面量
+func (p *ddlParser) defaultValue() (string, error) {
+	sign := ""
+	if p.acceptSymbol("-") {
+		sign = "-"
+	} else {
+		p.acceptSymbol("+")
+	}
+	tok := p.next()
+	switch {
+	case tok.kind == ddlTokenNumber && !strings.HasPrefix(strings.ToLower(tok.text), "0x"):
+		return sign + tok.text, nil
+	case sign != "":
+	case tok.kind == ddlTokenString:
+		return quoteDDLString(tok.text), nil
+	case tok.kind != ddlTokenWord:
+	case strings.EqualFold(tok.text, "NULL"):
+		return "NULL", nil
+	case strings.EqualFold(tok.text, "TRUE"):
+		return "1", nil
+	case strings.EqualFold(tok.text, "FALSE"):
+		return "0", nil
+	case strings.EqualFold(tok.text, "CURRENT_TIMESTAMP"), strings.EqualFold(tok.text, "NOW"),
+		strings.EqualFold(tok.text, "LOCALTIMESTAMP"):
+		if p.acceptSymbol("(") {
+			precision := ""
+			if p.peek().kind == ddlTokenNumber {
+				precision = p.next().text
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return "", err
+			}
+			if precision != "" && p.t.dialect == SQLDialectPostgreSQL {
+				return "CURRENT_TIMESTAMP(" + precision + ")", nil
+			}
+		}
+		return "CURRENT_TIMESTAMP", nil
+	}
+	return "", p.unsupported("default value " + tok.text)
+}
+
+func (p *ddlParser) createIndex(unique bool) ([]string, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	p.skipIndexOptions()
+	if !p.acceptWords("ON") {
+		return nil, p.syntaxError("expect ON")
+	}
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := p.keyParts(unique)
+	if err != nil {
+		return nil, err
+	}
+	p.skipAlterOptions()
+	return []string{p.t.createIndexSQL(table, &ddlIndex{name: name, unique: unique, columns: columns})}, nil
+}
+
+func (p *ddlParser) dropIndex() ([]string, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	if !p.acceptWords("ON") {
+		return nil, p.syntaxError("expect ON")
+	}
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	p.skipAlterOptions()
+	if strings.EqualFold(name, "PRIMARY") {
+		return p.t.dropPrimaryKeySQL(p, table)
+	}
+	return []string{p.t.dropIndexSQL(table, name)}, nil
+}
+
+//skipAlterOptions 跳过ALGORITHM以及LOCK选项
+func (p *ddlParser) skipAlterOptions() {
+	for p.acceptWords("ALGORITHM") || p.acceptWords("LOCK") {
+		p.acceptSymbol("=")
+		p.next()
+	}
+}
+
+func (p *ddlParser) dropTable() ([]string, error) {
+	ifExists := p.acceptWords("IF", "EXISTS")
+	var sqls []string
+	for {
+		table, err := p.tableName()
+		if err != nil {
+			return nil, err
+		}
+		sql := "DROP TABLE "
+		if ifExists {
+			sql += "IF EXISTS "
+		}
+		sqls = append(sqls, sql+quoteDDLTable(table))
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if !p.acceptWords("RESTRICT") {
+		p.acceptWords("CASCADE")
+	}
+	return sqls, nil
+}
+
+func (p *ddlParser) renameTable() ([]string, error) {
+	var sqls []string
+	for {
+		from, err := p.tableName()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptWords("TO") {
+			return nil, p.syntaxError("expect TO")
+		}
+		to, err := p.tableName()
+		if err != nil {
+			return nil, err
+		}
+		sql, err := p.t.renameTableSQL(p, from, to)
+		if err != nil {
+			return nil, err
+		}
+		sqls = append(sqls, sql)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	return sqls, nil
+}
+
+func (p *ddlParser) truncateTable() ([]string, error) {
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	if p.t.dialect == SQLDialectSQLite {
+		return []string{"DELETE FROM " + quoteDDLTable(table)}, nil
+	}
+	return []string{"TRUNCATE TABLE " + quoteDDLTable(table)}, nil
+}
+
+func (p *ddlParser) alterTable() ([]string, error) {
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	var sqls []string
+	for {
+		v, err := p.alterSpecification(&table)
+		if err != nil {
+			return nil, err
+		}
+		sqls = append(sqls, v...)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	return sqls, nil
+}
+
+//alterSpecification 解析ALTER TABLE中的一个修改，重命名表之后table会被修改
+func (p *ddlParser) alterSpecification(table *MysqlTableName) ([]string, error) {
+	t := p.t
+	switch {
+	case p.acceptWords("ALGORITHM"), p.acceptWords("LOCK"), p.acceptWords("ENGINE"),
+		p.acceptWords("AUTO_INCREMENT"), p.acceptWords("COMMENT"), p.acceptWords("ROW_FORMAT"):
+		p.acceptSymbol("=")
+		p.next()
+		return nil, nil
+	case p.acceptWords("DEFAULT"), p.acceptWords("CHARACTER", "SET"), p.acceptWords("CHARSET"),
+		p.acceptWords("COLLATE"), p.acceptWords("CONVERT", "TO"):
+		for !p.atEnd() && !p.isSymbol(",") {
+			p.next()
+		}
+		return nil, nil
+	case p.isWords("ADD", "PRIMARY"), p.isWords("ADD", "UNIQUE"), p.isWords("ADD", "KEY"),
+		p.isWords("ADD", "INDEX"), p.isWords("ADD", "CONSTRAINT"), p.isWords("ADD", "FOREIGN"),
+		p.isWords("ADD", "FULLTEXT"), p.isWords("ADD", "SPATIAL"), p.isWords("ADD", "CHECK"):
+		p.next()
+		index, _, err := p.indexDefinition()
+		if err != nil {
+			return nil, err
+		}
+		if index.primary {
+			return t.addPrimaryKeySQL(p, *table, index)
+		}
+		return []string{t.createIndexSQL(*table, index)}, nil
+	case p.acceptWords("ADD"):
+		p.acceptWords("COLUMN")
+		if p.isSymbol("(") {
+			return nil, p.unsupported("ADD COLUMN with parentheses")
+		}
+		c, err := p.columnDefinition()
+		if err != nil {
+			return nil, err
+		}
+		p.columnPosition()
+		return t.addColumnSQL(p, *table, c)
+	case p.acceptWords("DROP", "PRIMARY", "KEY"):
+		return t.dropPrimaryKeySQL(p, *table)
+	case p.acceptWords("DROP", "INDEX"), p.acceptWords("DROP", "KEY"):
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		return []string{t.dropIndexSQL(*table, name)}, nil
+	case p.isWords("DROP", "FOREIGN"), p.isWords("DROP", "CHECK"), p.isWords("DROP", "CONSTRAINT"):
+		p.next()
+		return nil, p.unsupported("DROP " + strings.ToUpper(p.peek().text))
+	case p.acceptWords("DROP"):
+		p.acceptWords("COLUMN")
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		return []string{"ALTER TABLE " + quoteDDLTable(*table) + " DROP COLUMN " + quoteDDLIdentifier(name)}, nil
+	case p.acceptWords("MODIFY"):
+		p.acceptWords("COLUMN")
+		c, err := p.columnDefinition()
+		if err != nil {
+			return nil, err
+		}
+		p.columnPosition()
+		return t.modifyColumnSQL(p, *table, c.name, c)
+	case p.acceptWords("CHANGE"):
+		p.acceptWords("COLUMN")
+		old, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		c, err := p.columnDefinition()
+		if err != nil {
+			return nil, err
+		}
+		p.columnPosition()
+		return t.modifyColumnSQL(p, *table, old, c)
+	case p.acceptWords("RENAME", "COLUMN"):
+		old, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptWords("TO") {
+			return nil, p.syntaxError("expect TO")
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		return []string{t.renameColumnSQL(*table, old, name)}, nil
+	case p.acceptWords("RENAME"):
+		if !p.acceptWords("TO") {
+			p.acceptWords("AS")
+		}
+		to, err := p.tableName()
+		if err != nil {
+			return nil, err
+		}
+		sql, err := t.renameTableSQL(p, *table, to)
+		if err != nil {
+			return nil, err
+		}
+		*table = to
+		return []string{sql}, nil
+	case p.acceptWords("ALTER"):
+		p.acceptWords("COLUMN")
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		return t.alterColumnDefaultSQL(p, *table, name)
+	}
+	if p.atEnd() {
+		return nil, p.syntaxError("expect alter specification")
+	}
+	return nil, p.unsupported("ALTER TABLE " + strings.ToUpper(p.peek().text))
+}
+
+//columnPosition 跳过FIRST以及AFTER，目标库中新的列总是在最后
+func (p *ddlParser) columnPosition() {
+	if p.acceptWords("AFTER") {
+		p.next()
+		return
+	}
+	p.acceptWords("FIRST")
+}
+
+//createTableSQL 生成建表语句，非主键的索引使用单独的CREATE INDEX
+func (t *DDLTranslator) createTableSQL(p *ddlParser, table MysqlTableName, ifNotExists bool,
+	columns []*ddlColumn, indexes []*ddlIndex) ([]string, error) {
+	var primary []string
+	var others []*ddlIndex
+	for _, c := range columns {
+		if c.primaryKey {
+			primary = append(primary, quoteDDLIdentifier(c.name))
+		}
+		if c.unique {
+			others = append(others, &ddlIndex{name: c.name, unique: true,
+				columns: []string{quoteDDLIdentifier(c.name)}})
+		}
+	}
+	for _, index := range indexes {
+		if index.primary {
+			primary = append(primary, index.columns...)
+		} else {
+			others = append(others, index)
+		}
+	}
+
+	var defs []string
+	inlinePrimary := false
+	for _, c := range columns {
+		def, err := t.columnSQL(p, c)
+		if err != nil {
+			return nil, err
+		}
+		if c.autoIncrement && t.dialect == SQLDialectSQLite {
+			//sqlite中只有INTEGER PRIMARY KEY才能自增
+			if len(primary) != 1 || primary[0] != quoteDDLIdentifier(c.name) {
+				return nil, p.unsupported("AUTO_INCREMENT column which is not the only primary key")
+			}
+			def += " PRIMARY KEY AUTOINCREMENT"
+			inlinePrimary = true
+		}
+		defs = append(defs, def)
+	}
+	if len(primary) > 0 && !inlinePrimary {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(primary, ", ")+")")
+	}
+
+	sql := "CREATE TABLE "
+	if ifNotExists {
+		sql += "IF NOT EXISTS "
+	}
+	sqls := []string{sql + quoteDDLTable(table) + " (" + strings.Join(defs, ", ") + ")"}
+	for _, index := range others {
+		sqls = append(sqls, t.createIndexSQL(table, index))
+	}
+	return sqls, nil
+}
+
+//columnSQL 列定义，不包含主键以及唯一键
+func (t *DDLTranslator) columnSQL(p *ddlParser, c *ddlColumn) (string, error) {
+	typ, err := t.columnType(p, c)
+	if err != nil {
+		return "", err
+	}
+	def := quoteDDLIdentifier(c.name) + " " + typ
+	if c.autoIncrement && t.dialect == SQLDialectPostgreSQL {
+		if typ != "SMALLINT" && typ != "INTEGER" && typ != "BIGINT" {
+			return "", p.unsupported("AUTO_INCREMENT on " + typ)
+		}
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	}
+	if c.notNull {
+		def += " NOT NULL"
+	}
+	if c.def != "" {
+		def += " DEFAULT " + c.def
+	}
+	return def, nil
+}
+
+//columnType 通过ddlTypes获取目标库中的列类型
+func (t *DDLTranslator) columnType(p *ddlParser, c *ddlColumn) (string, error) {
+	typ, ok := ddlTypes[c.typ]
+	if !ok {
+		return "", p.unsupported("column type " + c.typ)
+	}
+	if (c.typ == "enum" || c.typ == "set") && c.def != "" && c.def != "NULL" {
+		//目标库中保存的是序号以及位图，字符串的默认值无法使用
+		return "", p.unsupported("DEFAULT on " + strings.ToUpper(c.typ) + " column")
+	}
+	if t.dialect == SQLDialectSQLite {
+		return typ.sqlite, nil
+	}
+	if c.unsigned && typ.postgresUnsigned != "" {
+		return typ.postgresUnsigned, nil
+	}
+	if typ.keepArgs && len(c.args) > 0 {
+		return typ.postgres + "(" + strings.Join(c.args, ",") + ")", nil
+	}
+	return typ.postgres, nil
+}
+
+func (t *DDLTranslator) addColumnSQL(p *ddlParser, table MysqlTableName, c *ddlColumn) ([]string, error) {
+	if t.dialect == SQLDialectSQLite {
+		switch {
+		case c.primaryKey, c.autoIncrement:
+			return nil, p.unsupported("ADD COLUMN with PRIMARY KEY or AUTO_INCREMENT")
+		case c.notNull && (c.def == "" || c.def == "NULL"):
+			return nil, p.unsupported("ADD COLUMN NOT NULL without default")
+		}
+	}
+	def, err := t.columnSQL(p, c)
+	if err != nil {
+		return nil, err
+	}
+	if c.primaryKey {
+		def += " PRIMARY KEY"
+	}
+	sqls := []string{"ALTER TABLE " + quoteDDLTable(table) + " ADD COLUMN " + def}
+	if c.unique {
+		sqls = append(sqls, t.createIndexSQL(table, &ddlIndex{name: c.name, unique: true,
+			columns: []string{quoteDDLIdentifier(c.name)}}))
+	}
+	return sqls, nil
+}
+
+//modifyColumnSQL MODIFY以及CHANGE，mysql中的列定义会整体替换，所以没有默认值时会删除默认值
+func (t *DDLTranslator) modifyColumnSQL(p *ddlParser, table MysqlTableName, old string,
+	c *ddlColumn) ([]string, error) {
+	if t.dialect == SQLDialectSQLite {
+		if old != c.name {
+			return nil, p.unsupported("CHANGE COLUMN")
+		}
+		return nil, p.unsupported("MODIFY COLUMN")
+	}
+	if c.primaryKey || c.unique || c.autoIncrement {
+		return nil, p.unsupported("MODIFY COLUMN with PRIMARY KEY, UNIQUE or AUTO_INCREMENT")
+	}
+	typ, err := t.columnType(p, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var sqls []string
+	if old != c.name {
+		sqls = append(sqls, t.renameColumnSQL(table, old, c.name))
+	}
+	prefix := "ALTER TABLE " + quoteDDLTable(table) + " ALTER COLUMN " + quoteDDLIdentifier(c.name)
+	sqls = append(sqls, prefix+" TYPE "+typ+" USING "+quoteDDLIdentifier(c.name)+"::"+typ)
+	if c.notNull {
+		sqls = append(sqls, prefix+" SET NOT NULL")
+	} else {
+		sqls = append(sqls, prefix+" DROP NOT NULL")
+	}
+	if c.def != "" {
+		sqls = append(sqls, prefix+" SET DEFAULT "+c.def)
+	} else {
+		sqls = append(sqls, prefix+" DROP DEFAULT")
+	}
+	return sqls, nil
+}
+
+//alterColumnDefaultSQL ALTER COLUMN ... SET DEFAULT以及DROP DEFAULT
+func (t *DDLTranslator) alterColumnDefaultSQL(p *ddlParser, table MysqlTableName, name string) ([]string, error) {
+	var action string
+	switch {
+	case p.acceptWords("SET", "DEFAULT"):
+		def, err := p.defaultValue()
+		if err != nil {
+			return nil, err
+		}
+		action = "SET DEFAULT " + def
+	case p.acceptWords("DROP", "DEFAULT"):
+		action = "DROP DEFAULT"
+	default:
+		return nil, p.unsupported("ALTER COLUMN " + strings.ToUpper(p.peek().text))
+	}
+	if t.dialect == SQLDialectSQLite {
+		return nil, p.unsupported("ALTER COLUMN " + action)
+	}
+	return []string{"ALTER TABLE " + quoteDDLTable(table) + " ALTER COLUMN " + quoteDDLIdentifier(name) +
+		" " + action}, nil
+}
+
+func (t *DDLTranslator) renameColumnSQL(table MysqlTableName, old, name string) string {
+	return "ALTER TABLE " + quoteDDLTable(table) + " RENAME COLUMN " + quoteDDLIdentifier(old) +
+		" TO " + quoteDDLIdentifier(name)
+}
+
+//renameTableSQL 目标库中重命名表时不能修改数据库(schema)
+func (t *DDLTranslator) renameTableSQL(p *ddlParser, from, to MysqlTableName) (string, error) {
+	if from.DbName != to.DbName {
+		return "", p.unsupported("RENAME TABLE across databases")
+	}
+	return "ALTER TABLE " + quoteDDLTable(from) + " RENAME TO " + quoteDDLIdentifier(to.TableName), nil
+}
+
+func (t *DDLTranslator) addPrimaryKeySQL(p *ddlParser, table MysqlTableName, index *ddlIndex) ([]string, error) {
+	if t.dialect == SQLDialectSQLite {
+		return nil, p.unsupported("ADD PRIMARY KEY")
+	}
+	return []string{"ALTER TABLE " + quoteDDLTable(table) + " ADD PRIMARY KEY (" +
+		strings.Join(index.columns, ", ") + ")"}, nil
+}
+
+//dropPrimaryKeySQL postgresql中主键约束的默认名为"表名_pkey"
+func (t *DDLTranslator) dropPrimaryKeySQL(p *ddlParser, table MysqlTableName) ([]string, error) {
+	if t.dialect == SQLDialectSQLite {
+		return nil, p.unsupported("DROP PRIMARY KEY")
+	}
+	return []string{"ALTER TABLE " + quoteDDLTable(table) + " DROP CONSTRAINT " +
+		quoteDDLIdentifier(table.TableName+"_pkey")}, nil
+}
+
+func (t *DDLTranslator) createIndexSQL(table MysqlTableName, index *ddlIndex) string {
+	sql := "CREATE INDEX "
+	if index.unique {
+		sql = "CREATE UNIQUE INDEX "
+	}
+	if t.dialect == SQLDialectSQLite {
+		//sqlite中数据库名在索引名前，ON后的表名不能带数据库名
+		return sql + quoteDDLTable(NewMysqlTableName(table.DbName, ddlIndexName(table, index.name))) +
+			" ON " + quoteDDLIdentifier(table.TableName) + " (" + strings.Join(index.columns, ", ") + ")"
+	}
+	return sql + quoteDDLIdentifier(ddlIndexName(table, index.name)) + " ON " + quoteDDLTable(table) +
+		" (" + strings.Join(index.columns, ", ") + ")"
+}
+
+func (t *DDLTranslator) dropIndexSQL(table MysqlTableName, name string) string {
+	index := NewMysqlTableName(table.DbName, ddlIndexName(table, name))
+	return "DROP INDEX " + quoteDDLTable(index)
+}
+
+//ddlIndexName 目标库中的索引名
+func ddlIndexName(table MysqlTableName, name string) string {
+	return table.TableName + "_" + name
+}
+
+//quoteDDLIdentifier 用双引号括起来的标识符
+func quoteDDLIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+//quoteDDLTable 表名，数据库名为空时不带数据库名
+func quoteDDLTable(table MysqlTableName) string {
+	if table.DbName == "" {
+		return quoteDDLIdentifier(table.TableName)
+	}
+	return quoteDDLIdentifier(table.DbName) + "." + quoteDDLIdentifier(table.TableName)
+}
+
+//quoteDDLString 标准sql的字符串，单引号重复两次转义
+func quoteDDLString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,194 @@
+package gobinlog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestDDLTranslator_Translate(t *testing.T) {
+	testCases := []struct {
+		dialect SQLDialect
+		sql     string
+		want    []string
+	}{
+		{
+			dialect: SQLDialectSQLite,
+			sql: "CREATE TABLE `t` (\n" +
+				"  `id` int(11) unsigned NOT NULL AUTO_INCREMENT,\n" +
+				"  `name` varchar(64) CHARACTER SET utf8mb4 NOT NULL DEFAULT 'it''s' COMMENT 'name',\n" +
+				"  `created` datetime DEFAULT CURRENT_TIMESTAMP,\n" +
+				"  `data` blob,\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  UNIQUE KEY `uk_name` (`name`),\n" +
+				"  KEY `idx_created` (`created`(10)) USING BTREE\n" +
+				") /*!50100 ENGINE=InnoDB */ DEFAULT CHARSET=utf8mb4",
+			want: []string{
+				`CREATE TABLE "t" ("id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, ` +
+					`"name" TEXT NOT NULL DEFAULT 'it''s', "created" TEXT DEFAULT CURRENT_TIMESTAMP, "data" BLOB)`,
+				`CREATE UNIQUE INDEX "t_uk_name" ON "t" ("name")`,
+				`CREATE INDEX "t_idx_created" ON "t" ("created")`,
+			},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			sql: "create table if not exists other.t (id bigint auto_increment primary key, " +
+				"price decimal(10,2) default -1.5, n int unsigned, ts timestamp(3) not null default current_timestamp(3), " +
+				"flag tinyint(1) default true, doc json, c char(2) unique) engine=innodb",
+			want: []string{
+				`CREATE TABLE IF NOT EXISTS "other"."t" ("id" BIGINT GENERATED BY DEFAULT AS IDENTITY, ` +
+					`"price" NUMERIC(10,2) DEFAULT -1.5, "n" BIGINT, ` +
+					`"ts" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3), "flag" SMALLINT DEFAULT 1, ` +
+					`"doc" JSON, "c" CHAR(2), PRIMARY KEY ("id"))`,
+				`CREATE UNIQUE INDEX "t_c" ON "other"."t" ("c")`,
+			},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			sql:     "CREATE TABLE t (e enum('a','b'), s set('x','y') NOT NULL, b bit(3))",
+			want:    []string{`CREATE TABLE "db"."t" ("e" INTEGER, "s" BIGINT NOT NULL, "b" BIGINT)`},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			sql: "ALTER TABLE t ADD COLUMN c varchar(10) NOT NULL DEFAULT '' AFTER id, DROP COLUMN d, " +
+				"MODIFY e bigint, CHANGE f g double NOT NULL DEFAULT 0, ADD INDEX idx_c (c), DROP KEY idx_d, " +
+				"ALTER COLUMN h SET DEFAULT 'x', DROP PRIMARY KEY, ADD PRIMARY KEY (c), RENAME TO t2, " +
+				"ALGORITHM=INPLACE, LOCK=NONE",
+			want: []string{
+				`ALTER TABLE "db"."t" ADD COLUMN "c" VARCHAR(10) NOT NULL DEFAULT ''`,
+				`ALTER TABLE "db"."t" DROP COLUMN "d"`,
+				`ALTER TABLE "db"."t" ALTER COLUMN "e" TYPE BIGINT USING "e"::BIGINT`,
+				`ALTER TABLE "db"."t" ALTER COLUMN "e" DROP NOT NULL`,
+				`ALTER TABLE "db"."t" ALTER COLUMN "e" DROP DEFAULT`,
+				`ALTER TABLE "db"."t" RENAME COLUMN "f" TO "g"`,
+				`ALTER TABLE "db"."t" ALTER COLUMN "g" TYPE DOUBLE PRECISION USING "g"::DOUBLE PRECISION`,
+				`ALTER TABLE "db"."t" ALTER COLUMN "g" SET NOT NULL`,
+				`ALTER TABLE "db"."t" ALTER COLUMN "g" SET DEFAULT 0`,
+				`CREATE INDEX "t_idx_c" ON "db"."t" ("c")`,
+				`DROP INDEX "db"."t_idx_d"`,
+				`ALTER TABLE "db"."t" ALTER COLUMN "h" SET DEFAULT 'x'`,
+				`ALTER TABLE "db"."t" DROP CONSTRAINT "t_pkey"`,
+				`ALTER TABLE "db"."t" ADD PRIMARY KEY ("c")`,
+				`ALTER TABLE "db"."t" RENAME TO "t2"`,
+			},
+		},
+		{
+			dialect: SQLDialectSQLite,
+			sql:     "alter table t add c int default 0 first, rename column c to d, drop c",
+			want: []string{
+				`ALTER TABLE "t" ADD COLUMN "c" INTEGER DEFAULT 0`,
+				`ALTER TABLE "t" RENAME COLUMN "c" TO "d"`,
+				`ALTER TABLE "t" DROP COLUMN "c"`,
+			},
+		},
+		{
+			dialect: SQLDialectSQLite,
+			sql:     "DROP TABLE IF EXISTS t1, `db2`.`t2` /* generated by server */",
+			want:    []string{`DROP TABLE IF EXISTS "t1"`, `DROP TABLE IF EXISTS "t2"`},
+		},
+		{
+			dialect: SQLDialectSQLite,
+			sql:     "RENAME TABLE t1 TO t2, db.t3 TO db.t4",
+			want:    []string{`ALTER TABLE "t1" RENAME TO "t2"`, `ALTER TABLE "t3" RENAME TO "t4"`},
+		},
+		{
+			dialect: SQLDialectSQLite,
+			sql:     "TRUNCATE TABLE t",
+			want:    []string{`DELETE FROM "t"`},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			sql:     "truncate t",
+			want:    []string{`TRUNCATE TABLE "db"."t"`},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			sql:     "CREATE UNIQUE INDEX uk ON t (a, b DESC)",
+			want:    []string{`CREATE UNIQUE INDEX "t_uk" ON "db"."t" ("a", "b" DESC)`},
+		},
+		{
+			dialect: SQLDialectSQLite,
+			sql:     "DROP INDEX idx ON t ALGORITHM=INPLACE",
+			want:    []string{`DROP INDEX "t_idx"`},
+		},
+	}
+
+	for i, v := range testCases {
+		s := &StreamEvent{Type: GetStatementCategory(v.sql), Query: replication.Query{Database: "db", SQL: v.sql}}
+		out, err := NewDDLTranslator(v.dialect).Translate(s)
+		if err != nil {
+			t.Fatalf("%v Translate fail. err: %v", i, err)
+		}
+		if !reflect.DeepEqual(v.want, out) {
+			t.Fatalf("%v want != out\nwant: %q\nout:  %q", i, v.want, out)
+		}
+	}
+}
+
+func TestDDLTranslator_Unsupported(t *testing.T) {
+	testCases := []struct {
+		dialect   SQLDialect
+		sql       string
+		construct string
+	}{
+		{SQLDialectSQLite, "CREATE TABLE t (id int, p point)", "column type point"},
+		{SQLDialectPostgreSQL, "CREATE TABLE t (id int, b int REFERENCES a(id))", "column attribute REFERENCES"},
+		{SQLDialectPostgreSQL, "CREATE TABLE t (ts timestamp ON UPDATE CURRENT_TIMESTAMP)", "ON UPDATE"},
+		{SQLDialectPostgreSQL, "CREATE TABLE t (id int, FOREIGN KEY (id) REFERENCES a(id))", "FOREIGN constraint"},
+		{SQLDialectPostgreSQL, "CREATE TABLE t (id int) PARTITION BY HASH(id)", "PARTITION BY"},
+		{SQLDialectPostgreSQL, "CREATE TABLE t LIKE t2", "CREATE TABLE LIKE"},
+		{SQLDialectSQLite, "CREATE TABLE t (a int AUTO_INCREMENT, b int, PRIMARY KEY (a, b))",
+			"AUTO_INCREMENT column which is not the only primary key"},
+		{SQLDialectSQLite, "ALTER TABLE t MODIFY c bigint", "MODIFY COLUMN"},
+		{SQLDialectSQLite, "ALTER TABLE t ADD c int NOT NULL", "ADD COLUMN NOT NULL without default"},
+		{SQLDialectPostgreSQL, "ALTER TABLE t ADD UNIQUE KEY uk (name(10))", "prefix length in unique key"},
+		{SQLDialectPostgreSQL, "ALTER TABLE t PARTITION BY HASH(id)", "ALTER TABLE PARTITION"},
+		{SQLDialectPostgreSQL, "RENAME TABLE db.t TO db2.t", "RENAME TABLE across databases"},
+		{SQLDialectPostgreSQL, "CREATE DATABASE db2", "CREATE DATABASE"},
+		{SQLDialectSQLite, "ALTER TABLE t ALTER COLUMN c DROP DEFAULT", "ALTER COLUMN DROP DEFAULT"},
+		{SQLDialectPostgreSQL, "CREATE TABLE t (e enum('a','b') DEFAULT 'a')", "DEFAULT on ENUM column"},
+		{SQLDialectMySQL, "CREATE TABLE t (id int)", "translation"},
+	}
+
+	for i, v := range testCases {
+		s := &StreamEvent{Type: GetStatementCategory(v.sql), Query: replication.Query{Database: "db", SQL: v.sql}}
+		out, err := NewDDLTranslator(v.dialect).Translate(s)
+		e, ok := err.(*UnsupportedDDLError)
+		if !ok {
+			t.Fatalf("%v want UnsupportedDDLError out: %q err: %v", i, out, err)
+		}
+		if e.Construct != v.construct || e.SQL != v.sql || e.Dialect != v.dialect {
+			t.Fatalf("%v want != out\nwant: %v\nout:  %+v", i, v.construct, e)
+		}
+	}
+}
+
+func TestDDLTranslator_Rename(t *testing.T) {
+	s := &StreamEvent{Type: StatementInsert, Query: replication.Query{SQL: "insert into t values (1)"}}
+	if out, err := NewDDLTranslator(SQLDialectSQLite).Translate(s); out != nil || err != nil {
+		t.Fatalf("non-ddl want nil out: %q err: %v", out, err)
+	}
+
+	d := NewDDLTranslator(SQLDialectSQLite)
+	d.SetRename(func(name MysqlTableName) MysqlTableName {
+		return MysqlTableName{TableName: name.DbName + "_" + name.TableName}
+	})
+	s = &StreamEvent{Type: StatementCreate, Query: replication.Query{Database: "db",
+		SQL: "CREATE TABLE t (id int, KEY k (id))"}}
+	want := []string{`CREATE TABLE "db_t" ("id" INTEGER)`, `CREATE INDEX "db_t_k" ON "db_t" ("id")`}
+	out, err := d.Translate(s)
+	if err != nil || !reflect.DeepEqual(want, out) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q err: %v", want, out, err)
+	}
+
+	//映射为ATTACH的数据库时保留数据库名
+	d.SetRename(func(name MysqlTableName) MysqlTableName {
+		return MysqlTableName{DbName: "aux", TableName: name.TableName}
+	})
+	want = []string{`CREATE TABLE "aux"."t" ("id" INTEGER)`, `CREATE INDEX "aux"."t_k" ON "t" ("id")`}
+	out, err = d.Translate(s)
+	if err != nil || !reflect.DeepEqual(want, out) {
+		t.Fatalf("attached want != out\nwant: %q\nout:  %q err: %v", want, out, err)
+	}
+}
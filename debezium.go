@@ -0,0 +1,191 @@
+package gobinlog
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//debezium中op的取值
+var debeziumOps = map[StatementType]string{
+	StatementInsert: "c",
+	StatementUpdate: "u",
+	StatementDelete: "d",
+}
+
+//DebeziumRecord debezium格式的一条消息
+type DebeziumRecord struct {
+	Topic string //主题，格式为serverName.db.table
+	Key   []byte //主键列组成的json对象，没有主键时为nil
+	Value []byte //before，after，source，op，ts_ms组成的json对象，删除后的tombstone为nil
+}
+
+//DebeziumEncoder 将行数据编码为debezium mysql connector格式的消息，
+//数字列为json数字，二进制列为base64编码，其他列为字符串，没有变化(IsEmpty)的列不输出
+type DebeziumEncoder struct {
+	serverName string
+	tombstone  bool
+	values     *JSONEncoder
+	now        func() time.Time
+}
+
+//NewDebeziumEncoder 创建DebeziumEncoder，serverName对应debezium的database.server.name
+func NewDebeziumEncoder(serverName string) *DebeziumEncoder {
+	values := NewJSONEncoder()
+	values.SetNativeNumbers(true)
+	values.SetBinaryEncoding(JSONBinaryBase64)
+	return &DebeziumEncoder{
+		serverName: serverName,
+		tombstone:  true,
+		values:     values,
+		now:        time.Now,
+	}
+}
+
+//SetTombstoneOnDelete 设置删除消息后是否输出Value为nil的tombstone消息，默认输出，
+//与debezium的tombstones.on.delete相同
+func (e *DebeziumEncoder) SetTombstoneOnDelete(tombstone bool) {
+	e.tombstone = tombstone
+}
+
+//EncodeTransaction 编码事务中所有的行数据，sql语句会被忽略
+func (e *DebeziumEncoder) EncodeTransaction(t *Transaction) ([]*DebeziumRecord, error) {
+	var records []*DebeziumRecord
+	err := t.RangeEvents(func(s *StreamEvent) error {
+		r, err := e.EncodeStreamEvent(t, s)
+		if err != nil {
+			return err
+		}
+		records = append(records, r...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+//EncodeStreamEvent 将语句中的每一行编码为一条消息，t用于生成source
+func (e *DebeziumEncoder) EncodeStreamEvent(t *Transaction, s *StreamEvent) ([]*DebeziumRecord, error) {
+	op, ok := debeziumOps[s.Type]
+	if !ok || s.Query.SQL != "" {
+		return nil, nil
+	}
+
+	cnt := len(s.RowValues)
+	if len(s.RowIdentifies) > cnt {
+		cnt = len(s.RowIdentifies)
+	}
+	topic := e.serverName + "." + s.Table.DbName + "." + s.Table.TableName
+	records := make([]*DebeziumRecord, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		var before, after *RowData
+		if i < len(s.RowIdentifies) {
+			before = s.RowIdentifies[i]
+		}
+		if i < len(s.RowValues) {
+			after = s.RowValues[i]
+		}
+
+		key, err := e.key(before, after)
+		if err != nil {
+			return nil, newError(err).msgf("table %v debezium key fail.", s.Table.String())
+		}
+		value, err := e.value(op, t, s, i, before, after)
+		if err != nil {
+			return nil, newError(err).msgf("table %v debezium value fail.", s.Table.String())
+		}
+		records = append(records, &DebeziumRecord{Topic: topic, Key: key, Value: value})
+		if s.Type == StatementDelete && e.tombstone {
+			records = append(records, &DebeziumRecord{Topic: topic, Key: key})
+		}
+	}
+	return records, nil
+}
+
+//key 主键列优先使用after中的值，after中没有变化时使用before中的值
+func (e *DebeziumEncoder) key(before, after *RowData) ([]byte, error) {
+	image := after
+	if image == nil {
+		image = before
+	}
+
+	var key jsonObject
+	for i, c := range image.Columns {
+		if !c.IsPrimaryKey {
+			continue
+		}
+		if c.IsEmpty && before != nil && i < len(before.Columns) {
+			c = before.Columns[i]
+		}
+		v, err := e.values.columnValue(c)
+		if err != nil {
+			return nil, err
+		}
+		key = append(key, jsonField{c.Filed, v})
+	}
+	if key == nil {
+		return nil, nil
+	}
+	return json.Marshal(key)
+}
+
+func (e *DebeziumEncoder) value(op string, t *Transaction, s *StreamEvent, row int,
+	before, after *RowData) ([]byte, error) {
+	beforeValue, err := e.row(before)
+	if err != nil {
+		return nil, err
+	}
+	afterValue, err := e.row(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var gtid interface{}
+	if t.GTID != "" {
+		gtid = t.GTID
+	}
+	source := jsonObject{
+		{"version", "gobinlog"},
+		{"connector", "mysql"},
+		{"name", e.serverName},
+		{"ts_ms", s.Timestamp * 1000},
+		{"snapshot", "false"},
+		{"db", s.Table.DbName},
+		{"sequence", nil},
+		{"table", s.Table.TableName},
+		{"server_id", t.ServerID},
+		{"gtid", gtid},
+		{"file", t.NowPosition.Filename},
+		{"pos", t.NowPosition.Offset},
+		{"row", row},
+		{"thread", nil},
+		{"query", nil},
+	}
+	return json.Marshal(jsonObject{
+		{"before", beforeValue},
+		{"after", afterValue},
+		{"source", source},
+		{"op", op},
+		{"ts_ms", e.now().UnixNano() / int64(time.Millisecond)},
+		{"transaction", nil},
+	})
+}
+
+//row 行数据组成的json对象，没有行数据时为null
+func (e *DebeziumEncoder) row(r *RowData) (interface{}, error) {
+	if r == nil {
+		return nil, nil
+	}
+	o := make(jsonObject, 0, len(r.Columns))
+	for _, c := range r.Columns {
+		if c.IsEmpty {
+			continue
+		}
+		v, err := e.values.columnValue(c)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, jsonField{c.Filed, v})
+	}
+	return o, nil
+}
@@ -0,0 +1,100 @@
+package gobinlog
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestDebeziumEncoder_EncodeTransaction(t *testing.T) {
+	table := MysqlTableName{DbName: "db", TableName: "t"}
+	tran := &Transaction{
+		NowPosition: Position{Filename: "binlog.000001", Offset: 4},
+		GTID:        "uuid:1",
+		ServerID:    1,
+		Events: []*StreamEvent{
+			{Type: StatementAlter, Query: replication.Query{SQL: "alter table t add column c int"}},
+			{
+				Type:      StatementInsert,
+				Table:     table,
+				Timestamp: 10,
+				RowValues: []*RowData{{Columns: []*ColumnData{
+					{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+					{Filed: "name", Type: columnTypeVarchar, Data: []byte("a")},
+					{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: []byte{0xff}},
+				}}},
+			},
+			{
+				Type:      StatementUpdate,
+				Table:     table,
+				Timestamp: 11,
+				RowIdentifies: []*RowData{{Columns: []*ColumnData{
+					{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+					{Filed: "name", Type: columnTypeVarchar, Data: []byte("a")},
+					{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: []byte{0xff}},
+				}}},
+				RowValues: []*RowData{{Columns: []*ColumnData{
+					{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, IsEmpty: true},
+					{Filed: "name", Type: columnTypeVarchar, Data: nil},
+					{Filed: "raw", Type: columnTypeBlob, Charset: "binary", IsEmpty: true},
+				}}},
+			},
+			{
+				Type:      StatementDelete,
+				Table:     table,
+				Timestamp: 12,
+				RowIdentifies: []*RowData{{Columns: []*ColumnData{
+					{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+					{Filed: "name", Type: columnTypeVarchar, Data: nil},
+				}}},
+			},
+		},
+	}
+
+	source := func(ts int) string {
+		return `"source":{"version":"gobinlog","connector":"mysql","name":"server1","ts_ms":` + strconv.Itoa(ts) +
+			`,"snapshot":"false","db":"db","sequence":null,"table":"t","server_id":1,"gtid":"uuid:1",` +
+			`"file":"binlog.000001","pos":4,"row":0,"thread":null,"query":null}`
+	}
+	key := `{"id":1}`
+	want := []struct {
+		key   string
+		value string
+	}{
+		{key, `{"before":null,"after":{"id":1,"name":"a","raw":"/w=="},` + source(10000) +
+			`,"op":"c","ts_ms":1000,"transaction":null}`},
+		{key, `{"before":{"id":1,"name":"a","raw":"/w=="},"after":{"name":null},` + source(11000) +
+			`,"op":"u","ts_ms":1000,"transaction":null}`},
+		{key, `{"before":{"id":1,"name":null},"after":null,` + source(12000) +
+			`,"op":"d","ts_ms":1000,"transaction":null}`},
+		{key, ``},
+	}
+
+	e := NewDebeziumEncoder("server1")
+	e.now = func() time.Time { return time.Unix(1, 0) }
+	out, err := e.EncodeTransaction(tran)
+	if err != nil {
+		t.Fatalf("EncodeTransaction fail. err: %v", err)
+	}
+	if len(out) != len(want) {
+		t.Fatalf("len want: %v out: %v", len(want), len(out))
+	}
+	for i, v := range want {
+		if out[i].Topic != "server1.db.t" || string(out[i].Key) != v.key || string(out[i].Value) != v.value {
+			t.Fatalf("%v want != out\nwant: %v %v\nout:  %v %s %s", i, v.key, v.value,
+				out[i].Topic, out[i].Key, out[i].Value)
+		}
+	}
+	if out[3].Value != nil {
+		t.Fatalf("tombstone want nil value")
+	}
+
+	e.SetTombstoneOnDelete(false)
+	tran.Events[3].RowIdentifies[0].Columns[0].IsPrimaryKey = false
+	out, err = e.EncodeStreamEvent(tran, tran.Events[3])
+	if err != nil || len(out) != 1 || out[0].Key != nil {
+		t.Fatalf("without primary key want 1 record with nil key out: %v err: %v", out, err)
+	}
+}
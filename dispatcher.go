@@ -0,0 +1,202 @@
+package gobinlog
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	errDispatcherClosed = errors.New("dispatcher is closed") //分发器已经关闭
+)
+
+//LogicalClockDispatcher 基于MySQL逻辑时钟(last_committed/sequence_number)的并行事务分发器，
+//无论binlog_transaction_dependency_tracking是COMMIT_ORDER还是WRITESET，主库都会把依赖
+//写入逻辑时钟。互不依赖的事务会在多个worker上并发执行，有依赖的事务仍然按照顺序执行，
+//检查点只会推进到连续执行完毕的最后一个事务。
+//Send可以直接作为SendTransactionFunc注册到Streamer.Stream中
+//   d := NewLogicalClockDispatcher(8, apply, saveCheckpoint)
+//   err := s.Stream(ctx, d.Send)
+//   closeErr := d.Close()
+type LogicalClockDispatcher struct {
+	apply   SendTransactionFunc
+	saver   checkpointSaver
+	jobs    chan *dispatchJob
+	wg      sync.WaitGroup
+	sending sync.WaitGroup //正在写入jobs的Send，Close需要等待它们结束后才能关闭jobs
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tracker *checkpointTracker
+	running map[uint64]int64 //执行中的事务序号对应的sequence_number
+	lastSeq int64            //上一个分发的事务的sequence_number
+	serial  bool             //上一个分发的事务没有逻辑时钟，需要等它执行完毕
+	closed  bool
+	err     error
+}
+
+type dispatchJob struct {
+	id   uint64
+	tran *Transaction
+}
+
+//NewLogicalClockDispatcher 创建LogicalClockDispatcher，workers是并发执行的worker数，
+//apply是在worker中执行事务的函数，checkpoint在检查点推进时被调用，可以为nil，
+//checkpoint在锁之外依次被调用，可以执行耗时的I/O
+func NewLogicalClockDispatcher(workers int, apply SendTransactionFunc,
+	checkpoint CheckpointFunc) *LogicalClockDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &LogicalClockDispatcher{
+		apply:   apply,
+		saver:   checkpointSaver{fn: checkpoint},
+		jobs:    make(chan *dispatchJob),
+		tracker: newCheckpointTracker(Position{}),
+		running: make(map[uint64]int64),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+//Send 分发一个事务，在该事务依赖的事务都执行完毕之后，它会被交给空闲的worker执行，
+//如果worker执行失败，Send会返回该错误，此时Streamer.Stream会停止
+func (d *LogicalClockDispatcher) Send(tran *Transaction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	hasClock := tran.SequenceNumber > 0 && tran.LastCommitted < tran.SequenceNumber
+	switch {
+	case !hasClock, d.serial, tran.SequenceNumber <= d.lastSeq:
+		//没有逻辑时钟或者换了binlog文件，逻辑时钟无法比较，需要等待之前的事务全部执行完毕
+		d.waitLocked(func() bool { return len(d.running) == 0 })
+	default:
+		//所有sequence_number不大于last_committed的事务都执行完毕之后才能执行该事务
+		d.waitLocked(func() bool { return d.lowestRunningLocked() > tran.LastCommitted })
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if d.closed {
+		return errDispatcherClosed
+	}
+
+	id := d.tracker.track(tran.NowPosition, tran.NextPosition, 1)
+	d.running[id] = tran.SequenceNumber
+	d.lastSeq = tran.SequenceNumber
+	d.serial = !hasClock
+
+	//Send返回后Streamer会释放溢出文件，worker执行完毕之前需要保留
+	if tran.spill != nil {
+		tran.spill.retain()
+	}
+	d.sending.Add(1)
+	d.mu.Unlock()
+	d.jobs <- &dispatchJob{id: id, tran: tran}
+	d.sending.Done()
+	d.mu.Lock()
+	return d.err
+}
+
+//Close 等待所有已经分发的事务执行完毕并停止worker，返回执行过程中的第一个错误
+func (d *LogicalClockDispatcher) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return d.Err()
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	d.sending.Wait()
+	close(d.jobs)
+	d.wg.Wait()
+	return d.Err()
+}
+
+//Checkpoint 获取当前检查点，该位置之前的事务都已经执行完毕
+func (d *LogicalClockDispatcher) Checkpoint() Position {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.tracker.checkpoint()
+}
+
+//Err 获取执行过程中的第一个错误
+func (d *LogicalClockDispatcher) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *LogicalClockDispatcher) work() {
+	defer d.wg.Done()
+	for job := range d.jobs {
+		var err error
+		if d.Err() == nil {
+			err = d.apply(job.tran)
+		}
+		if job.tran.spill != nil {
+			job.tran.spill.release()
+		}
+		d.finish(job.id, err)
+	}
+}
+
+func (d *LogicalClockDispatcher) finish(id uint64, err error) {
+	d.mu.Lock()
+	delete(d.running, id)
+	advanced := false
+	if err != nil {
+		d.setErrLocked(err)
+	} else if d.err == nil {
+		_, advanced = d.tracker.done(id)
+	}
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	if advanced {
+		d.saveCheckpoint()
+	}
+}
+
+//saveCheckpoint 在锁之外调用checkpoint保存最新的检查点
+func (d *LogicalClockDispatcher) saveCheckpoint() {
+	err := d.saver.save(func() (Position, bool) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.tracker.checkpoint(), d.err == nil
+	})
+	if err != nil {
+		d.mu.Lock()
+		d.setErrLocked(err)
+		d.cond.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+func (d *LogicalClockDispatcher) setErrLocked(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+//waitLocked 等待直到ready返回true或者发生错误
+func (d *LogicalClockDispatcher) waitLocked(ready func() bool) {
+	for d.err == nil && !ready() {
+		d.cond.Wait()
+	}
+}
+
+//lowestRunningLocked 执行中的事务中最小的sequence_number，没有执行中的事务时返回最大值
+func (d *LogicalClockDispatcher) lowestRunningLocked() int64 {
+	lowest := int64(^uint64(0) >> 1)
+	for _, seq := range d.running {
+		if seq < lowest {
+			lowest = seq
+		}
+	}
+	return lowest
+}
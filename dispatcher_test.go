@@ -0,0 +1,290 @@
+package gobinlog
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newClockTransaction(offset, lastCommitted, sequenceNumber int64) *Transaction {
+	return &Transaction{
+		NowPosition:    testPosition(offset),
+		NextPosition:   testPosition(offset + 100),
+		LastCommitted:  lastCommitted,
+		SequenceNumber: sequenceNumber,
+	}
+}
+
+//applyRecorder 记录事务的开始与结束顺序，并可以阻塞指定的事务
+type applyRecorder struct {
+	mu       sync.Mutex
+	started  []int64
+	finished []int64
+	blocks   map[int64]chan struct{}
+}
+
+func newApplyRecorder() *applyRecorder {
+	return &applyRecorder{
+		blocks: make(map[int64]chan struct{}),
+	}
+}
+
+func (a *applyRecorder) block(seq int64) chan struct{} {
+	ch := make(chan struct{})
+	a.mu.Lock()
+	a.blocks[seq] = ch
+	a.mu.Unlock()
+	return ch
+}
+
+func (a *applyRecorder) apply(tran *Transaction) error {
+	a.mu.Lock()
+	a.started = append(a.started, tran.SequenceNumber)
+	ch := a.blocks[tran.SequenceNumber]
+	a.mu.Unlock()
+	if ch != nil {
+		<-ch
+	}
+	a.mu.Lock()
+	a.finished = append(a.finished, tran.SequenceNumber)
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *applyRecorder) startedCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.started)
+}
+
+func (a *applyRecorder) isStarted(seq int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, v := range a.started {
+		if v == seq {
+			return true
+		}
+	}
+	return false
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLogicalClockDispatcher_parallel(t *testing.T) {
+	r := newApplyRecorder()
+	var mu sync.Mutex
+	var checkpoints []Position
+	d := NewLogicalClockDispatcher(4, r.apply, func(pos Position) error {
+		mu.Lock()
+		checkpoints = append(checkpoints, pos)
+		mu.Unlock()
+		return nil
+	})
+
+	//1和2在主库上并行提交，3依赖2
+	block1 := r.block(1)
+	if err := d.Send(newClockTransaction(100, 0, 1)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	if err := d.Send(newClockTransaction(200, 0, 2)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	waitFor(t, func() bool { return r.isStarted(1) && r.isStarted(2) })
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- d.Send(newClockTransaction(300, 2, 3))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if r.isStarted(3) {
+		t.Fatalf("3 started before 1 finished")
+	}
+	//2已经执行完毕，但是1还在执行，检查点不能越过1
+	if d.Checkpoint() != testPosition(100) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(100), d.Checkpoint())
+	}
+
+	close(block1)
+	if err := <-sent; err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+	if d.Checkpoint() != testPosition(400) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(400), d.Checkpoint())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 1; i < len(checkpoints); i++ {
+		if checkpoints[i].Offset <= checkpoints[i-1].Offset {
+			t.Fatalf("checkpoints are not increasing: %v", checkpoints)
+		}
+	}
+}
+
+func TestLogicalClockDispatcher_serial(t *testing.T) {
+	testCases := []struct {
+		name   string
+		first  *Transaction
+		second *Transaction
+	}{
+		{
+			name:   "no logical clock",
+			first:  newClockTransaction(100, 0, 0),
+			second: newClockTransaction(200, 0, 0),
+		},
+		{
+			name:   "new binlog file",
+			first:  newClockTransaction(100, 4, 5),
+			second: newClockTransaction(200, 0, 1),
+		},
+	}
+
+	for _, v := range testCases {
+		r := newApplyRecorder()
+		d := NewLogicalClockDispatcher(4, r.apply, nil)
+		block := r.block(v.first.SequenceNumber)
+		if err := d.Send(v.first); err != nil {
+			t.Fatalf("%v Send fail. err: %v", v.name, err)
+		}
+		sent := make(chan error, 1)
+		go func() {
+			sent <- d.Send(v.second)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		if r.startedCount() != 1 {
+			t.Fatalf("%v second started before first finished", v.name)
+		}
+		close(block)
+		if err := <-sent; err != nil {
+			t.Fatalf("%v Send fail. err: %v", v.name, err)
+		}
+		if err := d.Close(); err != nil {
+			t.Fatalf("%v Close fail. err: %v", v.name, err)
+		}
+	}
+}
+
+func TestLogicalClockDispatcher_error(t *testing.T) {
+	errMock := errors.New("mock error")
+	d := NewLogicalClockDispatcher(2, func(tran *Transaction) error {
+		if tran.SequenceNumber == 2 {
+			return errMock
+		}
+		return nil
+	}, nil)
+
+	if err := d.Send(newClockTransaction(100, 0, 1)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	d.Send(newClockTransaction(200, 1, 2))
+	//3依赖2，2失败后3不会被执行
+	if err := d.Send(newClockTransaction(300, 2, 3)); err != errMock {
+		t.Fatalf("Send want: %v out: %v", errMock, err)
+	}
+	if err := d.Close(); err != errMock {
+		t.Fatalf("Close want: %v out: %v", errMock, err)
+	}
+	if d.Checkpoint() != testPosition(200) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(200), d.Checkpoint())
+	}
+	if err := d.Send(newClockTransaction(400, 0, 4)); err != errMock {
+		t.Fatalf("Send after close want: %v out: %v", errMock, err)
+	}
+}
+
+func TestLogicalClockDispatcher_sendClose(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := NewLogicalClockDispatcher(2, func(*Transaction) error { return nil }, nil)
+		sent := make(chan error)
+		go func() {
+			sent <- d.Send(newClockTransaction(100, 0, 1))
+		}()
+		if err := d.Close(); err != nil {
+			t.Fatalf("%v Close fail. err: %v", i, err)
+		}
+		if err := <-sent; err != nil && err != errDispatcherClosed {
+			t.Fatalf("%v Send want: <nil> or %v out: %v", i, errDispatcherClosed, err)
+		}
+	}
+}
+
+func TestLogicalClockDispatcher_spilled(t *testing.T) {
+	dir, clean := testSpillDir(t)
+	defer clean()
+	tran := newClockTransaction(100, 0, 1)
+	tran.Events = []*StreamEvent{newStreamEvent(StatementInsert, 0, tesInfo.name)}
+	release := testSpillTransaction(t, dir, tran)
+
+	sent := make(chan struct{})
+	var events int
+	d := NewLogicalClockDispatcher(1, func(tran *Transaction) error {
+		//等待Send返回并且Streamer释放溢出文件之后再读取
+		<-sent
+		return tran.RangeEvents(func(*StreamEvent) error {
+			events++
+			return nil
+		})
+	}, nil)
+	err := d.Send(tran)
+	release()
+	close(sent)
+	if err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	if err = d.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+	if events != 1 {
+		t.Fatalf("events want: 1 out: %v", events)
+	}
+	if _, err = os.Stat(tran.spill.name); !os.IsNotExist(err) {
+		t.Fatalf("spill file should be removed after apply. err: %v", err)
+	}
+}
+
+func TestLogicalClockDispatcher_checkpointReentrant(t *testing.T) {
+	var d *LogicalClockDispatcher
+	var checkpoints []Position
+	d = NewLogicalClockDispatcher(2, func(*Transaction) error { return nil }, func(pos Position) error {
+		//回调在锁之外执行，可以调用分发器的方法
+		if d.Checkpoint().Offset < pos.Offset || d.Err() != nil {
+			return errors.New("unexpected dispatcher state")
+		}
+		checkpoints = append(checkpoints, pos)
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		for i := int64(1); i <= 3; i++ {
+			if err := d.Send(newClockTransaction(i*100, i-1, i)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- d.Close()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Send or Close fail. err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("checkpoint callback deadlocks")
+	}
+	if len(checkpoints) == 0 || checkpoints[len(checkpoints)-1] != testPosition(400) {
+		t.Fatalf("last checkpoint want: %v out: %v", testPosition(400), checkpoints)
+	}
+}
@@ -0,0 +1,247 @@
+package gobinlog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+	_ "github.com/go-sql-driver/mysql" //queryGTIDExecuted通过database/sql的mysql驱动获取gtid_executed
+)
+
+//failover的默认配置
+const (
+	defaultFailoverProbeTimeout = 5 * time.Second  //获取候选数据库gtid_executed的超时时间
+	defaultFailoverRetries      = 10               //没有处理新的事务时连续切换的最大次数
+	defaultFailoverBackoff      = time.Second      //第一次切换之前的等待时间
+	maxFailoverBackoff          = 30 * time.Second //切换之前的最大等待时间
+)
+
+var (
+	errGTIDSetUnknown = errors.New("gtid set is unknown, call SetGTIDSet before Stream") //没有设置GTID集合
+)
+
+//NoFailoverCandidateError 没有任何候选数据库的gtid_executed包含已经处理完的事务，
+//可以通过Error.Original获取
+type NoFailoverCandidateError struct {
+	GTIDSet string   //已经处理完的事务的GTID集合
+	Reasons []string //每个候选数据库不可用的原因，格式为"地址: 原因"，地址不包含用户名以及密码
+}
+
+//Error 获取详细错误信息
+func (e *NoFailoverCandidateError) Error() string {
+	return fmt.Sprintf("no failover candidate contains gtid set %v: %v", e.GTIDSet, strings.Join(e.Reasons, "; "))
+}
+
+//SetFailoverDSNs 设置同一个复制集中的候选数据库，需要在Stream之前调用，同时需要通过SetGTIDSet设置GTID集合。
+//连接出错时依次检查NewStreamer中的dsn以及候选数据库，选择第一个gtid_executed包含已经处理完的事务的数据库，
+//使用COM_BINLOG_DUMP_GTID从GTID集合之后继续dump，切换后binlog位置是新数据库的位置。
+//检查gtid_executed使用database/sql的mysql驱动github.com/go-sql-driver/mysql，
+//dsn使用tls或者自定义网络时无法发送COM_BINLOG_DUMP_GTID，切换时返回错误
+func (s *Streamer) SetFailoverDSNs(dsns ...string) {
+	s.failoverDSNs = dsns
+}
+
+//SetFailoverRetry 设置连接出错后切换数据库的重试策略，retries是没有处理新的事务时连续切换的最大次数，
+//超过后Stream返回最后一个错误，小于等于0时不限制；backoff是第一次切换之前的等待时间，之后每次加倍，
+//最大为30秒。默认最多连续切换10次，第一次等待1秒
+func (s *Streamer) SetFailoverRetry(retries int, backoff time.Duration) {
+	s.failoverRetries = retries
+	s.failoverBackoff = backoff
+}
+
+//SetGTIDSet 设置已经处理完的事务的GTID集合，如@@GLOBAL.gtid_executed的格式，
+//之后处理完的事务的GTID会加入该集合。没有设置binlog位置时Stream使用COM_BINLOG_DUMP_GTID从该集合之后开始dump
+func (s *Streamer) SetGTIDSet(set string) error {
+	gtidSet, err := replication.ParseMysql56GTIDSet(set)
+	if err != nil {
+		return newError(err).msgf("SetGTIDSet fail. gtid set: %v", set)
+	}
+	s.gtidSet.Store(gtidSet)
+	return nil
+}
+
+//GTIDSet 获取已经处理完的事务的GTID集合，可以在Stream的同时调用，作为检查点保存后通过SetGTIDSet恢复，
+//没有设置GTID集合时返回空字符串。在SendTransactionFunc中调用时还不包含正在处理的事务。
+//事务的GTID在SendTransactionFunc返回后就会加入集合，使用AsyncQueue以及LogicalClockDispatcher等
+//异步处理时，集合中可能包含还没有确认的事务，不能直接作为至少一次投递的检查点
+func (s *Streamer) GTIDSet() string {
+	if set, ok := s.loadGTIDSet(); ok {
+		return set.String()
+	}
+	return ""
+}
+
+func (s *Streamer) loadGTIDSet() (replication.Mysql56GTIDSet, bool) {
+	set, ok := s.gtidSet.Load().(replication.Mysql56GTIDSet)
+	return set, ok
+}
+
+//addGTID 将SendTransactionFunc返回的事务的GTID加入GTID集合，不等待异步投递的确认，没有设置GTID集合时不记录
+func (s *Streamer) addGTID(gtid replication.GTID) {
+	if gtid == nil {
+		return
+	}
+	if set, ok := s.loadGTIDSet(); ok {
+		s.gtidSet.Store(set.AddGTID(gtid).(replication.Mysql56GTIDSet))
+	}
+}
+
+//streamWithFailover 连接出错时切换到gtid_executed包含已经处理完的事务的数据库，
+//如果一轮切换中所有数据库都无法建立dump连接，或者没有处理新的事务时连续切换的次数超过上限，则返回最后一个错误
+func (s *Streamer) streamWithFailover(ctx context.Context, gtid bool) error {
+	dsns := []string{s.dsn}
+	failover := false
+	retries := 0
+	for {
+		var lastErr *Error
+		gtidSet := s.GTIDSet()
+		established := false
+		for _, dsn := range dsns {
+			connFailed, err := s.streamFrom(ctx, dsn, gtid)
+			if err == nil && ctx.Err() == nil {
+				//dump连接已经建立，binlog流因为读取出错而结束
+				established = true
+				s.dsn = dsn
+				if e, ok := s.Error().(*Error); ok {
+					connFailed, err = true, e
+				}
+			}
+			if err == nil {
+				return nil
+			}
+			if !connFailed || ctx.Err() != nil {
+				return err
+			}
+			_log.Errorf("Streamer connection to %v fail in pos: %+v gtid set: %v. err: %v",
+				dsnAddress(dsn), s.binlogPosition(), s.GTIDSet(), err)
+			lastErr = err
+			if established {
+				break
+			}
+		}
+		if !established && failover {
+			return lastErr.msgf("failover fail, all candidates fail.")
+		}
+		if s.GTIDSet() != gtidSet {
+			//处理了新的事务，重新计算切换次数
+			retries = 0
+		}
+		retries++
+		if s.failoverRetries > 0 && retries > s.failoverRetries {
+			return lastErr.msgf("failover fail, retry %d times without new transactions.", s.failoverRetries)
+		}
+		if !s.failoverWait(ctx, retries) {
+			return nil
+		}
+
+		var err *Error
+		if dsns, err = s.failoverCandidates(ctx); err != nil {
+			return err
+		}
+		gtid, failover = true, true
+	}
+}
+
+//failoverWait 第retries次切换之前等待，等待时间从failoverBackoff开始每次加倍，ctx被取消时返回false
+func (s *Streamer) failoverWait(ctx context.Context, retries int) bool {
+	backoff := s.failoverBackoff
+	for i := 1; i < retries && backoff < maxFailoverBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxFailoverBackoff {
+		backoff = maxFailoverBackoff
+	}
+	if backoff <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+//failoverCandidates 依次检查NewStreamer中的dsn以及候选数据库，返回gtid_executed包含已经处理完的事务的数据库
+func (s *Streamer) failoverCandidates(ctx context.Context) ([]string, *Error) {
+	set, ok := s.loadGTIDSet()
+	if !ok {
+		return nil, newError(errGTIDSetUnknown).msgf("failover fail.")
+	}
+
+	var dsns, reasons []string
+	seen := make(map[string]bool)
+	for _, dsn := range append([]string{s.dsn}, s.failoverDSNs...) {
+		if seen[dsn] {
+			continue
+		}
+		seen[dsn] = true
+		if err := s.probeFailoverCandidate(ctx, dsn, set); err != nil {
+			_log.Infof("Streamer failover candidate %v is unavailable. err: %v", dsnAddress(dsn), err)
+			reasons = append(reasons, fmt.Sprintf("%v: %v", dsnAddress(dsn), err))
+			continue
+		}
+		dsns = append(dsns, dsn)
+	}
+	if len(dsns) == 0 {
+		return nil, newError(&NoFailoverCandidateError{
+			GTIDSet: set.String(),
+			Reasons: reasons,
+		}).msgf("failover fail.")
+	}
+	_log.Infof("Streamer failover to %v with gtid set: %v", dsnAddress(dsns[0]), set)
+	return dsns, nil
+}
+
+//probeFailoverCandidate 检查数据库是否可用，以及gtid_executed是否包含set
+func (s *Streamer) probeFailoverCandidate(ctx context.Context, dsn string, set replication.Mysql56GTIDSet) error {
+	executed, err := s.gtidExecuted(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	executedSet, err := replication.ParseMysql56GTIDSet(executed)
+	if err != nil {
+		return err
+	}
+	if !executedSet.Contains(set) {
+		return fmt.Errorf("gtid_executed %v does not contain gtid set", executedSet)
+	}
+	return nil
+}
+
+//queryGTIDExecuted 通过database/sql的mysql驱动获取@@GLOBAL.gtid_executed
+func queryGTIDExecuted(ctx context.Context, dsn string) (string, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultFailoverProbeTimeout)
+	defer cancel()
+	var executed string
+	if err = db.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&executed); err != nil {
+		return "", err
+	}
+	return executed, nil
+}
+
+//dsnAddress 获取dsn中的网络地址，如tcp(127.0.0.1:3306)，用于日志以及错误信息，避免泄露密码
+func dsnAddress(dsn string) string {
+	if i := strings.LastIndex(dsn, "@"); i >= 0 {
+		dsn = dsn[i+1:]
+	}
+	if i := strings.Index(dsn, ")"); i >= 0 {
+		return dsn[:i+1]
+	}
+	if i := strings.Index(dsn, "/"); i >= 0 {
+		return dsn[:i]
+	}
+	return dsn
+}
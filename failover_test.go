@@ -0,0 +1,272 @@
+package gobinlog
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+	"github.com/Breeze0806/mysql"
+)
+
+//testBinlogConn 依次返回events，读完后返回err，err为nil时返回EOF包
+type testBinlogConn struct {
+	events []replication.BinlogEvent
+	err    error
+}
+
+func (c *testBinlogConn) Close() error {
+	return nil
+}
+
+func (c *testBinlogConn) Exec(_ string) error {
+	return nil
+}
+
+func (c *testBinlogConn) NoticeDump(_ uint32, _ uint32, _ string, _ uint16) error {
+	return nil
+}
+
+func (c *testBinlogConn) ReadPacket() ([]byte, error) {
+	if len(c.events) == 0 {
+		if c.err != nil {
+			return nil, c.err
+		}
+		return []byte{mysql.PacketEOF}, nil
+	}
+	ev := c.events[0]
+	c.events = c.events[1:]
+	return append([]byte{mysql.PacketOK}, ev.Bytes()...), nil
+}
+
+func (c *testBinlogConn) HandleErrorPacket(data []byte) error {
+	return fmt.Errorf("%v", string(data))
+}
+
+//testGTIDConn 支持COM_BINLOG_DUMP_GTID的testBinlogConn，记录收到的GTID集合
+type testGTIDConn struct {
+	testBinlogConn
+	gtidSet replication.Mysql56GTIDSet
+}
+
+func (c *testGTIDConn) NoticeDumpGTID(_ uint32, flags uint16, sidBlock []byte) error {
+	if flags != binlogThroughGTID {
+		return fmt.Errorf("flags %v is not BINLOG_THROUGH_GTID", flags)
+	}
+	set, err := replication.NewMysql56GTIDSetFromSIDBlock(sidBlock)
+	c.gtidSet = set
+	return err
+}
+
+func TestStreamer_streamFailover(t *testing.T) {
+	const (
+		dsnA = "root:secret@tcp(10.0.0.1:3306)/"
+		dsnB = "root:secret@tcp(10.0.0.2:3306)/"
+		dsnC = "root:secret@tcp(10.0.0.3:3306)/"
+		sid  = "00010203-0405-0607-0809-0a0b0c0d0e0f"
+	)
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	s.ServerID = 62344
+	gtid := replication.Mysql56GTID{
+		Server:   replication.SID{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		Sequence: 5,
+	}
+	input := getInputData()
+	//在BEGIN之前加入GTID event
+	input = append(input[:3], append([]replication.BinlogEvent{
+		replication.NewMySQL57GTIDEvent(f, s, gtid, replication.LogicalTimestamp{}),
+	}, input[3:]...)...)
+	failed := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, 4, "mysql-bin.000007"),
+		replication.NewFormatDescriptionEvent(f, s),
+	}
+
+	testCases := []struct {
+		gtidSet  string
+		conns    map[string]dumpConn
+		executed map[string]string
+		want     string //空字符串表示没有错误
+		wantDSN  string
+		wantSet  string
+	}{
+		{
+			gtidSet: sid + ":1-4",
+			conns: map[string]dumpConn{
+				dsnA: &testBinlogConn{events: input, err: fmt.Errorf("connection reset")},
+				dsnC: &testGTIDConn{testBinlogConn: testBinlogConn{events: failed}},
+			},
+			executed: map[string]string{
+				dsnB: sid + ":1-4",
+				dsnC: sid + ":1-6",
+			},
+			wantDSN: dsnC,
+			wantSet: sid + ":1-5",
+		},
+		{
+			gtidSet: sid + ":1-4",
+			conns: map[string]dumpConn{
+				dsnA: &testBinlogConn{events: input, err: fmt.Errorf("connection reset")},
+			},
+			executed: map[string]string{
+				dsnB: sid + ":1-4",
+				dsnC: "invalid",
+			},
+			want: "no failover candidate contains gtid set " + sid + ":1-5: " +
+				"tcp(10.0.0.1:3306): connection refused; " +
+				"tcp(10.0.0.2:3306): gtid_executed " + sid + ":1-4 does not contain gtid set; " +
+				"tcp(10.0.0.3:3306): invalid MySQL 5.6 GTID set (\"invalid\"): expected uuid:interval",
+			wantDSN: dsnA,
+			wantSet: sid + ":1-5",
+		},
+		{
+			conns: map[string]dumpConn{
+				dsnA: &testBinlogConn{events: input, err: fmt.Errorf("connection reset")},
+			},
+			want:    errGTIDSetUnknown.Error(),
+			wantDSN: dsnA,
+		},
+		{
+			gtidSet: sid + ":1-4",
+			conns: map[string]dumpConn{
+				dsnA: &testBinlogConn{events: input, err: fmt.Errorf("connection reset")},
+				dsnC: &testBinlogConn{events: failed},
+			},
+			executed: map[string]string{
+				dsnC: sid + ":1-6",
+			},
+			want:    errGTIDDumpUnsupported.Error(),
+			wantDSN: dsnA,
+			wantSet: sid + ":1-5",
+		},
+	}
+
+	for i, v := range testCases {
+		st, err := NewStreamer(dsnA, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("%v NewStreamer err: %v", i, err)
+		}
+		st.SetBinlogPosition(testBinlogPosParseEvents)
+		st.SetFailoverDSNs(dsnB, dsnC)
+		st.SetFailoverRetry(defaultFailoverRetries, time.Millisecond)
+		if v.gtidSet != "" {
+			if err = st.SetGTIDSet(v.gtidSet); err != nil {
+				t.Fatalf("%v SetGTIDSet err: %v", i, err)
+			}
+		}
+		st.dial = func(ctx context.Context, dsn string) (dumpConn, error) {
+			if c, ok := v.conns[dsn]; ok {
+				return c, nil
+			}
+			return nil, fmt.Errorf("connection refused")
+		}
+		st.gtidExecuted = func(ctx context.Context, dsn string) (string, error) {
+			if executed, ok := v.executed[dsn]; ok {
+				return executed, nil
+			}
+			return "", fmt.Errorf("connection refused")
+		}
+
+		var out []*Transaction
+		err = st.Stream(context.Background(), func(tran *Transaction) error {
+			out = append(out, tran)
+			return nil
+		})
+		if v.want == "" {
+			if err != nil {
+				t.Fatalf("%v Stream err: %v", i, err)
+			}
+		} else {
+			e, ok := err.(*Error)
+			if !ok || e.Original().Error() != v.want {
+				t.Fatalf("%v Stream want: %v out: %v", i, v.want, err)
+			}
+			if strings.Contains(e.Error(), "secret") {
+				t.Fatalf("%v Stream error contains password: %v", i, e)
+			}
+		}
+
+		if len(out) != 1 || out[0].GTID != gtid.String() || st.GTIDSet() != v.wantSet || st.dsn != v.wantDSN {
+			t.Fatalf("%v want: 1 %v %v %v out: %v %v %v", i, gtid.String(), v.wantSet, v.wantDSN,
+				len(out), st.GTIDSet(), st.dsn)
+		}
+		if c, ok := v.conns[dsnC].(*testGTIDConn); ok {
+			want, _ := replication.ParseMysql56GTIDSet(sid + ":1-5")
+			if !reflect.DeepEqual(c.gtidSet, want) {
+				t.Fatalf("%v COM_BINLOG_DUMP_GTID want: %v out: %v", i, want, c.gtidSet)
+			}
+			if pos := st.binlogPosition(); pos.Filename != "mysql-bin.000007" || pos.Offset != 4 {
+				t.Fatalf("%v position want: mysql-bin.000007 4 out: %+v", i, pos)
+			}
+		}
+	}
+}
+
+func TestStreamer_streamFailoverRetry(t *testing.T) {
+	const (
+		dsnA = "root:secret@tcp(10.0.0.1:3306)/"
+		sid  = "00010203-0405-0607-0809-0a0b0c0d0e0f"
+	)
+	st, err := NewStreamer(dsnA, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewStreamer err: %v", err)
+	}
+	st.SetBinlogPosition(testBinlogPosParseEvents)
+	st.SetFailoverDSNs(dsnA)
+	st.SetFailoverRetry(3, time.Millisecond)
+	if err = st.SetGTIDSet(sid + ":1-4"); err != nil {
+		t.Fatalf("SetGTIDSet err: %v", err)
+	}
+	//每次都能建立dump连接，但是没有读取到任何事务就断开
+	dials := 0
+	st.dial = func(ctx context.Context, dsn string) (dumpConn, error) {
+		dials++
+		return &testGTIDConn{testBinlogConn: testBinlogConn{err: fmt.Errorf("connection reset")}}, nil
+	}
+	st.gtidExecuted = func(ctx context.Context, dsn string) (string, error) {
+		return sid + ":1-4", nil
+	}
+
+	start := time.Now()
+	err = st.Stream(context.Background(), func(tran *Transaction) error {
+		return nil
+	})
+	e, ok := err.(*Error)
+	if !ok || e.Original().Error() != "connection reset" {
+		t.Fatalf("Stream want: connection reset out: %v", err)
+	}
+	if dials != 4 {
+		t.Fatalf("dials want: 4 out: %v", dials)
+	}
+	//每次切换之前等待1ms，2ms，4ms
+	if elapsed := time.Since(start); elapsed < 7*time.Millisecond {
+		t.Fatalf("backoff want >= 7ms out: %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	st.SetFailoverRetry(0, time.Hour)
+	if st.failoverWait(ctx, 1) {
+		t.Fatalf("failoverWait want false after ctx is canceled")
+	}
+}
+
+func TestDsnAddress(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "root:secret@tcp(127.0.0.1:3306)/db?charset=utf8", want: "tcp(127.0.0.1:3306)"},
+		{input: "root:p@ss@unix(/tmp/mysql.sock)/db", want: "unix(/tmp/mysql.sock)"},
+		{input: "root@/db", want: ""},
+		{input: "127.0.0.1:3306", want: "127.0.0.1:3306"},
+	}
+	for i, v := range testCases {
+		if out := dsnAddress(v.input); out != v.want {
+			t.Fatalf("%v want != out\nwant: %v\nout:  %v", i, v.want, out)
+		}
+	}
+}
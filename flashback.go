@@ -0,0 +1,184 @@
+package gobinlog
+
+import "sync"
+
+//ReverseStreamEvent 生成撤销语句中行数据变更的语句，插入变为删除，删除变为插入，
+//更新交换更新前后的数据，多行时行的顺序也会反转，非行数据的语句返回nil，
+//更新后的数据中没有记录(IsEmpty)的列使用更新前的值，需要binlog_row_image=FULL才能完整还原
+func ReverseStreamEvent(s *StreamEvent) *StreamEvent {
+	if s.Query.SQL != "" {
+		return nil
+	}
+	r := &StreamEvent{
+		Table:     s.Table,
+		Timestamp: s.Timestamp,
+	}
+	switch s.Type {
+	case StatementInsert:
+		r.Type = StatementDelete
+		r.RowIdentifies = reverseRows(s.RowValues)
+	case StatementDelete:
+		r.Type = StatementInsert
+		r.RowValues = reverseRows(s.RowIdentifies)
+	case StatementUpdate:
+		r.Type = StatementUpdate
+		for i := len(s.RowValues) - 1; i >= 0; i-- {
+			if i >= len(s.RowIdentifies) {
+				continue
+			}
+			r.RowIdentifies = append(r.RowIdentifies, mergeRow(s.RowValues[i], s.RowIdentifies[i]))
+			r.RowValues = append(r.RowValues, s.RowIdentifies[i])
+		}
+	default:
+		return nil
+	}
+	return r
+}
+
+//ReverseTransaction 生成撤销事务中所有行数据变更的事务，语句的顺序反转，sql语句会被忽略，
+//溢出到磁盘的事务会读取全部语句，返回的事务不再引用溢出文件
+func ReverseTransaction(t *Transaction) (*Transaction, error) {
+	var events []*StreamEvent
+	if err := t.RangeEvents(func(s *StreamEvent) error {
+		events = append(events, s)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	r := *t
+	r.Events, r.spill, r.transforms = nil, nil, nil
+	for i := len(events) - 1; i >= 0; i-- {
+		if s := ReverseStreamEvent(events[i]); s != nil {
+			r.Events = append(r.Events, s)
+		}
+	}
+	return &r, nil
+}
+
+func reverseRows(rows []*RowData) []*RowData {
+	reversed := make([]*RowData, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		reversed = append(reversed, rows[i])
+	}
+	return reversed
+}
+
+//mergeRow 使用base中的值补全row中没有记录的列
+func mergeRow(row, base *RowData) *RowData {
+	merged := &RowData{Columns: make([]*ColumnData, 0, len(row.Columns))}
+	for i, c := range row.Columns {
+		if c.IsEmpty && i < len(base.Columns) {
+			c = base.Columns[i]
+		}
+		merged.Columns = append(merged.Columns, c)
+	}
+	return merged
+}
+
+//Flashback 收集一段binlog中的事务并生成撤销这些变更的sql，类似binlog2sql的--flashback，
+//Send可以直接作为SendTransactionFunc注册到Streamer.Stream中，Streamer的开始位置即闪回的开始位置，
+//Done返回true之后可以停止Stream
+type Flashback struct {
+	mu sync.Mutex
+
+	stopPos   Position
+	startTime int64
+	stopTime  int64
+	filter    func(name MysqlTableName) bool
+
+	transactions []*Transaction
+	done         bool
+}
+
+//NewFlashback 创建Flashback，默认不限制结束位置，时间以及表
+func NewFlashback() *Flashback {
+	return &Flashback{}
+}
+
+//SetStopPosition 设置结束位置，只收集在stop之前开始的事务，事务结束位置到达stop后Done返回true
+func (f *Flashback) SetStopPosition(stop Position) {
+	f.stopPos = stop
+}
+
+//SetTimeRange 设置事务执行时间的范围，秒级时间戳，包含start以及stop，为0时不限制，
+//出现stop之后的事务时Done返回true
+func (f *Flashback) SetTimeRange(start, stop int64) {
+	f.startTime = start
+	f.stopTime = stop
+}
+
+//SetTableFilter 设置需要闪回的表，filter返回false的表会被忽略，为nil时闪回所有表
+func (f *Flashback) SetTableFilter(filter func(name MysqlTableName) bool) {
+	f.filter = filter
+}
+
+//Send 收集一个事务，到达结束位置或者时间之后的事务会被忽略
+func (f *Flashback) Send(t *Transaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done {
+		return nil
+	}
+	if f.stopTime != 0 && t.Timestamp > f.stopTime {
+		f.done = true
+		return nil
+	}
+	if !f.stopPos.IsZero() {
+		if t.NowPosition.Compare(f.stopPos) >= 0 {
+			f.done = true
+			return nil
+		}
+		f.done = t.NextPosition.Compare(f.stopPos) >= 0
+	}
+	if f.startTime != 0 && t.Timestamp < f.startTime {
+		return nil
+	}
+
+	r, err := ReverseTransaction(t)
+	if err != nil {
+		return err
+	}
+	events := r.Events[:0]
+	for _, s := range r.Events {
+		if f.filter == nil || f.filter(s.Table) {
+			events = append(events, s)
+		}
+	}
+	if len(events) > 0 {
+		r.Events = events
+		f.transactions = append(f.transactions, r)
+	}
+	return nil
+}
+
+//Done 是否已经到达结束位置或者时间
+func (f *Flashback) Done() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.done
+}
+
+//Transactions 撤销变更的事务，按照需要执行的顺序，即原来事务的逆序
+func (f *Flashback) Transactions() []*Transaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	transactions := make([]*Transaction, 0, len(f.transactions))
+	for i := len(f.transactions) - 1; i >= 0; i-- {
+		transactions = append(transactions, f.transactions[i])
+	}
+	return transactions
+}
+
+//SQL 使用g生成撤销变更的sql，按照需要执行的顺序
+func (f *Flashback) SQL(g *SQLGenerator) ([]string, error) {
+	var sqls []string
+	for _, t := range f.Transactions() {
+		v, err := g.GenerateTransaction(t)
+		if err != nil {
+			return nil, err
+		}
+		sqls = append(sqls, v...)
+	}
+	return sqls, nil
+}
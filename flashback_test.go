@@ -0,0 +1,132 @@
+package gobinlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReverseTransaction(t *testing.T) {
+	table := MysqlTableName{DbName: "db", TableName: "t"}
+	row := func(id, name string) *RowData {
+		return &RowData{Columns: []*ColumnData{
+			{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte(id)},
+			{Filed: "name", Type: columnTypeVarchar, Data: []byte(name)},
+		}}
+	}
+	minimal := &RowData{Columns: []*ColumnData{
+		{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, IsEmpty: true},
+		{Filed: "name", Type: columnTypeVarchar, Data: []byte("c")},
+	}}
+	tran := &Transaction{
+		Timestamp: 10,
+		Events: []*StreamEvent{
+			{Type: StatementInsert, Table: table, RowValues: []*RowData{row("1", "a"), row("2", "b")}},
+			{Type: StatementUpdate, Table: table,
+				RowIdentifies: []*RowData{row("1", "a")}, RowValues: []*RowData{minimal}},
+			{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{row("2", "b")}},
+			{Type: StatementAlter, Table: table},
+		},
+	}
+
+	want := &Transaction{
+		Timestamp: 10,
+		Events: []*StreamEvent{
+			{Type: StatementInsert, Table: table, RowValues: []*RowData{row("2", "b")}},
+			{Type: StatementUpdate, Table: table,
+				RowIdentifies: []*RowData{{Columns: []*ColumnData{
+					{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+					{Filed: "name", Type: columnTypeVarchar, Data: []byte("c")},
+				}}},
+				RowValues: []*RowData{row("1", "a")}},
+			{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{row("2", "b"), row("1", "a")}},
+		},
+	}
+	out, err := ReverseTransaction(tran)
+	if err != nil {
+		t.Fatalf("ReverseTransaction fail. err: %v", err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out\nwant: %+v\nout:  %+v", want, out)
+	}
+	if len(tran.Events) != 4 {
+		t.Fatalf("ReverseTransaction should not modify the input")
+	}
+
+	//溢出到磁盘的事务，返回的事务不引用溢出文件
+	dir, clean := testSpillDir(t)
+	defer clean()
+	release := testSpillTransaction(t, dir, tran)
+	out, err = ReverseTransaction(tran)
+	release()
+	if err != nil {
+		t.Fatalf("spilled ReverseTransaction fail. err: %v", err)
+	}
+	if out.Spilled() || !reflect.DeepEqual(out, want) {
+		t.Fatalf("spilled want != out\nwant: %+v\nout:  %+v", want, out)
+	}
+	if _, err = ReverseTransaction(tran); err == nil {
+		t.Fatalf("released spill file want error")
+	}
+}
+
+func TestFlashback(t *testing.T) {
+	insert := func(db string, id string, now, next int64, ts int64) *Transaction {
+		return &Transaction{
+			NowPosition:  Position{Filename: "binlog.000001", Offset: now},
+			NextPosition: Position{Filename: "binlog.000001", Offset: next},
+			Timestamp:    ts,
+			Events: []*StreamEvent{{
+				Type:  StatementInsert,
+				Table: MysqlTableName{DbName: db, TableName: "t"},
+				RowValues: []*RowData{{Columns: []*ColumnData{
+					{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte(id)},
+				}}},
+			}},
+		}
+	}
+
+	f := NewFlashback()
+	f.SetStopPosition(Position{Filename: "binlog.000001", Offset: 400})
+	f.SetTimeRange(10, 0)
+	f.SetTableFilter(func(name MysqlTableName) bool { return name.DbName == "db" })
+	input := []*Transaction{
+		insert("db", "1", 4, 100, 9),
+		insert("db", "2", 100, 200, 10),
+		insert("other", "3", 200, 300, 11),
+		insert("db", "4", 300, 400, 12),
+	}
+	for i, v := range input {
+		if f.Done() {
+			t.Fatalf("%v want not done", i)
+		}
+		if err := f.Send(v); err != nil {
+			t.Fatalf("Send fail. err: %v", err)
+		}
+	}
+	if !f.Done() {
+		t.Fatalf("want done after stop position")
+	}
+	if err := f.Send(insert("db", "5", 400, 500, 13)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+
+	out, err := f.SQL(NewSQLGenerator())
+	if err != nil {
+		t.Fatalf("SQL fail. err: %v", err)
+	}
+	want := []string{
+		"DELETE FROM `db`.`t` WHERE `id`=4",
+		"DELETE FROM `db`.`t` WHERE `id`=2",
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, out)
+	}
+
+	f = NewFlashback()
+	f.SetTimeRange(0, 10)
+	f.Send(input[0])
+	f.Send(input[2])
+	if !f.Done() || len(f.Transactions()) != 1 {
+		t.Fatalf("want done after stop time with 1 transaction, out: %v %v", f.Done(), len(f.Transactions()))
+	}
+}
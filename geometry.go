@@ -0,0 +1,87 @@
+package gobinlog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//GeometryFormat 几何列的输出格式
+type GeometryFormat int32
+
+//几何列的输出格式
+const (
+	GeometryFormatRaw     GeometryFormat = iota //原始数据，4字节SRID加WKB
+	GeometryFormatWKT                           //WKT，如POINT(1 2)
+	GeometryFormatWKB                           //WKB，json中为16进制字符串
+	GeometryFormatGeoJSON                       //GeoJSON，json中为对象
+)
+
+var geometryFormatStrings = map[GeometryFormat]string{
+	GeometryFormatRaw:     "raw",
+	GeometryFormatWKT:     "wkt",
+	GeometryFormatWKB:     "wkb",
+	GeometryFormatGeoJSON: "geojson",
+}
+
+//String 打印
+func (f GeometryFormat) String() string {
+	if s, ok := geometryFormatStrings[f]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+//Geometry 解析几何列，得到SRID和结构化的几何数据
+func (c *ColumnData) Geometry() (*replication.Geometry, error) {
+	if !c.Type.IsGeometry() {
+		return nil, newError(fmt.Errorf("column type %v is not geometry", c.Type)).
+			msgf("column %v", c.Filed)
+	}
+	if c.Data == nil {
+		return nil, nil
+	}
+	g, err := replication.DecodeGeometry(c.Data)
+	if err != nil {
+		return nil, newError(err).msgf("column %v DecodeGeometry fail.", c.Filed)
+	}
+	return g, nil
+}
+
+//FormatGeometry 以f格式输出几何列，列为NULL时返回nil
+func (c *ColumnData) FormatGeometry(f GeometryFormat) ([]byte, error) {
+	if f == GeometryFormatRaw {
+		return c.Data, nil
+	}
+	g, err := c.Geometry()
+	if err != nil || g == nil {
+		return nil, err
+	}
+	switch f {
+	case GeometryFormatWKT:
+		return []byte(g.WKT()), nil
+	case GeometryFormatWKB:
+		return g.WKB, nil
+	case GeometryFormatGeoJSON:
+		return g.GeoJSON(), nil
+	}
+	return nil, newError(fmt.Errorf("unknown geometry format %v", int32(f))).
+		msgf("column %v", c.Filed)
+}
+
+//geometryJSON 几何列在json序列化中的data
+func (c *ColumnData) geometryJSON(f GeometryFormat) (interface{}, error) {
+	data, err := c.FormatGeometry(f)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	switch f {
+	case GeometryFormatWKB:
+		return hex.EncodeToString(data), nil
+	case GeometryFormatGeoJSON:
+		return json.RawMessage(data), nil
+	}
+	return string(data), nil
+}
@@ -0,0 +1,105 @@
+package gobinlog
+
+import (
+	"testing"
+)
+
+//geometryPoint SRID为4326的POINT(1 2)
+var geometryPoint = []byte{
+	0xe6, 0x10, 0, 0,
+	1, 1, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0xf0, 0x3f,
+	0, 0, 0, 0, 0, 0, 0, 0x40,
+}
+
+func TestColumnData_FormatGeometry(t *testing.T) {
+	c := &ColumnData{
+		Filed: "location",
+		Type:  columnTypeGeometry,
+		Data:  geometryPoint,
+	}
+	testCases := []struct {
+		format GeometryFormat
+		want   string
+	}{
+		{
+			format: GeometryFormatRaw,
+			want:   string(geometryPoint),
+		},
+		{
+			format: GeometryFormatWKT,
+			want:   "POINT(1 2)",
+		},
+		{
+			format: GeometryFormatWKB,
+			want:   string(geometryPoint[4:]),
+		},
+		{
+			format: GeometryFormatGeoJSON,
+			want:   `{"type":"Point","coordinates":[1,2]}`,
+		},
+	}
+	for _, v := range testCases {
+		out, err := c.FormatGeometry(v.format)
+		if err != nil {
+			t.Fatalf("%v FormatGeometry fail. err: %v", v.format, err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("%v want != out want: %v out: %v", v.format, []byte(v.want), out)
+		}
+	}
+
+	g, err := c.Geometry()
+	if err != nil || g.SRID != 4326 {
+		t.Fatalf("Geometry fail. g: %+v err: %v", g, err)
+	}
+
+	if out, err := (&ColumnData{Type: columnTypeGeometry}).FormatGeometry(GeometryFormatWKT); err != nil || out != nil {
+		t.Fatalf("null FormatGeometry want nil out: %v err: %v", out, err)
+	}
+	if _, err = (&ColumnData{Type: columnTypeLong, Data: []byte("1")}).Geometry(); err == nil {
+		t.Fatalf("Long Geometry want error")
+	}
+	if _, err = (&ColumnData{Type: columnTypeGeometry, Data: []byte{1}}).FormatGeometry(GeometryFormatWKT); err == nil {
+		t.Fatalf("bad data FormatGeometry want error")
+	}
+	if _, err = c.FormatGeometry(GeometryFormat(-1)); err == nil {
+		t.Fatalf("unknown format FormatGeometry want error")
+	}
+}
+
+func TestJSONEncoder_Geometry(t *testing.T) {
+	c := &ColumnData{
+		Filed: "location",
+		Type:  columnTypeGeometry,
+		Data:  geometryPoint,
+	}
+	testCases := []struct {
+		format GeometryFormat
+		want   string
+	}{
+		{
+			format: GeometryFormatWKT,
+			want:   `{"filed":"location","type":"Geometry","isEmpty":false,"data":"POINT(1 2)"}`,
+		},
+		{
+			format: GeometryFormatWKB,
+			want:   `{"filed":"location","type":"Geometry","isEmpty":false,"data":"0101000000000000000000f03f0000000000000040"}`,
+		},
+		{
+			format: GeometryFormatGeoJSON,
+			want:   `{"filed":"location","type":"Geometry","isEmpty":false,"data":{"type":"Point","coordinates":[1,2]}}`,
+		},
+	}
+	for _, v := range testCases {
+		e := NewJSONEncoder()
+		e.SetGeometryFormat(v.format)
+		out, err := e.EncodeColumnData(c)
+		if err != nil {
+			t.Fatalf("%v EncodeColumnData fail. err: %v", v.format, err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("%v want != out want: %v out: %s", v.format, v.want, out)
+		}
+	}
+}
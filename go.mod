@@ -6,5 +6,8 @@ require (
 	github.com/Breeze0806/go v0.0.0-20210513031655-61a934305111
 	github.com/Breeze0806/mysql v1.4.2
 	github.com/go-sql-driver/mysql v1.6.0
+	github.com/klauspost/compress v1.9.8
+	golang.org/x/text v0.3.6
+	google.golang.org/protobuf v1.26.0
 	google.golang.org/appengine v1.6.7 // indirect
 )
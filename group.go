@@ -0,0 +1,351 @@
+package gobinlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//defaultGroupRetryInterval StreamerGroup中的来源出错后默认的重连间隔
+const defaultGroupRetryInterval = 5 * time.Second
+
+var (
+	errGroupStarted    = errors.New("streamer group is started") //StreamerGroup已经开始
+	errSourceStreamEOF = errors.New("binlog stream is closed")   //binlog流被关闭
+)
+
+//SourceState StreamerGroup中来源的状态
+type SourceState int
+
+//来源的状态
+const (
+	SourceStarting SourceState = iota //还没有开始
+	SourceRunning                     //正在dump binlog
+	SourceRetrying                    //出错后等待重连
+	SourceStopped                     //已经停止
+	SourceFailed                      //连续出错的次数超过上限，不再重连
+)
+
+var sourceStateStrings = map[SourceState]string{
+	SourceStarting: "starting",
+	SourceRunning:  "running",
+	SourceRetrying: "retrying",
+	SourceStopped:  "stopped",
+	SourceFailed:   "failed",
+}
+
+//String 打印
+func (s SourceState) String() string {
+	if v, ok := sourceStateStrings[s]; ok {
+		return v
+	}
+	return "unknown"
+}
+
+//StreamerSource StreamerGroup中一个binlog来源的配置
+type StreamerSource struct {
+	Name        string                  //来源的标识，在StreamerGroup中唯一，会写入Transaction.Source
+	DSN         string                  //mysql数据库的信息
+	ServerID    uint32                  //标识该数据库的信息
+	Position    Position                //开始的binlog位置
+	TableMapper MysqlTableMapper        //获取表信息的接口
+	Middlewares []TransactionMiddleware //该来源的事务中间件
+	Send        SendTransactionFunc     //该来源的事务处理函数，为nil时使用StreamerGroup.Start中的send
+	Configure   func(s *Streamer)       //创建Streamer之后调用，可以设置其他配置，如SetTransactionMemoryLimit
+}
+
+//SourceStatus 来源的健康状态
+type SourceStatus struct {
+	Name     string
+	State    SourceState
+	Position Position      //已经处理完的最后一个事务的下一个位置，重连时从这里开始dump
+	Retries  int           //连续出错的次数，成功处理事务后清零
+	Err      error         //最后一次错误
+	Stats    StreamerStats //Streamer的统计信息
+}
+
+type groupSource struct {
+	name     string
+	streamer *Streamer
+	send     SendTransactionFunc
+	stream   func(ctx context.Context, send SendTransactionFunc) error //默认是streamer.Stream
+
+	mu      sync.Mutex
+	state   SourceState
+	retries int
+	err     error
+}
+
+//StreamerGroup 管理多个binlog来源，每个来源有自己的Streamer、goroutine以及检查点，
+//事务的Source是来源的标识，来源出错后按照重连间隔从上次处理完的位置重新dump，
+//一个来源出错不影响其他来源
+//   g := gobinlog.NewStreamerGroup()
+//   g.Add(gobinlog.StreamerSource{Name: "shard-01", DSN: dsn1, ServerID: 1001, TableMapper: m1})
+//   g.Add(gobinlog.StreamerSource{Name: "shard-02", DSN: dsn2, ServerID: 1001, TableMapper: m2})
+//   transactions, err := g.StartMerged(ctx, 1024)
+//   defer g.Stop()
+type StreamerGroup struct {
+	sources       []*groupSource
+	retryInterval time.Duration
+	maxRetries    int
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+//NewStreamerGroup 创建StreamerGroup，默认重连间隔为5秒，不限制重连次数
+func NewStreamerGroup() *StreamerGroup {
+	return &StreamerGroup{
+		retryInterval: defaultGroupRetryInterval,
+	}
+}
+
+//SetRetryInterval 设置来源出错后的重连间隔，需要在Start之前调用
+func (g *StreamerGroup) SetRetryInterval(d time.Duration) {
+	g.retryInterval = d
+}
+
+//SetMaxRetries 设置来源连续出错的最大次数，超过后该来源不再重连，小于等于0时不限制，需要在Start之前调用
+func (g *StreamerGroup) SetMaxRetries(n int) {
+	g.maxRetries = n
+}
+
+//Add 添加一个来源，需要在Start之前调用，来源的标识为空或者重复时返回错误
+func (g *StreamerGroup) Add(src StreamerSource) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.started {
+		return errGroupStarted
+	}
+	if src.Name == "" {
+		return newError(fmt.Errorf("name is empty")).msgf("add source fail.")
+	}
+	if g.source(src.Name) != nil {
+		return newError(fmt.Errorf("source %v is already added", src.Name)).msgf("add source fail.")
+	}
+
+	s, err := NewStreamer(src.DSN, src.ServerID, src.TableMapper)
+	if err != nil {
+		return err
+	}
+	s.SetBinlogPosition(src.Position)
+	s.Use(tagSource(src.Name))
+	s.Use(src.Middlewares...)
+	if src.Configure != nil {
+		src.Configure(s)
+	}
+	g.sources = append(g.sources, &groupSource{
+		name:     src.Name,
+		streamer: s,
+		send:     src.Send,
+		stream:   s.Stream,
+	})
+	return nil
+}
+
+//tagSource 将来源的标识写入事务
+func tagSource(name string) TransactionMiddleware {
+	return func(next SendTransactionFunc) SendTransactionFunc {
+		return func(t *Transaction) error {
+			r := *t
+			r.Source = name
+			return next(&r)
+		}
+	}
+}
+
+func (g *StreamerGroup) source(name string) *groupSource {
+	for _, src := range g.sources {
+		if src.name == name {
+			return src
+		}
+	}
+	return nil
+}
+
+//Streamer 返回来源的Streamer，没有该来源时返回nil
+func (g *StreamerGroup) Streamer(name string) *Streamer {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if src := g.source(name); src != nil {
+		return src.streamer
+	}
+	return nil
+}
+
+//Start 在各自的goroutine中开始dump所有来源，没有设置Send的来源的事务交给send，
+//事务处理函数返回错误时该来源会重连并从上次处理完的位置重新投递，即至少一次投递
+func (g *StreamerGroup) Start(ctx context.Context, send SendTransactionFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	return g.start(ctx, cancel, send)
+}
+
+//StartMerged 开始dump所有来源，没有设置Send的来源的事务合并到返回的chan中，size是chan的缓冲大小，
+//所有来源停止后chan被关闭。事务放入chan即视为处理完毕，来源重连时不会重新投递chan中还没有处理的事务，
+//所以不保证至少一次投递，需要时使用Start并在send中同步处理事务。
+//溢出到磁盘的事务在放入chan之前会读取全部语句，SetTransactionMemoryLimit对合并的事务不起作用
+func (g *StreamerGroup) StartMerged(ctx context.Context, size int) (<-chan *Transaction, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	transactions := make(chan *Transaction, size)
+	err := g.start(ctx, cancel, func(t *Transaction) error {
+		//send返回后溢出文件会被删除
+		t, err := t.load()
+		if err != nil {
+			return err
+		}
+		select {
+		case transactions <- t:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		g.wg.Wait()
+		close(transactions)
+	}()
+	return transactions, nil
+}
+
+func (g *StreamerGroup) start(ctx context.Context, cancel context.CancelFunc, send SendTransactionFunc) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.started {
+		cancel()
+		return errGroupStarted
+	}
+	for _, src := range g.sources {
+		if src.send == nil && send == nil {
+			cancel()
+			return newError(fmt.Errorf("source %v has no send function", src.name)).msgf("start fail.")
+		}
+	}
+
+	g.started = true
+	g.cancel = cancel
+	for _, src := range g.sources {
+		target := src.send
+		if target == nil {
+			target = send
+		}
+		g.wg.Add(1)
+		go g.run(ctx, src, target)
+	}
+	return nil
+}
+
+//run 持续dump一个来源，出错后重连，直到ctx被取消或者连续出错的次数超过上限
+func (g *StreamerGroup) run(ctx context.Context, src *groupSource, send SendTransactionFunc) {
+	defer g.wg.Done()
+	deliver := func(t *Transaction) error {
+		if err := send(t); err != nil {
+			return err
+		}
+		src.delivered()
+		return nil
+	}
+	for {
+		src.setState(SourceRunning)
+		err := src.stream(ctx, deliver)
+		if ctx.Err() != nil {
+			src.setState(SourceStopped)
+			return
+		}
+		if err == nil {
+			err = errSourceStreamEOF
+		}
+		retries := src.fail(err)
+		_log.Errorf("StreamerGroup source %v stream fail %d times in pos: %+v. err: %v",
+			src.name, retries, src.streamer.binlogPosition(), err)
+		if g.maxRetries > 0 && retries > g.maxRetries {
+			src.setState(SourceFailed)
+			return
+		}
+
+		src.setState(SourceRetrying)
+		select {
+		case <-ctx.Done():
+			src.setState(SourceStopped)
+			return
+		case <-time.After(g.retryInterval):
+		}
+	}
+}
+
+//Stop 停止所有来源并等待它们的goroutine退出
+func (g *StreamerGroup) Stop() {
+	g.mu.Lock()
+	cancel := g.cancel
+	g.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	g.wg.Wait()
+}
+
+//Wait 等待所有来源停止，如所有来源都因为出错而不再重连
+func (g *StreamerGroup) Wait() {
+	g.wg.Wait()
+}
+
+//Status 返回所有来源的健康状态，顺序与Add的顺序相同
+func (g *StreamerGroup) Status() []SourceStatus {
+	g.mu.Lock()
+	sources := g.sources
+	g.mu.Unlock()
+
+	status := make([]SourceStatus, 0, len(sources))
+	for _, src := range sources {
+		stats := src.streamer.Stats()
+		src.mu.Lock()
+		status = append(status, SourceStatus{
+			Name:     src.name,
+			State:    src.state,
+			Position: stats.Position,
+			Retries:  src.retries,
+			Err:      src.err,
+			Stats:    stats,
+		})
+		src.mu.Unlock()
+	}
+	return status
+}
+
+//Healthy 所有来源是否都在正常dump binlog
+func (g *StreamerGroup) Healthy() bool {
+	for _, s := range g.Status() {
+		if s.State != SourceRunning {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *groupSource) setState(state SourceState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+//fail 记录一次出错，返回连续出错的次数
+func (s *groupSource) fail(err error) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries++
+	s.err = err
+	return s.retries
+}
+
+//delivered 成功处理事务后清零连续出错的次数
+func (s *groupSource) delivered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries = 0
+}
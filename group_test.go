@@ -0,0 +1,176 @@
+package gobinlog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestStreamerGroup(t *testing.T) {
+	g := NewStreamerGroup()
+	g.SetRetryInterval(time.Millisecond)
+	g.SetMaxRetries(2)
+
+	for _, name := range []string{"shard-01", "shard-02"} {
+		if err := g.Add(StreamerSource{
+			Name:        name,
+			DSN:         testDSN,
+			ServerID:    testServerID,
+			Position:    testBinlogPosParseEvents,
+			TableMapper: newMockMapper(),
+		}); err != nil {
+			t.Fatalf("Add %v fail. err: %v", name, err)
+		}
+	}
+	if err := g.Add(StreamerSource{Name: "shard-01"}); err == nil {
+		t.Fatalf("Add duplicate source want error")
+	}
+
+	//shard-01解析测试数据后等待停止，事务溢出到磁盘，shard-02每次都连接失败
+	dir, clean := testSpillDir(t)
+	defer clean()
+	st := g.Streamer("shard-01")
+	st.SetTransactionMemoryLimit(1)
+	st.SetSpillDir(dir)
+	g.sources[0].stream = func(ctx context.Context, send SendTransactionFunc) error {
+		st.sendTransaction = ChainMiddlewares(send, st.middlewares...)
+		events := make(chan replication.BinlogEvent)
+		go func() {
+			for _, ev := range getInputData() {
+				events <- ev
+			}
+			close(events)
+		}()
+		if _, err := st.parseEvents(ctx, events); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	}
+	g.sources[1].stream = func(ctx context.Context, send SendTransactionFunc) error {
+		return fmt.Errorf("connection refused")
+	}
+
+	transactions, err := g.StartMerged(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("StartMerged fail. err: %v", err)
+	}
+	if err = g.Start(context.Background(), func(*Transaction) error { return nil }); err == nil {
+		t.Fatalf("Start twice want error")
+	}
+
+	tran := <-transactions
+	if tran.Spilled() {
+		t.Fatalf("merged transaction should not be spilled")
+	}
+	if tran.Source != "shard-01" || len(tran.Events) != 3 || tran.NextPosition.Offset != 4 {
+		t.Fatalf("transaction want: shard-01 3 4 out: %v %v %v", tran.Source, len(tran.Events),
+			tran.NextPosition.Offset)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for g.Status()[1].State != SourceFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("shard-02 want failed out: %+v", g.Status()[1])
+		}
+		time.Sleep(time.Millisecond)
+	}
+	status := g.Status()
+	if status[0].State != SourceRunning || status[0].Position.Offset != 4 || status[0].Retries != 0 {
+		t.Fatalf("shard-01 want: running 4 0 out: %v %v %v", status[0].State, status[0].Position.Offset,
+			status[0].Retries)
+	}
+	if status[1].Retries != 3 || status[1].Err == nil || status[1].Err.Error() != "connection refused" {
+		t.Fatalf("shard-02 want: 3 connection refused out: %v %v", status[1].Retries, status[1].Err)
+	}
+	if g.Healthy() {
+		t.Fatalf("Healthy want: false")
+	}
+
+	g.Stop()
+	if _, ok := <-transactions; ok {
+		t.Fatalf("transactions want closed")
+	}
+	if status = g.Status(); status[0].State != SourceStopped || status[1].State != SourceFailed {
+		t.Fatalf("state want: stopped failed out: %v %v", status[0].State, status[1].State)
+	}
+}
+
+//testDumpPosConn 记录COM_BINLOG_DUMP中的binlog位置的testBinlogConn
+type testDumpPosConn struct {
+	testBinlogConn
+	dumps chan<- Position
+}
+
+func (c *testDumpPosConn) NoticeDump(_ uint32, offset uint32, filename string, _ uint16) error {
+	c.dumps <- Position{Filename: filename, Offset: int64(offset)}
+	return nil
+}
+
+func TestStreamerGroup_redeliver(t *testing.T) {
+	const sid = "00010203-0405-0607-0809-0a0b0c0d0e0f"
+	for i, failover := range []bool{false, true} {
+		g := NewStreamerGroup()
+		g.SetRetryInterval(time.Millisecond)
+		if err := g.Add(StreamerSource{
+			Name:        "shard-01",
+			DSN:         testDSN,
+			ServerID:    testServerID,
+			Position:    testBinlogPosParseEvents,
+			TableMapper: newMockMapper(),
+		}); err != nil {
+			t.Fatalf("%v Add fail. err: %v", i, err)
+		}
+		st := g.Streamer("shard-01")
+		if failover {
+			st.SetFailoverDSNs(testDSN)
+			if err := st.SetGTIDSet(sid + ":1-4"); err != nil {
+				t.Fatalf("%v SetGTIDSet err: %v", i, err)
+			}
+		}
+		dumps := make(chan Position, 10)
+		st.dial = func(ctx context.Context, dsn string) (dumpConn, error) {
+			return &testDumpPosConn{testBinlogConn: testBinlogConn{events: getInputData()}, dumps: dumps}, nil
+		}
+
+		//第一次投递失败，重连后需要从同一个位置重新投递该事务
+		var mu sync.Mutex
+		var out []*Transaction
+		done := make(chan struct{})
+		err := g.Start(context.Background(), func(tran *Transaction) error {
+			mu.Lock()
+			defer mu.Unlock()
+			out = append(out, tran)
+			switch len(out) {
+			case 1:
+				return fmt.Errorf("send fail")
+			case 2:
+				close(done)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v Start fail. err: %v", i, err)
+		}
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v transaction is not redelivered", i)
+		}
+		g.Stop()
+
+		first, second := <-dumps, <-dumps
+		if first != second {
+			t.Fatalf("%v dump position want: %+v out: %+v", i, first, second)
+		}
+		if out[0].NowPosition != out[1].NowPosition || out[0].NextPosition != out[1].NextPosition ||
+			len(out[1].Events) != 3 {
+			t.Fatalf("%v redelivered transaction want: %+v %+v out: %+v %+v %v", i, out[0].NowPosition,
+				out[0].NextPosition, out[1].NowPosition, out[1].NextPosition, len(out[1].Events))
+		}
+	}
+}
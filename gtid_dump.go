@@ -0,0 +1,192 @@
+package gobinlog
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Breeze0806/mysql"
+)
+
+//COM_BINLOG_DUMP_GTID相关的常量
+const (
+	comBinlogDump     byte = 0x12      //COM_BINLOG_DUMP
+	comBinlogDumpGTID byte = 0x1e      //COM_BINLOG_DUMP_GTID
+	maxPacketPayload       = 1<<24 - 1 //单个mysql包的最大长度
+	gtidDumpNetPrefix      = "gobinlog-gtid-"
+)
+
+var (
+	gtidDumpNetLock sync.Mutex
+	gtidDumpNets    = make(map[string]bool) //已经注册的网络
+
+	errGTIDDumpRewrite = errors.New("unexpected packet when sending COM_BINLOG_DUMP_GTID")
+
+	_ gtidDumpConn = (*mysqlDumpConn)(nil)
+)
+
+//gtidDumpConnKey 通过context把gtidDialer传给注册的网络
+type gtidDumpConnKey struct{}
+
+//gtidDialer 连接数据库时建立的网络连接
+type gtidDialer struct {
+	net     string
+	timeout time.Duration
+	conn    *gtidNetConn
+}
+
+//dial 使用原有的网络连接数据库，同mysql库一样开启tcp keepalive
+func (d *gtidDialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	nd := net.Dialer{Timeout: d.timeout}
+	c, err := nd.DialContext(ctx, d.net, addr)
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := c.(*net.TCPConn); ok {
+		if err = tc.SetKeepAlive(true); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	d.conn = &gtidNetConn{Conn: c}
+	return d.conn, nil
+}
+
+//gtidNetConn github.com/Breeze0806/mysql只能发送COM_BINLOG_DUMP，
+//设置dump后把下一个COM_BINLOG_DUMP包替换为COM_BINLOG_DUMP_GTID包，保留包的序号
+type gtidNetConn struct {
+	net.Conn
+	mu   sync.Mutex
+	dump []byte //COM_BINLOG_DUMP_GTID包的内容，不包含包头
+}
+
+func (c *gtidNetConn) setDump(dump []byte) {
+	c.mu.Lock()
+	c.dump = dump
+	c.mu.Unlock()
+}
+
+//Write 写入mysql包，mysql库一次写入整个包，替换时返回原有包的长度
+func (c *gtidNetConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	dump := c.dump
+	c.dump = nil
+	c.mu.Unlock()
+	if dump == nil {
+		return c.Conn.Write(b)
+	}
+
+	if len(b) < 5 || b[4] != comBinlogDump {
+		return 0, errGTIDDumpRewrite
+	}
+	packet := make([]byte, 4+len(dump))
+	packet[0] = byte(len(dump))
+	packet[1] = byte(len(dump) >> 8)
+	packet[2] = byte(len(dump) >> 16)
+	packet[3] = b[3]
+	copy(packet[4:], dump)
+	if _, err := c.Conn.Write(packet); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+//mysqlDumpConn 在github.com/Breeze0806/mysql的DumpConn的基础上支持COM_BINLOG_DUMP_GTID
+type mysqlDumpConn struct {
+	*mysql.DumpConn
+	net *gtidNetConn //为nil时不支持COM_BINLOG_DUMP_GTID
+}
+
+//newMysqlDumpConn 连接数据库，使用tls或者自定义网络的连接无法替换dump包，不支持COM_BINLOG_DUMP_GTID
+func newMysqlDumpConn(ctx context.Context, dsn string) (*mysqlDumpConn, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cfg.TLSConfig != "" && cfg.TLSConfig != "false":
+	case cfg.Net == "tcp" || cfg.Net == "tcp4" || cfg.Net == "tcp6" || cfg.Net == "unix":
+		d := &gtidDialer{
+			net:     cfg.Net,
+			timeout: cfg.Timeout,
+		}
+		registerGTIDDumpNet(cfg.Net)
+		cfg.Net = gtidDumpNetPrefix + cfg.Net
+		dc, err := mysql.NewDumpConn(cfg.FormatDSN(), context.WithValue(ctx, gtidDumpConnKey{}, d))
+		if err != nil {
+			return nil, err
+		}
+		return &mysqlDumpConn{
+			DumpConn: dc,
+			net:      d.conn,
+		}, nil
+	}
+
+	dc, err := mysql.NewDumpConn(dsn, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlDumpConn{
+		DumpConn: dc,
+	}, nil
+}
+
+//registerGTIDDumpNet 注册通过gtidDialer连接数据库的网络
+func registerGTIDDumpNet(network string) {
+	gtidDumpNetLock.Lock()
+	defer gtidDumpNetLock.Unlock()
+	if gtidDumpNets[network] {
+		return
+	}
+	mysql.RegisterDialContext(gtidDumpNetPrefix+network, func(ctx context.Context, addr string) (net.Conn, error) {
+		d, ok := ctx.Value(gtidDumpConnKey{}).(*gtidDialer)
+		if !ok {
+			return nil, fmt.Errorf("network %v is only used by gobinlog", gtidDumpNetPrefix+network)
+		}
+		return d.dial(ctx, addr)
+	})
+	gtidDumpNets[network] = true
+}
+
+//NoticeDumpGTID 发送COM_BINLOG_DUMP_GTID，flags包含binlogThroughGTID时发送sidBlock
+func (c *mysqlDumpConn) NoticeDumpGTID(serverID uint32, flags uint16, sidBlock []byte) error {
+	if c.net == nil {
+		return errGTIDDumpUnsupported
+	}
+	dump := gtidDumpPayload(serverID, flags, sidBlock)
+	if len(dump) >= maxPacketPayload {
+		return fmt.Errorf("COM_BINLOG_DUMP_GTID is too large: %v bytes", len(dump))
+	}
+	c.net.setDump(dump)
+	defer c.net.setDump(nil)
+	return c.NoticeDump(serverID, 4, "", 0)
+}
+
+//gtidDumpPayload COM_BINLOG_DUMP_GTID包的内容，不指定binlog文件，从第一个没有执行的GTID开始
+func gtidDumpPayload(serverID uint32, flags uint16, sidBlock []byte) []byte {
+	length := 1 + //COM_BINLOG_DUMP_GTID
+		2 + //flags
+		4 + //server-id
+		4 + //binlog-filename-len
+		8 //binlog-pos
+	if flags&binlogThroughGTID != 0 {
+		length += 4 + len(sidBlock) //data-size以及data
+	}
+
+	data := make([]byte, length)
+	data[0] = comBinlogDumpGTID
+	binary.LittleEndian.PutUint16(data[1:], flags)
+	binary.LittleEndian.PutUint32(data[3:], serverID)
+	binary.LittleEndian.PutUint32(data[7:], 0)
+	binary.LittleEndian.PutUint64(data[11:], 4)
+	if flags&binlogThroughGTID != 0 {
+		binary.LittleEndian.PutUint32(data[19:], uint32(len(sidBlock)))
+		copy(data[23:], sidBlock)
+	}
+	return data
+}
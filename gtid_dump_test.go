@@ -0,0 +1,215 @@
+package gobinlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+//testMysqlServer 只处理握手、COM_QUERY以及一个dump包的mysql服务端，收到的dump包通过dumps传出
+type testMysqlServer struct {
+	listener net.Listener
+	dumps    chan []byte
+	errs     chan error
+}
+
+func newTestMysqlServer(t *testing.T) *testMysqlServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fail. err: %v", err)
+	}
+	s := &testMysqlServer{
+		listener: l,
+		dumps:    make(chan []byte, 1),
+		errs:     make(chan error, 1),
+	}
+	go func() {
+		s.errs <- s.serve()
+	}()
+	return s
+}
+
+func (s *testMysqlServer) close() {
+	s.listener.Close()
+}
+
+func (s *testMysqlServer) serve() error {
+	c, err := s.listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	//握手，不校验密码
+	handshake := []byte{10}
+	handshake = append(handshake, "5.7.30\x00"...)
+	handshake = append(handshake, 1, 0, 0, 0)
+	handshake = append(handshake, "abcdefgh"...)
+	handshake = append(handshake, 0, 0x01, 0x82, 33, 0x02, 0x00, 0x08, 0x00, 21)
+	handshake = append(handshake, make([]byte, 10)...)
+	handshake = append(handshake, "ijklmnopqrst\x00"...)
+	handshake = append(handshake, "mysql_native_password\x00"...)
+	ok := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	if err = writeTestPacket(c, 0, handshake); err != nil {
+		return err
+	}
+	if _, _, err = readTestPacket(c); err != nil {
+		return err
+	}
+	if err = writeTestPacket(c, 2, ok); err != nil {
+		return err
+	}
+
+	for {
+		seq, data, err := readTestPacket(c)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 || data[0] == 0x03 {
+			if err = writeTestPacket(c, seq+1, ok); err != nil {
+				return err
+			}
+			continue
+		}
+		if seq != 0 {
+			return fmt.Errorf("dump sequence %v", seq)
+		}
+		s.dumps <- data
+		return writeTestPacket(c, seq+1, []byte{0xfe, 0x00, 0x00, 0x02, 0x00})
+	}
+}
+
+func writeTestPacket(w io.Writer, seq byte, data []byte) error {
+	_, err := w.Write(append([]byte{byte(len(data)), byte(len(data) >> 8), byte(len(data) >> 16), seq}, data...))
+	return err
+}
+
+func readTestPacket(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[3], data, nil
+}
+
+func TestStreamer_dialGTIDDumpConn(t *testing.T) {
+	server := newTestMysqlServer(t)
+	defer server.close()
+
+	s, err := NewStreamer(fmt.Sprintf("root@tcp(%v)/", server.listener.Addr()), 1234, nil)
+	if err != nil {
+		t.Fatalf("NewStreamer fail. err: %v", err)
+	}
+	dc, err := s.dial(context.Background(), s.dsn)
+	if err != nil {
+		t.Fatalf("dial fail. err: %v", err)
+	}
+	defer dc.Close()
+	if _, ok := dc.(gtidDumpConn); !ok {
+		t.Fatalf("want != out dial type: %T is not gtidDumpConn", dc)
+	}
+}
+
+func Test_mysqlDumpConn_NoticeDumpGTID(t *testing.T) {
+	sidBlock := []byte{1, 0, 0, 0, 0, 0, 0, 0, 9, 8, 7}
+	testCases := []struct {
+		dsn  string
+		want []byte
+		err  error
+	}{
+		{
+			dsn:  "root@tcp(%v)/?timeout=5s",
+			want: gtidDumpPayload(1234, binlogThroughGTID, sidBlock),
+		},
+		{
+			dsn: "root@tcp(%v)/?tls=preferred",
+			err: errGTIDDumpUnsupported,
+		},
+	}
+
+	for _, v := range testCases {
+		func() {
+			server := newTestMysqlServer(t)
+			defer server.close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			dc, err := newMysqlDumpConn(ctx, fmt.Sprintf(v.dsn, server.listener.Addr()))
+			if err != nil {
+				t.Fatalf("newMysqlDumpConn fail. err: %v", err)
+			}
+			defer dc.Close()
+			if v.err != nil {
+				//服务端不支持tls，preferred时使用明文连接，但是无法替换dump包
+				if err = dc.NoticeDumpGTID(1234, binlogThroughGTID, sidBlock); err != v.err {
+					t.Fatalf("want != out err want: %v out: %v", v.err, err)
+				}
+				return
+			}
+
+			if err = dc.Exec("SET @master_binlog_checksum=@@global.binlog_checksum"); err != nil {
+				t.Fatalf("Exec fail. err: %v", err)
+			}
+			if err = dc.NoticeDumpGTID(1234, binlogThroughGTID, sidBlock); err != nil {
+				t.Fatalf("NoticeDumpGTID fail. err: %v", err)
+			}
+			select {
+			case out := <-server.dumps:
+				if !bytes.Equal(out, v.want) {
+					t.Fatalf("want != out dump want: %v out: %v", v.want, out)
+				}
+			case err = <-server.errs:
+				t.Fatalf("server fail. err: %v", err)
+			}
+
+			//包的序号保持连续，可以继续读取
+			data, err := dc.ReadPacket()
+			if err != nil {
+				t.Fatalf("ReadPacket fail. err: %v", err)
+			}
+			if data[0] != 0xfe {
+				t.Fatalf("want != out packet: %v", data)
+			}
+		}()
+	}
+}
+
+func Test_gtidDumpPayload(t *testing.T) {
+	testCases := []struct {
+		flags    uint16
+		sidBlock []byte
+		want     []byte
+	}{
+		{
+			flags:    binlogThroughGTID,
+			sidBlock: []byte{1, 2},
+			want: []byte{0x1e, 0x04, 0x00, 0xd2, 0x04, 0x00, 0x00, 0, 0, 0, 0,
+				4, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 1, 2},
+		},
+		{
+			flags:    0,
+			sidBlock: []byte{1, 2},
+			want: []byte{0x1e, 0x00, 0x00, 0xd2, 0x04, 0x00, 0x00, 0, 0, 0, 0,
+				4, 0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	for _, v := range testCases {
+		out := gtidDumpPayload(1234, v.flags, v.sidBlock)
+		if !bytes.Equal(out, v.want) {
+			t.Fatalf("want != out want: %v out: %v", v.want, out)
+		}
+		if binary.LittleEndian.Uint32(out[3:]) != 1234 {
+			t.Fatalf("want != out server id: %v", out[3:7])
+		}
+	}
+}
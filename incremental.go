@@ -0,0 +1,53 @@
+package gobinlog
+
+import "context"
+
+//defaultIncrementalBatchSize 增量投递时每批默认的语句数
+const defaultIncrementalBatchSize = 100
+
+//IncrementalHandler 增量投递事务的回调，通过Streamer.StreamIncremental注册，
+//每个事务依次调用Begin，零次或者多次Events，最后调用Commit或者Rollback，
+//语句在解析后按批交给Events，不会在内存中缓存整个事务，所以大事务不会占用大量内存，
+//回调参数中的事务只包含事务的信息，Events为空，只有Commit以及Rollback中的NextPosition有效，
+//保存检查点时只能使用Commit以及Rollback中的NextPosition，
+//Rollback中的NextPosition为空时表示该事务被丢弃(如binlog中出现了嵌套的BEGIN)，
+//任意回调返回错误时StreamIncremental会停止dump以及解析binlog且返回错误
+type IncrementalHandler interface {
+	Begin(t *Transaction) error
+	Events(t *Transaction, events []*StreamEvent) error
+	Commit(t *Transaction) error
+	Rollback(t *Transaction) error
+}
+
+//SetIncrementalBatchSize 设置增量投递时每批的最大语句数，默认为100，小于等于0时使用默认值
+func (s *Streamer) SetIncrementalBatchSize(n int) {
+	s.batchSize = n
+}
+
+func (s *Streamer) incrementalBatchSize() int {
+	if s.batchSize <= 0 {
+		return defaultIncrementalBatchSize
+	}
+	return s.batchSize
+}
+
+//StreamIncremental 与Stream相同，但是事务中的语句按批增量交给h，
+//Use注册的中间件作用于每一批语句
+func (s *Streamer) StreamIncremental(ctx context.Context, h IncrementalHandler) error {
+	s.sendTransaction = nil
+	s.incremental = h
+	return s.stream(ctx)
+}
+
+//applyMiddlewares 让一批语句经过Use注册的中间件，返回中间件输出的所有语句
+func (s *Streamer) applyMiddlewares(t *Transaction) ([]*StreamEvent, error) {
+	if len(s.middlewares) == 0 {
+		return t.Events, nil
+	}
+	var events []*StreamEvent
+	err := ChainMiddlewares(func(t *Transaction) error {
+		events = append(events, t.Events...)
+		return nil
+	}, s.middlewares...)(t)
+	return events, err
+}
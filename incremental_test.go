@@ -0,0 +1,116 @@
+package gobinlog
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//recordHandler 记录IncrementalHandler的调用
+type recordHandler struct {
+	calls []string
+	last  *Transaction
+}
+
+func (r *recordHandler) Begin(t *Transaction) error {
+	r.calls = append(r.calls, fmt.Sprintf("begin %v", len(t.Events)))
+	return nil
+}
+
+func (r *recordHandler) Events(t *Transaction, events []*StreamEvent) error {
+	call := "events"
+	for _, s := range events {
+		call += " " + s.Type.String()
+	}
+	r.calls = append(r.calls, call)
+	return nil
+}
+
+func (r *recordHandler) Commit(t *Transaction) error {
+	r.calls = append(r.calls, fmt.Sprintf("commit %v %v", t.NextPosition.Offset, len(t.Events)))
+	r.last = t
+	return nil
+}
+
+func (r *recordHandler) Rollback(t *Transaction) error {
+	r.calls = append(r.calls, fmt.Sprintf("rollback %v", t.NextPosition.IsZero()))
+	r.last = t
+	return nil
+}
+
+func TestStreamer_parseEventsIncremental(t *testing.T) {
+	f := replication.NewMySQL56BinlogFormat()
+	fs := replication.NewFakeBinlogStream()
+	fs.ServerID = 62344
+	input := getInputData()
+	rollback := append(append([]replication.BinlogEvent(nil), input[:len(input)-1]...),
+		replication.NewQueryEvent(f, fs, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "ROLLBACK"}))
+
+	testCases := []struct {
+		input       []replication.BinlogEvent
+		batchSize   int
+		middlewares []TransactionMiddleware
+		want        []string
+	}{
+		{
+			input: input,
+			want:  []string{"begin 0", "events insert update delete", "commit 4 0"},
+		},
+		{
+			input:     input,
+			batchSize: 2,
+			want:      []string{"begin 0", "events insert update", "events delete", "commit 4 0"},
+		},
+		{
+			input:       input,
+			batchSize:   2,
+			middlewares: []TransactionMiddleware{FilterStatements(StatementUpdate)},
+			want:        []string{"begin 0", "events update", "commit 4 0"},
+		},
+		{
+			input:     rollback,
+			batchSize: 1,
+			want:      []string{"begin 0", "events insert", "events update", "events delete", "rollback false"},
+		},
+	}
+
+	for i, v := range testCases {
+		s, err := NewStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("%v NewStreamer err: %v", i, err)
+		}
+		s.SetBinlogPosition(testBinlogPosParseEvents)
+		s.SetIncrementalBatchSize(v.batchSize)
+		s.Use(v.middlewares...)
+		h := &recordHandler{}
+		s.incremental = h
+
+		events := make(chan replication.BinlogEvent)
+		go func(input []replication.BinlogEvent) {
+			for i := range input {
+				events <- input[i]
+			}
+			close(events)
+		}(v.input)
+
+		if _, pErr := s.parseEvents(context.Background(), events); pErr != nil {
+			t.Fatalf("%v parseEvents err != %v, err: %v", i, nil, pErr)
+		}
+		if !reflect.DeepEqual(h.calls, v.want) {
+			t.Fatalf("%v want != out\nwant: %q\nout:  %q", i, v.want, h.calls)
+		}
+		if h.last.NowPosition != testBinlogPosParseEvents ||
+			h.last.NextPosition.Filename != testBinlogPosParseEvents.Filename {
+			t.Fatalf("%v position want: %v out: %v %v", i, testBinlogPosParseEvents,
+				h.last.NowPosition, h.last.NextPosition)
+		}
+		if stats := s.Stats(); stats.Transactions != 1 || stats.Position != h.last.NextPosition {
+			t.Fatalf("%v stats want: 1 %v out: %v %v", i, h.last.NextPosition, stats.Transactions, stats.Position)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package gobinlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//jsonTimeLocalLayout time.Time.String()的格式，即JSONTimeLocal的输出
+const jsonTimeLocalLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+var jsonDiffOperations = map[string]replication.JSONDiffOperation{
+	replication.JSONDiffReplace.String(): replication.JSONDiffReplace,
+	replication.JSONDiffInsert.String():  replication.JSONDiffInsert,
+	replication.JSONDiffRemove.String():  replication.JSONDiffRemove,
+}
+
+type transactionJSON struct {
+	NowPosition    Position        `json:"nowPosition"`
+	NextPosition   Position        `json:"nextPosition"`
+	Timestamp      json.RawMessage `json:"timestamp"`
+	GTID           string          `json:"gtid"`
+	LastCommitted  int64           `json:"lastCommitted"`
+	SequenceNumber int64           `json:"sequenceNumber"`
+	ServerID       uint32          `json:"serverID"`
+	XID            uint64          `json:"xid"`
+	Source         string          `json:"source"`
+	Events         []*StreamEvent  `json:"events"`
+}
+
+type streamEventJSON struct {
+	Name          MysqlTableName  `json:"name"`
+	Type          string          `json:"type"`
+	Timestamp     json.RawMessage `json:"timestamp"`
+	Database      string          `json:"database"`
+	SQL           string          `json:"sql"`
+	RowValues     []*RowData      `json:"rowValues"`
+	RowIdentifies []*RowData      `json:"rowIdentifies"`
+}
+
+type columnDataJSON struct {
+	Filed        string          `json:"filed"`
+	Field        string          `json:"field"`
+	Type         string          `json:"type"`
+	IsEmpty      bool            `json:"isEmpty"`
+	IsPrimaryKey bool            `json:"isPrimaryKey"`
+	Charset      string          `json:"charset"`
+	JSONDiffs    []jsonDiffJSON  `json:"jsonDiffs"`
+	Encoding     string          `json:"encoding"`
+	Data         json.RawMessage `json:"data"`
+}
+
+type jsonDiffJSON struct {
+	Op    string  `json:"op"`
+	Path  string  `json:"path"`
+	Value *string `json:"value"`
+}
+
+//decodeTransaction 解码JSONEncoder输出的事务
+func decodeTransaction(data []byte, t *Transaction) error {
+	var v transactionJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	ts, err := decodeJSONTimestamp(v.Timestamp)
+	if err != nil {
+		return err
+	}
+	*t = Transaction{
+		NowPosition:    v.NowPosition,
+		NextPosition:   v.NextPosition,
+		Timestamp:      ts,
+		GTID:           v.GTID,
+		LastCommitted:  v.LastCommitted,
+		SequenceNumber: v.SequenceNumber,
+		ServerID:       v.ServerID,
+		XID:            v.XID,
+		Source:         v.Source,
+		Events:         v.Events,
+	}
+	return nil
+}
+
+//decodeStreamEvent 解码JSONEncoder输出的语句
+func decodeStreamEvent(data []byte, s *StreamEvent) error {
+	var v streamEventJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	typ, err := parseStatementType(v.Type)
+	if err != nil {
+		return err
+	}
+	ts, err := decodeJSONTimestamp(v.Timestamp)
+	if err != nil {
+		return err
+	}
+	*s = StreamEvent{
+		Type:          typ,
+		Table:         v.Name,
+		Query:         replication.Query{Database: v.Database, SQL: v.SQL},
+		Timestamp:     ts,
+		RowValues:     v.RowValues,
+		RowIdentifies: v.RowIdentifies,
+	}
+	return nil
+}
+
+//decodeColumnData 解码JSONEncoder输出的列，JSONDiff只还原Operation，Path和Value
+func decodeColumnData(data []byte, c *ColumnData) error {
+	var v columnDataJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	typ, err := parseColumnType(v.Type)
+	if err != nil {
+		return err
+	}
+	field := v.Field
+	if field == "" {
+		field = v.Filed
+	}
+
+	var diffs []replication.JSONDiff
+	for _, d := range v.JSONDiffs {
+		op, ok := jsonDiffOperations[d.Op]
+		if !ok {
+			return newError(fmt.Errorf("unknown json diff operation %v", d.Op)).
+				msgf("column %v decode fail.", field)
+		}
+		diff := replication.JSONDiff{Operation: op, Path: d.Path}
+		if d.Value != nil {
+			diff.Value = []byte(*d.Value)
+		}
+		diffs = append(diffs, diff)
+	}
+
+	value, err := decodeJSONColumnValue(v.Data, v.Encoding)
+	if err != nil {
+		return newError(err).msgf("column %v decode fail.", field)
+	}
+	*c = ColumnData{
+		Filed:        field,
+		Type:         typ,
+		IsEmpty:      v.IsEmpty,
+		IsPrimaryKey: v.IsPrimaryKey,
+		Charset:      v.Charset,
+		Data:         value,
+		JSONDiffs:    diffs,
+	}
+	return nil
+}
+
+//decodeJSONColumnValue 解码列的数据，null为nil，数字以及GeoJSON等非字符串的值保留原本的json
+func decodeJSONColumnValue(data json.RawMessage, encoding string) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	if data[0] != '"' {
+		return append([]byte{}, data...), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	switch encoding {
+	case "":
+		return []byte(s), nil
+	case jsonBinaryEncodingNames[JSONBinaryBase64]:
+		return base64.StdEncoding.DecodeString(s)
+	case jsonBinaryEncodingNames[JSONBinaryHex]:
+		return hex.DecodeString(s)
+	}
+	return nil, fmt.Errorf("unknown binary encoding %v", encoding)
+}
+
+//decodeJSONTimestamp 解码执行时间，支持JSONTimeFormat的所有格式
+func decodeJSONTimestamp(data json.RawMessage) (int64, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return 0, nil
+	}
+	if data[0] != '"' {
+		var millis int64
+		if err := json.Unmarshal(data, &millis); err != nil {
+			return 0, newError(err).msgf("timestamp %s decode fail.", data)
+		}
+		return millis / 1000, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return 0, err
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if t, err = time.Parse(jsonTimeLocalLayout, s); err != nil {
+			return 0, newError(err).msgf("timestamp %v decode fail.", s)
+		}
+	}
+	return t.Unix(), nil
+}
+
+//parseStatementType 通过语句类型的信息获取语句类型
+func parseStatementType(s string) (StatementType, error) {
+	if s == StatementUnknown.String() {
+		return StatementUnknown, nil
+	}
+	if typ, ok := statementPrefixes[s]; ok {
+		return typ, nil
+	}
+	return StatementUnknown, newError(fmt.Errorf("unknown statement type %v", s))
+}
+
+//parseColumnType 通过列类型的信息获取列类型
+func parseColumnType(s string) (ColumnType, error) {
+	for typ, name := range columnTypeStrings {
+		if name == s {
+			return typ, nil
+		}
+	}
+	return 0, newError(fmt.Errorf("unknown column type %v", s))
+}
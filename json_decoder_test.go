@@ -0,0 +1,189 @@
+package gobinlog
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func testJSONDecoderTransaction() *Transaction {
+	return &Transaction{
+		NowPosition:    Position{Filename: "binlog.000001", Offset: 4},
+		NextPosition:   Position{Filename: "binlog.000001", Offset: 120},
+		Timestamp:      1407805592,
+		GTID:           "3e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+		LastCommitted:  1,
+		SequenceNumber: 2,
+		ServerID:       62344,
+		XID:            1<<63 + 1,
+		Source:         "shard-01",
+		Events: []*StreamEvent{
+			{
+				Type:      StatementAlter,
+				Timestamp: 1407805592,
+				Table:     MysqlTableName{DbName: "db", TableName: "t"},
+				Query:     replication.Query{Database: "db", SQL: "alter table t add column c int"},
+			},
+			{
+				Type:      StatementUpdate,
+				Timestamp: 1407805592,
+				Table:     MysqlTableName{DbName: "db", TableName: "t"},
+				RowIdentifies: []*RowData{
+					{
+						Columns: []*ColumnData{
+							{Filed: "id", Type: columnTypeLongLong, IsPrimaryKey: true, Data: []byte("18446744073709551615")},
+							{Filed: "price", Type: columnTypeNewDecimal, Data: []byte("-1.50")},
+							{Filed: "name", Type: columnTypeVarchar, Charset: "utf8mb4", Data: []byte("")},
+							{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: []byte{0x12, 0xff, 0x00}},
+							{Filed: "doc", Type: columnTypeJSON, Data: []byte(`{"a": 1}`)},
+						},
+					},
+				},
+				RowValues: []*RowData{
+					{
+						Columns: []*ColumnData{
+							{Filed: "id", Type: columnTypeLongLong, IsPrimaryKey: true, Data: []byte("18446744073709551615")},
+							{Filed: "price", Type: columnTypeNewDecimal, IsEmpty: true},
+							{Filed: "name", Type: columnTypeVarchar, Charset: "utf8mb4", Data: nil},
+							{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: []byte{0x12, 0xff, 0x00}},
+							{
+								Filed: "doc",
+								Type:  columnTypeJSON,
+								Data:  []byte(`{"a": 2}`),
+								JSONDiffs: []replication.JSONDiff{
+									{Operation: replication.JSONDiffReplace, Path: "$.a", Value: []byte("2")},
+									{Operation: replication.JSONDiffRemove, Path: "$.b"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestTransaction_UnmarshalJSON(t *testing.T) {
+	testCases := []func() *JSONEncoder{
+		func() *JSONEncoder {
+			e := NewJSONEncoder()
+			e.SetSchema(JSONSchemaV2)
+			e.SetBinaryEncoding(JSONBinaryBase64)
+			return e
+		},
+		func() *JSONEncoder {
+			e := NewJSONEncoder()
+			e.SetSchema(JSONSchemaV2)
+			e.SetTimeFormat(JSONTimeRFC3339)
+			e.SetBinaryEncoding(JSONBinaryHex)
+			e.SetNativeNumbers(true)
+			return e
+		},
+		func() *JSONEncoder {
+			e := NewJSONEncoder()
+			e.SetSchema("custom.v2")
+			e.SetTimeFormat(JSONTimeEpochMillis)
+			e.SetBinaryEncoding(JSONBinaryBase64)
+			return e
+		},
+	}
+	for i, encoder := range testCases {
+		want := testJSONDecoderTransaction()
+		data, err := encoder().EncodeTransaction(want)
+		if err != nil {
+			t.Fatalf("%v EncodeTransaction fail. err: %v", i, err)
+		}
+		out := &Transaction{}
+		if err = json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%v Unmarshal fail. err: %v", i, err)
+		}
+		if !reflect.DeepEqual(out, want) {
+			out, _ := json.Marshal(out)
+			t.Fatalf("%v want != out\njson: %s\nout:  %s", i, data, out)
+		}
+	}
+}
+
+func TestStreamEvent_UnmarshalJSON(t *testing.T) {
+	want := testJSONDecoderTransaction().Events[1]
+	e := NewJSONEncoder()
+	e.SetSchema(JSONSchemaV2)
+	e.SetBinaryEncoding(JSONBinaryBase64)
+	data, err := e.EncodeStreamEvent(want)
+	if err != nil {
+		t.Fatalf("EncodeStreamEvent fail. err: %v", err)
+	}
+	out := &StreamEvent{}
+	if err = json.Unmarshal(data, out); err != nil {
+		t.Fatalf("Unmarshal fail. err: %v", err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out want: %+v out: %+v", want, out)
+	}
+
+	if err = json.Unmarshal([]byte(`{"type":"select"}`), out); err == nil {
+		t.Fatalf("unknown statement type want error")
+	}
+	if err = json.Unmarshal([]byte(`{"type":"unknown","timestamp":"now"}`), out); err == nil {
+		t.Fatalf("invalid timestamp want error")
+	}
+}
+
+func TestColumnData_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  *ColumnData
+		err   bool
+	}{
+		{
+			input: `{"filed":"a","type":"Varchar","isEmpty":false,"data":""}`,
+			want:  &ColumnData{Filed: "a", Type: columnTypeVarchar, Data: []byte{}},
+		},
+		{
+			input: `{"field":"a","type":"Varchar","isEmpty":true,"data":null}`,
+			want:  &ColumnData{Filed: "a", Type: columnTypeVarchar, IsEmpty: true},
+		},
+		{
+			input: `{"field":"a","type":"Double","isEmpty":false,"data":1.5e+20}`,
+			want:  &ColumnData{Filed: "a", Type: columnTypeDouble, Data: []byte("1.5e+20")},
+		},
+		{
+			input: `{"field":"a","type":"Bit","isEmpty":false,"encoding":"hex","data":"0102"}`,
+			want:  &ColumnData{Filed: "a", Type: columnTypeBit, Data: []byte{1, 2}},
+		},
+		{
+			input: `{"field":"a","type":"Bit","isEmpty":false,"encoding":"base32","data":"AE"}`,
+			err:   true,
+		},
+		{
+			input: `{"field":"a","type":"Bit","isEmpty":false,"encoding":"hex","data":"0g"}`,
+			err:   true,
+		},
+		{
+			input: `{"field":"a","type":"Integer","isEmpty":false,"data":"1"}`,
+			err:   true,
+		},
+		{
+			input: `{"field":"a","type":"JSON","isEmpty":false,"jsonDiffs":[{"op":"merge","path":"$"}],"data":null}`,
+			err:   true,
+		},
+	}
+	for _, v := range testCases {
+		out := &ColumnData{}
+		err := json.Unmarshal([]byte(v.input), out)
+		if v.err {
+			if err == nil {
+				t.Fatalf("%v want error", v.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v Unmarshal fail. err: %v", v.input, err)
+		}
+		if !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("%v want != out want: %+v out: %+v", v.input, v.want, out)
+		}
+	}
+}
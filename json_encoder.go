@@ -0,0 +1,388 @@
+package gobinlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+)
+
+//JSONSchema json的结构名，带有版本号
+type JSONSchema string
+
+//json的结构名
+const (
+	JSONSchemaLegacy JSONSchema = ""            //兼容原有MarshalJSON的结构，列名的key为filed，不输出schema
+	JSONSchemaV1     JSONSchema = "gobinlog.v1" //列名的key为field，行的key为columns，并输出schema
+	JSONSchemaV2     JSONSchema = "gobinlog.v2" //在v1的基础上，二进制编码的列输出encoding，语句输出database
+)
+
+//JSONTimeFormat 事务以及语句执行时间的json格式
+type JSONTimeFormat int
+
+//执行时间的json格式
+const (
+	JSONTimeLocal       JSONTimeFormat = iota //本地时区的time.Time.String()，兼容原有MarshalJSON的输出
+	JSONTimeRFC3339                           //UTC时区的RFC3339字符串
+	JSONTimeEpochMillis                       //UTC的毫秒时间戳，json中为数字
+)
+
+//JSONBinaryEncoding 二进制列的json编码
+type JSONBinaryEncoding int
+
+//二进制列的json编码
+const (
+	JSONBinaryString JSONBinaryEncoding = iota //直接转为字符串，兼容原有MarshalJSON的输出
+	JSONBinaryBase64                           //标准base64编码
+	JSONBinaryHex                              //16进制编码
+)
+
+var jsonBinaryEncodingNames = map[JSONBinaryEncoding]string{
+	JSONBinaryBase64: "base64",
+	JSONBinaryHex:    "hex",
+}
+
+//JSONEncoder 可配置的json编码器，用于编码Transaction，StreamEvent以及ColumnData，
+//默认配置的输出与MarshalJSON相同
+type JSONEncoder struct {
+	schema           JSONSchema
+	timeFormat       JSONTimeFormat
+	binaryEncoding   JSONBinaryEncoding
+	nativeNumbers    bool
+	omitEmptyColumns bool
+	geometryFormat   GeometryFormat
+	jsonFormat       JSONFormat
+}
+
+//NewJSONEncoder 创建默认配置的JSONEncoder
+func NewJSONEncoder() *JSONEncoder {
+	return &JSONEncoder{}
+}
+
+//SetSchema 设置json的结构名，非JSONSchemaLegacy时输出schema，
+//也可以使用自定义的结构名，此时使用最新版本(v2)的结构
+func (e *JSONEncoder) SetSchema(schema JSONSchema) {
+	e.schema = schema
+}
+
+//SetTimeFormat 设置执行时间的json格式
+func (e *JSONEncoder) SetTimeFormat(format JSONTimeFormat) {
+	e.timeFormat = format
+}
+
+//SetBinaryEncoding 设置二进制列的json编码，二进制列包括bit列，binary字符集的列，
+//以及字符集未知且不是合法utf8的字符列，v2及之后的结构中编码后的列会输出encoding
+func (e *JSONEncoder) SetBinaryEncoding(encoding JSONBinaryEncoding) {
+	e.binaryEncoding = encoding
+}
+
+//SetNativeNumbers 设置整形，实数以及精确实数列是否输出为json的数字
+func (e *JSONEncoder) SetNativeNumbers(native bool) {
+	e.nativeNumbers = native
+}
+
+//SetOmitEmptyColumns 设置是否不输出没有变化(IsEmpty)的列
+func (e *JSONEncoder) SetOmitEmptyColumns(omit bool) {
+	e.omitEmptyColumns = omit
+}
+
+//SetGeometryFormat 设置几何列的输出格式，默认为GeometryFormatRaw
+func (e *JSONEncoder) SetGeometryFormat(format GeometryFormat) {
+	e.geometryFormat = format
+}
+
+//EncodeTransaction 编码事务
+func (e *JSONEncoder) EncodeTransaction(t *Transaction) ([]byte, error) {
+	o, err := e.transaction(t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(o)
+}
+
+//EncodeStreamEvent 编码语句
+func (e *JSONEncoder) EncodeStreamEvent(s *StreamEvent) ([]byte, error) {
+	o, err := e.streamEvent(s, true)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(o)
+}
+
+//EncodeColumnData 编码列
+func (e *JSONEncoder) EncodeColumnData(c *ColumnData) ([]byte, error) {
+	o, err := e.columnData(c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(o)
+}
+
+func (e *JSONEncoder) isLegacy() bool {
+	return e.schema == JSONSchemaLegacy
+}
+
+//isV1 是否使用v1及之前的结构，不输出v2中新增的encoding以及database
+func (e *JSONEncoder) isV1() bool {
+	return e.schema == JSONSchemaLegacy || e.schema == JSONSchemaV1
+}
+
+func (e *JSONEncoder) withSchema(o jsonObject) jsonObject {
+	if e.isLegacy() {
+		return o
+	}
+	return append(jsonObject{{"schema", string(e.schema)}}, o...)
+}
+
+func (e *JSONEncoder) timestamp(ts int64) interface{} {
+	t := time.Unix(ts, 0)
+	switch e.timeFormat {
+	case JSONTimeRFC3339:
+		return t.UTC().Format(time.RFC3339)
+	case JSONTimeEpochMillis:
+		return ts * 1000
+	}
+	return t.Local().String()
+}
+
+func (e *JSONEncoder) transaction(t *Transaction) (jsonObject, error) {
+	var events []interface{}
+	if t.Events != nil || t.spill != nil {
+		events = make([]interface{}, 0, len(t.Events))
+	}
+	err := t.RangeEvents(func(s *StreamEvent) error {
+		o, err := e.streamEvent(s, false)
+		if err != nil {
+			return err
+		}
+		events = append(events, o)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	o := jsonObject{
+		{"nowPosition", t.NowPosition},
+		{"nextPosition", t.NextPosition},
+		{"timestamp", e.timestamp(t.Timestamp)},
+	}
+	if t.GTID != "" {
+		o = append(o, jsonField{"gtid", t.GTID})
+	}
+	if t.LastCommitted != 0 {
+		o = append(o, jsonField{"lastCommitted", t.LastCommitted})
+	}
+	if t.SequenceNumber != 0 {
+		o = append(o, jsonField{"sequenceNumber", t.SequenceNumber})
+	}
+	if t.ServerID != 0 {
+		o = append(o, jsonField{"serverID", t.ServerID})
+	}
+	if t.XID != 0 {
+		o = append(o, jsonField{"xid", t.XID})
+	}
+	if t.Source != "" {
+		o = append(o, jsonField{"source", t.Source})
+	}
+	o = append(o, jsonField{"events", events})
+	return e.withSchema(o), nil
+}
+
+func (e *JSONEncoder) streamEvent(s *StreamEvent, toplevel bool) (jsonObject, error) {
+	o := jsonObject{
+		{"name", s.Table},
+		{"type", s.Type.String()},
+		{"timestamp", e.timestamp(s.Timestamp)},
+	}
+	if toplevel {
+		o = e.withSchema(o)
+	}
+	if s.Query.SQL != "" {
+		if !e.isV1() && s.Query.Database != "" {
+			o = append(o, jsonField{"database", s.Query.Database})
+		}
+		return append(o, jsonField{"sql", s.Query.SQL}), nil
+	}
+
+	values, err := e.rows(s.RowValues)
+	if err != nil {
+		return nil, err
+	}
+	identifies, err := e.rows(s.RowIdentifies)
+	if err != nil {
+		return nil, err
+	}
+	return append(o, jsonField{"rowValues", values}, jsonField{"rowIdentifies", identifies}), nil
+}
+
+func (e *JSONEncoder) rows(rows []*RowData) (interface{}, error) {
+	if rows == nil {
+		return nil, nil
+	}
+	key := "columns"
+	if e.isLegacy() {
+		key = "Columns"
+	}
+
+	result := make([]interface{}, 0, len(rows))
+	for _, r := range rows {
+		var columns []interface{}
+		if r.Columns != nil {
+			columns = make([]interface{}, 0, len(r.Columns))
+		}
+		for _, c := range r.Columns {
+			if e.omitEmptyColumns && c.IsEmpty {
+				continue
+			}
+			o, err := e.columnData(c)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, o)
+		}
+		result = append(result, jsonObject{{key, columns}})
+	}
+	return result, nil
+}
+
+func (e *JSONEncoder) columnData(c *ColumnData) (jsonObject, error) {
+	key := "field"
+	if e.isLegacy() {
+		key = "filed"
+	}
+	o := jsonObject{
+		{key, c.Filed},
+		{"type", c.Type.String()},
+		{"isEmpty", c.IsEmpty},
+	}
+	if c.IsPrimaryKey {
+		o = append(o, jsonField{"isPrimaryKey", true})
+	}
+	if c.Charset != "" {
+		o = append(o, jsonField{"charset", c.Charset})
+	}
+
+	var diffs []interface{}
+	for _, d := range c.JSONDiffs {
+		diff := jsonObject{
+			{"op", d.Operation.String()},
+			{"path", d.Path},
+		}
+		value, err := jsonDiffValue(d, e.jsonFormat)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			diff = append(diff, jsonField{"value", string(value)})
+		}
+		diffs = append(diffs, diff)
+	}
+	if diffs != nil {
+		o = append(o, jsonField{"jsonDiffs", diffs})
+	}
+
+	data, err := e.columnValue(c)
+	if err != nil {
+		return nil, err
+	}
+	if encoding, ok := e.binaryEncodingName(c); ok {
+		o = append(o, jsonField{"encoding", encoding})
+	}
+	return append(o, jsonField{"data", data}), nil
+}
+
+//binaryEncodingName 列的数据使用二进制编码时的编码名，用于UnmarshalJSON解码
+func (e *JSONEncoder) binaryEncodingName(c *ColumnData) (string, bool) {
+	if e.isV1() || c.Data == nil || e.binaryEncoding == JSONBinaryString || !c.isBinary() {
+		return "", false
+	}
+	if c.Type.IsGeometry() && e.geometryFormat != GeometryFormatRaw {
+		return "", false
+	}
+	if e.nativeNumbers && c.isNumber() && isJSONNumber(c.Data) {
+		return "", false
+	}
+	name, ok := jsonBinaryEncodingNames[e.binaryEncoding]
+	return name, ok
+}
+
+//columnValue 列的数据，按照配置输出为字符串，数字或者二进制编码
+func (e *JSONEncoder) columnValue(c *ColumnData) (interface{}, error) {
+	if c.Data == nil {
+		return nil, nil
+	}
+	if e.geometryFormat != GeometryFormatRaw && c.Type.IsGeometry() {
+		return c.geometryJSON(e.geometryFormat)
+	}
+	if e.nativeNumbers && c.isNumber() && isJSONNumber(c.Data) {
+		return json.Number(c.Data), nil
+	}
+	if e.binaryEncoding != JSONBinaryString && c.isBinary() {
+		switch e.binaryEncoding {
+		case JSONBinaryBase64:
+			return base64.StdEncoding.EncodeToString(c.Data), nil
+		case JSONBinaryHex:
+			return hex.EncodeToString(c.Data), nil
+		}
+	}
+	return string(c.Data), nil
+}
+
+//isNumber 是否是数字列
+func (c *ColumnData) isNumber() bool {
+	return c.Type.IsInteger() || c.Type.IsFloat() || c.Type.IsDecimal() || c.Type == columnTypeYear
+}
+
+//isBinary 是否是二进制列
+func (c *ColumnData) isBinary() bool {
+	switch {
+	case c.Type.IsBit(), c.Type.IsGeometry():
+		return true
+	case c.Type.IsBlob(), c.Type.IsString():
+		return c.Charset == charsetBinary || (c.Charset == "" && !utf8.Valid(c.Data))
+	}
+	return false
+}
+
+//isJSONNumber 是否是合法的json数字
+func isJSONNumber(b []byte) bool {
+	if len(b) == 0 || (b[0] != '-' && (b[0] < '0' || b[0] > '9')) ||
+		b[len(b)-1] < '0' || b[len(b)-1] > '9' {
+		return false
+	}
+	return json.Valid(b)
+}
+
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+//jsonObject 保持key顺序的json对象
+type jsonObject []jsonField
+
+//MarshalJSON 实现jsonObject的json序列化
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,189 @@
+package gobinlog
+
+import (
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestJSONEncoder_EncodeTransaction(t *testing.T) {
+	input := &Transaction{
+		NowPosition:  Position{Filename: "binlog.000001", Offset: 4},
+		NextPosition: Position{Filename: "binlog.000001", Offset: 120},
+		Timestamp:    1407805592,
+		Events: []*StreamEvent{
+			{
+				Type:      StatementUpdate,
+				Timestamp: 1407805592,
+				Table:     MysqlTableName{DbName: "db", TableName: "t"},
+				RowIdentifies: []*RowData{
+					{
+						Columns: []*ColumnData{
+							{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+							{Filed: "price", Type: columnTypeNewDecimal, Data: []byte("-1.50")},
+							{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: []byte{0x12, 0xff}},
+						},
+					},
+				},
+				RowValues: []*RowData{
+					{
+						Columns: []*ColumnData{
+							{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+							{Filed: "price", Type: columnTypeNewDecimal, IsEmpty: true},
+							{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: nil},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		encoder func() *JSONEncoder
+		want    string
+	}{
+		{
+			encoder: func() *JSONEncoder {
+				e := NewJSONEncoder()
+				e.SetSchema(JSONSchemaV2)
+				e.SetTimeFormat(JSONTimeRFC3339)
+				e.SetBinaryEncoding(JSONBinaryBase64)
+				e.SetNativeNumbers(true)
+				e.SetOmitEmptyColumns(true)
+				return e
+			},
+			want: `{"schema":"gobinlog.v2","nowPosition":{"filename":"binlog.000001","offset":4},` +
+				`"nextPosition":{"filename":"binlog.000001","offset":120},"timestamp":"2014-08-12T01:06:32Z",` +
+				`"events":[{"name":{"db":"db","table":"t"},"type":"update","timestamp":"2014-08-12T01:06:32Z",` +
+				`"rowValues":[{"columns":[{"field":"id","type":"Long","isEmpty":false,"isPrimaryKey":true,"data":1},` +
+				`{"field":"raw","type":"Blob","isEmpty":false,"charset":"binary","data":null}]}],` +
+				`"rowIdentifies":[{"columns":[{"field":"id","type":"Long","isEmpty":false,"isPrimaryKey":true,"data":1},` +
+				`{"field":"price","type":"NewDecimal","isEmpty":false,"data":-1.50},` +
+				`{"field":"raw","type":"Blob","isEmpty":false,"charset":"binary","encoding":"base64","data":"Ev8="}]}]}]}`,
+		},
+		{
+			encoder: func() *JSONEncoder {
+				e := NewJSONEncoder()
+				e.SetSchema("custom.v2")
+				e.SetTimeFormat(JSONTimeEpochMillis)
+				e.SetBinaryEncoding(JSONBinaryHex)
+				return e
+			},
+			want: `{"schema":"custom.v2","nowPosition":{"filename":"binlog.000001","offset":4},` +
+				`"nextPosition":{"filename":"binlog.000001","offset":120},"timestamp":1407805592000,` +
+				`"events":[{"name":{"db":"db","table":"t"},"type":"update","timestamp":1407805592000,` +
+				`"rowValues":[{"columns":[{"field":"id","type":"Long","isEmpty":false,"isPrimaryKey":true,"data":"1"},` +
+				`{"field":"price","type":"NewDecimal","isEmpty":true,"data":null},` +
+				`{"field":"raw","type":"Blob","isEmpty":false,"charset":"binary","data":null}]}],` +
+				`"rowIdentifies":[{"columns":[{"field":"id","type":"Long","isEmpty":false,"isPrimaryKey":true,"data":"1"},` +
+				`{"field":"price","type":"NewDecimal","isEmpty":false,"data":"-1.50"},` +
+				`{"field":"raw","type":"Blob","isEmpty":false,"charset":"binary","encoding":"hex","data":"12ff"}]}]}]}`,
+		},
+	}
+	for i, v := range testCases {
+		out, err := v.encoder().EncodeTransaction(input)
+		if err != nil {
+			t.Fatalf("%v EncodeTransaction fail. err: %v", i, err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("%v want != out\nwant: %v\nout:  %v", i, v.want, string(out))
+		}
+	}
+}
+
+func TestJSONEncoder_EncodeStreamEvent(t *testing.T) {
+	e := NewJSONEncoder()
+	e.SetSchema(JSONSchemaV1)
+	e.SetTimeFormat(JSONTimeEpochMillis)
+	input := &StreamEvent{
+		Type:      StatementInsert,
+		Timestamp: 1,
+		Table:     MysqlTableName{DbName: "db", TableName: "t"},
+		RowValues: []*RowData{{Columns: []*ColumnData{}}},
+	}
+	want := `{"schema":"gobinlog.v1","name":{"db":"db","table":"t"},"type":"insert","timestamp":1000,` +
+		`"rowValues":[{"columns":[]}],"rowIdentifies":null}`
+	out, err := e.EncodeStreamEvent(input)
+	if err != nil {
+		t.Fatalf("EncodeStreamEvent fail. err: %v", err)
+	}
+	if string(out) != want {
+		t.Fatalf("want != out\nwant: %v\nout:  %v", want, string(out))
+	}
+
+	input = &StreamEvent{
+		Type:      StatementCreate,
+		Timestamp: 1,
+		Table:     MysqlTableName{DbName: "db", TableName: "t"},
+		Query:     replication.Query{Database: "db", SQL: "create table t(id int)"},
+	}
+	want = `{"schema":"gobinlog.v1","name":{"db":"db","table":"t"},"type":"create","timestamp":1000,` +
+		`"sql":"create table t(id int)"}`
+	if out, err = e.EncodeStreamEvent(input); err != nil || string(out) != want {
+		t.Fatalf("v1 want != out\nwant: %v\nout:  %v err: %v", want, string(out), err)
+	}
+	e.SetSchema(JSONSchemaV2)
+	want = `{"schema":"gobinlog.v2","name":{"db":"db","table":"t"},"type":"create","timestamp":1000,` +
+		`"database":"db","sql":"create table t(id int)"}`
+	if out, err = e.EncodeStreamEvent(input); err != nil || string(out) != want {
+		t.Fatalf("v2 want != out\nwant: %v\nout:  %v err: %v", want, string(out), err)
+	}
+}
+
+func TestJSONEncoder_EncodeColumnData(t *testing.T) {
+	testCases := []struct {
+		input *ColumnData
+		want  string
+	}{
+		{
+			input: &ColumnData{Filed: "y", Type: columnTypeYear, Data: []byte("2019")},
+			want:  `{"field":"y","type":"Year","isEmpty":false,"data":2019}`,
+		},
+		{
+			input: &ColumnData{Filed: "d", Type: columnTypeDouble, Data: []byte("NaN")},
+			want:  `{"field":"d","type":"Double","isEmpty":false,"data":"NaN"}`,
+		},
+		{
+			input: &ColumnData{Filed: "b", Type: columnTypeBit, Data: []byte{0x01}},
+			want:  `{"field":"b","type":"Bit","isEmpty":false,"encoding":"hex","data":"01"}`,
+		},
+		{
+			input: &ColumnData{Filed: "s", Type: columnTypeVarchar, Data: []byte("abc")},
+			want:  `{"field":"s","type":"Varchar","isEmpty":false,"data":"abc"}`,
+		},
+		{
+			input: &ColumnData{Filed: "s", Type: columnTypeVarchar, Data: []byte{0xe9}},
+			want:  `{"field":"s","type":"Varchar","isEmpty":false,"encoding":"hex","data":"e9"}`,
+		},
+		{
+			input: &ColumnData{Filed: "s", Type: columnTypeVarchar, Charset: "latin1", Data: []byte{0xe9}},
+			want:  "{\"field\":\"s\",\"type\":\"Varchar\",\"isEmpty\":false,\"charset\":\"latin1\",\"data\":\"\ufffd\"}",
+		},
+	}
+
+	e := NewJSONEncoder()
+	e.SetSchema(JSONSchemaV2)
+	e.SetBinaryEncoding(JSONBinaryHex)
+	e.SetNativeNumbers(true)
+	for _, v := range testCases {
+		out, err := e.EncodeColumnData(v.input)
+		if err != nil {
+			t.Fatalf("%v EncodeColumnData fail. err: %v", v.input.Filed, err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("%v want != out want: %v out: %v", v.input.Filed, v.want, string(out))
+		}
+	}
+
+	v1 := `{"field":"b","type":"Bit","isEmpty":false,"data":"01"}`
+	e.SetSchema(JSONSchemaV1)
+	if out, err := e.EncodeColumnData(testCases[2].input); err != nil || string(out) != v1 {
+		t.Fatalf("v1 want != out want: %v out: %v err: %v", v1, string(out), err)
+	}
+
+	legacy := `{"filed":"y","type":"Year","isEmpty":false,"data":"2019"}`
+	out, err := NewJSONEncoder().EncodeColumnData(testCases[0].input)
+	if err != nil || string(out) != legacy {
+		t.Fatalf("legacy want != out want: %v out: %v err: %v", legacy, string(out), err)
+	}
+}
@@ -0,0 +1,88 @@
+package gobinlog
+
+import (
+	"encoding/json"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//JSONFormat JSON列的输出格式
+type JSONFormat int32
+
+//JSON列的输出格式
+const (
+	JSONFormatSQL     JSONFormat = iota //SQL表达式，如JSON_OBJECT('a',1)
+	JSONFormatRFC8259                   //RFC 8259的json文本，如{"a": 1}
+)
+
+var jsonFormatStrings = map[JSONFormat]string{
+	JSONFormatSQL:     "sql",
+	JSONFormatRFC8259: "rfc8259",
+}
+
+//String 打印
+func (f JSONFormat) String() string {
+	if s, ok := jsonFormatStrings[f]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+//SetJSONFormat 设置JSON列的ColumnData.Data的输出格式，默认为JSONFormatSQL，需要在Stream之前设置，
+//JSONDiffs的输出格式通过JSONEncoder.SetJSONFormat设置
+func (s *Streamer) SetJSONFormat(f JSONFormat) {
+	s.jsonFormat = f
+}
+
+//SetJSONFormat 设置JSONDiffs中新值的输出格式，默认为JSONFormatSQL，一般与Streamer.SetJSONFormat相同
+func (e *JSONEncoder) SetJSONFormat(f JSONFormat) {
+	e.jsonFormat = f
+}
+
+//cellBytes 解析第c列的数据，JSON列按照Streamer.SetJSONFormat设置的格式输出
+func cellBytes(tc *tableCache, data []byte, pos, c int) ([]byte, int, error) {
+	typ := tc.tableMap.Types[c]
+	metadata := tc.tableMap.Metadata[c]
+	if typ != replication.TypeJSON || tc.jsonFormat != JSONFormatRFC8259 {
+		return replication.CellBytes(data, pos, typ, metadata, tc.table.Columns()[c].IsUnSignedInt())
+	}
+
+	v, l, err := replication.CellJSON(data, pos, metadata)
+	if err != nil {
+		return nil, 0, err
+	}
+	b, err := replication.MarshalJSONValue(v)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, l, nil
+}
+
+//applyJSONDiffs 将diffs应用到JSON列的完整值上，按照f格式输出
+func applyJSONDiffs(before []byte, metadata uint16, diffs []replication.JSONDiff, f JSONFormat) ([]byte, error) {
+	if f != JSONFormatRFC8259 {
+		return replication.ApplyJSONDiffs(before, 0, metadata, diffs)
+	}
+
+	v, err := replication.ApplyJSONDiffsValue(before, 0, metadata, diffs)
+	if err != nil {
+		return nil, err
+	}
+	return replication.MarshalJSONValue(v)
+}
+
+//jsonDiffValue 按照f格式输出JSONDiff的新值，没有二进制json时Value需要已经是json文本
+func jsonDiffValue(d replication.JSONDiff, f JSONFormat) ([]byte, error) {
+	if f != JSONFormatRFC8259 || d.Value == nil {
+		return d.Value, nil
+	}
+
+	v, err := d.JSONValue()
+	if err != nil {
+		if json.Valid(d.Value) {
+			return d.Value, nil
+		}
+		return nil, err
+	}
+	return replication.MarshalJSONValue(v)
+}
@@ -0,0 +1,52 @@
+package gobinlog
+
+import (
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestJSONEncoder_JSONFormat(t *testing.T) {
+	//替换$.a为2
+	diffs, _, err := replication.JSONDiffs([]byte{9, 0, 0, 0, 0, 3, '$', '.', 'a', 3, 5, 2, 0}, 0, 4)
+	if err != nil {
+		t.Fatalf("JSONDiffs fail. err: %v", err)
+	}
+	//{"a":"b"}
+	before := []byte{15, 0, 0, 0, 0, 1, 0, 14, 0, 11, 0, 1, 0, 12, 12, 0, 97, 1, 98}
+
+	testCases := []struct {
+		format JSONFormat
+		want   string
+	}{
+		{
+			format: JSONFormatSQL,
+			want: `{"filed":"doc","type":"JSON","isEmpty":false,"jsonDiffs":[{"op":"replace","path":"$.a","value":"'2'"}],` +
+				`"data":"JSON_OBJECT('a',2)"}`,
+		},
+		{
+			format: JSONFormatRFC8259,
+			want: `{"filed":"doc","type":"JSON","isEmpty":false,"jsonDiffs":[{"op":"replace","path":"$.a","value":"2"}],` +
+				`"data":"{\"a\": 2}"}`,
+		},
+	}
+	for _, v := range testCases {
+		e := NewJSONEncoder()
+		e.SetJSONFormat(v.format)
+		c := &ColumnData{
+			Filed:     "doc",
+			Type:      columnTypeJSON,
+			JSONDiffs: diffs,
+		}
+		if c.Data, err = applyJSONDiffs(before, 4, diffs, v.format); err != nil {
+			t.Fatalf("%v applyJSONDiffs fail. err: %v", v.format, err)
+		}
+		out, err := e.EncodeColumnData(c)
+		if err != nil {
+			t.Fatalf("%v EncodeColumnData fail. err: %v", v.format, err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("%v want != out want: %v out: %s", v.format, v.want, out)
+		}
+	}
+}
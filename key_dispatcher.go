@@ -0,0 +1,285 @@
+package gobinlog
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+//SendStreamEventFunc 处理StreamEvent的函数，KeyDispatcher交给它的每个StreamEvent只包含一行数据
+type SendStreamEventFunc func(*StreamEvent) error
+
+//KeyDispatcher 按照表名以及主键的哈希值将事务中的行分发到多个worker中并发执行，
+//同一个主键的行总是由同一个worker按照binlog中的顺序执行，适用于没有逻辑时钟的场景。
+//没有主键的表按照表名分发，同一张表的行保持顺序；DDL等没有行数据的语句以及修改了主键
+//导致前后两个主键不在同一个worker上的行，会等待之前的行全部执行完毕后单独执行。
+//检查点只会推进到所有行都已经执行完毕的最后一个事务的NextPosition。
+//Send可以直接作为SendTransactionFunc注册到Streamer.Stream中
+//   d := NewKeyDispatcher(8, apply, saveCheckpoint)
+//   err := s.Stream(ctx, d.Send)
+//   closeErr := d.Close()
+type KeyDispatcher struct {
+	apply   SendStreamEventFunc
+	saver   checkpointSaver
+	queues  []chan *keyJob
+	wg      sync.WaitGroup
+	sending sync.WaitGroup //正在写入队列的Send，Close需要等待它们结束后才能关闭队列
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tracker *checkpointTracker
+	pending int //已经分发但还没有执行完毕的行数
+	closed  bool
+	err     error
+}
+
+type keyJob struct {
+	id        uint64
+	worker    int
+	exclusive bool //需要等待之前的行全部执行完毕后单独执行
+	event     *StreamEvent
+}
+
+//keyDispatcherQueueSize 每个worker的队列长度
+const keyDispatcherQueueSize = 64
+
+//NewKeyDispatcher 创建KeyDispatcher，workers是并发执行的worker数，
+//apply是在worker中执行单行StreamEvent的函数，checkpoint在检查点推进时被调用，可以为nil，
+//checkpoint在锁之外依次被调用，可以执行耗时的I/O
+func NewKeyDispatcher(workers int, apply SendStreamEventFunc,
+	checkpoint CheckpointFunc) *KeyDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &KeyDispatcher{
+		apply:   apply,
+		saver:   checkpointSaver{fn: checkpoint},
+		queues:  make([]chan *keyJob, workers),
+		tracker: newCheckpointTracker(Position{}),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	for i := range d.queues {
+		d.queues[i] = make(chan *keyJob, keyDispatcherQueueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+//Send 将事务拆分成单行的StreamEvent并分发到对应的worker中，
+//如果worker执行失败，Send会返回该错误，此时Streamer.Stream会停止
+func (d *KeyDispatcher) Send(tran *Transaction) error {
+	jobs, err := d.split(tran)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	if d.closed {
+		return errDispatcherClosed
+	}
+
+	id := d.tracker.track(tran.NowPosition, tran.NextPosition, len(jobs))
+	if len(jobs) == 0 {
+		if _, advanced := d.tracker.advance(); advanced {
+			d.mu.Unlock()
+			d.saveCheckpoint()
+			d.mu.Lock()
+		}
+		return d.err
+	}
+
+	for _, job := range jobs {
+		job.id = id
+		if job.exclusive {
+			d.waitLocked()
+		}
+		if d.err != nil {
+			return d.err
+		}
+		if d.closed {
+			//Close在分发过程中被调用，剩余的行不再分发，检查点不会越过该事务
+			return errDispatcherClosed
+		}
+		d.pending++
+		d.sending.Add(1)
+		d.mu.Unlock()
+		d.queues[job.worker] <- job
+		d.sending.Done()
+		d.mu.Lock()
+		if job.exclusive {
+			d.waitLocked()
+		}
+	}
+	return d.err
+}
+
+//Close 等待所有已经分发的行执行完毕并停止worker，返回执行过程中的第一个错误
+func (d *KeyDispatcher) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return d.Err()
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	d.sending.Wait()
+	for _, q := range d.queues {
+		close(q)
+	}
+	d.wg.Wait()
+	return d.Err()
+}
+
+//Checkpoint 获取当前检查点，该位置之前的事务都已经执行完毕
+func (d *KeyDispatcher) Checkpoint() Position {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.tracker.checkpoint()
+}
+
+//Err 获取执行过程中的第一个错误
+func (d *KeyDispatcher) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *KeyDispatcher) work(jobs <-chan *keyJob) {
+	defer d.wg.Done()
+	for job := range jobs {
+		var err error
+		if d.Err() == nil {
+			err = d.apply(job.event)
+		}
+		d.finish(job.id, err)
+	}
+}
+
+func (d *KeyDispatcher) finish(id uint64, err error) {
+	d.mu.Lock()
+	d.pending--
+	advanced := false
+	if err != nil {
+		if d.err == nil {
+			d.err = err
+		}
+	} else if d.err == nil {
+		_, advanced = d.tracker.done(id)
+	}
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	if advanced {
+		d.saveCheckpoint()
+	}
+}
+
+//saveCheckpoint 在锁之外调用checkpoint保存最新的检查点
+func (d *KeyDispatcher) saveCheckpoint() {
+	err := d.saver.save(func() (Position, bool) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.tracker.checkpoint(), d.err == nil
+	})
+	if err != nil {
+		d.mu.Lock()
+		if d.err == nil {
+			d.err = err
+		}
+		d.cond.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+//waitLocked 等待已经分发的行全部执行完毕或者发生错误
+func (d *KeyDispatcher) waitLocked() {
+	for d.err == nil && d.pending > 0 {
+		d.cond.Wait()
+	}
+}
+
+//split 将事务拆分成单行的任务，溢出到磁盘的事务在这里读取全部语句，
+//所以worker不会读取溢出文件，Send返回后溢出文件可以被删除
+func (d *KeyDispatcher) split(tran *Transaction) ([]*keyJob, error) {
+	var jobs []*keyJob
+	err := tran.RangeEvents(func(ev *StreamEvent) error {
+		rows := len(ev.RowValues)
+		if len(ev.RowIdentifies) > rows {
+			rows = len(ev.RowIdentifies)
+		}
+		if rows == 0 {
+			jobs = append(jobs, &keyJob{
+				exclusive: true,
+				event:     ev,
+			})
+			return nil
+		}
+
+		for i := 0; i < rows; i++ {
+			row := &StreamEvent{
+				Type:      ev.Type,
+				Table:     ev.Table,
+				Query:     ev.Query,
+				Timestamp: ev.Timestamp,
+			}
+			var before, after *RowData
+			if i < len(ev.RowIdentifies) {
+				before = ev.RowIdentifies[i]
+				row.RowIdentifies = []*RowData{before}
+			}
+			if i < len(ev.RowValues) {
+				after = ev.RowValues[i]
+				row.RowValues = []*RowData{after}
+			}
+
+			job := &keyJob{event: row}
+			beforeWorker, beforeOK := d.worker(ev.Table, before)
+			afterWorker, afterOK := d.worker(ev.Table, after)
+			switch {
+			case beforeOK && afterOK && beforeWorker != afterWorker:
+				job.exclusive = true
+			case beforeOK:
+				job.worker = beforeWorker
+			default:
+				job.worker = afterWorker
+			}
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+//worker 计算一行数据对应的worker，主键列缺失时返回false，没有主键的表按照表名计算
+func (d *KeyDispatcher) worker(table MysqlTableName, row *RowData) (int, bool) {
+	if row == nil {
+		return 0, false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(table.DbName))
+	h.Write([]byte{0})
+	h.Write([]byte(table.TableName))
+	for _, c := range row.Columns {
+		if !c.IsPrimaryKey {
+			continue
+		}
+		if c.IsEmpty {
+			return 0, false
+		}
+		if c.Data == nil {
+			h.Write([]byte{0})
+		} else {
+			h.Write([]byte{1})
+			h.Write(c.Data)
+		}
+	}
+	return int(h.Sum64() % uint64(len(d.queues))), true
+}
@@ -0,0 +1,319 @@
+package gobinlog
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func newKeyRow(id, message string) *RowData {
+	return &RowData{
+		Columns: []*ColumnData{
+			{
+				Filed:        "id",
+				Type:         columnTypeLong,
+				IsPrimaryKey: true,
+				Data:         []byte(id),
+			},
+			{
+				Filed: "message",
+				Type:  columnTypeVarchar,
+				Data:  []byte(message),
+			},
+		},
+	}
+}
+
+func newKeyTransaction(offset int64, events ...*StreamEvent) *Transaction {
+	return &Transaction{
+		NowPosition:  testPosition(offset),
+		NextPosition: testPosition(offset + 100),
+		Events:       events,
+	}
+}
+
+//eventRecorder 按照主键记录每行的执行顺序
+type eventRecorder struct {
+	mu     sync.Mutex
+	byKey  map[string][]string
+	order  []string
+	delay  time.Duration
+	errKey string
+}
+
+func newEventRecorder() *eventRecorder {
+	return &eventRecorder{
+		byKey: make(map[string][]string),
+	}
+}
+
+func (e *eventRecorder) apply(ev *StreamEvent) error {
+	if e.delay > 0 {
+		time.Sleep(e.delay)
+	}
+	if ev.Query.SQL != "" {
+		e.mu.Lock()
+		e.order = append(e.order, ev.Query.SQL)
+		e.mu.Unlock()
+		return nil
+	}
+	row := ev.RowValues
+	if len(row) == 0 {
+		row = ev.RowIdentifies
+	}
+	if len(row) != 1 {
+		return fmt.Errorf("row count want: 1 out: %v", len(row))
+	}
+	key := string(row[0].Columns[0].Data)
+	if key == e.errKey {
+		return errors.New("mock error")
+	}
+	e.mu.Lock()
+	e.byKey[key] = append(e.byKey[key], string(row[0].Columns[1].Data))
+	e.order = append(e.order, key)
+	e.mu.Unlock()
+	return nil
+}
+
+func TestKeyDispatcher_order(t *testing.T) {
+	r := newEventRecorder()
+	var mu sync.Mutex
+	var checkpoints []Position
+	d := NewKeyDispatcher(4, r.apply, func(pos Position) error {
+		mu.Lock()
+		checkpoints = append(checkpoints, pos)
+		mu.Unlock()
+		return nil
+	})
+
+	offset := int64(100)
+	for i := 0; i < 20; i++ {
+		ev := newStreamEvent(StatementInsert, 0, tesInfo.name)
+		for k := 0; k < 5; k++ {
+			ev.RowValues = append(ev.RowValues, newKeyRow(fmt.Sprint(k), fmt.Sprint(i)))
+		}
+		if err := d.Send(newKeyTransaction(offset, ev)); err != nil {
+			t.Fatalf("Send fail. err: %v", err)
+		}
+		offset += 100
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+
+	for k := 0; k < 5; k++ {
+		values := r.byKey[fmt.Sprint(k)]
+		if len(values) != 20 {
+			t.Fatalf("key %v len want: 20 out: %v", k, len(values))
+		}
+		for i, v := range values {
+			if v != fmt.Sprint(i) {
+				t.Fatalf("key %v is out of order: %v", k, values)
+			}
+		}
+	}
+	if d.Checkpoint() != testPosition(offset) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(offset), d.Checkpoint())
+	}
+	for i := 1; i < len(checkpoints); i++ {
+		if checkpoints[i].Offset <= checkpoints[i-1].Offset {
+			t.Fatalf("checkpoints are not increasing: %v", checkpoints)
+		}
+	}
+}
+
+func TestKeyDispatcher_exclusive(t *testing.T) {
+	r := newEventRecorder()
+	r.delay = time.Millisecond
+	d := NewKeyDispatcher(4, r.apply, nil)
+
+	insert := newStreamEvent(StatementInsert, 0, tesInfo.name)
+	for k := 0; k < 8; k++ {
+		insert.RowValues = append(insert.RowValues, newKeyRow(fmt.Sprint(k), "a"))
+	}
+	ddl := &StreamEvent{
+		Type:  StatementAlter,
+		Table: tesInfo.name,
+		Query: replication.Query{SQL: "alter table vt_a add column c int"},
+	}
+	//修改主键的update
+	update := newStreamEvent(StatementUpdate, 0, tesInfo.name)
+	update.RowIdentifies = append(update.RowIdentifies, newKeyRow("0", "a"))
+	update.RowValues = append(update.RowValues, newKeyRow("100", "b"))
+
+	if err := d.Send(newKeyTransaction(100, insert, ddl)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	if err := d.Send(newKeyTransaction(200, update)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+
+	if len(r.order) != 10 {
+		t.Fatalf("len of order want: 10 out: %v", r.order)
+	}
+	if r.order[8] != ddl.Query.SQL {
+		t.Fatalf("ddl is not executed after all rows: %v", r.order)
+	}
+	if d.Checkpoint() != testPosition(300) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(300), d.Checkpoint())
+	}
+}
+
+func TestKeyDispatcher_noPrimaryKey(t *testing.T) {
+	d := NewKeyDispatcher(8, nil, nil)
+	row := &RowData{
+		Columns: []*ColumnData{
+			{
+				Filed: "id",
+				Type:  columnTypeLong,
+				Data:  []byte("1"),
+			},
+		},
+	}
+	other := &RowData{
+		Columns: []*ColumnData{
+			{
+				Filed: "id",
+				Type:  columnTypeLong,
+				Data:  []byte("2"),
+			},
+		},
+	}
+	w1, ok1 := d.worker(tesInfo.name, row)
+	w2, ok2 := d.worker(tesInfo.name, other)
+	if !ok1 || !ok2 || w1 != w2 {
+		t.Fatalf("rows without primary key should be dispatched by table. out: %v %v %v %v", w1, ok1, w2, ok2)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+}
+
+func TestKeyDispatcher_spilled(t *testing.T) {
+	r := newEventRecorder()
+	r.delay = 10 * time.Millisecond
+	d := NewKeyDispatcher(4, r.apply, nil)
+
+	dir, clean := testSpillDir(t)
+	defer clean()
+	ev := newStreamEvent(StatementInsert, 0, tesInfo.name)
+	for k := 0; k < 5; k++ {
+		ev.RowValues = append(ev.RowValues, newKeyRow(fmt.Sprint(k), "spilled"))
+	}
+	tran := newKeyTransaction(100, ev)
+	release := testSpillTransaction(t, dir, tran)
+	err := d.Send(tran)
+	//与Streamer相同，Send返回后删除溢出文件
+	release()
+	if err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	if err = d.Close(); err != nil {
+		t.Fatalf("Close fail. err: %v", err)
+	}
+	for k := 0; k < 5; k++ {
+		if values := r.byKey[fmt.Sprint(k)]; len(values) != 1 || values[0] != "spilled" {
+			t.Fatalf("key %v want: [spilled] out: %v", k, values)
+		}
+	}
+	if d.Checkpoint() != testPosition(200) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(200), d.Checkpoint())
+	}
+
+	d = NewKeyDispatcher(1, r.apply, nil)
+	if err = d.Send(tran); err == nil {
+		t.Fatalf("released spill file want error")
+	}
+	d.Close()
+}
+
+func TestKeyDispatcher_error(t *testing.T) {
+	r := newEventRecorder()
+	r.errKey = "1"
+	d := NewKeyDispatcher(2, r.apply, nil)
+
+	if err := d.Send(newKeyTransaction(100)); err != nil {
+		t.Fatalf("Send fail. err: %v", err)
+	}
+	ev := newStreamEvent(StatementDelete, 0, tesInfo.name)
+	ev.RowIdentifies = append(ev.RowIdentifies, newKeyRow("1", "a"))
+	d.Send(newKeyTransaction(200, ev))
+	if err := d.Close(); err == nil {
+		t.Fatalf("Close want error")
+	}
+	if d.Checkpoint() != testPosition(200) {
+		t.Fatalf("checkpoint want: %v out: %v", testPosition(200), d.Checkpoint())
+	}
+	if err := d.Send(newKeyTransaction(300)); err == nil {
+		t.Fatalf("Send after error want error")
+	}
+}
+
+func TestKeyDispatcher_sendClose(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := NewKeyDispatcher(2, func(*StreamEvent) error { return nil }, nil)
+		sent := make(chan error)
+		go func() {
+			sent <- d.Send(newKeyTransaction(100, &StreamEvent{
+				Type:      StatementInsert,
+				Table:     NewMysqlTableName("db", "t"),
+				RowValues: []*RowData{newKeyRow("1", "a"), newKeyRow("2", "b")},
+			}))
+		}()
+		if err := d.Close(); err != nil {
+			t.Fatalf("%v Close fail. err: %v", i, err)
+		}
+		if err := <-sent; err != nil && err != errDispatcherClosed {
+			t.Fatalf("%v Send want: <nil> or %v out: %v", i, errDispatcherClosed, err)
+		}
+	}
+}
+
+func TestKeyDispatcher_checkpointReentrant(t *testing.T) {
+	r := newEventRecorder()
+	var d *KeyDispatcher
+	var checkpoints []Position
+	d = NewKeyDispatcher(2, r.apply, func(pos Position) error {
+		//回调在锁之外执行，可以调用分发器的方法
+		if d.Checkpoint().Offset < pos.Offset || d.Err() != nil {
+			return errors.New("unexpected dispatcher state")
+		}
+		checkpoints = append(checkpoints, pos)
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		//没有行数据的事务在Send中推进检查点
+		if err := d.Send(newKeyTransaction(100)); err != nil {
+			done <- err
+			return
+		}
+		ev := newStreamEvent(StatementInsert, 0, tesInfo.name)
+		ev.RowValues = []*RowData{newKeyRow("1", "a"), newKeyRow("2", "b")}
+		if err := d.Send(newKeyTransaction(200, ev)); err != nil {
+			done <- err
+			return
+		}
+		done <- d.Close()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Send or Close fail. err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("checkpoint callback deadlocks")
+	}
+	if len(checkpoints) == 0 || checkpoints[0] != testPosition(200) ||
+		checkpoints[len(checkpoints)-1] != testPosition(300) {
+		t.Fatalf("checkpoints want: [%v ... %v] out: %v", testPosition(200), testPosition(300), checkpoints)
+	}
+}
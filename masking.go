@@ -0,0 +1,339 @@
+package gobinlog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//MaskAction 列的脱敏方式
+type MaskAction int
+
+//列的脱敏方式
+const (
+	MaskDrop     MaskAction = iota //从行中删除该列
+	MaskNull                       //置为NULL
+	MaskHash                       //HMAC-SHA256，输出16进制字符串
+	MaskPartial                    //保留前后若干个字符，其余字符替换为掩码字符
+	MaskTokenize                   //替换为token，相同的值得到相同的token
+)
+
+var maskActionStrings = map[MaskAction]string{
+	MaskDrop:     "drop",
+	MaskNull:     "null",
+	MaskHash:     "hash",
+	MaskPartial:  "partial",
+	MaskTokenize: "tokenize",
+}
+
+//String 打印
+func (a MaskAction) String() string {
+	if s, ok := maskActionStrings[a]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+//maskedStatementSQL statement格式的DML脱敏后的sql
+const maskedStatementSQL = "/* statement redacted by gobinlog masker */"
+
+//defaultTokenPrefix 默认token的前缀
+const defaultTokenPrefix = "tok_"
+
+//Tokenizer 将敏感值替换为token，如通过外部的token服务保存原值以便授权后还原
+type Tokenizer interface {
+	Tokenize(table MysqlTableName, column string, value []byte) ([]byte, error)
+}
+
+//MaskRule 一条脱敏规则，Database，Table以及Column支持path.Match的通配符，如*，
+//列名不区分大小写
+type MaskRule struct {
+	Database   string
+	Table      string
+	Column     string
+	Action     MaskAction
+	Key        []byte    //MaskHash以及没有Tokenizer的MaskTokenize使用的HMAC密钥
+	KeepPrefix int       //MaskPartial保留的前缀字符数
+	KeepSuffix int       //MaskPartial保留的后缀字符数
+	MaskChar   rune      //MaskPartial的掩码字符，默认为*
+	Tokenizer  Tokenizer //MaskTokenize使用的Tokenizer，为nil时使用"tok_"加HMAC-SHA256的前24个16进制字符
+}
+
+func (r *MaskRule) match(table MysqlTableName, column string) bool {
+	ok, _ := path.Match(r.Database, table.DbName)
+	if !ok {
+		return false
+	}
+	if ok, _ = path.Match(r.Table, table.TableName); !ok {
+		return false
+	}
+	ok, _ = path.Match(strings.ToLower(r.Column), strings.ToLower(column))
+	return ok
+}
+
+//maskColumnKey 规则匹配结果缓存的键
+type maskColumnKey struct {
+	table  MysqlTableName
+	column string
+}
+
+//Masker 按照规则对行数据中的列脱敏，同时作用于RowValues以及RowIdentifies，
+//每一列使用第一个匹配的规则，脱敏后非NULL的值为utf8mb4字符串，列类型变为varchar，
+//只有部分更新的json列保留JSONDiffs，其中的新值脱敏后为json字符串，
+//DDL等sql语句不会被修改。statement格式的INSERT，UPDATE以及DELETE无法确定涉及的表和列，
+//其中的sql会被替换为注释，只保留语句类型以及Query.Database。
+//通过Streamer.UseEventMiddleware注册EventMiddleware时语句在解析后立即脱敏，溢出文件中也是脱敏后的数据
+type Masker struct {
+	rules []MaskRule
+
+	mu    sync.Mutex
+	cache map[maskColumnKey]int //列对应的规则下标，-1表示没有匹配的规则
+}
+
+//NewMasker 创建Masker，规则不合法时返回错误
+func NewMasker(rules ...MaskRule) (*Masker, error) {
+	rules = append([]MaskRule(nil), rules...)
+	for i := range rules {
+		r := &rules[i]
+		for _, pattern := range []string{r.Database, r.Table, r.Column} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, newError(err).msgf("mask rule %v pattern %v is invalid.", i, pattern)
+			}
+		}
+		switch r.Action {
+		case MaskDrop, MaskNull:
+		case MaskHash:
+			if len(r.Key) == 0 {
+				return nil, newError(fmt.Errorf("key is empty")).msgf("mask rule %v is invalid.", i)
+			}
+		case MaskTokenize:
+			if r.Tokenizer == nil && len(r.Key) == 0 {
+				return nil, newError(fmt.Errorf("key and tokenizer are empty")).msgf("mask rule %v is invalid.", i)
+			}
+		case MaskPartial:
+			if r.KeepPrefix < 0 || r.KeepSuffix < 0 {
+				return nil, newError(fmt.Errorf("keep prefix or suffix is negative")).
+					msgf("mask rule %v is invalid.", i)
+			}
+			if r.MaskChar == 0 {
+				r.MaskChar = '*'
+			}
+		default:
+			return nil, newError(fmt.Errorf("unknown action %v", r.Action)).msgf("mask rule %v is invalid.", i)
+		}
+	}
+	return &Masker{
+		rules: rules,
+		cache: make(map[maskColumnKey]int),
+	}, nil
+}
+
+//EventMiddleware 返回对语句脱敏的语句中间件，可以通过Streamer.UseEventMiddleware注册
+func (m *Masker) EventMiddleware() StreamEventMiddleware {
+	return func(s *StreamEvent) ([]*StreamEvent, error) {
+		r, err := m.MaskStreamEvent(s)
+		if err != nil {
+			return nil, err
+		}
+		return []*StreamEvent{r}, nil
+	}
+}
+
+//Middleware 返回对事务中的行数据脱敏的事务中间件，用于没有Streamer的事务，
+//Streamer中需要使用EventMiddleware，否则溢出到磁盘的事务在溢出文件中是脱敏之前的数据
+func (m *Masker) Middleware() TransactionMiddleware {
+	return EventMiddleware(m.EventMiddleware())
+}
+
+//MaskStreamEvent 返回脱敏后的语句，不修改s，没有匹配的列时返回s
+func (m *Masker) MaskStreamEvent(s *StreamEvent) (*StreamEvent, error) {
+	if s.Query.SQL != "" {
+		return m.maskStatement(s), nil
+	}
+	if !m.matchTable(s) {
+		return s, nil
+	}
+	r := *s
+	var err error
+	if r.RowValues, err = m.maskRows(s.Table, s.RowValues); err != nil {
+		return nil, err
+	}
+	if r.RowIdentifies, err = m.maskRows(s.Table, s.RowIdentifies); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+//maskStatement statement格式的DML中可能包含敏感值，替换其中的sql
+func (m *Masker) maskStatement(s *StreamEvent) *StreamEvent {
+	switch s.Type {
+	case StatementInsert, StatementUpdate, StatementDelete:
+	default:
+		return s
+	}
+	r := *s
+	r.Query.SQL = maskedStatementSQL
+	return &r
+}
+
+//matchTable 语句中是否有需要脱敏的列
+func (m *Masker) matchTable(s *StreamEvent) bool {
+	for _, rows := range [][]*RowData{s.RowValues, s.RowIdentifies} {
+		if len(rows) > 0 {
+			for _, c := range rows[0].Columns {
+				if m.rule(s.Table, c.Filed) != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (m *Masker) rule(table MysqlTableName, column string) *MaskRule {
+	key := maskColumnKey{table: table, column: column}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.cache[key]
+	if !ok {
+		i = -1
+		for j := range m.rules {
+			if m.rules[j].match(table, column) {
+				i = j
+				break
+			}
+		}
+		m.cache[key] = i
+	}
+	if i < 0 {
+		return nil
+	}
+	return &m.rules[i]
+}
+
+func (m *Masker) maskRows(table MysqlTableName, rows []*RowData) ([]*RowData, error) {
+	if rows == nil {
+		return nil, nil
+	}
+	masked := make([]*RowData, 0, len(rows))
+	for _, row := range rows {
+		r := &RowData{Columns: make([]*ColumnData, 0, len(row.Columns))}
+		for _, c := range row.Columns {
+			rule := m.rule(table, c.Filed)
+			if rule == nil {
+				r.Columns = append(r.Columns, c)
+				continue
+			}
+			if rule.Action == MaskDrop {
+				continue
+			}
+			mc, err := rule.mask(table, c)
+			if err != nil {
+				return nil, newError(err).msgf("mask column %v of %v fail.", c.Filed, table.String())
+			}
+			r.Columns = append(r.Columns, mc)
+		}
+		masked = append(masked, r)
+	}
+	return masked, nil
+}
+
+//mask 返回脱敏后的列，没有变化(IsEmpty)以及NULL的列只有MaskNull会修改，
+//只有部分更新(JSONDiffs)的json列没有完整的值，对每个新值脱敏后仍然是部分更新
+func (r *MaskRule) mask(table MysqlTableName, c *ColumnData) (*ColumnData, error) {
+	mc := *c
+	mc.JSONDiffs = nil
+	if r.Action == MaskNull {
+		mc.Data = nil
+		return &mc, nil
+	}
+	if c.isPartialJSON() {
+		diffs, err := r.maskJSONDiffs(table, c)
+		if err != nil {
+			return nil, err
+		}
+		mc.JSONDiffs = diffs
+		return &mc, nil
+	}
+	if c.IsEmpty || c.Data == nil {
+		return &mc, nil
+	}
+
+	data, err := r.maskData(table, c)
+	if err != nil {
+		return nil, err
+	}
+	mc.Data = data
+	mc.Type = columnTypeVarchar
+	mc.Charset = charsetUTF8MB4
+	return &mc, nil
+}
+
+//maskData 列的值脱敏后的utf8字符串
+func (r *MaskRule) maskData(table MysqlTableName, c *ColumnData) ([]byte, error) {
+	switch r.Action {
+	case MaskHash:
+		return []byte(hex.EncodeToString(r.hmac(c.Data))), nil
+	case MaskTokenize:
+		if r.Tokenizer == nil {
+			return []byte(defaultTokenPrefix + hex.EncodeToString(r.hmac(c.Data))[:24]), nil
+		}
+		return r.Tokenizer.Tokenize(table, c.Filed, c.Data)
+	case MaskPartial:
+		data, err := c.UTF8Data()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(r.partial(string(data))), nil
+	}
+	return c.Data, nil
+}
+
+//maskJSONDiffs 对部分更新的每个新值脱敏，脱敏后的值是json字符串
+func (r *MaskRule) maskJSONDiffs(table MysqlTableName, c *ColumnData) ([]replication.JSONDiff, error) {
+	diffs := make([]replication.JSONDiff, 0, len(c.JSONDiffs))
+	for _, d := range c.JSONDiffs {
+		md := replication.JSONDiff{Operation: d.Operation, Path: d.Path}
+		if d.Value != nil {
+			value := d.Value
+			var str string
+			if json.Unmarshal(d.Value, &str) == nil {
+				//json字符串对其内容脱敏
+				value = []byte(str)
+			}
+			data, err := r.maskData(table, &ColumnData{Filed: c.Filed, Type: c.Type, Data: value})
+			if err != nil {
+				return nil, err
+			}
+			if md.Value, err = json.Marshal(string(data)); err != nil {
+				return nil, err
+			}
+		}
+		diffs = append(diffs, md)
+	}
+	return diffs, nil
+}
+
+func (r *MaskRule) hmac(data []byte) []byte {
+	h := hmac.New(sha256.New, r.Key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+//partial 保留前后若干个字符，字符数不超过保留的字符数时全部替换
+func (r *MaskRule) partial(s string) string {
+	runes := []rune(s)
+	for i := range runes {
+		if len(runes) <= r.KeepPrefix+r.KeepSuffix || (i >= r.KeepPrefix && i < len(runes)-r.KeepSuffix) {
+			runes[i] = r.MaskChar
+		}
+	}
+	return string(runes)
+}
@@ -0,0 +1,203 @@
+package gobinlog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+type testTokenizer struct{}
+
+func (testTokenizer) Tokenize(table MysqlTableName, column string, value []byte) ([]byte, error) {
+	if string(value) == "bad" {
+		return nil, fmt.Errorf("tokenize fail")
+	}
+	return []byte(table.TableName + "." + column + ":" + string(value)), nil
+}
+
+func testMaskRow(email, phone, name string) *RowData {
+	return &RowData{Columns: []*ColumnData{
+		{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+		{Filed: "Email", Type: columnTypeVarchar, Data: []byte(email)},
+		{Filed: "phone", Type: columnTypeLongLong, Data: []byte(phone)},
+		{Filed: "name", Type: columnTypeVarchar, Charset: "gbk", Data: []byte(name)},
+		{Filed: "card", Type: columnTypeVarchar, Data: []byte("6222020200112233")},
+	}}
+}
+
+func testMaskValues(row *RowData) []string {
+	var out []string
+	for _, c := range row.Columns {
+		v := "NULL"
+		if c.Data != nil {
+			v = string(c.Data)
+		}
+		out = append(out, c.Filed+"="+v)
+	}
+	return out
+}
+
+func TestMasker_MaskStreamEvent(t *testing.T) {
+	key := []byte("secret")
+	m, err := NewMasker(
+		MaskRule{Database: "db", Table: "users", Column: "email", Action: MaskHash, Key: key},
+		MaskRule{Database: "db", Table: "users", Column: "phone", Action: MaskPartial, KeepPrefix: 3, KeepSuffix: 4},
+		MaskRule{Database: "db", Table: "user*", Column: "name", Action: MaskPartial, KeepPrefix: 1, MaskChar: '#'},
+		MaskRule{Database: "*", Table: "*", Column: "card", Action: MaskTokenize, Tokenizer: testTokenizer{}},
+		MaskRule{Database: "*", Table: "*", Column: "card", Action: MaskDrop},
+		MaskRule{Database: "db", Table: "users", Column: "id", Action: MaskNull},
+	)
+	if err != nil {
+		t.Fatalf("NewMasker fail. err: %v", err)
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("a@b.com"))
+	//"张三丰"的gbk编码
+	name := string([]byte{0xd5, 0xc5, 0xc8, 0xfd, 0xb7, 0xe1})
+	s := &StreamEvent{
+		Type:          StatementUpdate,
+		Table:         NewMysqlTableName("db", "users"),
+		RowIdentifies: []*RowData{testMaskRow("a@b.com", "13812345678", name)},
+		RowValues:     []*RowData{testMaskRow("a@b.com", "138", name)},
+	}
+	out, err := m.MaskStreamEvent(s)
+	if err != nil {
+		t.Fatalf("MaskStreamEvent fail. err: %v", err)
+	}
+	want := []string{"id=NULL", "Email=" + hex.EncodeToString(h.Sum(nil)), "phone=138****5678",
+		"name=张##", "card=users.card:6222020200112233"}
+	if values := testMaskValues(out.RowIdentifies[0]); !reflect.DeepEqual(values, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, values)
+	}
+	want[2] = "phone=***"
+	if values := testMaskValues(out.RowValues[0]); !reflect.DeepEqual(values, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, values)
+	}
+	if c := out.RowValues[0].Columns[2]; c.Type != columnTypeVarchar || c.Charset != charsetUTF8MB4 {
+		t.Fatalf("masked column want varchar utf8mb4 out: %v %v", c.Type, c.Charset)
+	}
+	if values := testMaskValues(s.RowValues[0]); values[1] != "Email=a@b.com" || values[0] != "id=1" {
+		t.Fatalf("original event was modified: %q", values)
+	}
+
+	other := &StreamEvent{Type: StatementInsert, Table: NewMysqlTableName("db2", "orders"),
+		RowValues: []*RowData{testMaskRow("a@b.com", "138", "bad")}}
+	if out, err = m.MaskStreamEvent(other); err != nil {
+		t.Fatalf("MaskStreamEvent fail. err: %v", err)
+	}
+	want = []string{"id=1", "Email=a@b.com", "phone=138", "name=bad", "card=orders.card:6222020200112233"}
+	if values := testMaskValues(out.RowValues[0]); !reflect.DeepEqual(values, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, values)
+	}
+
+	stmt := &StreamEvent{Type: StatementInsert,
+		Query: replication.Query{Database: "db", SQL: "insert into users(email) values('a@b.com')"}}
+	if out, err = m.MaskStreamEvent(stmt); err != nil || out.Query.SQL != maskedStatementSQL ||
+		out.Query.Database != "db" || stmt.Query.SQL == maskedStatementSQL {
+		t.Fatalf("statement want redacted out: %+v err: %v", out, err)
+	}
+	ddl := &StreamEvent{Type: StatementAlter, Query: replication.Query{Database: "db", SQL: "alter table users add c int"}}
+	if out, err = m.MaskStreamEvent(ddl); err != nil || out != ddl {
+		t.Fatalf("ddl want unchanged out: %+v err: %v", out, err)
+	}
+}
+
+func TestMasker_partialJSON(t *testing.T) {
+	diffs := []replication.JSONDiff{
+		{Operation: replication.JSONDiffReplace, Path: "$.phone", Value: []byte(`"13812345678"`)},
+		{Operation: replication.JSONDiffRemove, Path: "$.email"},
+	}
+	testCases := []struct {
+		action MaskAction
+		want   []replication.JSONDiff
+	}{
+		{
+			action: MaskPartial,
+			want: []replication.JSONDiff{
+				{Operation: replication.JSONDiffReplace, Path: "$.phone", Value: []byte(`"138*****678"`)},
+				{Operation: replication.JSONDiffRemove, Path: "$.email"},
+			},
+		},
+		{action: MaskNull},
+	}
+
+	for i, v := range testCases {
+		m, err := NewMasker(MaskRule{Database: "db", Table: "users", Column: "doc", Action: v.action,
+			KeepPrefix: 3, KeepSuffix: 3})
+		if err != nil {
+			t.Fatalf("%v NewMasker fail. err: %v", i, err)
+		}
+		s := &StreamEvent{Type: StatementUpdate, Table: NewMysqlTableName("db", "users"),
+			RowIdentifies: []*RowData{{Columns: []*ColumnData{{Filed: "doc", Type: columnTypeJSON, Data: []byte("{}")}}}},
+			RowValues:     []*RowData{{Columns: []*ColumnData{{Filed: "doc", Type: columnTypeJSON, JSONDiffs: diffs}}}},
+		}
+		out, err := m.MaskStreamEvent(s)
+		if err != nil {
+			t.Fatalf("%v MaskStreamEvent fail. err: %v", i, err)
+		}
+		c := out.RowValues[0].Columns[0]
+		if c.Data != nil || !reflect.DeepEqual(c.JSONDiffs, v.want) {
+			t.Fatalf("%v want != out\nwant: %+v\nout:  %q %+v", i, v.want, c.Data, c.JSONDiffs)
+		}
+		if string(s.RowValues[0].Columns[0].JSONDiffs[0].Value) != `"13812345678"` {
+			t.Fatalf("%v original json diffs were modified", i)
+		}
+	}
+}
+
+func TestMasker_Middleware(t *testing.T) {
+	m, err := NewMasker(
+		MaskRule{Database: "db", Table: "t", Column: "email", Action: MaskDrop},
+		MaskRule{Database: "db", Table: "t", Column: "card", Action: MaskTokenize, Key: []byte("k")},
+	)
+	if err != nil {
+		t.Fatalf("NewMasker fail. err: %v", err)
+	}
+	var out *Transaction
+	send := ChainMiddlewares(func(t *Transaction) error {
+		out = t
+		return nil
+	}, m.Middleware())
+	tran := &Transaction{Events: []*StreamEvent{{Type: StatementDelete, Table: NewMysqlTableName("db", "t"),
+		RowIdentifies: []*RowData{testMaskRow("a@b.com", "1", "n"), testMaskRow("c@d.com", "2", "n")}}}}
+	if err = send(tran); err != nil {
+		t.Fatalf("send fail. err: %v", err)
+	}
+	rows := out.Events[0].RowIdentifies
+	if len(rows) != 2 || len(rows[0].Columns) != 4 || rows[0].Columns[1].Filed != "phone" {
+		t.Fatalf("email want dropped out: %q", testMaskValues(rows[0]))
+	}
+	card := string(rows[0].Columns[3].Data)
+	if len(card) != len(defaultTokenPrefix)+24 || card[:len(defaultTokenPrefix)] != defaultTokenPrefix ||
+		card != string(rows[1].Columns[3].Data) {
+		t.Fatalf("bad token: %v %s", card, rows[1].Columns[3].Data)
+	}
+
+	tokenErr, _ := NewMasker(MaskRule{Database: "*", Table: "*", Column: "name", Action: MaskTokenize,
+		Tokenizer: testTokenizer{}})
+	tran.Events[0].RowIdentifies[0].Columns[3].Data = []byte("bad")
+	if err = ChainMiddlewares(send, tokenErr.Middleware())(tran); err == nil {
+		t.Fatalf("tokenizer error want error")
+	}
+}
+
+func TestNewMasker(t *testing.T) {
+	testCases := []MaskRule{
+		{Database: "[", Table: "*", Column: "*", Action: MaskDrop},
+		{Database: "*", Table: "*", Column: "*", Action: MaskHash},
+		{Database: "*", Table: "*", Column: "*", Action: MaskTokenize},
+		{Database: "*", Table: "*", Column: "*", Action: MaskPartial, KeepPrefix: -1},
+		{Database: "*", Table: "*", Column: "*", Action: MaskAction(100)},
+	}
+	for i, v := range testCases {
+		if _, err := NewMasker(v); err == nil {
+			t.Fatalf("%v want error", i)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package gobinlog
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+//maxwell中type的取值
+var maxwellTypes = map[StatementType]string{
+	StatementInsert: "insert",
+	StatementUpdate: "update",
+	StatementDelete: "delete",
+}
+
+//MaxwellEncoder 将行数据编码为maxwell的json格式，每一行对应一条消息，事务的最后一行带有commit，
+//数字列为json数字，json列为json对象，二进制列为base64编码，sql语句会被忽略
+type MaxwellEncoder struct {
+	values *JSONEncoder
+
+	outputPosition    bool
+	outputServerID    bool
+	outputGTID        bool
+	outputPrimaryKeys bool
+}
+
+//NewMaxwellEncoder 创建MaxwellEncoder，默认只输出maxwell默认配置下的字段
+func NewMaxwellEncoder() *MaxwellEncoder {
+	values := NewJSONEncoder()
+	values.SetNativeNumbers(true)
+	values.SetBinaryEncoding(JSONBinaryBase64)
+	return &MaxwellEncoder{values: values}
+}
+
+//SetOutputBinlogPosition 设置是否输出position，与maxwell的output_binlog_position相同
+func (e *MaxwellEncoder) SetOutputBinlogPosition(output bool) {
+	e.outputPosition = output
+}
+
+//SetOutputServerID 设置是否输出server_id，与maxwell的output_server_id相同
+func (e *MaxwellEncoder) SetOutputServerID(output bool) {
+	e.outputServerID = output
+}
+
+//SetOutputGTID 设置是否输出gtid，与maxwell的output_gtid_position相同
+func (e *MaxwellEncoder) SetOutputGTID(output bool) {
+	e.outputGTID = output
+}
+
+//SetOutputPrimaryKeys 设置是否输出primary_key以及primary_key_columns，
+//与maxwell的output_primary_keys以及output_primary_key_columns相同
+func (e *MaxwellEncoder) SetOutputPrimaryKeys(output bool) {
+	e.outputPrimaryKeys = output
+}
+
+//EncodeTransaction 将事务中的每一行编码为一条消息
+func (e *MaxwellEncoder) EncodeTransaction(t *Transaction) ([][]byte, error) {
+	total := 0
+	err := t.RangeEvents(func(s *StreamEvent) error {
+		total += maxwellRowCount(s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([][]byte, 0, total)
+	err = t.RangeEvents(func(s *StreamEvent) error {
+		typ := maxwellTypes[s.Type]
+		for i := 0; i < maxwellRowCount(s); i++ {
+			var before, after *RowData
+			if i < len(s.RowIdentifies) {
+				before = s.RowIdentifies[i]
+			}
+			if i < len(s.RowValues) {
+				after = s.RowValues[i]
+			}
+			m, err := e.message(t, s, typ, len(messages), len(messages) == total-1, before, after)
+			if err != nil {
+				return newError(err).msgf("table %v maxwell encode fail.", s.Table.String())
+			}
+			messages = append(messages, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+//maxwellRowCount 语句中需要输出的行数
+func maxwellRowCount(s *StreamEvent) int {
+	if _, ok := maxwellTypes[s.Type]; !ok || s.Query.SQL != "" {
+		return 0
+	}
+	if len(s.RowIdentifies) > len(s.RowValues) {
+		return len(s.RowIdentifies)
+	}
+	return len(s.RowValues)
+}
+
+func (e *MaxwellEncoder) message(t *Transaction, s *StreamEvent, typ string, offset int, commit bool,
+	before, after *RowData) ([]byte, error) {
+	image := after
+	if image == nil {
+		image = before
+	}
+
+	o := jsonObject{
+		{"database", s.Table.DbName},
+		{"table", s.Table.TableName},
+		{"type", typ},
+		{"ts", s.Timestamp},
+		{"xid", t.XID},
+	}
+	if commit {
+		o = append(o, jsonField{"commit", true})
+	} else {
+		o = append(o, jsonField{"xoffset", offset})
+	}
+	if e.outputPosition {
+		o = append(o, jsonField{"position",
+			t.NowPosition.Filename + ":" + strconv.FormatInt(t.NowPosition.Offset, 10)})
+	}
+	if e.outputGTID && t.GTID != "" {
+		o = append(o, jsonField{"gtid", t.GTID})
+	}
+	if e.outputServerID {
+		o = append(o, jsonField{"server_id", t.ServerID})
+	}
+	if e.outputPrimaryKeys {
+		keys, columns, err := e.primaryKeys(before, image)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, jsonField{"primary_key", keys}, jsonField{"primary_key_columns", columns})
+	}
+
+	data, err := e.row(image, nil)
+	if err != nil {
+		return nil, err
+	}
+	o = append(o, jsonField{"data", data})
+	if s.Type == StatementUpdate && before != nil && after != nil {
+		old, err := e.row(before, after)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, jsonField{"old", old})
+	}
+	return json.Marshal(o)
+}
+
+//primaryKeys 主键列的值以及列名，image中没有记录的主键列使用before中的值
+func (e *MaxwellEncoder) primaryKeys(before, image *RowData) ([]interface{}, []string, error) {
+	keys := []interface{}{}
+	columns := []string{}
+	for i, c := range image.Columns {
+		if !c.IsPrimaryKey {
+			continue
+		}
+		if c.IsEmpty && before != nil && i < len(before.Columns) {
+			c = before.Columns[i]
+		}
+		v, err := e.value(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, v)
+		columns = append(columns, c.Filed)
+	}
+	return keys, columns, nil
+}
+
+//row 行数据组成的json对象，没有记录(IsEmpty)的列不输出，changed不为nil时只输出与changed中不同的列
+func (e *MaxwellEncoder) row(r *RowData, changed *RowData) (jsonObject, error) {
+	o := make(jsonObject, 0, len(r.Columns))
+	for i, c := range r.Columns {
+		if c.IsEmpty {
+			continue
+		}
+		if changed != nil && i < len(changed.Columns) && !c.isChanged(changed.Columns[i]) {
+			continue
+		}
+		v, err := e.value(c)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, jsonField{c.Filed, v})
+	}
+	return o, nil
+}
+
+//value 列的值，bit列为数字，json列为json对象
+func (e *MaxwellEncoder) value(c *ColumnData) (interface{}, error) {
+	switch {
+	case c.Data == nil:
+		return nil, nil
+	case c.Type.IsBit():
+		return c.bitValue(), nil
+	case c.Type == columnTypeJSON && json.Valid(c.Data):
+		return json.RawMessage(c.Data), nil
+	}
+	return e.values.columnValue(c)
+}
@@ -0,0 +1,72 @@
+package gobinlog
+
+import (
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestMaxwellEncoder_EncodeTransaction(t *testing.T) {
+	table := MysqlTableName{DbName: "db", TableName: "t"}
+	row := func(id, name string) *RowData {
+		return &RowData{Columns: []*ColumnData{
+			{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte(id)},
+			{Filed: "name", Type: columnTypeVarchar, Data: []byte(name)},
+			{Filed: "doc", Type: columnTypeJSON, Data: []byte(`{"a": 1}`)},
+			{Filed: "flag", Type: columnTypeBit, Data: []byte{0x01}},
+			{Filed: "raw", Type: columnTypeBlob, Charset: "binary", Data: []byte{0xff}},
+		}}
+	}
+	tran := &Transaction{
+		NowPosition: Position{Filename: "binlog.000001", Offset: 4},
+		GTID:        "uuid:1",
+		ServerID:    7,
+		XID:         99,
+		Events: []*StreamEvent{
+			{Type: StatementAlter, Query: replication.Query{SQL: "alter table t add column c int"}},
+			{Type: StatementInsert, Table: table, Timestamp: 10, RowValues: []*RowData{row("1", "a"), row("2", "b")}},
+			{Type: StatementUpdate, Table: table, Timestamp: 11,
+				RowIdentifies: []*RowData{row("1", "a")}, RowValues: []*RowData{row("1", "c")}},
+		},
+	}
+
+	data := func(id, name string) string {
+		return `"data":{"id":` + id + `,"name":"` + name + `","doc":{"a":1},"flag":1,"raw":"/w=="}`
+	}
+	want := []string{
+		`{"database":"db","table":"t","type":"insert","ts":10,"xid":99,"xoffset":0,` + data("1", "a") + `}`,
+		`{"database":"db","table":"t","type":"insert","ts":10,"xid":99,"xoffset":1,` + data("2", "b") + `}`,
+		`{"database":"db","table":"t","type":"update","ts":11,"xid":99,"commit":true,` + data("1", "c") +
+			`,"old":{"name":"a"}}`,
+	}
+
+	e := NewMaxwellEncoder()
+	out, err := e.EncodeTransaction(tran)
+	if err != nil {
+		t.Fatalf("EncodeTransaction fail. err: %v", err)
+	}
+	if len(out) != len(want) {
+		t.Fatalf("len want: %v out: %v", len(want), len(out))
+	}
+	for i, v := range want {
+		if string(out[i]) != v {
+			t.Fatalf("%v want != out\nwant: %v\nout:  %s", i, v, out[i])
+		}
+	}
+
+	e.SetOutputBinlogPosition(true)
+	e.SetOutputGTID(true)
+	e.SetOutputServerID(true)
+	e.SetOutputPrimaryKeys(true)
+	tran.Events = tran.Events[2:]
+	out, err = e.EncodeTransaction(tran)
+	if err != nil {
+		t.Fatalf("EncodeTransaction fail. err: %v", err)
+	}
+	v := `{"database":"db","table":"t","type":"update","ts":11,"xid":99,"commit":true,` +
+		`"position":"binlog.000001:4","gtid":"uuid:1","server_id":7,"primary_key":[1],` +
+		`"primary_key_columns":["id"],` + data("1", "c") + `,"old":{"name":"a"}}`
+	if len(out) != 1 || string(out[0]) != v {
+		t.Fatalf("want != out\nwant: %v\nout:  %s", v, out)
+	}
+}
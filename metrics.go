@@ -0,0 +1,205 @@
+package gobinlog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//defaultLatencyBuckets SendTransactionFunc耗时直方图默认的桶上界，单位为秒
+var defaultLatencyBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+//StreamerStats Streamer的统计信息快照，通过Streamer.Stats获取
+type StreamerStats struct {
+	Events             map[string]uint64          //按照binlog event类型统计的个数，如query，write_rows
+	Transactions       uint64                     //发送给SendTransactionFunc或者IncrementalHandler的事务个数
+	Rows               map[StreamerRowsKey]uint64 //按照表以及操作统计的行数
+	BytesRead          uint64                     //读取的binlog event的总字节数
+	DecodeErrors       uint64                     //解析binlog event失败的次数
+	Position           Position                   //最后一个发送的事务的结束位置
+	LastEventTimestamp int64                      //最后一个binlog event的时间戳，秒
+	LagSeconds         int64                      //复制延迟，处理最后一个binlog event时的时间与其时间戳的差，秒
+	CallbackLatency    Histogram                  //SendTransactionFunc的耗时，秒
+	Compression        CompressionStats           //压缩事务的统计信息
+}
+
+//StreamerRowsKey 行数统计的键
+type StreamerRowsKey struct {
+	Table MysqlTableName
+	Type  StatementType //StatementInsert，StatementUpdate或者StatementDelete
+}
+
+//Histogram 直方图，Counts是小于等于Buckets中对应上界的累积个数
+type Histogram struct {
+	Buckets []float64 //每个桶的上界，递增
+	Counts  []uint64  //累积个数，与Buckets一一对应
+	Count   uint64    //总个数
+	Sum     float64   //总和
+}
+
+//observe 记录一个值
+func (h *Histogram) observe(v float64) {
+	if h.Counts == nil {
+		h.Counts = make([]uint64, len(h.Buckets))
+	}
+	for i, b := range h.Buckets {
+		if v <= b {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += v
+}
+
+func (h Histogram) clone() Histogram {
+	c := h
+	c.Buckets = append([]float64(nil), h.Buckets...)
+	c.Counts = make([]uint64, len(h.Buckets))
+	copy(c.Counts, h.Counts)
+	return c
+}
+
+//streamerMetrics Streamer运行时的统计信息，解析binlog的协程写入，Stats读取
+type streamerMetrics struct {
+	mu sync.Mutex
+
+	events             map[string]uint64
+	transactions       uint64
+	rows               map[StreamerRowsKey]uint64
+	bytesRead          uint64
+	decodeErrors       uint64
+	position           Position
+	lastEventTimestamp int64
+	lag                int64
+	latency            Histogram
+}
+
+//addEvent 记录一个binlog event，时间戳为0的event(如伪造的ROTATE_EVENT)不更新延迟
+func (m *streamerMetrics) addEvent(ev replication.BinlogEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = make(map[string]uint64)
+	}
+	m.events[binlogEventType(ev)]++
+	if ts := int64(ev.Timestamp()); ts != 0 {
+		m.lastEventTimestamp = ts
+		m.lag = time.Now().Unix() - ts
+		if m.lag < 0 {
+			m.lag = 0
+		}
+	}
+}
+
+func (m *streamerMetrics) addBytes(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesRead += uint64(n)
+}
+
+func (m *streamerMetrics) addDecodeError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decodeErrors++
+}
+
+//addTransaction 记录一个发送成功的事务以及SendTransactionFunc的耗时
+func (m *streamerMetrics) addTransaction(t *Transaction, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.latency.Buckets == nil {
+		m.latency.Buckets = defaultLatencyBuckets
+	}
+	m.transactions++
+	m.position = t.NextPosition
+	m.latency.observe(latency.Seconds())
+	m.addRowsLocked(t.Events)
+}
+
+//addRows 记录增量投递的语句中的行数
+func (m *streamerMetrics) addRows(events []*StreamEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addRowsLocked(events)
+}
+
+func (m *streamerMetrics) addRowsLocked(events []*StreamEvent) {
+	if m.rows == nil {
+		m.rows = make(map[StreamerRowsKey]uint64)
+	}
+	for _, s := range events {
+		if s.Query.SQL != "" {
+			continue
+		}
+		rows := len(s.RowValues)
+		if len(s.RowIdentifies) > rows {
+			rows = len(s.RowIdentifies)
+		}
+		m.rows[StreamerRowsKey{Table: s.Table, Type: s.Type}] += uint64(rows)
+	}
+}
+
+func (m *streamerMetrics) stats() StreamerStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := StreamerStats{
+		Events:             make(map[string]uint64, len(m.events)),
+		Transactions:       m.transactions,
+		Rows:               make(map[StreamerRowsKey]uint64, len(m.rows)),
+		BytesRead:          m.bytesRead,
+		DecodeErrors:       m.decodeErrors,
+		Position:           m.position,
+		LastEventTimestamp: m.lastEventTimestamp,
+		LagSeconds:         m.lag,
+	}
+	latency := m.latency
+	if latency.Buckets == nil {
+		latency.Buckets = defaultLatencyBuckets
+	}
+	s.CallbackLatency = latency.clone()
+	for k, v := range m.events {
+		s.Events[k] = v
+	}
+	for k, v := range m.rows {
+		s.Rows[k] = v
+	}
+	return s
+}
+
+//binlogEventType binlog event类型的名称，用于统计
+func binlogEventType(ev replication.BinlogEvent) string {
+	switch {
+	case ev.IsFormatDescription():
+		return "format_description"
+	case ev.IsXID():
+		return "xid"
+	case ev.IsRotate():
+		return "rotate"
+	case ev.IsQuery():
+		return "query"
+	case ev.IsTableMap():
+		return "table_map"
+	case ev.IsWriteRows():
+		return "write_rows"
+	case ev.IsUpdateRows():
+		return "update_rows"
+	case ev.IsDeleteRows():
+		return "delete_rows"
+	case ev.IsTransactionPayload():
+		return "transaction_payload"
+	case ev.IsPreviousGTIDs():
+		return "previous_gtids"
+	case ev.IsGTID():
+		return "gtid"
+	case ev.IsAnonymousGTID():
+		return "anonymous_gtid"
+	case ev.IsRand():
+		return "rand"
+	case ev.IsIntVar():
+		return "intvar"
+	case ev.IsRowsQuery():
+		return "rows_query"
+	}
+	return "other"
+}
@@ -0,0 +1,88 @@
+package gobinlog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestStreamer_Stats(t *testing.T) {
+	s, err := NewStreamer(testDSN, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewStreamer err: %v", err)
+	}
+	s.SetBinlogPosition(testBinlogPosParseEvents)
+	if stats := s.Stats(); stats.Position != testBinlogPosParseEvents || stats.Transactions != 0 {
+		t.Fatalf("stats before stream want position: %+v out: %+v", testBinlogPosParseEvents, stats)
+	}
+
+	s.sendTransaction = func(tran *Transaction) error {
+		return nil
+	}
+	input := getInputData()
+	events := make(chan replication.BinlogEvent)
+	go func() {
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+	}()
+	if _, pErr := s.parseEvents(context.Background(), events); pErr != nil {
+		t.Fatalf("parseEvents err != %v, err: %v", nil, pErr)
+	}
+
+	stats := s.Stats()
+	wantEvents := map[string]uint64{
+		"format_description": 1, "query": 1, "table_map": 1, "write_rows": 1,
+		"update_rows": 1, "delete_rows": 1, "xid": 1,
+	}
+	for typ, n := range wantEvents {
+		if stats.Events[typ] != n {
+			t.Fatalf("Events[%v] want: %v out: %+v", typ, n, stats.Events)
+		}
+	}
+	for _, typ := range []StatementType{StatementInsert, StatementUpdate, StatementDelete} {
+		if n := stats.Rows[StreamerRowsKey{Table: tesInfo.name, Type: typ}]; n != 1 {
+			t.Fatalf("Rows[%v] want: 1 out: %+v", typ, stats.Rows)
+		}
+	}
+	if stats.Transactions != 1 || stats.BytesRead == 0 || stats.DecodeErrors != 0 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	if stats.Position.Offset != 4 || stats.LastEventTimestamp != 1407805592 || stats.LagSeconds <= 0 {
+		t.Fatalf("bad position or lag: %+v", stats)
+	}
+	if h := stats.CallbackLatency; h.Count != 1 || h.Counts[len(h.Counts)-1] != 1 {
+		t.Fatalf("bad callback latency: %+v", h)
+	}
+
+	s.sendTransaction = func(tran *Transaction) error {
+		return fmt.Errorf("send fail")
+	}
+	events = make(chan replication.BinlogEvent)
+	go func() {
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+	}()
+	if _, pErr := s.parseEvents(context.Background(), events); pErr == nil {
+		t.Fatalf("parseEvents want error")
+	}
+	if stats = s.Stats(); stats.DecodeErrors != 0 || stats.Transactions != 1 {
+		t.Fatalf("callback error should not be a decode error: %+v", stats)
+	}
+}
+
+func TestHistogram_observe(t *testing.T) {
+	h := Histogram{Buckets: []float64{0.1, 1}}
+	for _, v := range []time.Duration{50 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second} {
+		h.observe(v.Seconds())
+	}
+	if h.Counts[0] != 1 || h.Counts[1] != 2 || h.Count != 3 || h.Sum != 2.55 {
+		t.Fatalf("bad histogram: %+v", h)
+	}
+}
@@ -0,0 +1,141 @@
+package gobinlog
+
+//TransactionMiddleware 事务中间件，返回包装了next的处理函数，可以修改事务后调用next，
+//不调用next即丢弃该事务，多次调用next即将事务拆分为多个事务，
+//事务以及语句可能被之前的中间件或者统计信息共用，修改时需要复制而不是直接修改
+type TransactionMiddleware func(next SendTransactionFunc) SendTransactionFunc
+
+//StreamEventMiddleware 语句中间件，返回替换该语句的语句，返回空即丢弃该语句，返回多个即拆分该语句
+type StreamEventMiddleware func(s *StreamEvent) ([]*StreamEvent, error)
+
+//ChainMiddlewares 将中间件组合到send之前，事务按照middlewares的顺序依次经过各个中间件，最后交给send
+func ChainMiddlewares(send SendTransactionFunc, middlewares ...TransactionMiddleware) SendTransactionFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		send = middlewares[i](send)
+	}
+	return send
+}
+
+//EventMiddleware 将语句中间件转换为事务中间件，事务中的每个语句依次经过f，
+//所有语句都被丢弃的事务仍然会交给next，以便保存检查点，可以使用DropEmptyTransactions丢弃，
+//溢出到磁盘的事务中的语句在通过Transaction.Iterator读取时才经过f，f返回的错误由迭代器返回
+func EventMiddleware(f StreamEventMiddleware) TransactionMiddleware {
+	return func(next SendTransactionFunc) SendTransactionFunc {
+		return func(t *Transaction) error {
+			if t.spill != nil {
+				r := *t
+				r.transforms = append(t.transforms[:len(t.transforms):len(t.transforms)], f)
+				return next(&r)
+			}
+			events := make([]*StreamEvent, 0, len(t.Events))
+			for _, s := range t.Events {
+				v, err := f(s)
+				if err != nil {
+					return err
+				}
+				events = append(events, v...)
+			}
+			r := *t
+			r.Events = events
+			return next(&r)
+		}
+	}
+}
+
+//transformEvent 语句依次经过语句中间件，返回最后一个中间件输出的所有语句
+func transformEvent(e *StreamEvent, middlewares []StreamEventMiddleware) ([]*StreamEvent, error) {
+	events := []*StreamEvent{e}
+	for _, f := range middlewares {
+		var out []*StreamEvent
+		for _, e := range events {
+			v, err := f(e)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v...)
+		}
+		events = out
+	}
+	return events, nil
+}
+
+//FilterTables 只保留filter返回true的表的行数据变更，sql语句(如DDL)不会被过滤
+func FilterTables(filter func(name MysqlTableName) bool) TransactionMiddleware {
+	return EventMiddleware(func(s *StreamEvent) ([]*StreamEvent, error) {
+		if s.Query.SQL == "" && !filter(s.Table) {
+			return nil, nil
+		}
+		return []*StreamEvent{s}, nil
+	})
+}
+
+//FilterStatements 只保留这些类型的语句
+func FilterStatements(types ...StatementType) TransactionMiddleware {
+	set := make(map[StatementType]bool, len(types))
+	for _, typ := range types {
+		set[typ] = true
+	}
+	return EventMiddleware(func(s *StreamEvent) ([]*StreamEvent, error) {
+		if !set[s.Type] {
+			return nil, nil
+		}
+		return []*StreamEvent{s}, nil
+	})
+}
+
+//RenameTables 修改行数据变更中的库名以及表名
+func RenameTables(rename func(name MysqlTableName) MysqlTableName) TransactionMiddleware {
+	return EventMiddleware(func(s *StreamEvent) ([]*StreamEvent, error) {
+		if s.Query.SQL != "" {
+			return []*StreamEvent{s}, nil
+		}
+		r := *s
+		r.Table = rename(s.Table)
+		return []*StreamEvent{&r}, nil
+	})
+}
+
+//SplitRows 将行数超过n的行数据变更拆分为多个不超过n行的语句，n小于等于0时不拆分
+func SplitRows(n int) TransactionMiddleware {
+	return EventMiddleware(func(s *StreamEvent) ([]*StreamEvent, error) {
+		rows := len(s.RowValues)
+		if len(s.RowIdentifies) > rows {
+			rows = len(s.RowIdentifies)
+		}
+		if n <= 0 || rows <= n {
+			return []*StreamEvent{s}, nil
+		}
+		var events []*StreamEvent
+		for i := 0; i < rows; i += n {
+			r := *s
+			r.RowValues = sliceRows(s.RowValues, i, i+n)
+			r.RowIdentifies = sliceRows(s.RowIdentifies, i, i+n)
+			events = append(events, &r)
+		}
+		return events, nil
+	})
+}
+
+//sliceRows 越界时截断
+func sliceRows(rows []*RowData, start, end int) []*RowData {
+	if start >= len(rows) {
+		return nil
+	}
+	if end > len(rows) {
+		end = len(rows)
+	}
+	return rows[start:end:end]
+}
+
+//DropEmptyTransactions 丢弃没有语句的事务，丢弃后下游无法通过这些事务保存检查点，
+//溢出到磁盘的事务不会被丢弃
+func DropEmptyTransactions() TransactionMiddleware {
+	return func(next SendTransactionFunc) SendTransactionFunc {
+		return func(t *Transaction) error {
+			if len(t.Events) == 0 && t.spill == nil {
+				return nil
+			}
+			return next(t)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package gobinlog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func testMiddlewareTransaction() *Transaction {
+	return &Transaction{
+		NextPosition: Position{Filename: "binlog.000001", Offset: 100},
+		Events: []*StreamEvent{
+			{Type: StatementAlter, Query: replication.Query{SQL: "alter table t add c int"}},
+			{Type: StatementInsert, Table: NewMysqlTableName("db", "a"),
+				RowValues: []*RowData{{}, {}, {}}},
+			{Type: StatementDelete, Table: NewMysqlTableName("db", "b"),
+				RowIdentifies: []*RowData{{}}},
+		},
+	}
+}
+
+//testMiddlewareSummary 每个语句的类型，表名以及行数
+func testMiddlewareSummary(transactions []*Transaction) []string {
+	var out []string
+	for _, t := range transactions {
+		for _, s := range t.Events {
+			out = append(out, fmt.Sprintf("%v %v %v", s.Type, s.Table.String(),
+				len(s.RowValues)+len(s.RowIdentifies)))
+		}
+	}
+	return out
+}
+
+func TestChainMiddlewares(t *testing.T) {
+	testCases := []struct {
+		middlewares []TransactionMiddleware
+		want        []string
+	}{
+		{
+			middlewares: nil,
+			want:        []string{"alter ``.`` 0", "insert `db`.`a` 3", "delete `db`.`b` 1"},
+		},
+		{
+			middlewares: []TransactionMiddleware{FilterTables(func(name MysqlTableName) bool {
+				return name.TableName == "b"
+			})},
+			want: []string{"alter ``.`` 0", "delete `db`.`b` 1"},
+		},
+		{
+			middlewares: []TransactionMiddleware{FilterStatements(StatementInsert, StatementDelete)},
+			want:        []string{"insert `db`.`a` 3", "delete `db`.`b` 1"},
+		},
+		{
+			middlewares: []TransactionMiddleware{
+				RenameTables(func(name MysqlTableName) MysqlTableName {
+					return NewMysqlTableName("copy", name.TableName)
+				}),
+				SplitRows(2),
+			},
+			want: []string{"alter ``.`` 0", "insert `copy`.`a` 2", "insert `copy`.`a` 1", "delete `copy`.`b` 1"},
+		},
+		{
+			middlewares: []TransactionMiddleware{FilterStatements(StatementUpdate), DropEmptyTransactions()},
+			want:        nil,
+		},
+	}
+
+	for i, v := range testCases {
+		var out []*Transaction
+		send := ChainMiddlewares(func(t *Transaction) error {
+			out = append(out, t)
+			return nil
+		}, v.middlewares...)
+		tran := testMiddlewareTransaction()
+		if err := send(tran); err != nil {
+			t.Fatalf("%v send fail. err: %v", i, err)
+		}
+		if summary := testMiddlewareSummary(out); !reflect.DeepEqual(summary, v.want) {
+			t.Fatalf("%v want != out\nwant: %q\nout:  %q", i, v.want, summary)
+		}
+		if summary := testMiddlewareSummary([]*Transaction{tran}); len(summary) != 3 ||
+			summary[1] != "insert `db`.`a` 3" {
+			t.Fatalf("%v original transaction was modified: %q", i, summary)
+		}
+	}
+}
+
+func TestStreamer_Use(t *testing.T) {
+	s, err := NewStreamer(testDSN, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewStreamer err: %v", err)
+	}
+	var order []string
+	mark := func(name string) TransactionMiddleware {
+		return func(next SendTransactionFunc) SendTransactionFunc {
+			return func(t *Transaction) error {
+				order = append(order, name)
+				return next(t)
+			}
+		}
+	}
+	split := func(next SendTransactionFunc) SendTransactionFunc {
+		return func(t *Transaction) error {
+			for _, s := range t.Events {
+				r := *t
+				r.Events = []*StreamEvent{s}
+				if err := next(&r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	s.Use(mark("first"), split)
+	s.Use(mark("second"))
+
+	send := ChainMiddlewares(func(t *Transaction) error {
+		order = append(order, "send")
+		return nil
+	}, s.middlewares...)
+	if err = send(testMiddlewareTransaction()); err != nil {
+		t.Fatalf("send fail. err: %v", err)
+	}
+	want := []string{"first", "second", "send", "second", "send", "second", "send"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, order)
+	}
+
+	fail := EventMiddleware(func(s *StreamEvent) ([]*StreamEvent, error) {
+		return nil, fmt.Errorf("event fail")
+	})
+	if err = ChainMiddlewares(func(t *Transaction) error { return nil }, fail)(testMiddlewareTransaction()); err == nil {
+		t.Fatalf("EventMiddleware want error")
+	}
+}
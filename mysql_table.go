@@ -6,12 +6,36 @@ type MysqlColumn interface {
 	IsUnSignedInt() bool //是否是无符号整形
 }
 
+//MysqlPrimaryKeyColumn 用于标识主键列的接口，MysqlColumn可以选择实现该接口，
+//未实现时认为该列不是主键
+type MysqlPrimaryKeyColumn interface {
+	IsPrimaryKey() bool //是否是主键列
+}
+
+//MysqlTypeColumn 用于提供列定义类型的接口，MysqlColumn可以选择实现该接口，
+//未实现或者返回空时通过binlog中的列类型推断
+type MysqlTypeColumn interface {
+	Type() string //列类型，与information_schema.COLUMNS.COLUMN_TYPE相同，如int(11) unsigned
+}
+
 //MysqlTable 用于实现mysql表的接口
 type MysqlTable interface {
 	Name() MysqlTableName   //表名
 	Columns() []MysqlColumn //所有列
 }
 
+//PrimaryKeyColumns 获取表的主键列在Columns()中的下标，没有主键或者列没有实现
+//MysqlPrimaryKeyColumn时返回空
+func PrimaryKeyColumns(table MysqlTable) []int {
+	var keys []int
+	for i, c := range table.Columns() {
+		if pk, ok := c.(MysqlPrimaryKeyColumn); ok && pk.IsPrimaryKey() {
+			keys = append(keys, i)
+		}
+	}
+	return keys
+}
+
 //MysqlTableName mysql的表名
 type MysqlTableName struct {
 	DbName    string `json:"db"`    //数据库名
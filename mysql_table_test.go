@@ -1,6 +1,9 @@
 package gobinlog
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestMysqlTableName_String(t *testing.T) {
 	testCases := []struct {
@@ -20,3 +23,42 @@ func TestMysqlTableName_String(t *testing.T) {
 		}
 	}
 }
+
+func TestPrimaryKeyColumns(t *testing.T) {
+	testCases := []struct {
+		input MysqlTable
+		want  []int
+	}{
+		{
+			input: tesInfo,
+			want:  []int{0},
+		},
+		{
+			input: &mysqlTableInfo{
+				name: tesInfo.name,
+				columns: []MysqlColumn{
+					&mysqlColumnAttribute{field: "a", typ: "int(11)", key: "PRI"},
+					&mysqlColumnAttribute{field: "b", typ: "int(11)"},
+					&mysqlColumnAttribute{field: "c", typ: "int(11)", key: "PRI"},
+				},
+			},
+			want: []int{0, 2},
+		},
+		{
+			input: &mysqlTableInfo{
+				name: tesInfo.name,
+				columns: []MysqlColumn{
+					&mysqlColumnAttribute{field: "a", typ: "int(11)", key: "UNI"},
+				},
+			},
+			want: nil,
+		},
+	}
+
+	for _, v := range testCases {
+		out := PrimaryKeyColumns(v.input)
+		if !reflect.DeepEqual(v.want, out) {
+			t.Fatalf("want != out input: %+v want: %v, out: %v", v.input, v.want, out)
+		}
+	}
+}
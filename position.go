@@ -10,3 +10,26 @@ type Position struct {
 func (p Position) IsZero() bool {
 	return p.Filename == "" || p.Offset == 0
 }
+
+//Compare 比较两个位置的先后，p在o之前返回-1，相同返回0，之后返回1，
+//binlog文件名序号位数增加时文件名更长，所以先比较文件名的长度
+func (p Position) Compare(o Position) int {
+	switch {
+	case len(p.Filename) != len(o.Filename):
+		if len(p.Filename) < len(o.Filename) {
+			return -1
+		}
+		return 1
+	case p.Filename != o.Filename:
+		if p.Filename < o.Filename {
+			return -1
+		}
+		return 1
+	case p.Offset != o.Offset:
+		if p.Offset < o.Offset {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
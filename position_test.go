@@ -48,3 +48,24 @@ func TestPosition_IsZero(t *testing.T) {
 		}
 	}
 }
+
+func TestPosition_Compare(t *testing.T) {
+	testCases := []struct {
+		p    Position
+		o    Position
+		want int
+	}{
+		{Position{"binlog.000005", 4}, Position{"binlog.000005", 4}, 0},
+		{Position{"binlog.000005", 4}, Position{"binlog.000005", 120}, -1},
+		{Position{"binlog.000005", 120}, Position{"binlog.000005", 4}, 1},
+		{Position{"binlog.000006", 4}, Position{"binlog.000005", 120}, 1},
+		{Position{"binlog.999999", 4}, Position{"binlog.1000000", 4}, -1},
+	}
+
+	for _, v := range testCases {
+		out := v.p.Compare(v.o)
+		if v.want != out {
+			t.Fatalf("want != out p: %+v o: %+v want: %v, out: %v", v.p, v.o, v.want, out)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package gobinlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//defaultPrometheusNamespace 默认的指标名前缀
+const defaultPrometheusNamespace = "gobinlog"
+
+//PrometheusExporter 将Streamer.Stats按照prometheus的文本格式(text/plain; version=0.0.4)输出，
+//不依赖prometheus的客户端库，实现了http.Handler，可以直接注册到http.ServeMux中，如
+//   http.Handle("/metrics", gobinlog.NewPrometheusExporter(streamer))
+type PrometheusExporter struct {
+	streamer  *Streamer
+	namespace string
+}
+
+//NewPrometheusExporter 创建PrometheusExporter，指标名的前缀默认为gobinlog
+func NewPrometheusExporter(s *Streamer) *PrometheusExporter {
+	return &PrometheusExporter{
+		streamer:  s,
+		namespace: defaultPrometheusNamespace,
+	}
+}
+
+//SetNamespace 设置指标名的前缀，为空时指标名没有前缀
+func (p *PrometheusExporter) SetNamespace(namespace string) {
+	p.namespace = namespace
+}
+
+//ServeHTTP 输出当前的统计信息
+func (p *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := p.WriteTo(w); err != nil {
+		_log.Errorf("PrometheusExporter write metrics fail. err: %v", err)
+	}
+}
+
+//WriteTo 将当前的统计信息按照prometheus的文本格式写入w
+func (p *PrometheusExporter) WriteTo(w io.Writer) (int64, error) {
+	stats := p.streamer.Stats()
+	pw := &prometheusWriter{w: bufio.NewWriter(w)}
+
+	name := p.name("events_total")
+	pw.header(name, "counter", "Number of binlog events by type.")
+	types := make([]string, 0, len(stats.Events))
+	for typ := range stats.Events {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		pw.sample(name, []string{"type", typ}, float64(stats.Events[typ]))
+	}
+
+	name = p.name("transactions_total")
+	pw.header(name, "counter", "Number of transactions sent to the callback.")
+	pw.sample(name, nil, float64(stats.Transactions))
+
+	name = p.name("rows_total")
+	pw.header(name, "counter", "Number of changed rows by table and operation.")
+	keys := make([]StreamerRowsKey, 0, len(stats.Rows))
+	for k := range stats.Rows {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Table != keys[j].Table {
+			return keys[i].Table.String() < keys[j].Table.String()
+		}
+		return keys[i].Type < keys[j].Type
+	})
+	for _, k := range keys {
+		pw.sample(name, []string{"database", k.Table.DbName, "table", k.Table.TableName, "op", k.Type.String()},
+			float64(stats.Rows[k]))
+	}
+
+	name = p.name("read_bytes_total")
+	pw.header(name, "counter", "Number of binlog event bytes read.")
+	pw.sample(name, nil, float64(stats.BytesRead))
+
+	name = p.name("decode_errors_total")
+	pw.header(name, "counter", "Number of binlog event decode errors.")
+	pw.sample(name, nil, float64(stats.DecodeErrors))
+
+	name = p.name("position")
+	pw.header(name, "gauge", "Binlog offset after the last transaction.")
+	pw.sample(name, []string{"file", stats.Position.Filename}, float64(stats.Position.Offset))
+
+	name = p.name("last_event_timestamp_seconds")
+	pw.header(name, "gauge", "Timestamp of the last binlog event.")
+	pw.sample(name, nil, float64(stats.LastEventTimestamp))
+
+	name = p.name("lag_seconds")
+	pw.header(name, "gauge", "Replication lag when the last binlog event was processed.")
+	pw.sample(name, nil, float64(stats.LagSeconds))
+
+	name = p.name("callback_duration_seconds")
+	pw.header(name, "histogram", "Latency of the transaction callback.")
+	h := stats.CallbackLatency
+	for i, b := range h.Buckets {
+		pw.sample(name+"_bucket", []string{"le", formatPrometheusFloat(b)}, float64(h.Counts[i]))
+	}
+	pw.sample(name+"_bucket", []string{"le", "+Inf"}, float64(h.Count))
+	pw.sample(name+"_sum", nil, h.Sum)
+	pw.sample(name+"_count", nil, float64(h.Count))
+
+	name = p.name("compressed_transactions_total")
+	pw.header(name, "counter", "Number of compressed transaction payload events.")
+	pw.sample(name, nil, float64(stats.Compression.Events))
+
+	name = p.name("compressed_bytes_total")
+	pw.header(name, "counter", "Compressed bytes of transaction payload events.")
+	pw.sample(name, nil, float64(stats.Compression.CompressedBytes))
+
+	name = p.name("uncompressed_bytes_total")
+	pw.header(name, "counter", "Uncompressed bytes of transaction payload events.")
+	pw.sample(name, nil, float64(stats.Compression.UncompressedBytes))
+
+	if pw.err == nil {
+		pw.err = pw.w.Flush()
+	}
+	return pw.n, pw.err
+}
+
+func (p *PrometheusExporter) name(name string) string {
+	if p.namespace == "" {
+		return name
+	}
+	return p.namespace + "_" + name
+}
+
+//prometheusWriter 写入prometheus文本格式，出错后不再写入
+type prometheusWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (p *prometheusWriter) printf(format string, a ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	var n int
+	n, p.err = fmt.Fprintf(p.w, format, a...)
+	p.n += int64(n)
+}
+
+func (p *prometheusWriter) header(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+//sample 写入一个样本，labels是依次排列的标签名和标签值
+func (p *prometheusWriter) sample(name string, labels []string, value float64) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString(`="`)
+			b.WriteString(prometheusLabelReplacer.Replace(labels[i+1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	p.printf("%s %s\n", b.String(), formatPrometheusFloat(value))
+}
+
+//prometheusLabelReplacer 标签值中需要转义的字符
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//formatPrometheusFloat 整数不使用科学计数法
+func formatPrometheusFloat(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,52 @@
+package gobinlog
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestPrometheusExporter_ServeHTTP(t *testing.T) {
+	s, err := NewStreamer(testDSN, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewStreamer err: %v", err)
+	}
+	s.metrics.addBytes(100)
+	s.metrics.addTransaction(&Transaction{
+		NextPosition: Position{Filename: "binlog.000002", Offset: 1024},
+		Events: []*StreamEvent{
+			{Type: StatementAlter, Query: replication.Query{SQL: "alter table t add c int"}},
+			{Type: StatementInsert, Table: NewMysqlTableName("db", `t"1`), RowValues: []*RowData{{}, {}}},
+		},
+	}, 2*time.Millisecond)
+
+	e := NewPrometheusExporter(s)
+	e.SetNamespace("binlog")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	out := w.Body.String()
+
+	want := []string{
+		"# TYPE binlog_transactions_total counter\nbinlog_transactions_total 1\n",
+		`binlog_rows_total{database="db",table="t\"1",op="insert"} 2` + "\n",
+		"binlog_read_bytes_total 100\n",
+		`binlog_position{file="binlog.000002"} 1024` + "\n",
+		"# TYPE binlog_callback_duration_seconds histogram\n",
+		`binlog_callback_duration_seconds_bucket{le="0.001"} 0` + "\n",
+		`binlog_callback_duration_seconds_bucket{le="0.005"} 1` + "\n",
+		`binlog_callback_duration_seconds_bucket{le="+Inf"} 1` + "\n",
+		"binlog_callback_duration_seconds_count 1\n",
+		"binlog_compressed_transactions_total 0\n",
+	}
+	for _, v := range want {
+		if !strings.Contains(out, v) {
+			t.Fatalf("want: %q\nout: %v", v, out)
+		}
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("bad content type: %v", ct)
+	}
+}
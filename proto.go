@@ -0,0 +1,548 @@
+package gobinlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/Breeze0806/gobinlog/replication"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+//proto/gobinlog.proto中的字段编号
+const (
+	protoPositionFilename protowire.Number = 1
+	protoPositionOffset   protowire.Number = 2
+
+	protoTransactionNowPosition    protowire.Number = 1
+	protoTransactionNextPosition   protowire.Number = 2
+	protoTransactionTimestamp      protowire.Number = 3
+	protoTransactionGTID           protowire.Number = 4
+	protoTransactionLastCommitted  protowire.Number = 5
+	protoTransactionSequenceNumber protowire.Number = 6
+	protoTransactionEvents         protowire.Number = 7
+	protoTransactionServerID       protowire.Number = 8
+	protoTransactionXID            protowire.Number = 9
+	protoTransactionSource         protowire.Number = 10
+
+	protoTableNameDb    protowire.Number = 1
+	protoTableNameTable protowire.Number = 2
+
+	protoStreamEventType          protowire.Number = 1
+	protoStreamEventTable         protowire.Number = 2
+	protoStreamEventSQL           protowire.Number = 3
+	protoStreamEventTimestamp     protowire.Number = 4
+	protoStreamEventRowValues     protowire.Number = 5
+	protoStreamEventRowIdentifies protowire.Number = 6
+	protoStreamEventDatabase      protowire.Number = 7
+	protoStreamEventCharset       protowire.Number = 8
+
+	protoCharsetClient protowire.Number = 1
+	protoCharsetConn   protowire.Number = 2
+	protoCharsetServer protowire.Number = 3
+
+	protoRowDataColumns protowire.Number = 1
+
+	protoJSONDiffOperation protowire.Number = 1
+	protoJSONDiffPath      protowire.Number = 2
+	protoJSONDiffValue     protowire.Number = 3
+
+	protoColumnDataField        protowire.Number = 1
+	protoColumnDataType         protowire.Number = 2
+	protoColumnDataIsEmpty      protowire.Number = 3
+	protoColumnDataIsPrimaryKey protowire.Number = 4
+	protoColumnDataCharset      protowire.Number = 5
+	protoColumnDataIntValue     protowire.Number = 6
+	protoColumnDataUintValue    protowire.Number = 7
+	protoColumnDataDoubleValue  protowire.Number = 8
+	protoColumnDataStringValue  protowire.Number = 9
+	protoColumnDataBytesValue   protowire.Number = 10
+	protoColumnDataJSONDiffs    protowire.Number = 11
+)
+
+//MarshalProto 实现Transaction的protobuf序列化，结构见proto/gobinlog.proto
+func (t *Transaction) MarshalProto() ([]byte, error) {
+	return t.appendProto(nil)
+}
+
+//UnmarshalProto 实现Transaction的protobuf反序列化
+func (t *Transaction) UnmarshalProto(data []byte) error {
+	*t = Transaction{}
+	return rangeProtoFields(data, func(f protoField) (err error) {
+		switch f.num {
+		case protoTransactionNowPosition:
+			err = f.message(t.NowPosition.unmarshalProto)
+		case protoTransactionNextPosition:
+			err = f.message(t.NextPosition.unmarshalProto)
+		case protoTransactionTimestamp:
+			t.Timestamp, err = f.int64()
+		case protoTransactionGTID:
+			t.GTID, err = f.string()
+		case protoTransactionLastCommitted:
+			t.LastCommitted, err = f.int64()
+		case protoTransactionSequenceNumber:
+			t.SequenceNumber, err = f.int64()
+		case protoTransactionEvents:
+			s := &StreamEvent{}
+			if err = f.message(s.UnmarshalProto); err == nil {
+				t.Events = append(t.Events, s)
+			}
+		case protoTransactionServerID:
+			var v int64
+			v, err = f.int64()
+			t.ServerID = uint32(v)
+		case protoTransactionXID:
+			t.XID, err = f.varint()
+		case protoTransactionSource:
+			t.Source, err = f.string()
+		}
+		return
+	})
+}
+
+//MarshalProto 实现StreamEvent的protobuf序列化
+func (s *StreamEvent) MarshalProto() ([]byte, error) {
+	return s.appendProto(nil), nil
+}
+
+//UnmarshalProto 实现StreamEvent的protobuf反序列化
+func (s *StreamEvent) UnmarshalProto(data []byte) error {
+	*s = StreamEvent{}
+	return rangeProtoFields(data, func(f protoField) (err error) {
+		switch f.num {
+		case protoStreamEventType:
+			var v int64
+			v, err = f.int64()
+			s.Type = StatementType(v)
+		case protoStreamEventTable:
+			err = f.message(func(b []byte) error {
+				return unmarshalProtoTableName(b, &s.Table)
+			})
+		case protoStreamEventSQL:
+			s.Query.SQL, err = f.string()
+		case protoStreamEventTimestamp:
+			s.Timestamp, err = f.int64()
+		case protoStreamEventRowValues:
+			r := &RowData{}
+			if err = f.message(r.UnmarshalProto); err == nil {
+				s.RowValues = append(s.RowValues, r)
+			}
+		case protoStreamEventRowIdentifies:
+			r := &RowData{}
+			if err = f.message(r.UnmarshalProto); err == nil {
+				s.RowIdentifies = append(s.RowIdentifies, r)
+			}
+		case protoStreamEventDatabase:
+			s.Query.Database, err = f.string()
+		case protoStreamEventCharset:
+			s.Query.Charset = &replication.Charset{}
+			err = f.message(func(b []byte) error {
+				return unmarshalProtoCharset(b, s.Query.Charset)
+			})
+		}
+		return
+	})
+}
+
+//MarshalProto 实现RowData的protobuf序列化
+func (r *RowData) MarshalProto() ([]byte, error) {
+	return r.appendProto(nil), nil
+}
+
+//UnmarshalProto 实现RowData的protobuf反序列化
+func (r *RowData) UnmarshalProto(data []byte) error {
+	*r = RowData{}
+	return rangeProtoFields(data, func(f protoField) (err error) {
+		if f.num == protoRowDataColumns {
+			c := &ColumnData{}
+			if err = f.message(c.UnmarshalProto); err == nil {
+				r.Columns = append(r.Columns, c)
+			}
+		}
+		return
+	})
+}
+
+//MarshalProto 实现ColumnData的protobuf序列化，整形以及实数列在可以无损还原时使用数字类型，
+//其他列使用字符串或者二进制，Data为nil时不设置值
+func (c *ColumnData) MarshalProto() ([]byte, error) {
+	return c.appendProto(nil), nil
+}
+
+//UnmarshalProto 实现ColumnData的protobuf反序列化，JSONDiff只还原Operation，Path和Value
+func (c *ColumnData) UnmarshalProto(data []byte) error {
+	*c = ColumnData{}
+	var double *float64
+	err := rangeProtoFields(data, func(f protoField) (err error) {
+		switch f.num {
+		case protoColumnDataField:
+			c.Filed, err = f.string()
+		case protoColumnDataType:
+			var v int64
+			v, err = f.int64()
+			c.Type = ColumnType(v)
+		case protoColumnDataIsEmpty:
+			c.IsEmpty, err = f.bool()
+		case protoColumnDataIsPrimaryKey:
+			c.IsPrimaryKey, err = f.bool()
+		case protoColumnDataCharset:
+			c.Charset, err = f.string()
+		case protoColumnDataIntValue:
+			var v uint64
+			if v, err = f.varint(); err == nil {
+				double, c.Data = nil, strconv.AppendInt(nil, protowire.DecodeZigZag(v), 10)
+			}
+		case protoColumnDataUintValue:
+			var v uint64
+			if v, err = f.varint(); err == nil {
+				double, c.Data = nil, strconv.AppendUint(nil, v, 10)
+			}
+		case protoColumnDataDoubleValue:
+			var v float64
+			if v, err = f.double(); err == nil {
+				double, c.Data = &v, nil
+			}
+		case protoColumnDataStringValue, protoColumnDataBytesValue:
+			var v []byte
+			if v, err = f.bytes(); err == nil {
+				double, c.Data = nil, append([]byte{}, v...)
+			}
+		case protoColumnDataJSONDiffs:
+			d := replication.JSONDiff{}
+			err = f.message(func(b []byte) error {
+				return unmarshalProtoJSONDiff(b, &d)
+			})
+			c.JSONDiffs = append(c.JSONDiffs, d)
+		}
+		return
+	})
+	//实数的输出精度依赖于列类型，字段的顺序是不确定的
+	if err == nil && double != nil && c.Data == nil {
+		c.Data = strconv.AppendFloat(nil, *double, 'f', -1, c.floatBitSize())
+	}
+	return err
+}
+
+//WriteProtoFrame 以varint长度前缀加protobuf的格式写入事务，与protobuf的writeDelimitedTo兼容
+func WriteProtoFrame(w io.Writer, t *Transaction) error {
+	data, err := t.MarshalProto()
+	if err != nil {
+		return err
+	}
+	frame := protowire.AppendBytes(make([]byte, 0, len(data)+binary.MaxVarintLen64), data)
+	_, err = w.Write(frame)
+	return err
+}
+
+//ReadProtoFrame 读取WriteProtoFrame写入的事务，没有更多的事务时返回io.EOF
+func ReadProtoFrame(r *bufio.Reader) (*Transaction, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	if _, err = io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	t := &Transaction{}
+	if err = t.UnmarshalProto(data); err != nil {
+		return nil, newError(err).msgf("frame UnmarshalProto fail.")
+	}
+	return t, nil
+}
+
+func (t *Transaction) appendProto(b []byte) ([]byte, error) {
+	b = appendProtoMessage(b, protoTransactionNowPosition, t.NowPosition.appendProto(nil))
+	b = appendProtoMessage(b, protoTransactionNextPosition, t.NextPosition.appendProto(nil))
+	b = appendProtoInt64(b, protoTransactionTimestamp, t.Timestamp)
+	b = appendProtoString(b, protoTransactionGTID, t.GTID)
+	b = appendProtoInt64(b, protoTransactionLastCommitted, t.LastCommitted)
+	b = appendProtoInt64(b, protoTransactionSequenceNumber, t.SequenceNumber)
+	//溢出到磁盘的事务从溢出文件中依次读取语句
+	err := t.RangeEvents(func(s *StreamEvent) error {
+		b = appendProtoMessage(b, protoTransactionEvents, s.appendProto(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b = appendProtoInt64(b, protoTransactionServerID, int64(t.ServerID))
+	b = appendProtoInt64(b, protoTransactionXID, int64(t.XID))
+	return appendProtoString(b, protoTransactionSource, t.Source), nil
+}
+
+func (p *Position) appendProto(b []byte) []byte {
+	b = appendProtoString(b, protoPositionFilename, p.Filename)
+	return appendProtoInt64(b, protoPositionOffset, p.Offset)
+}
+
+func (p *Position) unmarshalProto(data []byte) error {
+	*p = Position{}
+	return rangeProtoFields(data, func(f protoField) (err error) {
+		switch f.num {
+		case protoPositionFilename:
+			p.Filename, err = f.string()
+		case protoPositionOffset:
+			p.Offset, err = f.int64()
+		}
+		return
+	})
+}
+
+func unmarshalProtoTableName(data []byte, m *MysqlTableName) error {
+	*m = MysqlTableName{}
+	return rangeProtoFields(data, func(f protoField) (err error) {
+		switch f.num {
+		case protoTableNameDb:
+			m.DbName, err = f.string()
+		case protoTableNameTable:
+			m.TableName, err = f.string()
+		}
+		return
+	})
+}
+
+func (s *StreamEvent) appendProto(b []byte) []byte {
+	b = appendProtoInt64(b, protoStreamEventType, int64(s.Type))
+	var table []byte
+	table = appendProtoString(table, protoTableNameDb, s.Table.DbName)
+	table = appendProtoString(table, protoTableNameTable, s.Table.TableName)
+	b = appendProtoMessage(b, protoStreamEventTable, table)
+	b = appendProtoString(b, protoStreamEventSQL, s.Query.SQL)
+	b = appendProtoInt64(b, protoStreamEventTimestamp, s.Timestamp)
+	for _, r := range s.RowValues {
+		b = appendProtoMessage(b, protoStreamEventRowValues, r.appendProto(nil))
+	}
+	for _, r := range s.RowIdentifies {
+		b = appendProtoMessage(b, protoStreamEventRowIdentifies, r.appendProto(nil))
+	}
+	b = appendProtoString(b, protoStreamEventDatabase, s.Query.Database)
+	if c := s.Query.Charset; c != nil {
+		var charset []byte
+		charset = appendProtoInt64(charset, protoCharsetClient, int64(c.Client))
+		charset = appendProtoInt64(charset, protoCharsetConn, int64(c.Conn))
+		charset = appendProtoInt64(charset, protoCharsetServer, int64(c.Server))
+		b = appendProtoMessage(b, protoStreamEventCharset, charset)
+	}
+	return b
+}
+
+func unmarshalProtoCharset(data []byte, c *replication.Charset) error {
+	return rangeProtoFields(data, func(f protoField) (err error) {
+		var v int64
+		switch f.num {
+		case protoCharsetClient:
+			v, err = f.int64()
+			c.Client = int32(v)
+		case protoCharsetConn:
+			v, err = f.int64()
+			c.Conn = int32(v)
+		case protoCharsetServer:
+			v, err = f.int64()
+			c.Server = int32(v)
+		}
+		return
+	})
+}
+
+func (r *RowData) appendProto(b []byte) []byte {
+	for _, c := range r.Columns {
+		b = appendProtoMessage(b, protoRowDataColumns, c.appendProto(nil))
+	}
+	return b
+}
+
+func (c *ColumnData) appendProto(b []byte) []byte {
+	b = appendProtoString(b, protoColumnDataField, c.Filed)
+	b = appendProtoInt64(b, protoColumnDataType, int64(c.Type))
+	b = appendProtoBool(b, protoColumnDataIsEmpty, c.IsEmpty)
+	b = appendProtoBool(b, protoColumnDataIsPrimaryKey, c.IsPrimaryKey)
+	b = appendProtoString(b, protoColumnDataCharset, c.Charset)
+	b = c.appendProtoValue(b)
+	for _, d := range c.JSONDiffs {
+		var diff []byte
+		diff = appendProtoInt64(diff, protoJSONDiffOperation, int64(d.Operation))
+		diff = appendProtoString(diff, protoJSONDiffPath, d.Path)
+		if d.Value != nil {
+			diff = protowire.AppendTag(diff, protoJSONDiffValue, protowire.BytesType)
+			diff = protowire.AppendBytes(diff, d.Value)
+		}
+		b = appendProtoMessage(b, protoColumnDataJSONDiffs, diff)
+	}
+	return b
+}
+
+//appendProtoValue 写入列的值，数字类型只在可以还原为相同的Data时使用
+func (c *ColumnData) appendProtoValue(b []byte) []byte {
+	if c.Data == nil {
+		return b
+	}
+	s := string(c.Data)
+	switch {
+	case c.Type.IsInteger() || c.Type == columnTypeYear:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(v, 10) == s {
+			b = protowire.AppendTag(b, protoColumnDataIntValue, protowire.VarintType)
+			return protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+		}
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil && strconv.FormatUint(v, 10) == s {
+			b = protowire.AppendTag(b, protoColumnDataUintValue, protowire.VarintType)
+			return protowire.AppendVarint(b, v)
+		}
+	case c.Type.IsFloat():
+		v, err := strconv.ParseFloat(s, c.floatBitSize())
+		if err == nil && strconv.FormatFloat(v, 'f', -1, c.floatBitSize()) == s {
+			b = protowire.AppendTag(b, protoColumnDataDoubleValue, protowire.Fixed64Type)
+			return protowire.AppendFixed64(b, math.Float64bits(v))
+		}
+	}
+
+	num := protoColumnDataBytesValue
+	if !c.isBinary() && utf8.Valid(c.Data) {
+		num = protoColumnDataStringValue
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, c.Data)
+}
+
+//floatBitSize 实数列的精度，与CellBytes的输出相同
+func (c *ColumnData) floatBitSize() int {
+	if c.Type == columnTypeFloat {
+		return 32
+	}
+	return 64
+}
+
+func unmarshalProtoJSONDiff(data []byte, d *replication.JSONDiff) error {
+	return rangeProtoFields(data, func(f protoField) (err error) {
+		switch f.num {
+		case protoJSONDiffOperation:
+			var v int64
+			v, err = f.int64()
+			d.Operation = replication.JSONDiffOperation(v)
+		case protoJSONDiffPath:
+			d.Path, err = f.string()
+		case protoJSONDiffValue:
+			var v []byte
+			if v, err = f.bytes(); err == nil {
+				d.Value = append([]byte{}, v...)
+			}
+		}
+		return
+	})
+}
+
+//appendProtoInt64 写入int64，proto3中零值不写入
+func appendProtoInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendProtoBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func appendProtoString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendProtoMessage(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+//protoField protobuf中的单个字段
+type protoField struct {
+	num  protowire.Number
+	typ  protowire.Type
+	data []byte
+}
+
+//rangeProtoFields 遍历data中的字段，f中不处理的字段会被跳过
+func rangeProtoFields(data []byte, f func(protoField) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := f(protoField{num: num, typ: typ, data: data[:n]}); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (f protoField) wireTypeError() error {
+	return fmt.Errorf("field %v has unexpected wire type %v", f.num, f.typ)
+}
+
+func (f protoField) varint() (uint64, error) {
+	if f.typ != protowire.VarintType {
+		return 0, f.wireTypeError()
+	}
+	v, _ := protowire.ConsumeVarint(f.data)
+	return v, nil
+}
+
+func (f protoField) int64() (int64, error) {
+	v, err := f.varint()
+	return int64(v), err
+}
+
+func (f protoField) bool() (bool, error) {
+	v, err := f.varint()
+	return v != 0, err
+}
+
+func (f protoField) double() (float64, error) {
+	if f.typ != protowire.Fixed64Type {
+		return 0, f.wireTypeError()
+	}
+	v, _ := protowire.ConsumeFixed64(f.data)
+	return math.Float64frombits(v), nil
+}
+
+func (f protoField) bytes() ([]byte, error) {
+	if f.typ != protowire.BytesType {
+		return nil, f.wireTypeError()
+	}
+	v, _ := protowire.ConsumeBytes(f.data)
+	return v, nil
+}
+
+func (f protoField) string() (string, error) {
+	v, err := f.bytes()
+	return string(v), err
+}
+
+func (f protoField) message(unmarshal func([]byte) error) error {
+	v, err := f.bytes()
+	if err != nil {
+		return err
+	}
+	return unmarshal(v)
+}
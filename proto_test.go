@@ -0,0 +1,153 @@
+package gobinlog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestTransaction_MarshalProto(t *testing.T) {
+	want := testJSONDecoderTransaction()
+	want.Events[1].RowValues[0].Columns = append(want.Events[1].RowValues[0].Columns,
+		&ColumnData{Filed: "f", Type: columnTypeFloat, Data: []byte("0.1")},
+		&ColumnData{Filed: "d", Type: columnTypeDouble, Data: []byte("-1234.5678")},
+		&ColumnData{Filed: "i", Type: columnTypeLong, Data: []byte("-2147483648")},
+		&ColumnData{Filed: "z", Type: columnTypeLong, Data: []byte("007")},
+		&ColumnData{Filed: "y", Type: columnTypeYear, Data: []byte("2019")},
+		&ColumnData{Filed: "s", Type: columnTypeBlob, Data: []byte{0xe9, 0x00}},
+		&ColumnData{Filed: "e", Type: columnTypeVarchar, Data: []byte{}},
+	)
+	want.Events[1].RowValues = append(want.Events[1].RowValues, &RowData{})
+	want.Events[0].Query.Charset = &replication.Charset{Client: 33, Conn: 33, Server: 8}
+
+	data, err := want.MarshalProto()
+	if err != nil {
+		t.Fatalf("MarshalProto fail. err: %v", err)
+	}
+	out := &Transaction{}
+	if err = out.UnmarshalProto(data); err != nil {
+		t.Fatalf("UnmarshalProto fail. err: %v", err)
+	}
+	//sql语句没有行数据
+	want.Events[0].RowValues, want.Events[0].RowIdentifies = nil, nil
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out want: %+v out: %+v", want, out)
+	}
+
+	if err = out.UnmarshalProto(data[:len(data)-1]); err == nil {
+		t.Fatalf("truncated data want error")
+	}
+}
+
+func TestColumnData_MarshalProto(t *testing.T) {
+	testCases := []struct {
+		input *ColumnData
+		num   protowire.Number
+	}{
+		{input: &ColumnData{Type: columnTypeLong, Data: []byte("-1")}, num: protoColumnDataIntValue},
+		{input: &ColumnData{Type: columnTypeLongLong, Data: []byte("18446744073709551615")}, num: protoColumnDataUintValue},
+		{input: &ColumnData{Type: columnTypeLong, Data: []byte("007")}, num: protoColumnDataStringValue},
+		{input: &ColumnData{Type: columnTypeDouble, Data: []byte("1.5")}, num: protoColumnDataDoubleValue},
+		{input: &ColumnData{Type: columnTypeDouble, Data: []byte("1.50")}, num: protoColumnDataStringValue},
+		{input: &ColumnData{Type: columnTypeNewDecimal, Data: []byte("1.50")}, num: protoColumnDataStringValue},
+		{input: &ColumnData{Type: columnTypeBit, Data: []byte("a")}, num: protoColumnDataBytesValue},
+		{input: &ColumnData{Type: columnTypeVarchar, Data: []byte{0xff}}, num: protoColumnDataBytesValue},
+		{input: &ColumnData{Type: columnTypeVarchar}, num: 0},
+	}
+	for i, v := range testCases {
+		data, _ := v.input.MarshalProto()
+		var num protowire.Number
+		err := rangeProtoFields(data, func(f protoField) error {
+			if f.num >= protoColumnDataIntValue && f.num <= protoColumnDataBytesValue {
+				num = f.num
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v rangeProtoFields fail. err: %v", i, err)
+		}
+		if num != v.num {
+			t.Fatalf("%v want != out want: %v out: %v", i, v.num, num)
+		}
+	}
+
+	//字段顺序与写入顺序不同时也可以正确还原实数
+	var data []byte
+	data = protowire.AppendTag(data, protoColumnDataDoubleValue, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 0x3fb99999a0000000)
+	data = appendProtoInt64(data, protoColumnDataType, int64(columnTypeFloat))
+	out := &ColumnData{}
+	if err := out.UnmarshalProto(data); err != nil || string(out.Data) != "0.1" {
+		t.Fatalf("want 0.1 out: %s err: %v", out.Data, err)
+	}
+
+	data = protowire.AppendTag(nil, protoColumnDataField, protowire.VarintType)
+	data = protowire.AppendVarint(data, 1)
+	if err := out.UnmarshalProto(data); err == nil {
+		t.Fatalf("unexpected wire type want error")
+	}
+}
+
+func TestTransaction_MarshalProtoSpilled(t *testing.T) {
+	tran := testJSONDecoderTransaction()
+	want, err := tran.MarshalProto()
+	if err != nil {
+		t.Fatalf("MarshalProto fail. err: %v", err)
+	}
+
+	dir, clean := testSpillDir(t)
+	defer clean()
+	release := testSpillTransaction(t, dir, tran)
+	out, err := tran.MarshalProto()
+	if err != nil {
+		t.Fatalf("spilled MarshalProto fail. err: %v", err)
+	}
+	if !bytes.Equal(out, want) {
+		t.Fatalf("want != out want: %x out: %x", want, out)
+	}
+
+	release()
+	if _, err = tran.MarshalProto(); err == nil {
+		t.Fatalf("released spill file want error")
+	}
+}
+
+func TestProtoFrame(t *testing.T) {
+	buf := &bytes.Buffer{}
+	want := []*Transaction{
+		{NowPosition: Position{Filename: "binlog.000001", Offset: 4}, Timestamp: 1},
+		testJSONDecoderTransaction(),
+	}
+	want[1].Events[0].RowValues, want[1].Events[0].RowIdentifies = nil, nil
+	for _, v := range want {
+		if err := WriteProtoFrame(buf, v); err != nil {
+			t.Fatalf("WriteProtoFrame fail. err: %v", err)
+		}
+	}
+	data := buf.Bytes()
+
+	r := bufio.NewReader(bytes.NewReader(data))
+	for i, v := range want {
+		out, err := ReadProtoFrame(r)
+		if err != nil {
+			t.Fatalf("%v ReadProtoFrame fail. err: %v", i, err)
+		}
+		if !reflect.DeepEqual(out, v) {
+			t.Fatalf("%v want != out want: %+v out: %+v", i, v, out)
+		}
+	}
+	if _, err := ReadProtoFrame(r); err != io.EOF {
+		t.Fatalf("want io.EOF out: %v", err)
+	}
+
+	r = bufio.NewReader(bytes.NewReader(data[:len(data)-1]))
+	ReadProtoFrame(r)
+	if _, err := ReadProtoFrame(r); err != io.ErrUnexpectedEOF {
+		t.Fatalf("want io.ErrUnexpectedEOF out: %v", err)
+	}
+}
@@ -73,6 +73,10 @@ type BinlogEvent interface {
 	// IsGTID returns true if this is a GTID_EVENT.
 	IsGTID() bool
 
+	// IsAnonymousGTID returns true if this is an ANONYMOUS_GTID_EVENT,
+	// which is written instead of GTID_EVENT when gtid_mode is OFF.
+	IsAnonymousGTID() bool
+
 	// IsRotate returns true if this is a ROTATE_EVENT.
 	IsRotate() bool
 
@@ -85,6 +89,10 @@ type BinlogEvent interface {
 	// IsPreviousGTIDs returns true if this event is a PREVIOUS_GTIDS_EVENT.
 	IsPreviousGTIDs() bool
 
+	// IsTransactionPayload returns true if this is a
+	// TRANSACTION_PAYLOAD_EVENT.
+	IsTransactionPayload() bool
+
 	// RBR events. Replication Based Rows
 	// IsRowsQuery returns true if this is a ROWS_QUERY_EVENT.
 	IsRowsQuery() bool
@@ -95,7 +103,8 @@ type BinlogEvent interface {
 	// IsWriteRowsEvent returns true if this is a WRITE_ROWS_EVENT.
 	IsWriteRows() bool
 
-	// IsUpdateRowsEvent returns true if this is a UPDATE_ROWS_EVENT.
+	// IsUpdateRowsEvent returns true if this is a UPDATE_ROWS_EVENT or
+	// a PARTIAL_UPDATE_ROWS_EVENT.
 	IsUpdateRows() bool
 
 	// IsDeleteRowsEvent returns true if this is a DELETE_ROWS_EVENT.
@@ -104,6 +113,9 @@ type BinlogEvent interface {
 	// Timestamp returns the timestamp from the event header.
 	Timestamp() uint32
 
+	// ServerID returns the server_id from the event header.
+	ServerID() uint32
+
 	// NextPosition return Next binlog event position from the event header.
 	NextPosition() int64
 
@@ -116,6 +128,11 @@ type BinlogEvent interface {
 	// This is only valid if IsGTID() returns true.
 	GTID(BinlogFormat) (GTID, bool, error)
 
+	// LogicalTimestamp returns the logical clock of the transaction, and
+	// if the event carries one. Only MySQL 5.7.6+ writes it.
+	// This is only valid if IsGTID() or IsAnonymousGTID() returns true.
+	LogicalTimestamp(BinlogFormat) (LogicalTimestamp, bool, error)
+
 	// Query returns a Query struct representing data from a QUERY_EVENT.
 	// This is only valid if IsQuery() returns true.
 	Query(BinlogFormat) (Query, error)
@@ -128,6 +145,10 @@ type BinlogEvent interface {
 	// This is only valid if IsRand() returns true.
 	Rand(BinlogFormat) (uint64, uint64, error)
 
+	// XID returns the transaction id of a XID_EVENT.
+	// This is only valid if IsXID() returns true.
+	XID(BinlogFormat) (uint64, error)
+
 	// Rotate returns the binlog filename and offset for a ROTATE_EVENT.
 	// This is only valid if IsRotate() returns true.
 	Rotate(BinlogFormat) (string, int64, error)
@@ -136,6 +157,11 @@ type BinlogEvent interface {
 	// This is only valid if IsPreviousGTIDs() returns true.
 	PreviousGTIDs(BinlogFormat) (GTIDSet, error)
 
+	// TransactionPayload returns the compressed events of a
+	// TRANSACTION_PAYLOAD_EVENT. This is only valid if
+	// IsTransactionPayload() returns true.
+	TransactionPayload(BinlogFormat) (*TransactionPayload, error)
+
 	// RowsQuery returns a Rows Query SQL from ROWS_QUERY_EVENT
 	// This is only valid if IsRowsQuery() returns true.
 	// todo RowsQuery(BinlogFormat) (string, error)
@@ -195,10 +221,27 @@ func (f BinlogFormat) IsZero() bool {
 }
 
 // HeaderSize returns the header size of any event type.
+// It returns 0 for event types unknown to the format.
 func (f BinlogFormat) HeaderSize(typ byte) byte {
+	if typ == 0 || int(typ) > len(f.HeaderSizes) {
+		return 0
+	}
 	return f.HeaderSizes[typ-1]
 }
 
+// LogicalTimestamp contains the logical clock from a GTID_EVENT or an
+// ANONYMOUS_GTID_EVENT. A transaction may be applied in parallel with the
+// transactions committed after the one whose SequenceNumber equals its
+// LastCommitted. Both counters restart in every binlog file.
+type LogicalTimestamp struct {
+	// LastCommitted is the sequence number of the last transaction
+	// this transaction depends on.
+	LastCommitted int64
+
+	// SequenceNumber is the sequence number of this transaction.
+	SequenceNumber int64
+}
+
 // Query contains data from a QUERY_EVENT.
 type Query struct {
 	Database string
@@ -236,6 +279,31 @@ type TableMap struct {
 	// - If the metadata is one byte, only the lower 8 bits are used.
 	// - If the metadata is two bytes, all 16 bits are used.
 	Metadata []uint16
+
+	// Collations is the collation id of each column, from the optional
+	// metadata written when binlog_row_metadata is set (MySQL 8.0.1+).
+	// It is 0 for the columns which are not character columns, and nil
+	// if the event has no charset metadata.
+	Collations []uint64
+}
+
+// IsCharacterColumn returns true if the c-th column stores characters,
+// such as CHAR, VARCHAR and TEXT, the ones Collations is set for.
+// BINARY, VARBINARY and BLOB are character columns with the binary
+// collation.
+func (tm *TableMap) IsCharacterColumn(c int) bool {
+	switch tm.Types[c] {
+	case TypeVarchar, TypeVarString, TypeTinyBlob, TypeMediumBlob, TypeLongBlob, TypeBlob:
+		return true
+	case TypeString:
+		// The real type is in the upper byte of the metadata.
+		switch tm.Metadata[c] >> 8 {
+		case TypeEnum, TypeSet:
+			return false
+		}
+		return true
+	}
+	return false
 }
 
 // Rows contains data from a {WRITE,UPDATE,DELETE}_ROWS_EVENT.
@@ -283,6 +351,32 @@ type Row struct {
 
 	// It means the Value which they INSERT or UPDATE(add from xd.fang)
 	Data []byte
+
+	// ValueOptions is the value_options of the after image.
+	// It is only set for PARTIAL_UPDATE_ROWS_EVENT.
+	ValueOptions uint64
+
+	// PartialJSONColumns has one bit for every JSON column of the
+	// table, whether it is present in the after image or not. A set
+	// bit means the value in Data is a list of JSONDiff instead of a
+	// full JSON document. It is only set for PARTIAL_UPDATE_ROWS_EVENT
+	// when ValueOptions has the PARTIAL_JSON_UPDATES bit.
+	PartialJSONColumns Bitmap
+}
+
+// IsPartialJSON returns true if the value of the c-th column in Data is
+// a list of JSONDiff, which can be read with JSONDiffs.
+func (r *Row) IsPartialJSON(tm *TableMap, c int) bool {
+	if r.ValueOptions&rowValueOptionPartialJSON == 0 || tm.Types[c] != TypeJSON {
+		return false
+	}
+	index := 0
+	for i := 0; i < c; i++ {
+		if tm.Types[i] == TypeJSON {
+			index++
+		}
+	}
+	return index < r.PartialJSONColumns.Count() && r.PartialJSONColumns.Bit(index)
 }
 
 // Bitmap is used by the previous structures.
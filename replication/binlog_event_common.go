@@ -116,11 +116,21 @@ func (ev binlogEvent) IsRand() bool {
 	return ev.Type() == eRandEvent
 }
 
+// IsAnonymousGTID implements BinlogEvent.IsAnonymousGTID().
+func (ev binlogEvent) IsAnonymousGTID() bool {
+	return ev.Type() == eAnonymousGTIDEvent
+}
+
 // IsPreviousGTIDs implements BinlogEvent.IsPreviousGTIDs().
 func (ev binlogEvent) IsPreviousGTIDs() bool {
 	return ev.Type() == ePreviousGTIDsEvent
 }
 
+// IsTransactionPayload implements BinlogEvent.IsTransactionPayload().
+func (ev binlogEvent) IsTransactionPayload() bool {
+	return ev.Type() == eTransactionPayloadEvent
+}
+
 // IsRowsQuery implements BinlogEvent.IsRowsQuery().
 func (ev binlogEvent) IsRowsQuery() bool {
 	return ev.Type() == eRowsQueryEvent
@@ -142,7 +152,8 @@ func (ev binlogEvent) IsWriteRows() bool {
 // We do not support v0.
 func (ev binlogEvent) IsUpdateRows() bool {
 	return ev.Type() == eUpdateRowsEventV1 ||
-		ev.Type() == eUpdateRowsEventV2
+		ev.Type() == eUpdateRowsEventV2 ||
+		ev.Type() == ePartialUpdateRowsEvent
 }
 
 // IsDeleteRows implements BinlogEvent.IsDeleteRows().
@@ -322,6 +333,19 @@ func (ev binlogEvent) Rand(f BinlogFormat) (seed1 uint64, seed2 uint64, err erro
 	return seed1, seed2, nil
 }
 
+// XID implements BinlogEvent.XID().
+//
+// Expected format (L = total length of event data):
+//   # bytes   field
+//   8         xid
+func (ev binlogEvent) XID(f BinlogFormat) (uint64, error) {
+	data := ev.Bytes()[f.HeaderLength:]
+	if len(data) < 8 {
+		return 0, fmt.Errorf("XID event is too short: %v bytes", len(data))
+	}
+	return binary.LittleEndian.Uint64(data[:8]), nil
+}
+
 func (ev binlogEvent) TableID(f BinlogFormat) uint64 {
 	typ := ev.Type()
 	pos := f.HeaderLength
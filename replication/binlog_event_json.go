@@ -3,6 +3,7 @@ package replication
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"strconv"
@@ -112,9 +113,7 @@ func printJSONObject(data []byte, large bool, result *bytes.Buffer) error {
 		if i > 0 {
 			result.WriteByte(',')
 		}
-		result.WriteByte('\'')
-		result.Write(keys[i])
-		result.WriteByte('\'')
+		writeSQLString(keys[i], result)
 		result.WriteByte(',')
 
 		if err := printJSONValueEntry(data, pos, large, result); err != nil {
@@ -322,136 +321,136 @@ func printJSONString(data []byte, toplevel bool, result *bytes.Buffer) {
 	// string inside a string, as the value is parsed as JSON.
 	// So the value should be: '"value"'.
 	if toplevel {
-		result.WriteString("'\"")
-		// FIXME(alainjobart): escape reserved characters
-		result.Write(data[pos : pos+size])
-		result.WriteString("\"'")
+		writeSQLString(quoteJSONString(data[pos:pos+size]), result)
 		return
 	}
 
 	// Inside a JSON_ARRAY() or JSON_OBJECT method, we just print the string
 	// as SQL string.
+	writeSQLString(data[pos:pos+size], result)
+}
+
+// sqlStringEscapes are the characters escaped in a single-quoted SQL
+// string literal. Double quotes are kept as is.
+var sqlStringEscapes = map[byte]string{
+	0:      `\0`,
+	'\n':   `\n`,
+	'\r':   `\r`,
+	'\\':   `\\`,
+	'\'':   `\'`,
+	'\x1a': `\Z`,
+}
+
+// writeSQLString prints s as a single-quoted SQL string literal,
+// escaping the characters that could end the literal.
+func writeSQLString(s []byte, result *bytes.Buffer) {
 	result.WriteByte('\'')
-	result.Write(data[pos : pos+size])
+	for _, b := range s {
+		if e, ok := sqlStringEscapes[b]; ok {
+			result.WriteString(e)
+			continue
+		}
+		result.WriteByte(b)
+	}
 	result.WriteByte('\'')
 }
 
-func printJSONOpaque(data []byte, toplevel bool, result *bytes.Buffer) error {
-	typ := data[0]
-	size, pos := readVariableLength(data, 1)
+// quoteJSONString returns s as a double-quoted JSON string.
+func quoteJSONString(s []byte) []byte {
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	// Encoding a string never fails, invalid UTF-8 is replaced.
+	enc.Encode(string(s))
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
 
-	// A few types have special encoding.
-	switch typ {
-	case TypeDate:
-		return printJSONDate(data[pos:pos+size], toplevel, result)
-	case TypeTime:
-		return printJSONTime(data[pos:pos+size], toplevel, result)
-	case TypeDateTime:
-		return printJSONDateTime(data[pos:pos+size], toplevel, result)
-	case TypeNewDecimal:
-		return printJSONDecimal(data[pos:pos+size], toplevel, result)
-	}
-
-	// Other types are encoded in somewhat weird ways. Since we
-	// have no metadata, it seems some types first provide the
-	// metadata, and then the values. But even that metadata is
-	// not straightforward (for instance, a bit field seems to
-	// have one byte as metadata, not two as would be expected).
-	// To be on the safer side, we just reject these cases for now.
-	return fmt.Errorf("opaque type %v is not supported yet, with data %v", typ, data[1:])
+func printJSONOpaque(data []byte, toplevel bool, result *bytes.Buffer) error {
+	v, err := decodeJSONOpaque(data)
+	if err != nil {
+		return err
+	}
+	if _, ok := v.(JSONOpaque); ok {
+		return fmt.Errorf("opaque type %v is not supported yet, with data %v", data[0], data[1:])
+	}
+	return printJSONOpaqueNode(v, toplevel, result)
 }
 
-func printJSONDate(data []byte, toplevel bool, result *bytes.Buffer) error {
-	raw := binary.LittleEndian.Uint64(data[:8])
-	value := raw >> 24
-	yearMonth := (value >> 22) & 0x01ffff // 17 bits starting at 22nd
-	year := yearMonth / 13
-	month := yearMonth % 13
-	day := (value >> 17) & 0x1f // 5 bits starting at 17th
+// printJSONOpaqueNode prints a decoded opaque value.
+func printJSONOpaqueNode(v interface{}, toplevel bool, result *bytes.Buffer) error {
+	switch v := v.(type) {
+	case JSONDate:
+		printJSONDate(v, toplevel, result)
+	case JSONTime:
+		printJSONTime(v, toplevel, result)
+	case JSONDateTime:
+		printJSONDateTime(v, toplevel, result)
+	case JSONDecimal:
+		printJSONDecimal(v, toplevel, result)
+	case JSONOpaque:
+		// Other types are encoded in somewhat weird ways. Since we
+		// have no metadata, it seems some types first provide the
+		// metadata, and then the values. But even that metadata is
+		// not straightforward (for instance, a bit field seems to
+		// have one byte as metadata, not two as would be expected).
+		// To be on the safer side, we just reject these cases for now.
+		return fmt.Errorf("opaque type %v is not supported yet, with data %v", v.Type, v.Data)
+	}
+	return nil
+}
 
+func printJSONDate(d JSONDate, toplevel bool, result *bytes.Buffer) {
 	if toplevel {
 		result.WriteString("CAST(")
 	}
-	fmt.Fprintf(result, "CAST('%04d-%02d-%02d' AS DATE)", year, month, day)
+	fmt.Fprintf(result, "CAST('%v' AS DATE)", d)
 	if toplevel {
 		result.WriteString(" AS JSON)")
 	}
-	return nil
 }
 
-func printJSONTime(data []byte, toplevel bool, result *bytes.Buffer) error {
-	raw := binary.LittleEndian.Uint64(data[:8])
-	value := raw >> 24
-	hour := (value >> 12) & 0x03ff // 10 bits starting at 12th
-	minute := (value >> 6) & 0x3f  // 6 bits starting at 6th
-	second := value & 0x3f         // 6 bits starting at 0th
-	microSeconds := raw & 0xffffff // 24 lower bits
-
+func printJSONTime(t JSONTime, toplevel bool, result *bytes.Buffer) {
 	if toplevel {
 		result.WriteString("CAST(")
 	}
 	result.WriteString("CAST('")
-	if value&0x8000000000 != 0 {
+	if t.Negative {
 		result.WriteByte('-')
 	}
-	fmt.Fprintf(result, "%02d:%02d:%02d", hour, minute, second)
-	if microSeconds != 0 {
-		fmt.Fprintf(result, ".%06d", microSeconds)
+	fmt.Fprintf(result, "%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if t.Microsecond != 0 {
+		fmt.Fprintf(result, ".%06d", t.Microsecond)
 	}
 	result.WriteString("' AS TIME(6))")
 	if toplevel {
 		result.WriteString(" AS JSON)")
 	}
-	return nil
 }
 
-func printJSONDateTime(data []byte, toplevel bool, result *bytes.Buffer) error {
-	raw := binary.LittleEndian.Uint64(data[:8])
-	value := raw >> 24
-	yearMonth := (value >> 22) & 0x01ffff // 17 bits starting at 22nd
-	year := yearMonth / 13
-	month := yearMonth % 13
-	day := (value >> 17) & 0x1f    // 5 bits starting at 17th
-	hour := (value >> 12) & 0x1f   // 5 bits starting at 12th
-	minute := (value >> 6) & 0x3f  // 6 bits starting at 6th
-	second := value & 0x3f         // 6 bits starting at 0th
-	microSeconds := raw & 0xffffff // 24 lower bits
-
+func printJSONDateTime(t JSONDateTime, toplevel bool, result *bytes.Buffer) {
 	if toplevel {
 		result.WriteString("CAST(")
 	}
-	fmt.Fprintf(result, "CAST('%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
-	if microSeconds != 0 {
-		fmt.Fprintf(result, ".%06d", microSeconds)
+	fmt.Fprintf(result, "CAST('%04d-%02d-%02d %02d:%02d:%02d", t.Year, t.Month, t.Day, t.Hour, t.Minute, t.Second)
+	if t.Microsecond != 0 {
+		fmt.Fprintf(result, ".%06d", t.Microsecond)
 	}
 	result.WriteString("' AS DATETIME(6))")
 	if toplevel {
 		result.WriteString(" AS JSON)")
 	}
-	return nil
 }
 
-func printJSONDecimal(data []byte, toplevel bool, result *bytes.Buffer) error {
-	// Precision and scale are first (as there is no metadata)
-	// then we use the same decoding.
-	precision := data[0]
-	scale := data[1]
-	metadata := (uint16(precision) << 8) + uint16(scale)
-
-	val, _, err := CellBytes(data, 2, TypeNewDecimal, metadata, false)
-	if err != nil {
-		return err
-	}
+func printJSONDecimal(d JSONDecimal, toplevel bool, result *bytes.Buffer) {
 	if toplevel {
 		result.WriteString("CAST(")
 	}
 	result.WriteString("CAST('")
-	result.Write(val)
-	fmt.Fprintf(result, "' AS DECIMAL(%d,%d))", precision, scale)
+	result.WriteString(d.Value)
+	fmt.Fprintf(result, "' AS DECIMAL(%d,%d))", d.Precision, d.Scale)
 	if toplevel {
 		result.WriteString(" AS JSON)")
 	}
-	return nil
 }
 
 func readOffsetOrSize(data []byte, pos int, large bool) (int, int) {
@@ -0,0 +1,565 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JSONDiffOperation is the operation of a JSONDiff.
+type JSONDiffOperation byte
+
+// These constants are the operations of a partial JSON update, see
+// enum_json_diff_operation in sql/json_diff.h.
+const (
+	// JSONDiffReplace replaces the value at the path.
+	JSONDiffReplace JSONDiffOperation = 0
+
+	// JSONDiffInsert inserts the value at the path.
+	JSONDiffInsert JSONDiffOperation = 1
+
+	// JSONDiffRemove removes the value at the path.
+	JSONDiffRemove JSONDiffOperation = 2
+)
+
+// String returns the name of the operation.
+func (o JSONDiffOperation) String() string {
+	switch o {
+	case JSONDiffReplace:
+		return "replace"
+	case JSONDiffInsert:
+		return "insert"
+	case JSONDiffRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// JSONDiff is a single change of a partial JSON update written by MySQL
+// 8.0 when binlog_row_value_options=PARTIAL_JSON.
+type JSONDiff struct {
+	// Operation is what is done at Path.
+	Operation JSONDiffOperation
+
+	// Path is the JSON path of the change, such as $.a[1].
+	Path string
+
+	// Value is the new value printed like CellBytes prints a JSON
+	// column. It is nil for JSONDiffRemove.
+	Value []byte
+
+	// data is the new value in MySQL binary JSON format.
+	data []byte
+}
+
+// JSONDiffs parses the partial JSON update of a column at data[pos:],
+// see Json_diff_vector::write_binary in sql/json_diff.cc. It returns the
+// diffs and the length of the value occupied in data.
+//
+// Expected format:
+//  # bytes   field
+//  metadata  length of the diffs
+//  -- for each diff
+//  1         operation
+//  <var>     path length pl (var-len encoded)
+//  pl        path
+//  -- if operation != remove
+//  <var>     value length vl (var-len encoded)
+//  vl        value in MySQL binary JSON format
+//  -- endif
+//  --
+func JSONDiffs(data []byte, pos int, metadata uint16) ([]JSONDiff, int, error) {
+	l, err := cellLength(data, pos, TypeJSON, metadata)
+	if err != nil {
+		return nil, 0, err
+	}
+	if pos+l > len(data) {
+		return nil, 0, fmt.Errorf("not enough data for partial json, have %v bytes need %v", len(data)-pos, l)
+	}
+
+	var diffs []JSONDiff
+	buf := data[pos+int(metadata) : pos+l]
+	for i := 0; i < len(buf); {
+		d := JSONDiff{
+			Operation: JSONDiffOperation(buf[i]),
+		}
+		i++
+		if d.Operation > JSONDiffRemove {
+			return nil, 0, fmt.Errorf("unknown partial json operation %v", buf[i-1])
+		}
+
+		pathLength, next, ok := readLenEncInt(buf, i)
+		if !ok || uint64(len(buf)-next) < pathLength {
+			return nil, 0, fmt.Errorf("not enough data for partial json path at %v", i)
+		}
+		d.Path = string(buf[next : next+int(pathLength)])
+		i = next + int(pathLength)
+
+		if d.Operation != JSONDiffRemove {
+			valueLength, next, ok := readLenEncInt(buf, i)
+			if !ok || uint64(len(buf)-next) < valueLength {
+				return nil, 0, fmt.Errorf("not enough data for partial json value at %v", i)
+			}
+			d.data = buf[next : next+int(valueLength)]
+			if d.Value, err = printJSONData(d.data); err != nil {
+				return nil, 0, err
+			}
+			i = next + int(valueLength)
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, l, nil
+}
+
+// ApplyJSONDiffsValue is the same as ApplyJSONDiffs, but returns the
+// new value as a tree described in DecodeJSON.
+func ApplyJSONDiffsValue(data []byte, pos int, metadata uint16, diffs []JSONDiff) (interface{}, error) {
+	l, err := cellLength(data, pos, TypeJSON, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if pos+l > len(data) {
+		return nil, fmt.Errorf("not enough data for json, have %v bytes need %v", len(data)-pos, l)
+	}
+
+	doc, err := decodeJSONData(data[pos+int(metadata) : pos+l])
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range diffs {
+		if doc, err = applyJSONDiff(doc, d); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// ApplyJSONDiffs applies diffs to the full JSON value of a column at
+// data[pos:], such as the one returned by Rows.IdentifyCell, and returns
+// the new value printed like CellBytes.
+func ApplyJSONDiffs(data []byte, pos int, metadata uint16, diffs []JSONDiff) ([]byte, error) {
+	doc, err := ApplyJSONDiffsValue(data, pos, metadata, diffs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &bytes.Buffer{}
+	if err = printJSONNode(doc, true /* toplevel */, result); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+// decodeJSONData decodes the MySQL binary format for JSON data into the
+// value tree described in DecodeJSON.
+func decodeJSONData(data []byte) (interface{}, error) {
+	// Same as printJSONData, empty data is 'null'.
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return decodeJSONValue(data[0], data[1:])
+}
+
+func decodeJSONValue(typ byte, data []byte) (interface{}, error) {
+	switch typ {
+	case jsonTypeSmallObject:
+		return decodeJSONObject(data, false)
+	case jsonTypeLargeObject:
+		return decodeJSONObject(data, true)
+	case jsonTypeSmallArray:
+		return decodeJSONArray(data, false)
+	case jsonTypeLargeArray:
+		return decodeJSONArray(data, true)
+	case jsonTypeLiteral:
+		return decodeJSONLiteral(data[0])
+	case jsonTypeInt16:
+		return int64(int16(binary.LittleEndian.Uint16(data[:2]))), nil
+	case jsonTypeUint16:
+		return uint64(binary.LittleEndian.Uint16(data[:2])), nil
+	case jsonTypeInt32:
+		return int64(int32(binary.LittleEndian.Uint32(data[:4]))), nil
+	case jsonTypeUint32:
+		return uint64(binary.LittleEndian.Uint32(data[:4])), nil
+	case jsonTypeInt64:
+		return int64(binary.LittleEndian.Uint64(data[:8])), nil
+	case jsonTypeUint64:
+		return binary.LittleEndian.Uint64(data[:8]), nil
+	case jsonTypeDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data[:8])), nil
+	case jsonTypeString:
+		size, pos := readVariableLength(data, 0)
+		return string(data[pos : pos+size]), nil
+	case jsonTypeOpaque:
+		return decodeJSONOpaque(data)
+	}
+	return nil, fmt.Errorf("unknown object type in JSON: %v", typ)
+}
+
+func decodeJSONObject(data []byte, large bool) (interface{}, error) {
+	pos := 0
+	elementCount, pos := readOffsetOrSize(data, pos, large)
+	size, pos := readOffsetOrSize(data, pos, large)
+	if size > len(data) {
+		return nil, fmt.Errorf("not enough data for object, have %v bytes need %v", len(data), size)
+	}
+
+	keys := make([]string, elementCount)
+	for i := 0; i < elementCount; i++ {
+		var keyOffset, keyLength int
+		keyOffset, pos = readOffsetOrSize(data, pos, large)
+		keyLength, pos = readOffsetOrSize(data, pos, false) // always 16
+		keys[i] = string(data[keyOffset : keyOffset+keyLength])
+	}
+
+	result := make(map[string]interface{}, elementCount)
+	for i := 0; i < elementCount; i++ {
+		value, err := decodeJSONValueEntry(data, pos, large)
+		if err != nil {
+			return nil, err
+		}
+		result[keys[i]] = value
+		pos += jsonValueEntrySize(large)
+	}
+	return result, nil
+}
+
+func decodeJSONArray(data []byte, large bool) (interface{}, error) {
+	pos := 0
+	elementCount, pos := readOffsetOrSize(data, pos, large)
+	size, pos := readOffsetOrSize(data, pos, large)
+	if size > len(data) {
+		return nil, fmt.Errorf("not enough data for object, have %v bytes need %v", len(data), size)
+	}
+
+	result := make([]interface{}, 0, elementCount)
+	for i := 0; i < elementCount; i++ {
+		value, err := decodeJSONValueEntry(data, pos, large)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+		pos += jsonValueEntrySize(large)
+	}
+	return result, nil
+}
+
+// jsonValueEntrySize is the size of a value entry: the type byte, and
+// then 2 or 4 bytes.
+func jsonValueEntrySize(large bool) int {
+	if large {
+		return 5
+	}
+	return 3
+}
+
+// decodeJSONValueEntry decodes an entry, with the same inlining rules
+// as printJSONValueEntry.
+func decodeJSONValueEntry(data []byte, pos int, large bool) (interface{}, error) {
+	typ := data[pos]
+	pos++
+
+	switch {
+	case typ == jsonTypeLiteral, typ == jsonTypeInt16, typ == jsonTypeUint16:
+		return decodeJSONValue(typ, data[pos:])
+	case (typ == jsonTypeInt32 || typ == jsonTypeUint32) && large:
+		return decodeJSONValue(typ, data[pos:])
+	}
+	offset, _ := readOffsetOrSize(data, pos, large)
+	if offset >= len(data) {
+		return nil, fmt.Errorf("json value offset %v is out of range %v", offset, len(data))
+	}
+	return decodeJSONValue(typ, data[offset:])
+}
+
+func decodeJSONLiteral(b byte) (interface{}, error) {
+	switch b {
+	case jsonNullLiteral:
+		return nil, nil
+	case jsonTrueLiteral:
+		return true, nil
+	case jsonFalseLiteral:
+		return false, nil
+	}
+	return nil, fmt.Errorf("unknown literal value %v", b)
+}
+
+// printJSONNode prints a decoded JSON value the same way printJSONValue
+// prints the binary format.
+func printJSONNode(v interface{}, toplevel bool, result *bytes.Buffer) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result.WriteString("JSON_OBJECT(")
+		for i, k := range sortJSONKeys(v) {
+			if i > 0 {
+				result.WriteByte(',')
+			}
+			result.WriteByte('\'')
+			result.WriteString(k)
+			result.WriteString("',")
+			if err := printJSONNode(v[k], false /* toplevel */, result); err != nil {
+				return err
+			}
+		}
+		result.WriteByte(')')
+	case []interface{}:
+		result.WriteString("JSON_ARRAY(")
+		for i, e := range v {
+			if i > 0 {
+				result.WriteByte(',')
+			}
+			if err := printJSONNode(e, false /* toplevel */, result); err != nil {
+				return err
+			}
+		}
+		result.WriteByte(')')
+	case nil:
+		return printJSONLiteral(jsonNullLiteral, toplevel, result)
+	case bool:
+		if v {
+			return printJSONLiteral(jsonTrueLiteral, toplevel, result)
+		}
+		return printJSONLiteral(jsonFalseLiteral, toplevel, result)
+	case int64:
+		printJSONScalar(strconv.AppendInt(nil, v, 10), toplevel, result)
+	case uint64:
+		printJSONScalar(strconv.AppendUint(nil, v, 10), toplevel, result)
+	case float64:
+		printJSONScalar(strconv.AppendFloat(nil, v, 'E', -1, 64), toplevel, result)
+	case string:
+		if toplevel {
+			result.WriteString("'\"")
+			result.WriteString(v)
+			result.WriteString("\"'")
+			return nil
+		}
+		result.WriteByte('\'')
+		result.WriteString(v)
+		result.WriteByte('\'')
+	case JSONDate, JSONTime, JSONDateTime, JSONDecimal, JSONOpaque:
+		return printJSONOpaqueNode(v, toplevel, result)
+	default:
+		return fmt.Errorf("unknown json value %T", v)
+	}
+	return nil
+}
+
+// printJSONScalar prints a number, quoted if it is the toplevel value.
+func printJSONScalar(b []byte, toplevel bool, result *bytes.Buffer) {
+	if toplevel {
+		result.WriteByte('\'')
+	}
+	result.Write(b)
+	if toplevel {
+		result.WriteByte('\'')
+	}
+}
+
+// jsonPathLeg is a member or an array cell of a JSON path.
+type jsonPathLeg struct {
+	key     string
+	isIndex bool
+	index   int  // array index, or offset from the last element
+	last    bool // the index is last-index
+}
+
+// parseJSONPath parses a JSON path without wildcards, such as
+// $.a."b c"[1][last-1].
+func parseJSONPath(path string) ([]jsonPathLeg, error) {
+	p := strings.TrimSpace(path)
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("json path %q does not start with $", path)
+	}
+	p = p[1:]
+
+	var legs []jsonPathLeg
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			if strings.HasPrefix(p, "\"") {
+				end := 1
+				for ; end < len(p) && p[end] != '"'; end++ {
+					if p[end] == '\\' {
+						end++
+					}
+				}
+				if end >= len(p) {
+					return nil, fmt.Errorf("json path %q has an unterminated key", path)
+				}
+				var key string
+				if err := json.Unmarshal([]byte(p[:end+1]), &key); err != nil {
+					return nil, fmt.Errorf("json path %q has a bad key: %v", path, err)
+				}
+				legs = append(legs, jsonPathLeg{key: key})
+				p = p[end+1:]
+				continue
+			}
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			key := strings.TrimSpace(p[:end])
+			if key == "" || key == "*" {
+				return nil, fmt.Errorf("json path %q has an unsupported member", path)
+			}
+			legs = append(legs, jsonPathLeg{key: key})
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q has an unterminated array cell", path)
+			}
+			leg, err := parseJSONPathIndex(strings.TrimSpace(p[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("json path %q has a bad array cell: %v", path, err)
+			}
+			legs = append(legs, leg)
+			p = p[end+1:]
+		case ' ':
+			p = p[1:]
+		default:
+			return nil, fmt.Errorf("json path %q is unsupported", path)
+		}
+	}
+	return legs, nil
+}
+
+func parseJSONPathIndex(s string) (jsonPathLeg, error) {
+	leg := jsonPathLeg{isIndex: true}
+	if strings.HasPrefix(s, "last") {
+		leg.last = true
+		s = strings.TrimSpace(s[len("last"):])
+		if s == "" {
+			return leg, nil
+		}
+		if s[0] != '-' {
+			return leg, fmt.Errorf("unsupported array cell %q", s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 {
+		return leg, fmt.Errorf("unsupported array cell %q", s)
+	}
+	leg.index = index
+	return leg, nil
+}
+
+// arrayIndex resolves the index of the leg in an array of size length.
+func (l jsonPathLeg) arrayIndex(length int) int {
+	if l.last {
+		return length - 1 - l.index
+	}
+	return l.index
+}
+
+// applyJSONDiff applies a diff to doc and returns the new document, see
+// apply_json_diffs in sql/json_diff.cc.
+func applyJSONDiff(doc interface{}, d JSONDiff) (interface{}, error) {
+	legs, err := parseJSONPath(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if d.Operation != JSONDiffRemove {
+		if value, err = decodeJSONData(d.data); err != nil {
+			return nil, err
+		}
+	}
+	return applyJSONDiffAt(doc, legs, d, value)
+}
+
+func applyJSONDiffAt(node interface{}, legs []jsonPathLeg, d JSONDiff, value interface{}) (interface{}, error) {
+	if len(legs) == 0 {
+		if d.Operation != JSONDiffReplace {
+			return nil, fmt.Errorf("json diff %v %v can not be applied to the whole document", d.Operation, d.Path)
+		}
+		return value, nil
+	}
+
+	leg := legs[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if leg.isIndex {
+			// A scalar or an object is treated as an array of one element.
+			if leg.arrayIndex(1) == 0 {
+				return applyJSONDiffAt(node, legs[1:], d, value)
+			}
+			return nil, fmt.Errorf("json diff %v path %v is not found", d.Operation, d.Path)
+		}
+		child, ok := n[leg.key]
+		if len(legs) > 1 {
+			if !ok {
+				return nil, fmt.Errorf("json diff %v path %v is not found", d.Operation, d.Path)
+			}
+			child, err := applyJSONDiffAt(child, legs[1:], d, value)
+			if err != nil {
+				return nil, err
+			}
+			n[leg.key] = child
+			return n, nil
+		}
+		switch d.Operation {
+		case JSONDiffReplace:
+			if !ok {
+				return nil, fmt.Errorf("json diff %v path %v is not found", d.Operation, d.Path)
+			}
+			n[leg.key] = value
+		case JSONDiffInsert:
+			n[leg.key] = value
+		case JSONDiffRemove:
+			if !ok {
+				return nil, fmt.Errorf("json diff %v path %v is not found", d.Operation, d.Path)
+			}
+			delete(n, leg.key)
+		}
+		return n, nil
+
+	case []interface{}:
+		if !leg.isIndex {
+			return nil, fmt.Errorf("json diff %v path %v is not found", d.Operation, d.Path)
+		}
+		index := leg.arrayIndex(len(n))
+		if len(legs) > 1 || d.Operation != JSONDiffInsert {
+			if index < 0 || index >= len(n) {
+				return nil, fmt.Errorf("json diff %v path %v is not found", d.Operation, d.Path)
+			}
+		}
+		if len(legs) > 1 {
+			child, err := applyJSONDiffAt(n[index], legs[1:], d, value)
+			if err != nil {
+				return nil, err
+			}
+			n[index] = child
+			return n, nil
+		}
+		switch d.Operation {
+		case JSONDiffReplace:
+			n[index] = value
+		case JSONDiffInsert:
+			if index < 0 {
+				index = 0
+			}
+			if index >= len(n) {
+				return append(n, value), nil
+			}
+			n = append(n, nil)
+			copy(n[index+1:], n[index:])
+			n[index] = value
+		case JSONDiffRemove:
+			n = append(n[:index], n[index+1:]...)
+		}
+		return n, nil
+
+	default:
+		// A scalar is treated as an array of one element.
+		if leg.isIndex && leg.arrayIndex(1) == 0 {
+			return applyJSONDiffAt(node, legs[1:], d, value)
+		}
+		return nil, fmt.Errorf("json diff %v path %v is not found", d.Operation, d.Path)
+	}
+}
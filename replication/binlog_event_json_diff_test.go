@@ -0,0 +1,197 @@
+package replication
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// jsonCell returns a JSON column with 4 bytes of length.
+func jsonCell(data []byte) []byte {
+	return append([]byte{byte(len(data)), byte(len(data) >> 8), 0, 0}, data...)
+}
+
+func TestDecodeJSONData(t *testing.T) {
+	testcases := [][]byte{
+		{},
+		{0, 1, 0, 14, 0, 11, 0, 1, 0, 12, 12, 0, 97, 1, 98},
+		{0, 1, 0, 29, 0, 11, 0, 4, 0, 0, 15, 0, 97, 115, 100, 102, 1, 0, 14, 0, 11, 0, 3, 0, 5, 123, 0, 102, 111, 111},
+		{2, 2, 0, 10, 0, 5, 1, 0, 5, 2, 0},
+		{0, 4, 0, 60, 0, 32, 0, 1, 0, 33, 0, 1, 0, 34, 0, 2, 0, 36, 0, 2, 0, 12, 38, 0, 12, 40, 0, 12, 42, 0, 2, 46, 0, 97, 99, 97, 98, 98, 99, 1, 98, 1, 100, 3, 97, 98, 99, 2, 0, 14, 0, 12, 10, 0, 12, 12, 0, 1, 120, 1, 121},
+		{2, 3, 0, 37, 0, 12, 13, 0, 2, 18, 0, 12, 33, 0, 4, 104, 101, 114, 101, 2, 0, 15, 0, 12, 10, 0, 12, 12, 0, 1, 73, 2, 97, 109, 3, 33, 33, 33},
+		{12, 13, 115, 99, 97, 108, 97, 114, 32, 115, 116, 114, 105, 110, 103},
+		{4, 1},
+		{4, 0},
+		{5, 255, 255},
+		{9, 255, 255, 255, 255, 255, 255, 255, 255},
+		{10, 255, 255, 255, 255, 255, 255, 255, 255},
+		{11, 0, 0, 0, 0, 0, 0, 4, 64},
+		{15, 10, 8, 0, 0, 0, 0, 0, 206, 196, 25},
+	}
+
+	for _, data := range testcases {
+		want, err := printJSONData(data)
+		if err != nil {
+			t.Fatalf("printJSONData fail. data: %v err: %v", data, err)
+		}
+		doc, err := decodeJSONData(data)
+		if err != nil {
+			t.Fatalf("decodeJSONData fail. data: %v err: %v", data, err)
+		}
+		out := &bytes.Buffer{}
+		if err = printJSONNode(doc, true, out); err != nil {
+			t.Fatalf("printJSONNode fail. data: %v err: %v", data, err)
+		}
+		if out.String() != string(want) {
+			t.Fatalf("want != out data: %v want: %s out: %s", data, want, out.String())
+		}
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	testcases := []struct {
+		path  string
+		want  []jsonPathLeg
+		valid bool
+	}{
+		{
+			path:  "$",
+			valid: true,
+		},
+		{
+			path: `$.a."b.c"[1][last][last-2]`,
+			want: []jsonPathLeg{
+				{key: "a"},
+				{key: "b.c"},
+				{isIndex: true, index: 1},
+				{isIndex: true, last: true},
+				{isIndex: true, last: true, index: 2},
+			},
+			valid: true,
+		},
+		{
+			path: "a.b",
+		},
+		{
+			path: "$.*",
+		},
+		{
+			path: "$[1",
+		},
+		{
+			path: "$[-1]",
+		},
+		{
+			path: `$."a`,
+		},
+	}
+
+	for _, v := range testcases {
+		out, err := parseJSONPath(v.path)
+		if (err == nil) != v.valid {
+			t.Fatalf("parseJSONPath(%v) valid want: %v err: %v", v.path, v.valid, err)
+		}
+		if v.valid && !reflect.DeepEqual(v.want, out) {
+			t.Fatalf("want != out path: %v want: %+v out: %+v", v.path, v.want, out)
+		}
+	}
+}
+
+func TestApplyJSONDiffs(t *testing.T) {
+	object := []byte{0, 1, 0, 14, 0, 11, 0, 1, 0, 12, 12, 0, 97, 1, 98} // {"a":"b"}
+	array := []byte{2, 2, 0, 10, 0, 5, 1, 0, 5, 2, 0}                   // [1,2]
+
+	testcases := []struct {
+		before []byte
+		diffs  []JSONDiff
+		want   string
+		valid  bool
+	}{
+		{
+			before: object,
+			diffs: []JSONDiff{
+				{Operation: JSONDiffReplace, Path: "$.a", data: []byte{5, 2, 0}},
+				{Operation: JSONDiffInsert, Path: "$.c", data: array},
+				{Operation: JSONDiffInsert, Path: "$.c[0]", data: []byte{4, 0}},
+				{Operation: JSONDiffRemove, Path: "$.c[last]"},
+			},
+			want:  "JSON_OBJECT('a',2,'c',JSON_ARRAY(null,1))",
+			valid: true,
+		},
+		{
+			before: array,
+			diffs: []JSONDiff{
+				{Operation: JSONDiffInsert, Path: "$[5]", data: []byte{12, 1, 'x'}},
+				{Operation: JSONDiffReplace, Path: "$[last-2]", data: []byte{4, 1}},
+				{Operation: JSONDiffRemove, Path: "$[1]"},
+			},
+			want:  "JSON_ARRAY(true,'x')",
+			valid: true,
+		},
+		{
+			before: array,
+			diffs: []JSONDiff{
+				{Operation: JSONDiffReplace, Path: "$", data: []byte{12, 1, 'y'}},
+			},
+			want:  `'"y"'`,
+			valid: true,
+		},
+		{
+			before: object,
+			diffs: []JSONDiff{
+				{Operation: JSONDiffReplace, Path: "$.b", data: []byte{4, 1}},
+			},
+		},
+		{
+			before: array,
+			diffs: []JSONDiff{
+				{Operation: JSONDiffRemove, Path: "$[2]"},
+			},
+		},
+		{
+			before: array,
+			diffs: []JSONDiff{
+				{Operation: JSONDiffRemove, Path: "$"},
+			},
+		},
+	}
+
+	for i, v := range testcases {
+		out, err := ApplyJSONDiffs(jsonCell(v.before), 0, 4, v.diffs)
+		if (err == nil) != v.valid {
+			t.Fatalf("%d valid want: %v err: %v", i, v.valid, err)
+		}
+		if v.valid && string(out) != v.want {
+			t.Fatalf("%d want != out want: %v out: %s", i, v.want, out)
+		}
+	}
+}
+
+func TestJSONDiffs(t *testing.T) {
+	data := jsonCell([]byte{
+		0, 3, '$', '.', 'a', 3, 5, 2, 0, // replace $.a 2
+		2, 4, '$', '[', '0', ']', // remove $[0]
+	})
+	diffs, l, err := JSONDiffs(data, 0, 4)
+	if err != nil {
+		t.Fatalf("JSONDiffs fail. err: %v", err)
+	}
+	want := []JSONDiff{
+		{Operation: JSONDiffReplace, Path: "$.a", Value: []byte("'2'"), data: []byte{5, 2, 0}},
+		{Operation: JSONDiffRemove, Path: "$[0]"},
+	}
+	if l != len(data) || !reflect.DeepEqual(want, diffs) {
+		t.Fatalf("want != out want: %v %+v out: %v %+v", len(data), want, l, diffs)
+	}
+
+	for _, bad := range [][]byte{
+		jsonCell([]byte{3, 1, '$'}),
+		jsonCell([]byte{0, 3, '$'}),
+		jsonCell([]byte{0, 1, '$', 3, 5}),
+		{20, 0, 0, 0, 0},
+	} {
+		if _, _, err = JSONDiffs(bad, 0, 4); err == nil {
+			t.Fatalf("JSONDiffs(%v) want error", bad)
+		}
+	}
+}
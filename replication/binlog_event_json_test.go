@@ -23,6 +23,13 @@ func TestJSON(t *testing.T) {
 	}, {
 		data:     []byte{0, 1, 0, 29, 0, 11, 0, 4, 0, 0, 15, 0, 97, 115, 100, 102, 1, 0, 14, 0, 11, 0, 3, 0, 5, 123, 0, 102, 111, 111},
 		expected: `JSON_OBJECT('asdf',JSON_OBJECT('foo',123))`,
+	}, {
+		// quotes in keys and values are escaped
+		data:     []byte{0, 1, 0, 14, 0, 11, 0, 1, 0, 12, 12, 0, '\'', 1, '\''},
+		expected: `JSON_OBJECT('\'','\'')`,
+	}, {
+		data:     []byte{12, 6, 'i', 't', '\'', 's', '"', '\\'},
+		expected: `'"it\'s\\"\\\\"'`,
 	}, {
 		data:     []byte{2, 2, 0, 10, 0, 5, 1, 0, 5, 2, 0},
 		expected: `JSON_ARRAY(1,2)`,
@@ -0,0 +1,358 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONDate is a DATE stored in a JSON document.
+type JSONDate struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// JSONTime is a TIME stored in a JSON document.
+type JSONTime struct {
+	Negative    bool
+	Hour        int
+	Minute      int
+	Second      int
+	Microsecond int
+}
+
+// JSONDateTime is a DATETIME or a TIMESTAMP stored in a JSON document.
+type JSONDateTime struct {
+	// Type is TypeDateTime or TypeTimestamp.
+	Type byte
+
+	Year        int
+	Month       int
+	Day         int
+	Hour        int
+	Minute      int
+	Second      int
+	Microsecond int
+}
+
+// JSONDecimal is a DECIMAL stored in a JSON document.
+type JSONDecimal struct {
+	Precision byte
+	Scale     byte
+
+	// Value is the decimal printed like CellBytes, such as 3.14.
+	Value string
+}
+
+// JSONOpaque is any other MySQL value stored in a JSON document, such
+// as a BLOB or a BIT.
+type JSONOpaque struct {
+	// Type is the MySQL type of the value.
+	Type byte
+
+	// Data is the value in the MySQL storage format.
+	Data []byte
+}
+
+// String returns the date as YYYY-MM-DD.
+func (d JSONDate) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// String returns the time as [-]HH:MM:SS.ffffff, the same as MySQL
+// prints it in a JSON document.
+func (t JSONTime) String() string {
+	sign := ""
+	if t.Negative {
+		sign = "-"
+	}
+	return fmt.Sprintf("%v%02d:%02d:%02d.%06d", sign, t.Hour, t.Minute, t.Second, t.Microsecond)
+}
+
+// String returns the datetime as YYYY-MM-DD HH:MM:SS.ffffff, the same
+// as MySQL prints it in a JSON document.
+func (t JSONDateTime) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d.%06d",
+		t.Year, t.Month, t.Day, t.Hour, t.Minute, t.Second, t.Microsecond)
+}
+
+// String returns the decimal value.
+func (d JSONDecimal) String() string {
+	return d.Value
+}
+
+// String returns the value as base64:type<Type>:<Data in base64>, the
+// same as MySQL prints it in a JSON document.
+func (o JSONOpaque) String() string {
+	return fmt.Sprintf("base64:type%d:%v", o.Type, base64.StdEncoding.EncodeToString(o.Data))
+}
+
+// DecodeJSON decodes the MySQL binary format for JSON data into a value
+// tree made of:
+//   - map[string]interface{} for an object
+//   - []interface{} for an array
+//   - string
+//   - int64, uint64 or float64 for a number
+//   - bool
+//   - nil for null
+//   - JSONDate, JSONTime, JSONDateTime, JSONDecimal or JSONOpaque for
+//     an opaque value
+func DecodeJSON(data []byte) (interface{}, error) {
+	return decodeJSONData(data)
+}
+
+// CellJSON decodes the value of a JSON column at data[pos:] into a
+// value tree described in DecodeJSON. It returns the value and the
+// length occupied in data, the same as CellBytes.
+func CellJSON(data []byte, pos int, metadata uint16) (interface{}, int, error) {
+	l, err := cellLength(data, pos, TypeJSON, metadata)
+	if err != nil {
+		return nil, 0, err
+	}
+	if pos+l > len(data) {
+		return nil, 0, fmt.Errorf("not enough data for json, have %v bytes need %v", len(data)-pos, l)
+	}
+	v, err := decodeJSONData(data[pos+int(metadata) : pos+l])
+	if err != nil {
+		return nil, 0, fmt.Errorf("error parsing JSON data %v err: %v", data[pos:pos+l], err)
+	}
+	return v, l, nil
+}
+
+// JSONValue returns the new value of the diff as a tree described in
+// DecodeJSON. It is nil for JSONDiffRemove. It fails if the diff was not
+// parsed from a binlog event, as only Value is known then.
+func (d JSONDiff) JSONValue() (interface{}, error) {
+	if d.Operation == JSONDiffRemove {
+		return nil, nil
+	}
+	if len(d.data) == 0 {
+		return nil, fmt.Errorf("json diff of %v has no binary value", d.Path)
+	}
+	return decodeJSONData(d.data)
+}
+
+// decodeJSONOpaque decodes an opaque value, data starts with the MySQL
+// type of the value.
+func decodeJSONOpaque(data []byte) (interface{}, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("not enough data for opaque, have %v bytes", len(data))
+	}
+	typ := data[0]
+	size, pos := readVariableLength(data, 1)
+	if pos+size > len(data) {
+		return nil, fmt.Errorf("not enough data for opaque type %v, have %v bytes need %v", typ, len(data)-pos, size)
+	}
+	data = data[pos : pos+size]
+
+	// A few types have special encoding.
+	switch typ {
+	case TypeDate, TypeTime, TypeDateTime, TypeTimestamp:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("not enough data for opaque type %v, have %v bytes need 8", typ, len(data))
+		}
+	case TypeNewDecimal:
+		if len(data) < 2 {
+			return nil, fmt.Errorf("not enough data for opaque decimal, have %v bytes", len(data))
+		}
+	}
+
+	switch typ {
+	case TypeDate:
+		t := decodeJSONDateTime(data)
+		return JSONDate{Year: t.Year, Month: t.Month, Day: t.Day}, nil
+	case TypeTime:
+		return decodeJSONTime(data), nil
+	case TypeDateTime, TypeTimestamp:
+		t := decodeJSONDateTime(data)
+		t.Type = typ
+		return t, nil
+	case TypeNewDecimal:
+		// Precision and scale are first (as there is no metadata)
+		// then we use the same decoding.
+		precision := data[0]
+		scale := data[1]
+		metadata := (uint16(precision) << 8) + uint16(scale)
+
+		val, _, err := CellBytes(data, 2, TypeNewDecimal, metadata, false)
+		if err != nil {
+			return nil, err
+		}
+		return JSONDecimal{Precision: precision, Scale: scale, Value: string(val)}, nil
+	}
+	return JSONOpaque{Type: typ, Data: data}, nil
+}
+
+func decodeJSONTime(data []byte) JSONTime {
+	raw := binary.LittleEndian.Uint64(data[:8])
+	value := raw >> 24
+	return JSONTime{
+		Negative:    value&0x8000000000 != 0,
+		Hour:        int((value >> 12) & 0x03ff), // 10 bits starting at 12th
+		Minute:      int((value >> 6) & 0x3f),    // 6 bits starting at 6th
+		Second:      int(value & 0x3f),           // 6 bits starting at 0th
+		Microsecond: int(raw & 0xffffff),         // 24 lower bits
+	}
+}
+
+func decodeJSONDateTime(data []byte) JSONDateTime {
+	raw := binary.LittleEndian.Uint64(data[:8])
+	value := raw >> 24
+	yearMonth := (value >> 22) & 0x01ffff // 17 bits starting at 22nd
+	return JSONDateTime{
+		Type:        TypeDateTime,
+		Year:        int(yearMonth / 13),
+		Month:       int(yearMonth % 13),
+		Day:         int((value >> 17) & 0x1f), // 5 bits starting at 17th
+		Hour:        int((value >> 12) & 0x1f), // 5 bits starting at 12th
+		Minute:      int((value >> 6) & 0x3f),  // 6 bits starting at 6th
+		Second:      int(value & 0x3f),         // 6 bits starting at 0th
+		Microsecond: int(raw & 0xffffff),       // 24 lower bits
+	}
+}
+
+// sortJSONKeys returns the keys of an object in the order MySQL stores
+// them: by length first, and then by bytes.
+func sortJSONKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// MarshalJSONValue serializes a value tree described in DecodeJSON as
+// RFC 8259 JSON text, the same as MySQL prints the document: the keys
+// of objects are in the MySQL order, dates and times are strings, and
+// decimals are numbers.
+func MarshalJSONValue(v interface{}) ([]byte, error) {
+	result := &bytes.Buffer{}
+	if err := marshalJSONValue(v, result); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+func marshalJSONValue(v interface{}, result *bytes.Buffer) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result.WriteByte('{')
+		for i, k := range sortJSONKeys(v) {
+			if i > 0 {
+				result.WriteString(", ")
+			}
+			marshalJSONString(k, result)
+			result.WriteString(": ")
+			if err := marshalJSONValue(v[k], result); err != nil {
+				return err
+			}
+		}
+		result.WriteByte('}')
+	case []interface{}:
+		result.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				result.WriteString(", ")
+			}
+			if err := marshalJSONValue(e, result); err != nil {
+				return err
+			}
+		}
+		result.WriteByte(']')
+	case nil:
+		result.WriteString("null")
+	case bool:
+		result.WriteString(strconv.FormatBool(v))
+	case int64:
+		result.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		result.WriteString(strconv.FormatUint(v, 10))
+	case float64:
+		return marshalJSONDouble(v, result)
+	case string:
+		marshalJSONString(v, result)
+	case JSONDecimal:
+		result.WriteString(v.Value)
+	case JSONDate, JSONTime, JSONDateTime, JSONOpaque:
+		marshalJSONString(v.(fmt.Stringer).String(), result)
+	default:
+		return fmt.Errorf("unknown json value %T", v)
+	}
+	return nil
+}
+
+// marshalJSONDouble prints a double the way MySQL does, with .0 when it
+// is integral, and the exponent without + and leading zeros.
+func marshalJSONDouble(v float64, result *bytes.Buffer) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("unsupported json double %v", v)
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		exp := strings.TrimLeft(strings.TrimPrefix(s[i+1:], "+"), "0")
+		if strings.HasPrefix(exp, "-") {
+			exp = "-" + strings.TrimLeft(exp[1:], "0")
+		}
+		s = s[:i+1] + exp
+	} else if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	result.WriteString(s)
+	return nil
+}
+
+// marshalJSONString prints a quoted string, only the characters RFC
+// 8259 requires are escaped, and invalid UTF-8 is replaced by U+FFFD.
+func marshalJSONString(s string, result *bytes.Buffer) {
+	const hex = "0123456789abcdef"
+	result.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				result.WriteByte('\\')
+				result.WriteByte(c)
+			case c == '\b':
+				result.WriteString(`\b`)
+			case c == '\f':
+				result.WriteString(`\f`)
+			case c == '\n':
+				result.WriteString(`\n`)
+			case c == '\r':
+				result.WriteString(`\r`)
+			case c == '\t':
+				result.WriteString(`\t`)
+			case c < 0x20:
+				result.WriteString(`\u00`)
+				result.WriteByte(hex[c>>4])
+				result.WriteByte(hex[c&0xf])
+			default:
+				result.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			result.WriteString("\ufffd")
+		} else {
+			result.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	result.WriteByte('"')
+}
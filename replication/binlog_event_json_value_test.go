@@ -0,0 +1,183 @@
+package replication
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	testcases := []struct {
+		data []byte
+		want interface{}
+		json string
+	}{
+		{
+			data: []byte{},
+			json: `null`,
+		},
+		{
+			data: []byte{0, 1, 0, 14, 0, 11, 0, 1, 0, 12, 12, 0, 97, 1, 98},
+			want: map[string]interface{}{"a": "b"},
+			json: `{"a": "b"}`,
+		},
+		{
+			data: []byte{0, 4, 0, 60, 0, 32, 0, 1, 0, 33, 0, 1, 0, 34, 0, 2, 0, 36, 0, 2, 0, 12, 38, 0, 12, 40, 0, 12, 42, 0, 2, 46, 0, 97, 99, 97, 98, 98, 99, 1, 98, 1, 100, 3, 97, 98, 99, 2, 0, 14, 0, 12, 10, 0, 12, 12, 0, 1, 120, 1, 121},
+			want: map[string]interface{}{"a": "b", "c": "d", "ab": "abc", "bc": []interface{}{"x", "y"}},
+			json: `{"a": "b", "c": "d", "ab": "abc", "bc": ["x", "y"]}`,
+		},
+		{
+			data: []byte{2, 2, 0, 10, 0, 5, 1, 0, 5, 2, 0},
+			want: []interface{}{int64(1), int64(2)},
+			json: `[1, 2]`,
+		},
+		{
+			data: []byte{12, 7, 'a', '"', '\\', '\n', 0x01, '<', '/'},
+			want: "a\"\\\n\x01</",
+			json: `"a\"\\\n\u0001</"`,
+		},
+		{
+			data: []byte{4, 2},
+			want: false,
+			json: `false`,
+		},
+		{
+			data: []byte{10, 255, 255, 255, 255, 255, 255, 255, 255},
+			want: uint64(18446744073709551615),
+			json: `18446744073709551615`,
+		},
+		{
+			data: []byte{11, 0, 0, 0, 0, 0, 0, 4, 64},
+			want: float64(2.5),
+			json: `2.5`,
+		},
+		{
+			data: []byte{11, 0, 0, 0, 0, 0, 0, 240, 63},
+			want: float64(1),
+			json: `1.0`,
+		},
+		{
+			data: []byte{11, 0, 0, 0, 0, 0, 0, 240, 127},
+			want: math.Inf(1),
+		},
+		{
+			data: []byte{15, 10, 8, 0, 0, 0, 0, 0, 30, 149, 25},
+			want: JSONDate{Year: 2015, Month: 1, Day: 15},
+			json: `"2015-01-15"`,
+		},
+		{
+			data: []byte{15, 11, 8, 0, 0, 0, 184, 200, 0, 0, 128},
+			want: JSONTime{Negative: true, Hour: 12, Minute: 34, Second: 56},
+			json: `"-12:34:56.000000"`,
+		},
+		{
+			data: []byte{15, 12, 8, 123, 0, 0, 25, 118, 31, 149, 25},
+			want: JSONDateTime{Type: TypeDateTime, Year: 2015, Month: 1, Day: 15, Hour: 23, Minute: 24, Second: 25, Microsecond: 123},
+			json: `"2015-01-15 23:24:25.000123"`,
+		},
+		{
+			data: []byte{15, 246, 8, 13, 4, 135, 91, 205, 21, 4, 210},
+			want: JSONDecimal{Precision: 13, Scale: 4, Value: "123456789.1234"},
+			json: `123456789.1234`,
+		},
+		{
+			data: []byte{15, 16, 2, 202, 254},
+			want: JSONOpaque{Type: 16, Data: []byte{202, 254}},
+			json: `"base64:type16:yv4="`,
+		},
+	}
+
+	for _, v := range testcases {
+		out, err := DecodeJSON(v.data)
+		if err != nil {
+			t.Fatalf("DecodeJSON fail. data: %v err: %v", v.data, err)
+		}
+		if !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("want != out data: %v want: %#v out: %#v", v.data, v.want, out)
+		}
+		b, err := MarshalJSONValue(out)
+		if (err == nil) != (v.json != "") {
+			t.Fatalf("MarshalJSONValue data: %v err: %v", v.data, err)
+		}
+		if string(b) != v.json {
+			t.Fatalf("want != out data: %v want: %v out: %s", v.data, v.json, b)
+		}
+	}
+
+	for _, bad := range [][]byte{
+		{15, 10, 8, 0, 0},
+		{15, 10, 4, 0, 0, 0, 0},
+		{15, 246, 0},
+		{13, 0},
+	} {
+		if _, err := DecodeJSON(bad); err == nil {
+			t.Fatalf("DecodeJSON(%v) want error", bad)
+		}
+	}
+}
+
+func TestMarshalJSONValue(t *testing.T) {
+	testcases := []struct {
+		input interface{}
+		want  string
+	}{
+		{
+			input: 1e20,
+			want:  `1e20`,
+		},
+		{
+			input: -1.5e-7,
+			want:  `-1.5e-7`,
+		},
+		{
+			input: "\xffé",
+			want:  "\"\ufffdé\"",
+		},
+		{
+			input: map[string]interface{}{},
+			want:  `{}`,
+		},
+		{
+			input: map[string]interface{}{"b": true, "a": nil, "aa": int64(-1)},
+			want:  `{"a": null, "b": true, "aa": -1}`,
+		},
+	}
+	for _, v := range testcases {
+		out, err := MarshalJSONValue(v.input)
+		if err != nil {
+			t.Fatalf("MarshalJSONValue fail. input: %v err: %v", v.input, err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("want != out want: %v out: %s", v.want, out)
+		}
+	}
+
+	if _, err := MarshalJSONValue(int(1)); err == nil {
+		t.Fatalf("MarshalJSONValue(int) want error")
+	}
+}
+
+func TestCellJSON(t *testing.T) {
+	data := jsonCell([]byte{2, 2, 0, 10, 0, 5, 1, 0, 5, 2, 0})
+	out, l, err := CellJSON(data, 0, 4)
+	if err != nil {
+		t.Fatalf("CellJSON fail. err: %v", err)
+	}
+	if want := []interface{}{int64(1), int64(2)}; l != len(data) || !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out want: %v %v out: %v %v", len(data), want, l, out)
+	}
+	if _, _, err = CellJSON(data[:8], 0, 4); err == nil {
+		t.Fatalf("CellJSON want error")
+	}
+
+	diffs, _, err := JSONDiffs(jsonCell([]byte{0, 3, '$', '.', 'a', 3, 5, 2, 0, 2, 3, '$', '.', 'b'}), 0, 4)
+	if err != nil {
+		t.Fatalf("JSONDiffs fail. err: %v", err)
+	}
+	if v, err := diffs[0].JSONValue(); err != nil || v != int64(2) {
+		t.Fatalf("JSONValue want 2 out: %v err: %v", v, err)
+	}
+	if v, err := diffs[1].JSONValue(); err != nil || v != nil {
+		t.Fatalf("JSONValue want nil out: %v err: %v", v, err)
+	}
+}
@@ -2,6 +2,8 @@ package replication
 
 import (
 	"encoding/binary"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 // This file contains utility methods to create binlog replication
@@ -22,6 +24,21 @@ func NewMySQL56BinlogFormat() BinlogFormat {
 	}
 }
 
+// NewMySQL80BinlogFormat returns a typical BinlogFormat for MySQL 8.0.
+func NewMySQL80BinlogFormat() BinlogFormat {
+	return BinlogFormat{
+		FormatVersion:     4,
+		ServerVersion:     "8.0.21",
+		HeaderLength:      19,
+		ChecksumAlgorithm: BinlogChecksumAlgCRC32,
+		HeaderSizes: []byte{
+			56, 13, 0, 8, 0, 18, 0, 4, 4, 4,
+			4, 18, 0, 0, 95, 0, 4, 26, 8, 0,
+			0, 0, 8, 8, 8, 2, 0, 0, 0, 10,
+			10, 10, 42, 42, 0, 18, 52, 0, 10, 0},
+	}
+}
+
 // NewMariaDBBinlogFormat returns a typical BinlogFormat for MariaDB 10.0.
 func NewMariaDBBinlogFormat() BinlogFormat {
 	return BinlogFormat{
@@ -204,10 +221,16 @@ func NewInvalidQueryEvent(f BinlogFormat, s *FakeBinlogStream) BinlogEvent {
 	return NewMysql56BinlogEvent(ev)
 }
 
-// NewXIDEvent returns a XID event. We do not use the data, so keep it 0.
+// NewXIDEvent returns a XID event with a xid of 0.
 func NewXIDEvent(f BinlogFormat, s *FakeBinlogStream) BinlogEvent {
+	return NewXIDEventWithID(f, s, 0)
+}
+
+// NewXIDEventWithID returns a XID event with the given xid.
+func NewXIDEventWithID(f BinlogFormat, s *FakeBinlogStream, xid uint64) BinlogEvent {
 	length := 8
 	data := make([]byte, length)
+	binary.LittleEndian.PutUint64(data, xid)
 
 	ev := s.Packetize(f, eXIDEvent, 0, data)
 	return NewMysql56BinlogEvent(ev)
@@ -232,6 +255,39 @@ func NewIntVarEvent(f BinlogFormat, s *FakeBinlogStream, typ byte, value uint64)
 	return NewMysql56BinlogEvent(ev)
 }
 
+// NewMySQL57GTIDEvent returns a MySQL 5.7 GTID event, which carries
+// the logical timestamp after the GTID.
+func NewMySQL57GTIDEvent(f BinlogFormat, s *FakeBinlogStream, gtid Mysql56GTID, lt LogicalTimestamp) BinlogEvent {
+	data := newMySQL57GTIDData(gtid, lt)
+	ev := s.Packetize(f, eGTIDEvent, 0, data)
+	return NewMysql56BinlogEvent(ev)
+}
+
+// NewAnonymousGTIDEvent returns a MySQL 5.7 ANONYMOUS_GTID_EVENT, which is
+// written when gtid_mode is OFF but still carries the logical timestamp.
+func NewAnonymousGTIDEvent(f BinlogFormat, s *FakeBinlogStream, lt LogicalTimestamp) BinlogEvent {
+	data := newMySQL57GTIDData(Mysql56GTID{}, lt)
+	ev := s.Packetize(f, eAnonymousGTIDEvent, 0, data)
+	return NewMysql56BinlogEvent(ev)
+}
+
+func newMySQL57GTIDData(gtid Mysql56GTID, lt LogicalTimestamp) []byte {
+	length := 1 + // flags
+		16 + // SID
+		8 + // GNO
+		1 + // logical timestamp type code
+		8 + // last_committed
+		8 // sequence_number
+	data := make([]byte, length)
+	data[0] = 1 // commit flag
+	copy(data[1:1+16], gtid.Server[:])
+	binary.LittleEndian.PutUint64(data[1+16:1+16+8], uint64(gtid.Sequence))
+	data[1+16+8] = logicalTimestampTypeCode
+	binary.LittleEndian.PutUint64(data[1+16+8+1:1+16+8+1+8], uint64(lt.LastCommitted))
+	binary.LittleEndian.PutUint64(data[1+16+8+1+8:], uint64(lt.SequenceNumber))
+	return data
+}
+
 // NewMariaDBGTIDEvent returns a MariaDB specific GTID event.
 // It ignores the Server in the gtid, instead uses the FakeBinlogStream.ServerID.
 func NewMariaDBGTIDEvent(f BinlogFormat, s *FakeBinlogStream, gtid MariadbGTID, hasBegin bool) BinlogEvent {
@@ -323,6 +379,17 @@ func NewTableMapEvent(f BinlogFormat, s *FakeBinlogStream, tableID uint64, tm *T
 		panic("bad encoding")
 	}
 
+	// The collations are written as a COLUMN_CHARSET field.
+	if tm.Collations != nil {
+		var field []byte
+		for _, c := range tm.characterColumns() {
+			field = appendLenEncInt(field, tm.Collations[c])
+		}
+		data = append(data, tableMapColumnCharset)
+		data = appendLenEncInt(data, uint64(len(field)))
+		data = append(data, field...)
+	}
+
 	ev := s.Packetize(f, eTableMapEvent, 0, data)
 	return NewMariadbBinlogEvent(ev)
 }
@@ -342,10 +409,18 @@ func NewDeleteRowsEvent(f BinlogFormat, s *FakeBinlogStream, tableID uint64, row
 	return newRowsEvent(f, s, eDeleteRowsEventV2, tableID, rows)
 }
 
+// NewPartialUpdateRowsEvent returns a PartialUpdateRows event. The
+// ValueOptions and PartialJSONColumns of every row are written in front
+// of its after image.
+func NewPartialUpdateRowsEvent(f BinlogFormat, s *FakeBinlogStream, tableID uint64, rows Rows) BinlogEvent {
+	return newRowsEvent(f, s, ePartialUpdateRowsEvent, tableID, rows)
+}
+
 // newRowsEvent can create an event of type:
 // eWriteRowsEventV1, eWriteRowsEventV2,
 // eUpdateRowsEventV1, eUpdateRowsEventV2,
-// eDeleteRowsEventV1, eDeleteRowsEventV2.
+// eDeleteRowsEventV1, eDeleteRowsEventV2,
+// ePartialUpdateRowsEvent.
 func newRowsEvent(f BinlogFormat, s *FakeBinlogStream, typ byte, tableID uint64, rows Rows) BinlogEvent {
 	if f.HeaderSize(typ) == 6 {
 		panic("Not implemented, post_header_length==6")
@@ -362,13 +437,17 @@ func newRowsEvent(f BinlogFormat, s *FakeBinlogStream, typ byte, tableID uint64,
 			len(row.NullColumns.data) +
 			len(row.Identify) +
 			len(row.Data)
+		if typ == ePartialUpdateRowsEvent {
+			length += len(appendLenEncInt(nil, row.ValueOptions)) + // value_options, packed integer
+				len(row.PartialJSONColumns.data)
+		}
 	}
 	data := make([]byte, length)
 
 	hasIdentify := typ == eUpdateRowsEventV1 || typ == eUpdateRowsEventV2 ||
-		typ == eDeleteRowsEventV1 || typ == eDeleteRowsEventV2
+		typ == eDeleteRowsEventV1 || typ == eDeleteRowsEventV2 || typ == ePartialUpdateRowsEvent
 	hasData := typ == eWriteRowsEventV1 || typ == eWriteRowsEventV2 ||
-		typ == eUpdateRowsEventV1 || typ == eUpdateRowsEventV2
+		typ == eUpdateRowsEventV1 || typ == eUpdateRowsEventV2 || typ == ePartialUpdateRowsEvent
 
 	data[0] = byte(tableID)
 	data[1] = byte(tableID >> 8)
@@ -401,6 +480,10 @@ func newRowsEvent(f BinlogFormat, s *FakeBinlogStream, typ byte, tableID uint64,
 			pos += copy(data[pos:], row.Identify)
 		}
 		if hasData {
+			if typ == ePartialUpdateRowsEvent {
+				pos += copy(data[pos:], appendLenEncInt(nil, row.ValueOptions))
+				pos += copy(data[pos:], row.PartialJSONColumns.data)
+			}
 			pos += copy(data[pos:], row.NullColumns.data)
 			pos += copy(data[pos:], row.Data)
 		}
@@ -409,3 +492,56 @@ func newRowsEvent(f BinlogFormat, s *FakeBinlogStream, typ byte, tableID uint64,
 	ev := s.Packetize(f, typ, 0, data)
 	return NewMysql56BinlogEvent(ev)
 }
+
+// NewTransactionPayloadEvent returns a TransactionPayload event which
+// contains events compressed with compressionType. The events must not
+// have checksums.
+func NewTransactionPayloadEvent(f BinlogFormat, s *FakeBinlogStream, compressionType uint64, events ...BinlogEvent) BinlogEvent {
+	var raw []byte
+	for _, ev := range events {
+		raw = append(raw, ev.Bytes()...)
+	}
+
+	payload := raw
+	if compressionType == PayloadCompressionZstd {
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			panic(err)
+		}
+		payload = encoder.EncodeAll(raw, nil)
+	}
+
+	var data []byte
+	for _, field := range [][2]uint64{
+		{payloadCompressionTypeField, compressionType},
+		{payloadUncompressedSizeField, uint64(len(raw))},
+		{payloadSizeField, uint64(len(payload))},
+	} {
+		value := appendLenEncInt(nil, field[1])
+		data = appendLenEncInt(data, field[0])
+		data = appendLenEncInt(data, uint64(len(value)))
+		data = append(data, value...)
+	}
+	data = appendLenEncInt(data, payloadHeaderEndMark)
+	data = append(data, payload...)
+
+	ev := s.Packetize(f, eTransactionPayloadEvent, 0, data)
+	return NewMysql56BinlogEvent(ev)
+}
+
+// appendLenEncInt appends v to data as a var-len encoded integer.
+func appendLenEncInt(data []byte, v uint64) []byte {
+	switch {
+	case v < 251:
+		return append(data, byte(v))
+	case v < 1<<16:
+		return append(data, 0xfc, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(data, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	}
+	data = append(data, 0xfe)
+	for i := uint(0); i < 64; i += 8 {
+		data = append(data, byte(v>>i))
+	}
+	return data
+}
@@ -162,6 +162,15 @@ func TestXIDEvent(t *testing.T) {
 	if !ev.IsXID() {
 		t.Fatalf("NewXIDEvent().IsXID() is false")
 	}
+
+	ev = NewXIDEventWithID(f, s, 0x123456789abcdef0)
+	xid, err := ev.XID(f)
+	if err != nil {
+		t.Fatalf("NewXIDEventWithID().XID() returned error: %v", err)
+	}
+	if xid != 0x123456789abcdef0 {
+		t.Fatalf("NewXIDEventWithID().XID() returned %x was expecting %x", xid, uint64(0x123456789abcdef0))
+	}
 }
 
 func TestIntVarEvent(t *testing.T) {
@@ -290,6 +299,73 @@ func TestMariadDBGTIDEVent(t *testing.T) {
 	}
 }
 
+func TestMySQL57GTIDEvent(t *testing.T) {
+	f := NewMySQL56BinlogFormat()
+	s := NewFakeBinlogStream()
+
+	want := Mysql56GTID{
+		Server:   SID{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		Sequence: 0x123456789abc,
+	}
+	wantLT := LogicalTimestamp{LastCommitted: 0x1234, SequenceNumber: 0x1236}
+	event := NewMySQL57GTIDEvent(f, s, want, wantLT)
+	if !event.IsValid() {
+		t.Fatalf("NewMySQL57GTIDEvent().IsValid() is false")
+	}
+	if !event.IsGTID() || event.IsAnonymousGTID() {
+		t.Fatalf("NewMySQL57GTIDEvent().IsGTID() is false or IsAnonymousGTID() is true")
+	}
+	event, _, err := event.StripChecksum(f)
+	if err != nil {
+		t.Fatalf("StripChecksum failed: %v", err)
+	}
+
+	gtid, hasBegin, err := event.GTID(f)
+	if err != nil {
+		t.Fatalf("NewMySQL57GTIDEvent().GTID() returned error: %v", err)
+	}
+	if hasBegin {
+		t.Fatalf("NewMySQL57GTIDEvent().GTID() returned hasBegin")
+	}
+	if gtid != want {
+		t.Fatalf("NewMySQL57GTIDEvent().GTID() = %v, want %v", gtid, want)
+	}
+
+	lt, ok, err := event.LogicalTimestamp(f)
+	if err != nil {
+		t.Fatalf("NewMySQL57GTIDEvent().LogicalTimestamp() returned error: %v", err)
+	}
+	if !ok || lt != wantLT {
+		t.Fatalf("NewMySQL57GTIDEvent().LogicalTimestamp() = %+v %v, want %+v true", lt, ok, wantLT)
+	}
+}
+
+func TestAnonymousGTIDEvent(t *testing.T) {
+	f := NewMySQL56BinlogFormat()
+	s := NewFakeBinlogStream()
+
+	wantLT := LogicalTimestamp{LastCommitted: 7, SequenceNumber: 9}
+	event := NewAnonymousGTIDEvent(f, s, wantLT)
+	if !event.IsValid() {
+		t.Fatalf("NewAnonymousGTIDEvent().IsValid() is false")
+	}
+	if event.IsGTID() || !event.IsAnonymousGTID() {
+		t.Fatalf("NewAnonymousGTIDEvent().IsGTID() is true or IsAnonymousGTID() is false")
+	}
+	event, _, err := event.StripChecksum(f)
+	if err != nil {
+		t.Fatalf("StripChecksum failed: %v", err)
+	}
+
+	lt, ok, err := event.LogicalTimestamp(f)
+	if err != nil {
+		t.Fatalf("NewAnonymousGTIDEvent().LogicalTimestamp() returned error: %v", err)
+	}
+	if !ok || lt != wantLT {
+		t.Fatalf("NewAnonymousGTIDEvent().LogicalTimestamp() = %+v %v, want %+v true", lt, ok, wantLT)
+	}
+}
+
 func TestTableMapEvent(t *testing.T) {
 	f := NewMySQL56BinlogFormat()
 	s := NewFakeBinlogStream()
@@ -447,3 +523,173 @@ func TestRowsEvent(t *testing.T) {
 		t.Fatalf("NewRowsEvent().Rows() got Rows:\n%v\nexpected:\n%v", gotRows, rows)
 	}
 }
+
+func TestPartialUpdateRowsEvent(t *testing.T) {
+	f := NewMySQL80BinlogFormat()
+	s := NewFakeBinlogStream()
+
+	tm := &TableMap{
+		Database: "my_database",
+		Name:     "my_table",
+		Types: []byte{
+			TypeLong,
+			TypeJSON,
+			TypeJSON,
+		},
+		CanBeNull: NewServerBitmap(3),
+		Metadata: []uint16{
+			0,
+			4,
+			4,
+		},
+	}
+
+	rows := Rows{
+		IdentifyColumns: NewServerBitmap(3),
+		DataColumns:     NewServerBitmap(3),
+		Rows: []Row{
+			{
+				NullIdentifyColumns: NewServerBitmap(3),
+				NullColumns:         NewServerBitmap(3),
+				Identify: []byte{
+					0x10, 0x20, 0x30, 0x40, // long
+					15, 0, 0, 0, // len(json)
+					0, 1, 0, 14, 0, 11, 0, 1, 0, 12, 12, 0, 97, 1, 98, // {"a":"b"}
+					11, 0, 0, 0, // len(json)
+					2, 2, 0, 10, 0, 5, 1, 0, 5, 2, 0, // [1,2]
+				},
+				ValueOptions:       rowValueOptionPartialJSON,
+				PartialJSONColumns: NewServerBitmap(2),
+				Data: []byte{
+					0x10, 0x20, 0x30, 0x40, // long
+					13, 0, 0, 0, // len(json)
+					0, 1, 0, 12, 0, 11, 0, 1, 0, 5, 2, 0, 97, // {"a":2}
+					10, 0, 0, 0, // len(diffs)
+					1, 4, '$', '[', '1', ']', 3, 12, 1, 'x', // insert $[1] "x"
+				},
+			},
+		},
+	}
+	for c := 0; c < 3; c++ {
+		rows.IdentifyColumns.Set(c, true)
+		rows.DataColumns.Set(c, true)
+	}
+	rows.Rows[0].PartialJSONColumns.Set(1, true)
+
+	ev := NewPartialUpdateRowsEvent(f, s, 0x102030405060, rows)
+	if !ev.IsValid() {
+		t.Fatal("NewPartialUpdateRowsEvent().IsValid() is false")
+	}
+	if !ev.IsUpdateRows() {
+		t.Fatal("NewPartialUpdateRowsEvent().IsUpdateRows() if false")
+	}
+
+	ev, _, err := ev.StripChecksum(f)
+	if err != nil {
+		t.Fatalf("StripChecksum failed: %v", err)
+	}
+	gotRows, err := ev.Rows(f, tm)
+	if err != nil {
+		t.Fatalf("NewPartialUpdateRowsEvent().Rows() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(gotRows, rows) {
+		t.Fatalf("NewPartialUpdateRowsEvent().Rows() got Rows:\n%v\nexpected:\n%v", gotRows, rows)
+	}
+
+	row := gotRows.Rows[0]
+	for c, want := range []bool{false, false, true} {
+		if out := row.IsPartialJSON(tm, c); out != want {
+			t.Fatalf("IsPartialJSON(%v) want: %v out: %v", c, want, out)
+		}
+	}
+
+	before, ok, err := gotRows.IdentifyCell(tm, 0, 2)
+	if err != nil || !ok {
+		t.Fatalf("IdentifyCell fail. ok: %v err: %v", ok, err)
+	}
+	diffs, l, err := JSONDiffs(row.Data, 4+17, tm.Metadata[2])
+	if err != nil {
+		t.Fatalf("JSONDiffs fail. err: %v", err)
+	}
+	if l != 14 || len(diffs) != 1 || diffs[0].Operation != JSONDiffInsert ||
+		diffs[0].Path != "$[1]" || string(diffs[0].Value) != "'\"x\"'" {
+		t.Fatalf("JSONDiffs got %v %+v", l, diffs)
+	}
+	after, err := ApplyJSONDiffs(before, 0, tm.Metadata[2], diffs)
+	if err != nil {
+		t.Fatalf("ApplyJSONDiffs fail. err: %v", err)
+	}
+	if want := "JSON_ARRAY(1,'x',2)"; string(after) != want {
+		t.Fatalf("ApplyJSONDiffs want: %v out: %s", want, after)
+	}
+
+	// value_options is a packed integer, values >= 251 need more than one byte.
+	rows.Rows[0].ValueOptions = rowValueOptionPartialJSON | 1<<8
+	ev, _, err = NewPartialUpdateRowsEvent(f, s, 0x102030405060, rows).StripChecksum(f)
+	if err != nil {
+		t.Fatalf("StripChecksum failed: %v", err)
+	}
+	if gotRows, err = ev.Rows(f, tm); err != nil || !reflect.DeepEqual(gotRows, rows) {
+		t.Fatalf("NewPartialUpdateRowsEvent().Rows() got Rows:\n%v\nexpected:\n%v\nerr: %v", gotRows, rows, err)
+	}
+}
+
+func TestTableMapEvent_collations(t *testing.T) {
+	f := NewMySQL56BinlogFormat()
+	s := NewFakeBinlogStream()
+
+	tm := &TableMap{
+		Database: "my_database",
+		Name:     "my_table",
+		Types: []byte{
+			TypeLongLong,
+			TypeVarchar,
+			TypeString,
+			TypeString,
+			TypeBlob,
+		},
+		CanBeNull: NewServerBitmap(5),
+		Metadata: []uint16{
+			0,
+			384,
+			uint16(TypeEnum)<<8 | 1,
+			uint16(TypeString)<<8 | 10,
+			2,
+		},
+		Collations: []uint64{0, 28, 0, 8, 63},
+	}
+
+	ev := NewTableMapEvent(f, s, 1, tm)
+	ev, _, err := ev.StripChecksum(f)
+	if err != nil {
+		t.Fatalf("StripChecksum failed: %v", err)
+	}
+	gotTm, err := ev.TableMap(f)
+	if err != nil {
+		t.Fatalf("NewTableMapEvent().TableMapEvent() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(gotTm, tm) {
+		t.Fatalf("NewTableMapEvent().TableMapEvent() got TableMap:\n%v\nexpected:\n%v", gotTm, tm)
+	}
+
+	// DEFAULT_CHARSET is latin1_swedish_ci, and the 2nd character
+	// column is utf8mb4_0900_ai_ci.
+	gotTm.Collations = nil
+	if err = gotTm.readOptionalMetadata([]byte{tableMapDefaultCharset, 3, 8, 1, 255, 7, 0}, 0); err != nil {
+		t.Fatalf("readOptionalMetadata failed: %v", err)
+	}
+	if want := []uint64{0, 8, 0, 255, 8}; !reflect.DeepEqual(gotTm.Collations, want) {
+		t.Fatalf("DEFAULT_CHARSET got %v want %v", gotTm.Collations, want)
+	}
+
+	for _, bad := range [][]byte{
+		{tableMapDefaultCharset, 3, 8, 3, 255},
+		{tableMapDefaultCharset, 2, 8, 1},
+		{tableMapColumnCharset, 2, 8, 8},
+		{tableMapColumnCharset, 5, 8},
+	} {
+		if err = gotTm.readOptionalMetadata(bad, 0); err == nil {
+			t.Fatalf("readOptionalMetadata(%v) want error", bad)
+		}
+	}
+}
@@ -42,6 +42,13 @@ func (ev mariadbBinlogEvent) GTID(f BinlogFormat) (GTID, bool, error) {
 	}, flags2&FLStandalone == 0, nil
 }
 
+// LogicalTimestamp implements BinlogEvent.LogicalTimestamp().
+// MariaDB groups parallel transactions by commit_id instead, so there is no
+// logical timestamp.
+func (ev mariadbBinlogEvent) LogicalTimestamp(f BinlogFormat) (LogicalTimestamp, bool, error) {
+	return LogicalTimestamp{}, false, nil
+}
+
 // PreviousGTIDs implements BinlogEvent.PreviousGTIDs().
 func (ev mariadbBinlogEvent) PreviousGTIDs(f BinlogFormat) (GTIDSet, error) {
 	return nil, fmt.Errorf("MariaDB should not provide PREVIOUS_GTIDS_EVENT events")
@@ -37,6 +37,34 @@ func (ev mysql56BinlogEvent) GTID(f BinlogFormat) (GTID, bool, error) {
 	return Mysql56GTID{Server: sid, Sequence: gno}, false, nil
 }
 
+// LogicalTimestamp implements BinlogEvent.LogicalTimestamp().
+//
+// Expected format (MySQL 5.7.6+, following the GTID fields above):
+//   # bytes   field
+//   1         logical timestamp type code (2)
+//   8         last_committed
+//   8         sequence_number
+// MySQL 5.6 ends the event after the GNO, so there is no logical timestamp.
+func (ev mysql56BinlogEvent) LogicalTimestamp(f BinlogFormat) (LogicalTimestamp, bool, error) {
+	const ltPos = 1 + 16 + 8
+
+	data := ev.Bytes()[f.HeaderLength:]
+	if len(data) <= ltPos {
+		return LogicalTimestamp{}, false, nil
+	}
+	if data[ltPos] != logicalTimestampTypeCode {
+		return LogicalTimestamp{}, false, fmt.Errorf("unknown logical timestamp type code: %v", data[ltPos])
+	}
+	if len(data) < ltPos+1+8+8 {
+		return LogicalTimestamp{}, false, fmt.Errorf("logical timestamp overflows buffer (%v > %v)",
+			ltPos+1+8+8, len(data))
+	}
+	return LogicalTimestamp{
+		LastCommitted:  int64(binary.LittleEndian.Uint64(data[ltPos+1 : ltPos+1+8])),
+		SequenceNumber: int64(binary.LittleEndian.Uint64(data[ltPos+1+8 : ltPos+1+8+8])),
+	}, true, nil
+}
+
 // PreviousGTIDs implements BinlogEvent.PreviousGTIDs().
 func (ev mysql56BinlogEvent) PreviousGTIDs(f BinlogFormat) (GTIDSet, error) {
 	data := ev.Bytes()[f.HeaderLength:]
@@ -79,6 +79,26 @@ func TestMysql56GTID(t *testing.T) {
 	}
 }
 
+func TestMysql56LogicalTimestamp(t *testing.T) {
+	format, err := mysql56FormatEvent.Format()
+	if err != nil {
+		t.Fatalf("Format() error: %v", err)
+	}
+	input, _, err := mysql56GTIDEvent.StripChecksum(format)
+	if err != nil {
+		t.Fatalf("StripChecksum() error: %v", err)
+	}
+
+	// MySQL 5.6 doesn't write the logical timestamp.
+	_, ok, err := input.LogicalTimestamp(format)
+	if err != nil {
+		t.Fatalf("LogicalTimestamp() error: %v", err)
+	}
+	if ok {
+		t.Errorf("LogicalTimestamp() returned a logical timestamp for MySQL 5.6")
+	}
+}
+
 func TestMysql56ParseGTID(t *testing.T) {
 	input := "00010203-0405-0607-0809-0A0B0C0D0E0F:56789"
 	want := Mysql56GTID{
@@ -0,0 +1,180 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// These constants are the field types in the header of a
+// TRANSACTION_PAYLOAD_EVENT.
+const (
+	payloadHeaderEndMark         = 0
+	payloadSizeField             = 1
+	payloadCompressionTypeField  = 2
+	payloadUncompressedSizeField = 3
+)
+
+// These constants describe the compression algorithm of a
+// TRANSACTION_PAYLOAD_EVENT.
+const (
+	// PayloadCompressionZstd is the payload compressed by zstd.
+	PayloadCompressionZstd = 0
+
+	// PayloadCompressionNone is the payload not compressed.
+	PayloadCompressionNone = 255
+)
+
+// zstdFrameMagic is the magic number at the start of every zstd frame.
+// Older versions of the zstd decoder return no data and no error for
+// input that is not a zstd frame, so it is checked before decoding.
+var zstdFrameMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// payloadDecoder is shared by all the TRANSACTION_PAYLOAD_EVENT,
+// DecodeAll can be called concurrently.
+var (
+	payloadDecoder     *zstd.Decoder
+	payloadDecoderErr  error
+	payloadDecoderOnce sync.Once
+)
+
+// TransactionPayload contains data from a TRANSACTION_PAYLOAD_EVENT,
+// written by MySQL 8.0.20+ with binlog_transaction_compression=ON.
+type TransactionPayload struct {
+	// CompressionType is the compression algorithm of Payload.
+	CompressionType uint64
+
+	// CompressedSize is the size of Payload.
+	CompressedSize uint64
+
+	// UncompressedSize is the size of the events in Payload once
+	// uncompressed.
+	UncompressedSize uint64
+
+	// Payload is the compressed events.
+	Payload []byte
+}
+
+// TransactionPayload implements BinlogEvent.TransactionPayload().
+//
+// Expected format (L = total length of event data):
+//  # bytes   field
+//  -- for each header field
+//  <var>     field type (var-len encoded)
+//  <var>     field length fl (var-len encoded)
+//  fl        field value (var-len encoded)
+//  --
+//  <var>     end mark, 0 (var-len encoded)
+//  L-...     payload
+func (ev binlogEvent) TransactionPayload(f BinlogFormat) (*TransactionPayload, error) {
+	data := ev.Bytes()[f.HeaderLength:]
+	result := &TransactionPayload{
+		CompressionType: PayloadCompressionNone,
+	}
+
+	pos := 0
+	for {
+		typ, next, ok := readLenEncInt(data, pos)
+		if !ok {
+			return nil, fmt.Errorf("transaction payload event is too small to read field type at %v", pos)
+		}
+		pos = next
+		if typ == payloadHeaderEndMark {
+			break
+		}
+
+		length, next, ok := readLenEncInt(data, pos)
+		if !ok || uint64(len(data)-next) < length {
+			return nil, fmt.Errorf("transaction payload event is too small to read field %v at %v", typ, pos)
+		}
+		pos = next
+		field := data[:pos+int(length)]
+
+		var value uint64
+		switch typ {
+		case payloadSizeField, payloadCompressionTypeField, payloadUncompressedSizeField:
+			if value, _, ok = readLenEncInt(field, pos); !ok {
+				return nil, fmt.Errorf("transaction payload event has a bad field %v at %v", typ, pos)
+			}
+		}
+		switch typ {
+		case payloadSizeField:
+			result.CompressedSize = value
+		case payloadCompressionTypeField:
+			result.CompressionType = value
+		case payloadUncompressedSizeField:
+			result.UncompressedSize = value
+		}
+		pos += int(length)
+	}
+
+	result.Payload = data[pos:]
+	if result.CompressedSize > uint64(len(result.Payload)) {
+		return nil, fmt.Errorf("transaction payload event has %v bytes of payload, want %v",
+			len(result.Payload), result.CompressedSize)
+	}
+	if result.CompressedSize > 0 {
+		result.Payload = result.Payload[:result.CompressedSize]
+	}
+	result.CompressedSize = uint64(len(result.Payload))
+	return result, nil
+}
+
+// Decompress returns the uncompressed events of the payload.
+func (p *TransactionPayload) Decompress() ([]byte, error) {
+	switch p.CompressionType {
+	case PayloadCompressionNone:
+		return p.Payload, nil
+	case PayloadCompressionZstd:
+		payloadDecoderOnce.Do(func() {
+			payloadDecoder, payloadDecoderErr = zstd.NewReader(nil)
+		})
+		if payloadDecoderErr != nil {
+			return nil, payloadDecoderErr
+		}
+		if !bytes.HasPrefix(p.Payload, zstdFrameMagic) {
+			return nil, fmt.Errorf("can't decompress transaction payload: not a zstd frame")
+		}
+		data, err := payloadDecoder.DecodeAll(p.Payload, make([]byte, 0, p.UncompressedSize))
+		if err != nil {
+			return nil, fmt.Errorf("can't decompress transaction payload: %v", err)
+		}
+		if p.UncompressedSize != 0 && uint64(len(data)) != p.UncompressedSize {
+			return nil, fmt.Errorf("can't decompress transaction payload: uncompressed size %v, want %v",
+				len(data), p.UncompressedSize)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported transaction payload compression type %v", p.CompressionType)
+}
+
+// Events decompresses the payload and splits it into events. The
+// events don't have checksums, they can be parsed with f whatever its
+// ChecksumAlgorithm is.
+func (p *TransactionPayload) Events(f BinlogFormat) ([]BinlogEvent, error) {
+	data, err := p.Decompress()
+	if err != nil {
+		return nil, err
+	}
+	if p.UncompressedSize > 0 && uint64(len(data)) != p.UncompressedSize {
+		return nil, fmt.Errorf("transaction payload has %v bytes once uncompressed, want %v",
+			len(data), p.UncompressedSize)
+	}
+
+	var events []BinlogEvent
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < int(f.HeaderLength) {
+			return nil, fmt.Errorf("transaction payload is too small to read event header at %v", pos)
+		}
+		length := int(binary.LittleEndian.Uint32(data[pos+9 : pos+13]))
+		if length < int(f.HeaderLength) || length > len(data)-pos {
+			return nil, fmt.Errorf("transaction payload has a bad event length %v at %v", length, pos)
+		}
+		events = append(events, NewMysql56BinlogEvent(data[pos:pos+length]))
+		pos += length
+	}
+	return events, nil
+}
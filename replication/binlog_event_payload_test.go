@@ -0,0 +1,124 @@
+package replication
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransactionPayloadEvent(t *testing.T) {
+	f := NewMySQL80BinlogFormat()
+	s := NewFakeBinlogStream()
+
+	// The events in the payload have no checksum.
+	inner := f
+	inner.ChecksumAlgorithm = BinlogChecksumAlgOff
+	q := Query{
+		Database: "my_database",
+		SQL:      "insert into my_table values(1)",
+	}
+	events := []BinlogEvent{
+		NewQueryEvent(inner, s, q),
+		NewXIDEvent(inner, s),
+	}
+
+	for _, typ := range []uint64{PayloadCompressionZstd, PayloadCompressionNone} {
+		ev := NewTransactionPayloadEvent(f, s, typ, events...)
+		if !ev.IsValid() {
+			t.Fatalf("%v NewTransactionPayloadEvent().IsValid() is false", typ)
+		}
+		if !ev.IsTransactionPayload() {
+			t.Fatalf("%v NewTransactionPayloadEvent().IsTransactionPayload() is false", typ)
+		}
+		ev, _, err := ev.StripChecksum(f)
+		if err != nil {
+			t.Fatalf("%v StripChecksum failed: %v", typ, err)
+		}
+
+		payload, err := ev.TransactionPayload(f)
+		if err != nil {
+			t.Fatalf("%v TransactionPayload failed: %v", typ, err)
+		}
+		size := uint64(len(events[0].Bytes()) + len(events[1].Bytes()))
+		if payload.CompressionType != typ || payload.UncompressedSize != size ||
+			payload.CompressedSize != uint64(len(payload.Payload)) {
+			t.Fatalf("%v bad TransactionPayload: %+v", typ, payload)
+		}
+
+		got, err := payload.Events(f)
+		if err != nil {
+			t.Fatalf("%v Events failed: %v", typ, err)
+		}
+		if !reflect.DeepEqual(got, events) {
+			t.Fatalf("%v Events got %v expected %v", typ, got, events)
+		}
+		gotQuery, err := got[0].Query(f)
+		if err != nil || !reflect.DeepEqual(gotQuery, q) {
+			t.Fatalf("%v Query got %+v err: %v", typ, gotQuery, err)
+		}
+		if !got[1].IsXID() {
+			t.Fatalf("%v the last event is not a XID event", typ)
+		}
+	}
+}
+
+func TestTransactionPayloadEvent_error(t *testing.T) {
+	f := NewMySQL80BinlogFormat()
+	f.ChecksumAlgorithm = BinlogChecksumAlgOff
+	s := NewFakeBinlogStream()
+
+	testcases := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "no end mark",
+			data: []byte{payloadCompressionTypeField, 1, 0},
+		},
+		{
+			name: "short field",
+			data: []byte{payloadSizeField, 4, 1},
+		},
+		{
+			name: "short payload",
+			data: []byte{payloadSizeField, 1, 10, payloadHeaderEndMark, 1, 2},
+		},
+	}
+	for _, v := range testcases {
+		ev := NewMysql56BinlogEvent(s.Packetize(f, eTransactionPayloadEvent, 0, v.data))
+		if _, err := ev.TransactionPayload(f); err == nil {
+			t.Fatalf("%v TransactionPayload want error", v.name)
+		}
+	}
+
+	testcases = []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "unknown compression",
+			data: []byte{payloadCompressionTypeField, 1, 7, payloadHeaderEndMark},
+		},
+		{
+			name: "bad zstd",
+			data: []byte{payloadCompressionTypeField, 1, 0, payloadHeaderEndMark, 1, 2, 3},
+		},
+		{
+			name: "bad event",
+			data: []byte{payloadCompressionTypeField, 1, 255, payloadHeaderEndMark, 1, 2, 3},
+		},
+		{
+			name: "bad uncompressed size",
+			data: []byte{payloadCompressionTypeField, 1, 255, payloadUncompressedSizeField, 1, 9, payloadHeaderEndMark},
+		},
+	}
+	for _, v := range testcases {
+		ev := NewMysql56BinlogEvent(s.Packetize(f, eTransactionPayloadEvent, 0, v.data))
+		payload, err := ev.TransactionPayload(f)
+		if err != nil {
+			t.Fatalf("%v TransactionPayload failed: %v", v.name, err)
+		}
+		if _, err = payload.Events(f); err == nil {
+			t.Fatalf("%v Events want error", v.name)
+		}
+	}
+}
@@ -91,11 +91,108 @@ func (ev binlogEvent) TableMap(f BinlogFormat) (*TableMap, error) {
 	}
 
 	// A bit array that says if each colum can be NULL.
-	result.CanBeNull, _ = newBitmap(data, pos, columnCount)
+	result.CanBeNull, pos = newBitmap(data, pos, columnCount)
 
+	if err := result.readOptionalMetadata(data, pos); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
+// readOptionalMetadata reads the optional metadata at the end of a
+// TABLE_MAP_EVENT, see Table_map_log_event::init_optional_metadata in
+// libbinlogevents/src/rows_event.cpp.
+//
+// Expected format:
+//  # bytes   field
+//  -- for each field
+//  1         field type
+//  <var>     field length fl (var-len encoded)
+//  fl        field value
+//  --
+func (tm *TableMap) readOptionalMetadata(data []byte, pos int) error {
+	for pos < len(data) {
+		typ := data[pos]
+		length, next, ok := readLenEncInt(data, pos+1)
+		if !ok || uint64(len(data)-next) < length {
+			return fmt.Errorf("table map optional metadata is too small to read field %v at %v", typ, pos)
+		}
+		field := data[next : next+int(length)]
+		pos = next + int(length)
+
+		var err error
+		switch typ {
+		case tableMapDefaultCharset:
+			err = tm.readDefaultCharset(field)
+		case tableMapColumnCharset:
+			err = tm.readColumnCharset(field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// characterColumns returns the indexes of the character columns.
+func (tm *TableMap) characterColumns() []int {
+	var columns []int
+	for c := range tm.Types {
+		if tm.IsCharacterColumn(c) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// readDefaultCharset reads the DEFAULT_CHARSET field: the default
+// collation, and then the pairs of the index among the character
+// columns and the collation of the columns not using the default one.
+func (tm *TableMap) readDefaultCharset(field []byte) error {
+	columns := tm.characterColumns()
+	defaultCollation, pos, ok := readLenEncInt(field, 0)
+	if !ok {
+		return fmt.Errorf("table map default charset is too small, data: %v", field)
+	}
+	tm.Collations = make([]uint64, len(tm.Types))
+	for _, c := range columns {
+		tm.Collations[c] = defaultCollation
+	}
+	for pos < len(field) {
+		index, next, ok := readLenEncInt(field, pos)
+		if !ok {
+			return fmt.Errorf("table map default charset is too small to read index at %v", pos)
+		}
+		collation, next, ok := readLenEncInt(field, next)
+		if !ok {
+			return fmt.Errorf("table map default charset is too small to read collation at %v", pos)
+		}
+		if index >= uint64(len(columns)) {
+			return fmt.Errorf("table map default charset has a bad column index %v", index)
+		}
+		tm.Collations[columns[index]] = collation
+		pos = next
+	}
+	return nil
+}
+
+// readColumnCharset reads the COLUMN_CHARSET field: the collation of
+// every character column.
+func (tm *TableMap) readColumnCharset(field []byte) error {
+	columns := tm.characterColumns()
+	tm.Collations = make([]uint64, len(tm.Types))
+	pos := 0
+	for _, c := range columns {
+		collation, next, ok := readLenEncInt(field, pos)
+		if !ok {
+			return fmt.Errorf("table map column charset is too small to read collation at %v", pos)
+		}
+		tm.Collations[c] = collation
+		pos = next
+	}
+	return nil
+}
+
 // metadataLength returns how many bytes are used for metadata, based on a type.
 func metadataLength(typ byte) int {
 	switch typ {
@@ -884,6 +981,11 @@ func CellBytes(data []byte, pos int, typ byte, metadata uint16, isUnSignedInt bo
 // -- for each row
 // <var>      null bitmap for identify for present rows
 // <var>      values for each identify field
+// -- if PARTIAL_UPDATE_ROWS_EVENT
+// <var>      value_options (var-len encoded)
+// <var>      partial bitmap, one bit per JSON column, if value_options
+//            has PARTIAL_JSON_UPDATES
+// -- endif
 // <var>      null bitmap for data for present rows
 // <var>      values for each data field
 // --
@@ -891,9 +993,9 @@ func (ev binlogEvent) Rows(f BinlogFormat, tm *TableMap) (Rows, error) {
 	typ := ev.Type()
 	data := ev.Bytes()[f.HeaderLength:]
 	hasIdentify := typ == eUpdateRowsEventV1 || typ == eUpdateRowsEventV2 ||
-		typ == eDeleteRowsEventV1 || typ == eDeleteRowsEventV2
+		typ == eDeleteRowsEventV1 || typ == eDeleteRowsEventV2 || typ == ePartialUpdateRowsEvent
 	hasData := typ == eWriteRowsEventV1 || typ == eWriteRowsEventV2 ||
-		typ == eUpdateRowsEventV1 || typ == eUpdateRowsEventV2
+		typ == eUpdateRowsEventV1 || typ == eUpdateRowsEventV2 || typ == ePartialUpdateRowsEvent
 
 	result := Rows{}
 	pos := 6
@@ -904,7 +1006,8 @@ func (ev binlogEvent) Rows(f BinlogFormat, tm *TableMap) (Rows, error) {
 	pos += 2
 
 	// version=2 have extra data here.
-	if typ == eWriteRowsEventV2 || typ == eUpdateRowsEventV2 || typ == eDeleteRowsEventV2 {
+	if typ == eWriteRowsEventV2 || typ == eUpdateRowsEventV2 || typ == eDeleteRowsEventV2 ||
+		typ == ePartialUpdateRowsEvent {
 		// This extraDataLength contains the 2 bytes length.
 		extraDataLength := binary.LittleEndian.Uint16(data[pos : pos+2])
 		pos += int(extraDataLength)
@@ -971,6 +1074,20 @@ func (ev binlogEvent) Rows(f BinlogFormat, tm *TableMap) (Rows, error) {
 		}
 
 		if hasData {
+			if typ == ePartialUpdateRowsEvent {
+				// The after image starts with value_options, and the
+				// partial bitmap if some JSON columns are partial updates.
+				row.ValueOptions, nPos, ok = readLenEncInt(data, pos)
+				if !ok {
+					return result, errors.New("data is too small to read value_options, data:" +
+						hex.EncodeToString(data) + "pos:" + strconv.Itoa(pos))
+				}
+				pos = nPos
+				if row.ValueOptions&rowValueOptionPartialJSON != 0 {
+					row.PartialJSONColumns, pos = newBitmap(data, pos, jsonColumnCount(tm.Types))
+				}
+			}
+
 			// Bitmap of columns that are null (amongst the ones that are present).
 			row.NullColumns, pos = newBitmap(data, pos, numDataColumns)
 
@@ -1006,6 +1123,52 @@ func (ev binlogEvent) Rows(f BinlogFormat, tm *TableMap) (Rows, error) {
 	return result, nil
 }
 
+// jsonColumnCount returns how many JSON columns there are in types.
+func jsonColumnCount(types []byte) int {
+	count := 0
+	for _, typ := range types {
+		if typ == TypeJSON {
+			count++
+		}
+	}
+	return count
+}
+
+// IdentifyCell returns the raw value of the c-th column in the identify
+// image of the row-th row, as it can be passed to CellBytes at position 0.
+// It returns false if the column is not present in the identify image or
+// is NULL.
+func (rs *Rows) IdentifyCell(tm *TableMap, row, c int) ([]byte, bool, error) {
+	if c >= rs.IdentifyColumns.Count() || !rs.IdentifyColumns.Bit(c) {
+		return nil, false, nil
+	}
+	r := rs.Rows[row]
+	pos := 0
+	valueIndex := 0
+	for i := 0; i <= c; i++ {
+		if !rs.IdentifyColumns.Bit(i) {
+			continue
+		}
+		if r.NullIdentifyColumns.Bit(valueIndex) {
+			if i == c {
+				return nil, false, nil
+			}
+			valueIndex++
+			continue
+		}
+		l, err := cellLength(r.Identify, pos, tm.Types[i], tm.Metadata[i])
+		if err != nil {
+			return nil, false, err
+		}
+		if i == c {
+			return r.Identify[pos : pos+l], true, nil
+		}
+		pos += l
+		valueIndex++
+	}
+	return nil, false, nil
+}
+
 func readLenEncInt(data []byte, pos int) (uint64, int, bool) {
 	if pos >= len(data) {
 		return 0, 0, false
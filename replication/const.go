@@ -178,6 +178,10 @@ const (
 	eViewChangeEvent         = 37
 	eXAPrepareLogEvent       = 38
 
+	// MySQL 8.0 events
+	ePartialUpdateRowsEvent  = 39
+	eTransactionPayloadEvent = 40
+
 	// MariaDB specific values. They start at 160.
 	eMariaAnnotateRowsEvent     = 160
 	eMariaBinlogCheckpointEvent = 161
@@ -186,6 +190,23 @@ const (
 	eMariaStartEncryptionEvent  = 164
 )
 
+// rowValueOptionPartialJSON is the PARTIAL_JSON_UPDATES bit of the
+// value_options written in front of the after image of every row in a
+// PARTIAL_UPDATE_ROWS_EVENT.
+const rowValueOptionPartialJSON = 1
+
+// These constants are the types of the optional metadata fields at the
+// end of a TABLE_MAP_EVENT, written when binlog_row_metadata is set
+// (MySQL 8.0.1+). Only the charset fields are parsed.
+const (
+	tableMapDefaultCharset = 2
+	tableMapColumnCharset  = 3
+)
+
+// logicalTimestampTypeCode is the LOGICAL_TIMESTAMP_TYPECODE written
+// in front of last_committed and sequence_number in a GTID event.
+const logicalTimestampTypeCode = 2
+
 // These constants describe the type of status variables in q Query packet.
 const (
 	// QFlags2Code is Q_FLAGS2_CODE
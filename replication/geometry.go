@@ -0,0 +1,492 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// GeometryType is the type of a geometry in WKB.
+type GeometryType uint32
+
+// These constants are the 2D geometry types of WKB, the only ones
+// MySQL stores.
+const (
+	GeometryTypePoint              GeometryType = 1
+	GeometryTypeLineString         GeometryType = 2
+	GeometryTypePolygon            GeometryType = 3
+	GeometryTypeMultiPoint         GeometryType = 4
+	GeometryTypeMultiLineString    GeometryType = 5
+	GeometryTypeMultiPolygon       GeometryType = 6
+	GeometryTypeGeometryCollection GeometryType = 7
+)
+
+// wkbHeaderLength is the length of the byte order and the type in
+// front of every WKB geometry.
+const wkbHeaderLength = 5
+
+// geometryTypeNames are the WKT and GeoJSON names of the types.
+var geometryTypeNames = map[GeometryType][2]string{
+	GeometryTypePoint:              {"POINT", "Point"},
+	GeometryTypeLineString:         {"LINESTRING", "LineString"},
+	GeometryTypePolygon:            {"POLYGON", "Polygon"},
+	GeometryTypeMultiPoint:         {"MULTIPOINT", "MultiPoint"},
+	GeometryTypeMultiLineString:    {"MULTILINESTRING", "MultiLineString"},
+	GeometryTypeMultiPolygon:       {"MULTIPOLYGON", "MultiPolygon"},
+	GeometryTypeGeometryCollection: {"GEOMETRYCOLLECTION", "GeometryCollection"},
+}
+
+// String returns the WKT name of the type.
+func (t GeometryType) String() string {
+	if name, ok := geometryTypeNames[t]; ok {
+		return name[0]
+	}
+	return "UNKNOWN"
+}
+
+// Shape is one of Point, LineString, Polygon, MultiPoint,
+// MultiLineString, MultiPolygon and GeometryCollection.
+type Shape interface {
+	// Type returns the type of the shape.
+	Type() GeometryType
+
+	// appendWKT appends the text of the shape without its type name.
+	appendWKT(buf *bytes.Buffer)
+
+	// appendGeoJSON appends the GeoJSON coordinates of the shape,
+	// or its geometries for a GeometryCollection.
+	appendGeoJSON(buf *bytes.Buffer)
+}
+
+// Point is a single coordinate.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// LineString is a list of points.
+type LineString []Point
+
+// Polygon is a list of rings, the first one is the exterior ring.
+type Polygon []LineString
+
+// MultiPoint is a list of points.
+type MultiPoint []Point
+
+// MultiLineString is a list of line strings.
+type MultiLineString []LineString
+
+// MultiPolygon is a list of polygons.
+type MultiPolygon []Polygon
+
+// GeometryCollection is a list of any shapes.
+type GeometryCollection []Shape
+
+// Geometry is a decoded value of a GEOMETRY column.
+type Geometry struct {
+	// SRID is the spatial reference system identifier.
+	SRID uint32
+
+	// Shape is the structured geometry.
+	Shape Shape
+
+	// WKB is the well-known binary of Shape.
+	WKB []byte
+}
+
+// DecodeGeometry decodes a GEOMETRY column as returned by CellBytes,
+// which is a 4 bytes little endian SRID and then a WKB geometry.
+func DecodeGeometry(data []byte) (*Geometry, error) {
+	if len(data) < 4+wkbHeaderLength {
+		return nil, fmt.Errorf("geometry is too small, data: %v", data)
+	}
+	shape, pos, err := decodeWKB(data, 4)
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("geometry has %v bytes after the WKB", len(data)-pos)
+	}
+	return &Geometry{
+		SRID:  binary.LittleEndian.Uint32(data[:4]),
+		Shape: shape,
+		WKB:   data[4:],
+	}, nil
+}
+
+// WKT returns the well-known text of the geometry, such as
+// POINT(1 2), the same as ST_AsText.
+func (g *Geometry) WKT() string {
+	buf := &bytes.Buffer{}
+	appendWKT(g.Shape, buf)
+	return buf.String()
+}
+
+// GeoJSON returns the GeoJSON geometry object of the geometry, such as
+// {"type":"Point","coordinates":[1,2]}.
+func (g *Geometry) GeoJSON() []byte {
+	buf := &bytes.Buffer{}
+	appendGeoJSON(g.Shape, buf)
+	return buf.Bytes()
+}
+
+// wkbReader reads the numbers of a WKB geometry in its byte order.
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, fmt.Errorf("wkb is too small to read uint32 at %v", r.pos)
+	}
+	v := r.order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+// count reads the number of elements, each of them has at least size
+// bytes, so a bad count doesn't allocate too much.
+func (r *wkbReader) count(size int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n)*uint64(size) > uint64(len(r.data)-r.pos) {
+		return 0, fmt.Errorf("wkb is too small for %v elements at %v", n, r.pos)
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) point() (Point, error) {
+	if len(r.data)-r.pos < 16 {
+		return Point{}, fmt.Errorf("wkb is too small to read point at %v", r.pos)
+	}
+	p := Point{
+		X: math.Float64frombits(r.order.Uint64(r.data[r.pos:])),
+		Y: math.Float64frombits(r.order.Uint64(r.data[r.pos+8:])),
+	}
+	r.pos += 16
+	return p, nil
+}
+
+func (r *wkbReader) lineString() (LineString, error) {
+	n, err := r.count(16)
+	if err != nil {
+		return nil, err
+	}
+	points := make(LineString, n)
+	for i := range points {
+		if points[i], err = r.point(); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+func (r *wkbReader) polygon() (Polygon, error) {
+	n, err := r.count(4)
+	if err != nil {
+		return nil, err
+	}
+	rings := make(Polygon, n)
+	for i := range rings {
+		if rings[i], err = r.lineString(); err != nil {
+			return nil, err
+		}
+	}
+	return rings, nil
+}
+
+// decodeWKB decodes the WKB geometry at data[pos:], and returns the
+// position after it.
+func decodeWKB(data []byte, pos int) (Shape, int, error) {
+	if len(data)-pos < wkbHeaderLength {
+		return nil, 0, fmt.Errorf("wkb is too small to read header at %v", pos)
+	}
+	r := &wkbReader{
+		data:  data,
+		pos:   pos + 1,
+		order: binary.LittleEndian,
+	}
+	switch data[pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+	default:
+		return nil, 0, fmt.Errorf("wkb has unknown byte order %v at %v", data[pos], pos)
+	}
+	typ, _ := r.uint32()
+
+	var shape Shape
+	var err error
+	switch GeometryType(typ) {
+	case GeometryTypePoint:
+		var p Point
+		p, err = r.point()
+		shape = p
+	case GeometryTypeLineString:
+		shape, err = r.lineString()
+	case GeometryTypePolygon:
+		shape, err = r.polygon()
+	case GeometryTypeMultiPoint, GeometryTypeMultiLineString,
+		GeometryTypeMultiPolygon, GeometryTypeGeometryCollection:
+		shape, err = decodeWKBCollection(GeometryType(typ), r)
+	default:
+		return nil, 0, fmt.Errorf("wkb has unsupported geometry type %v at %v", typ, pos)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return shape, r.pos, nil
+}
+
+// decodeWKBCollection decodes the geometries of a Multi* or a
+// GeometryCollection, each of them has its own WKB header.
+func decodeWKBCollection(typ GeometryType, r *wkbReader) (Shape, error) {
+	n, err := r.count(wkbHeaderLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var collection GeometryCollection
+	var multiPoint MultiPoint
+	var multiLineString MultiLineString
+	var multiPolygon MultiPolygon
+	for i := 0; i < n; i++ {
+		var shape Shape
+		if shape, r.pos, err = decodeWKB(r.data, r.pos); err != nil {
+			return nil, err
+		}
+		var ok bool
+		switch typ {
+		case GeometryTypeMultiPoint:
+			var p Point
+			p, ok = shape.(Point)
+			multiPoint = append(multiPoint, p)
+		case GeometryTypeMultiLineString:
+			var l LineString
+			l, ok = shape.(LineString)
+			multiLineString = append(multiLineString, l)
+		case GeometryTypeMultiPolygon:
+			var p Polygon
+			p, ok = shape.(Polygon)
+			multiPolygon = append(multiPolygon, p)
+		default:
+			ok = true
+			collection = append(collection, shape)
+		}
+		if !ok {
+			return nil, fmt.Errorf("wkb %v has a %v", typ, shape.Type())
+		}
+	}
+
+	switch typ {
+	case GeometryTypeMultiPoint:
+		return multiPoint, nil
+	case GeometryTypeMultiLineString:
+		return multiLineString, nil
+	case GeometryTypeMultiPolygon:
+		return multiPolygon, nil
+	}
+	return collection, nil
+}
+
+// Type implements Shape.Type().
+func (p Point) Type() GeometryType { return GeometryTypePoint }
+
+// Type implements Shape.Type().
+func (l LineString) Type() GeometryType { return GeometryTypeLineString }
+
+// Type implements Shape.Type().
+func (p Polygon) Type() GeometryType { return GeometryTypePolygon }
+
+// Type implements Shape.Type().
+func (m MultiPoint) Type() GeometryType { return GeometryTypeMultiPoint }
+
+// Type implements Shape.Type().
+func (m MultiLineString) Type() GeometryType { return GeometryTypeMultiLineString }
+
+// Type implements Shape.Type().
+func (m MultiPolygon) Type() GeometryType { return GeometryTypeMultiPolygon }
+
+// Type implements Shape.Type().
+func (c GeometryCollection) Type() GeometryType { return GeometryTypeGeometryCollection }
+
+// appendWKT appends the type name and the text of a shape.
+func appendWKT(s Shape, buf *bytes.Buffer) {
+	buf.WriteString(s.Type().String())
+	s.appendWKT(buf)
+}
+
+func appendCoordinate(v float64, buf *bytes.Buffer) {
+	buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (p Point) appendWKT(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	p.appendWKTCoordinates(buf)
+	buf.WriteByte(')')
+}
+
+func (p Point) appendWKTCoordinates(buf *bytes.Buffer) {
+	appendCoordinate(p.X, buf)
+	buf.WriteByte(' ')
+	appendCoordinate(p.Y, buf)
+}
+
+func (l LineString) appendWKT(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, p := range l {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		p.appendWKTCoordinates(buf)
+	}
+	buf.WriteByte(')')
+}
+
+func (p Polygon) appendWKT(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, ring := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		ring.appendWKT(buf)
+	}
+	buf.WriteByte(')')
+}
+
+func (m MultiPoint) appendWKT(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, p := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		p.appendWKT(buf)
+	}
+	buf.WriteByte(')')
+}
+
+func (m MultiLineString) appendWKT(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, l := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		l.appendWKT(buf)
+	}
+	buf.WriteByte(')')
+}
+
+func (m MultiPolygon) appendWKT(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, p := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		p.appendWKT(buf)
+	}
+	buf.WriteByte(')')
+}
+
+func (c GeometryCollection) appendWKT(buf *bytes.Buffer) {
+	if len(c) == 0 {
+		buf.WriteString(" EMPTY")
+		return
+	}
+	buf.WriteByte('(')
+	for i, s := range c {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		appendWKT(s, buf)
+	}
+	buf.WriteByte(')')
+}
+
+// appendGeoJSON appends the GeoJSON geometry object of a shape.
+func appendGeoJSON(s Shape, buf *bytes.Buffer) {
+	buf.WriteString(`{"type":"`)
+	buf.WriteString(geometryTypeNames[s.Type()][1])
+	if s.Type() == GeometryTypeGeometryCollection {
+		buf.WriteString(`","geometries":`)
+	} else {
+		buf.WriteString(`","coordinates":`)
+	}
+	s.appendGeoJSON(buf)
+	buf.WriteByte('}')
+}
+
+// appendGeoJSONCoordinate appends a coordinate of GeoJSON, which has
+// no representation for NaN and Inf, so they are null.
+func appendGeoJSONCoordinate(v float64, buf *bytes.Buffer) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		buf.WriteString("null")
+		return
+	}
+	appendCoordinate(v, buf)
+}
+
+func (p Point) appendGeoJSON(buf *bytes.Buffer) {
+	buf.WriteByte('[')
+	appendGeoJSONCoordinate(p.X, buf)
+	buf.WriteByte(',')
+	appendGeoJSONCoordinate(p.Y, buf)
+	buf.WriteByte(']')
+}
+
+func (l LineString) appendGeoJSON(buf *bytes.Buffer) {
+	buf.WriteByte('[')
+	for i, p := range l {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		p.appendGeoJSON(buf)
+	}
+	buf.WriteByte(']')
+}
+
+func (p Polygon) appendGeoJSON(buf *bytes.Buffer) {
+	buf.WriteByte('[')
+	for i, ring := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		ring.appendGeoJSON(buf)
+	}
+	buf.WriteByte(']')
+}
+
+func (m MultiPoint) appendGeoJSON(buf *bytes.Buffer) {
+	LineString(m).appendGeoJSON(buf)
+}
+
+func (m MultiLineString) appendGeoJSON(buf *bytes.Buffer) {
+	Polygon(m).appendGeoJSON(buf)
+}
+
+func (m MultiPolygon) appendGeoJSON(buf *bytes.Buffer) {
+	buf.WriteByte('[')
+	for i, p := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		p.appendGeoJSON(buf)
+	}
+	buf.WriteByte(']')
+}
+
+func (c GeometryCollection) appendGeoJSON(buf *bytes.Buffer) {
+	buf.WriteByte('[')
+	for i, s := range c {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		appendGeoJSON(s, buf)
+	}
+	buf.WriteByte(']')
+}
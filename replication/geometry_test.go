@@ -0,0 +1,137 @@
+package replication
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// wkb builds a little endian WKB geometry from its type and numbers.
+// A uint32 is a count and a float64 is a coordinate, a []byte is
+// another geometry.
+func wkb(typ GeometryType, values ...interface{}) []byte {
+	data := []byte{1}
+	data = binary.LittleEndian.AppendUint32(data, uint32(typ))
+	for _, v := range values {
+		switch v := v.(type) {
+		case uint32:
+			data = binary.LittleEndian.AppendUint32(data, v)
+		case float64:
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		case []byte:
+			data = append(data, v...)
+		}
+	}
+	return data
+}
+
+func TestDecodeGeometry(t *testing.T) {
+	point := wkb(GeometryTypePoint, 1.0, 2.0)
+	line := wkb(GeometryTypeLineString, uint32(2), 0.0, 0.0, 1.5, -1.0)
+	polygon := wkb(GeometryTypePolygon, uint32(1), uint32(4), 0.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0, 0.0)
+
+	// POINT(1 2) in big endian.
+	bigEndian := []byte{0, 0, 0, 0, 1}
+	bigEndian = binary.BigEndian.AppendUint64(bigEndian, math.Float64bits(1))
+	bigEndian = binary.BigEndian.AppendUint64(bigEndian, math.Float64bits(2))
+
+	testcases := []struct {
+		data    []byte
+		shape   Shape
+		wkt     string
+		geoJSON string
+	}{
+		{
+			data:    point,
+			shape:   Point{X: 1, Y: 2},
+			wkt:     "POINT(1 2)",
+			geoJSON: `{"type":"Point","coordinates":[1,2]}`,
+		},
+		{
+			data:    bigEndian,
+			shape:   Point{X: 1, Y: 2},
+			wkt:     "POINT(1 2)",
+			geoJSON: `{"type":"Point","coordinates":[1,2]}`,
+		},
+		{
+			data:    line,
+			shape:   LineString{{0, 0}, {1.5, -1}},
+			wkt:     "LINESTRING(0 0,1.5 -1)",
+			geoJSON: `{"type":"LineString","coordinates":[[0,0],[1.5,-1]]}`,
+		},
+		{
+			data:    polygon,
+			shape:   Polygon{{{0, 0}, {1, 0}, {0, 1}, {0, 0}}},
+			wkt:     "POLYGON((0 0,1 0,0 1,0 0))",
+			geoJSON: `{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,1],[0,0]]]}`,
+		},
+		{
+			data:    wkb(GeometryTypeMultiPoint, uint32(2), point, point),
+			shape:   MultiPoint{{1, 2}, {1, 2}},
+			wkt:     "MULTIPOINT((1 2),(1 2))",
+			geoJSON: `{"type":"MultiPoint","coordinates":[[1,2],[1,2]]}`,
+		},
+		{
+			data:    wkb(GeometryTypeMultiLineString, uint32(1), line),
+			shape:   MultiLineString{{{0, 0}, {1.5, -1}}},
+			wkt:     "MULTILINESTRING((0 0,1.5 -1))",
+			geoJSON: `{"type":"MultiLineString","coordinates":[[[0,0],[1.5,-1]]]}`,
+		},
+		{
+			data:    wkb(GeometryTypeMultiPolygon, uint32(1), polygon),
+			shape:   MultiPolygon{{{{0, 0}, {1, 0}, {0, 1}, {0, 0}}}},
+			wkt:     "MULTIPOLYGON(((0 0,1 0,0 1,0 0)))",
+			geoJSON: `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[0,1],[0,0]]]]}`,
+		},
+		{
+			data:    wkb(GeometryTypeGeometryCollection, uint32(2), point, line),
+			shape:   GeometryCollection{Point{1, 2}, LineString{{0, 0}, {1.5, -1}}},
+			wkt:     "GEOMETRYCOLLECTION(POINT(1 2),LINESTRING(0 0,1.5 -1))",
+			geoJSON: `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[0,0],[1.5,-1]]}]}`,
+		},
+		{
+			data:    wkb(GeometryTypeGeometryCollection, uint32(0)),
+			wkt:     "GEOMETRYCOLLECTION EMPTY",
+			geoJSON: `{"type":"GeometryCollection","geometries":[]}`,
+		},
+	}
+
+	for _, v := range testcases {
+		data := append([]byte{0xe6, 0x10, 0, 0}, v.data...)
+		g, err := DecodeGeometry(data)
+		if err != nil {
+			t.Fatalf("DecodeGeometry fail. data: %v err: %v", data, err)
+		}
+		if v.shape == nil {
+			v.shape = GeometryCollection(nil)
+		}
+		if g.SRID != 4326 || !reflect.DeepEqual(g.Shape, v.shape) || !reflect.DeepEqual(g.WKB, v.data) {
+			t.Fatalf("want != out data: %v want: %#v out: %#v", data, v.shape, g)
+		}
+		if g.WKT() != v.wkt {
+			t.Fatalf("WKT want != out want: %v out: %v", v.wkt, g.WKT())
+		}
+		if string(g.GeoJSON()) != v.geoJSON {
+			t.Fatalf("GeoJSON want != out want: %v out: %s", v.geoJSON, g.GeoJSON())
+		}
+	}
+}
+
+func TestDecodeGeometry_error(t *testing.T) {
+	point := wkb(GeometryTypePoint, 1.0, 2.0)
+	testcases := [][]byte{
+		{0, 0, 0, 0},
+		append([]byte{0, 0, 0, 0}, point[:10]...),
+		append([]byte{0, 0, 0, 0}, append(point, 0)...),
+		append([]byte{0, 0, 0, 0}, 2, 1, 0, 0, 0),
+		append([]byte{0, 0, 0, 0}, wkb(GeometryType(1001), 1.0, 2.0, 3.0)...),
+		append([]byte{0, 0, 0, 0}, wkb(GeometryTypeLineString, uint32(1000))...),
+		append([]byte{0, 0, 0, 0}, wkb(GeometryTypeMultiPoint, uint32(1), wkb(GeometryTypeLineString, uint32(0)))...),
+	}
+	for _, data := range testcases {
+		if _, err := DecodeGeometry(data); err == nil {
+			t.Fatalf("DecodeGeometry(%v) want error", data)
+		}
+	}
+}
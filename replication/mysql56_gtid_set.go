@@ -121,6 +121,16 @@ func parseMysql56GTIDSet(s string) (GTIDSet, error) {
 	return set, nil
 }
 
+// ParseMysql56GTIDSet parses the string form of a MySQL 5.6 GTID set, such
+// as the value of @@GLOBAL.gtid_executed.
+func ParseMysql56GTIDSet(s string) (Mysql56GTIDSet, error) {
+	set, err := parseMysql56GTIDSet(s)
+	if err != nil {
+		return nil, err
+	}
+	return set.(Mysql56GTIDSet), nil
+}
+
 // Mysql56GTIDSet implements GTIDSet for MySQL 5.6.
 type Mysql56GTIDSet map[SID][]interval
 
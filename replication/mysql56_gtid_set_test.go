@@ -88,6 +88,11 @@ func TestParseMysql56GTIDSet(t *testing.T) {
 			sid1: []interval{{1, 5}, {10, 20}},
 			sid2: []interval{{1, 5}, {50, 50}},
 		},
+		// Multiple SIDs with a newline after the comma, as in @@GLOBAL.gtid_executed
+		"00010203-0405-0607-0809-0a0b0c0d0e0f:1-5:10-20,\n00010203-0405-0607-0809-0a0b0c0d0eff:1-5:50": {
+			sid1: []interval{{1, 5}, {10, 20}},
+			sid2: []interval{{1, 5}, {50, 50}},
+		},
 	}
 
 	for input, want := range table {
@@ -99,6 +104,9 @@ func TestParseMysql56GTIDSet(t *testing.T) {
 		if !got.Equal(want) {
 			t.Errorf("parseMysql56GTIDSet(%#v) = %#v, want %#v", input, got, want)
 		}
+		if set, err := ParseMysql56GTIDSet(input); err != nil || !set.Equal(want) {
+			t.Errorf("ParseMysql56GTIDSet(%#v) = %#v, %v, want %#v", input, set, err, want)
+		}
 	}
 }
 
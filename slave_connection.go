@@ -2,6 +2,7 @@ package gobinlog
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	"github.com/Breeze0806/gobinlog/replication"
@@ -16,6 +17,16 @@ type dumpConn interface {
 	HandleErrorPacket([]byte) error
 }
 
+// gtidDumpConn 支持COM_BINLOG_DUMP_GTID的dumpConn，sidBlock是已经执行过的GTID集合的编码
+type gtidDumpConn interface {
+	NoticeDumpGTID(serverID uint32, flags uint16, sidBlock []byte) error
+}
+
+// binlogThroughGTID COM_BINLOG_DUMP_GTID的flag，表示根据GTID集合确定开始的位置
+const binlogThroughGTID uint16 = 0x04
+
+var errGTIDDumpUnsupported = errors.New("dump connection does not support COM_BINLOG_DUMP_GTID")
+
 // slaveConnection 从github.com/youtube/vitess/go/vt/mysqlctl/slave_connection.go的基础上移植过来
 // slaveConn通过StartDumpFromBinlogPosition和mysql库进行binlog dump，将自己伪装成slave，
 // 先执行SET @master_binlog_checksum=@@global.binlog_checksum，然后发送 binlog dump包，
@@ -75,6 +86,27 @@ func (s *slaveConnection) startDumpFromBinlogPosition(ctx context.Context, serve
 		return nil, newError(err).msgf("noticeDump fail")
 	}
 
+	return s.dumpEvents(ctx), nil
+}
+
+// startDumpFromGTIDSet 发送COM_BINLOG_DUMP_GTID，从set之后的第一个事务开始dump，
+// 主库会先发送一个假的ROTATE_EVENT告知开始的binlog文件
+func (s *slaveConnection) startDumpFromGTIDSet(ctx context.Context, serverID uint32,
+	set replication.Mysql56GTIDSet) (<-chan replication.BinlogEvent, *Error) {
+	gc, ok := s.dc.(gtidDumpConn)
+	if !ok {
+		return nil, newError(errGTIDDumpUnsupported).msgf("startDumpFromGTIDSet fail")
+	}
+	_log.Infof("startDumpFromGTIDSet sending binlog dump gtid command: gtidSet: %v slaveID: %v",
+		set, serverID)
+	if err := gc.NoticeDumpGTID(serverID, binlogThroughGTID, set.SIDBlock()); err != nil {
+		return nil, newError(err).msgf("noticeDumpGTID fail")
+	}
+	return s.dumpEvents(ctx), nil
+}
+
+// dumpEvents 在goroutine中读取binlog event并通过chan传出，读取出错或者ctx被取消时将错误写入errChan
+func (s *slaveConnection) dumpEvents(ctx context.Context) <-chan replication.BinlogEvent {
 	// FIXME(xd.fang) I think we can use a buffered channel for better performance.
 	eventChan := make(chan replication.BinlogEvent)
 
@@ -86,7 +118,7 @@ func (s *slaveConnection) startDumpFromBinlogPosition(ctx context.Context, serve
 		for {
 			ev, err := s.readBinlogEvent()
 			if err != nil {
-				_log.Errorf("startDumpFromBinlogPosition readBinlogEvent fail. reason: %v", err)
+				_log.Errorf("dumpEvents readBinlogEvent fail. reason: %v", err)
 				s.errChan <- err
 				close(s.errChan)
 				return
@@ -95,15 +127,15 @@ func (s *slaveConnection) startDumpFromBinlogPosition(ctx context.Context, serve
 			select {
 			case eventChan <- ev:
 			case <-ctx.Done():
-				_log.Infof("startDumpFromBinlogPosition stop by ctx. reason: %v", ctx.Err())
-				s.errChan <- newError(ctx.Err()).msgf("startDumpFromBinlogPosition cancel")
+				_log.Infof("dumpEvents stop by ctx. reason: %v", ctx.Err())
+				s.errChan <- newError(ctx.Err()).msgf("dumpEvents cancel")
 				close(s.errChan)
 				return
 			}
 		}
 	}()
 
-	return eventChan, nil
+	return eventChan
 }
 
 func (s *slaveConnection) readBinlogEvent() (replication.BinlogEvent, *Error) {
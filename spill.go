@@ -0,0 +1,243 @@
+package gobinlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+//SetTransactionMemoryLimit 设置Stream时一个事务在内存中缓存的语句的最大字节数(估算值)，
+//超过后事务中的语句会溢出到临时文件中，通过Transaction.Iterator或者RangeEvents读取，
+//小于等于0时不限制，默认不限制，StreamIncremental不使用该限制
+func (s *Streamer) SetTransactionMemoryLimit(n int64) {
+	s.memoryLimit = n
+}
+
+//SetSpillDir 设置溢出文件的目录，为空时使用os.TempDir()，
+//溢出文件中是经过UseEventMiddleware注册的中间件之后，Use注册的中间件之前的语句
+func (s *Streamer) SetSpillDir(dir string) {
+	s.spillDir = dir
+}
+
+//memSize 估算语句占用的内存
+func (s *StreamEvent) memSize() int64 {
+	size := int64(64 + len(s.Query.SQL) + len(s.Query.Database) + len(s.Table.DbName) + len(s.Table.TableName))
+	for _, rows := range [][]*RowData{s.RowValues, s.RowIdentifies} {
+		for _, r := range rows {
+			size += 32
+			for _, c := range r.Columns {
+				size += int64(96 + len(c.Filed) + len(c.Charset) + len(c.Data))
+				for _, d := range c.JSONDiffs {
+					size += int64(48 + len(d.Path) + len(d.Value))
+				}
+			}
+		}
+	}
+	return size
+}
+
+//spillFile 事务中溢出到磁盘的语句，每个语句以varint长度前缀加protobuf的格式写入临时文件
+type spillFile struct {
+	refs   int32 //引用计数，为0时删除文件
+	name   string
+	f      *os.File
+	w      *bufio.Writer
+	buf    []byte
+	events int //文件中的语句数
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	f, err := ioutil.TempFile(dir, "gobinlog-spill-*")
+	if err != nil {
+		return nil, newError(err).msgf("create spill file in %v fail.", dir)
+	}
+	return &spillFile{
+		refs: 1,
+		name: f.Name(),
+		f:    f,
+		w:    bufio.NewWriter(f),
+	}, nil
+}
+
+func (f *spillFile) write(events ...*StreamEvent) error {
+	for _, s := range events {
+		f.buf = s.appendProto(f.buf[:0])
+		if _, err := f.w.Write(protowire.AppendVarint(nil, uint64(len(f.buf)))); err != nil {
+			return newError(err).msgf("write spill file %v fail.", f.name)
+		}
+		if _, err := f.w.Write(f.buf); err != nil {
+			return newError(err).msgf("write spill file %v fail.", f.name)
+		}
+		f.events++
+	}
+	return nil
+}
+
+//finish 将缓存的数据写入文件，之后才能读取
+func (f *spillFile) finish() error {
+	if err := f.w.Flush(); err != nil {
+		return newError(err).msgf("flush spill file %v fail.", f.name)
+	}
+	return nil
+}
+
+//retain 增加引用计数，如异步投递的事务在确认之前需要保留溢出文件
+func (f *spillFile) retain() {
+	atomic.AddInt32(&f.refs, 1)
+}
+
+//release 减少引用计数，没有引用时删除文件
+func (f *spillFile) release() {
+	if atomic.AddInt32(&f.refs, -1) == 0 {
+		f.remove()
+	}
+}
+
+//remove 关闭并删除文件
+func (f *spillFile) remove() {
+	if err := f.f.Close(); err != nil {
+		_log.Errorf("close spill file %v fail. err: %v", f.name, err)
+	}
+	if err := os.Remove(f.name); err != nil {
+		_log.Errorf("remove spill file %v fail. err: %v", f.name, err)
+	}
+}
+
+//Spilled 事务中的语句是否溢出到了磁盘，为true时Events为空，需要通过Iterator或者RangeEvents读取，
+//溢出文件在SendTransactionFunc返回后被删除，所以只能在SendTransactionFunc返回前读取，
+//AsyncQueue中的事务在Ack之前，LogicalClockDispatcher中的事务在apply返回之前都可以读取，
+//KeyDispatcher在Send中读取全部语句
+func (t *Transaction) Spilled() bool {
+	return t.spill != nil
+}
+
+//load 返回语句都在Events中的事务，溢出到磁盘的事务会读取全部语句，返回的事务不再引用溢出文件
+func (t *Transaction) load() (*Transaction, error) {
+	if t.spill == nil {
+		return t, nil
+	}
+	var events []*StreamEvent
+	if err := t.RangeEvents(func(s *StreamEvent) error {
+		events = append(events, s)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	r := *t
+	r.Events, r.spill, r.transforms = events, nil, nil
+	return &r, nil
+}
+
+//Iterator 返回依次读取事务中语句的迭代器，Spilled为false时返回Events中的语句
+func (t *Transaction) Iterator() *EventIterator {
+	return &EventIterator{t: t}
+}
+
+//RangeEvents 依次对事务中的每个语句调用f，f返回错误时停止并返回该错误
+func (t *Transaction) RangeEvents(f func(s *StreamEvent) error) error {
+	it := t.Iterator()
+	defer it.Close()
+	for it.Next() {
+		if err := f(it.Event()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+//EventIterator 事务中语句的迭代器，溢出到磁盘的语句在读取时才解码，同一时间只有一个语句在内存中，
+//使用完后需要调用Close
+type EventIterator struct {
+	t       *Transaction
+	i       int //已经读取的语句数
+	f       *os.File
+	r       *bufio.Reader
+	pending []*StreamEvent //语句中间件输出的还没有返回的语句
+	event   *StreamEvent
+	err     error
+}
+
+//Next 读取下一个语句，没有更多的语句或者出错时返回false
+func (it *EventIterator) Next() bool {
+	for len(it.pending) == 0 {
+		if it.err != nil {
+			return false
+		}
+		s, ok := it.read()
+		if !ok {
+			return false
+		}
+		it.pending, it.err = it.transform(s)
+	}
+	it.event, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+//Event 返回Next读取的语句
+func (it *EventIterator) Event() *StreamEvent {
+	return it.event
+}
+
+//Err 返回读取时的错误
+func (it *EventIterator) Err() error {
+	return it.err
+}
+
+//Close 关闭打开的溢出文件
+func (it *EventIterator) Close() error {
+	if it.f == nil {
+		return nil
+	}
+	err := it.f.Close()
+	it.f, it.r = nil, nil
+	return err
+}
+
+func (it *EventIterator) read() (*StreamEvent, bool) {
+	t := it.t
+	if t.spill == nil {
+		if it.i >= len(t.Events) {
+			return nil, false
+		}
+		it.i++
+		return t.Events[it.i-1], true
+	}
+
+	if it.i >= t.spill.events {
+		return nil, false
+	}
+	if it.r == nil {
+		f, err := os.Open(t.spill.name)
+		if err != nil {
+			it.err = newError(err).msgf("open spill file %v fail.", t.spill.name)
+			return nil, false
+		}
+		it.f, it.r = f, bufio.NewReader(f)
+	}
+	size, err := binary.ReadUvarint(it.r)
+	if err == nil {
+		data := make([]byte, size)
+		if _, err = io.ReadFull(it.r, data); err == nil {
+			s := &StreamEvent{}
+			if err = s.UnmarshalProto(data); err == nil {
+				it.i++
+				return s, true
+			}
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	it.err = newError(err).msgf("read spill file %v fail.", t.spill.name)
+	return nil, false
+}
+
+//transform 语句依次经过事务的语句中间件
+func (it *EventIterator) transform(s *StreamEvent) ([]*StreamEvent, error) {
+	return transformEvent(s, it.t.transforms)
+}
@@ -0,0 +1,247 @@
+package gobinlog
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//testSpillDir 创建测试用的溢出文件目录，返回的函数用于删除该目录
+func testSpillDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "gobinlog-test-")
+	if err != nil {
+		t.Fatalf("TempDir fail. err: %v", err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+//testSpillTransaction 将事务中的语句写入dir中的溢出文件，返回的函数用于释放溢出文件
+func testSpillTransaction(t *testing.T, dir string, tran *Transaction) func() {
+	f, err := newSpillFile(dir)
+	if err != nil {
+		t.Fatalf("newSpillFile fail. err: %v", err)
+	}
+	if err = f.write(tran.Events...); err != nil {
+		t.Fatalf("write fail. err: %v", err)
+	}
+	if err = f.finish(); err != nil {
+		t.Fatalf("finish fail. err: %v", err)
+	}
+	tran.Events, tran.spill = nil, f
+	return f.release
+}
+
+func TestSpillFile(t *testing.T) {
+	events := []*StreamEvent{
+		{
+			Type:      StatementAlter,
+			Timestamp: 1407805592,
+			Query: replication.Query{
+				Database: "db",
+				Charset:  &replication.Charset{Client: 33, Conn: 33, Server: 8},
+				SQL:      "alter table t add c int",
+			},
+		},
+		{
+			Type:      StatementUpdate,
+			Table:     NewMysqlTableName("db", "t"),
+			Timestamp: 1407805593,
+			RowIdentifies: []*RowData{{Columns: []*ColumnData{
+				{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("-12")},
+				{Filed: "name", Type: columnTypeVarchar, Charset: charsetUTF8MB4, Data: []byte("abc")},
+				{Filed: "doc", Type: columnTypeJSON, Data: nil},
+			}}},
+			RowValues: []*RowData{{Columns: []*ColumnData{
+				{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("-12")},
+				{Filed: "name", Type: columnTypeVarchar, Charset: charsetUTF8MB4, Data: []byte{}},
+				{Filed: "doc", Type: columnTypeJSON, JSONDiffs: []replication.JSONDiff{
+					{Operation: replication.JSONDiffReplace, Path: "$.a", Value: []byte("1")},
+				}},
+			}}},
+		},
+	}
+
+	dir, clean := testSpillDir(t)
+	defer clean()
+	f, err := newSpillFile(dir)
+	if err != nil {
+		t.Fatalf("newSpillFile fail. err: %v", err)
+	}
+	defer f.release()
+	if err = f.write(events...); err != nil {
+		t.Fatalf("write fail. err: %v", err)
+	}
+	if err = f.finish(); err != nil {
+		t.Fatalf("finish fail. err: %v", err)
+	}
+
+	tran := &Transaction{spill: f}
+	if !tran.Spilled() {
+		t.Fatalf("Spilled want: true")
+	}
+	for i := 0; i < 2; i++ {
+		var out []*StreamEvent
+		if err = tran.RangeEvents(func(s *StreamEvent) error {
+			out = append(out, s)
+			return nil
+		}); err != nil {
+			t.Fatalf("%v RangeEvents fail. err: %v", i, err)
+		}
+		if !reflect.DeepEqual(out, events) {
+			t.Fatalf("%v want != out\nwant: %+v\nout:  %+v", i, events, out)
+		}
+	}
+
+	f.events++
+	if err = tran.RangeEvents(func(s *StreamEvent) error { return nil }); err == nil {
+		t.Fatalf("truncated spill file want error")
+	}
+}
+
+func TestStreamer_parseEventsSpill(t *testing.T) {
+	testCases := []struct {
+		memoryLimit int64
+		middlewares []TransactionMiddleware
+		spilled     bool
+		want        []string
+	}{
+		{
+			memoryLimit: 0,
+			want:        []string{"insert", "update", "delete"},
+		},
+		{
+			memoryLimit: 1 << 20,
+			want:        []string{"insert", "update", "delete"},
+		},
+		{
+			memoryLimit: 1,
+			spilled:     true,
+			want:        []string{"insert", "update", "delete"},
+		},
+		{
+			memoryLimit: 300,
+			spilled:     true,
+			want:        []string{"insert", "update", "delete"},
+		},
+		{
+			memoryLimit: 1,
+			middlewares: []TransactionMiddleware{FilterStatements(StatementDelete), DropEmptyTransactions()},
+			spilled:     true,
+			want:        []string{"delete"},
+		},
+	}
+
+	for i, v := range testCases {
+		s, err := NewStreamer(testDSN, testServerID, newMockMapper())
+		if err != nil {
+			t.Fatalf("%v NewStreamer err: %v", i, err)
+		}
+		s.SetBinlogPosition(testBinlogPosParseEvents)
+		s.SetTransactionMemoryLimit(v.memoryLimit)
+		dir, clean := testSpillDir(t)
+		defer clean()
+		s.SetSpillDir(dir)
+
+		var out []string
+		var spilled bool
+		var name string
+		s.sendTransaction = ChainMiddlewares(func(tran *Transaction) error {
+			spilled = tran.Spilled()
+			if spilled {
+				name = tran.spill.name
+				if tran.Events != nil {
+					t.Fatalf("%v spilled transaction has events: %v", i, tran.Events)
+				}
+			}
+			return tran.RangeEvents(func(e *StreamEvent) error {
+				out = append(out, e.Type.String())
+				return nil
+			})
+		}, v.middlewares...)
+
+		events := make(chan replication.BinlogEvent)
+		go func(input []replication.BinlogEvent) {
+			for i := range input {
+				events <- input[i]
+			}
+			close(events)
+		}(getInputData())
+
+		if _, pErr := s.parseEvents(context.Background(), events); pErr != nil {
+			t.Fatalf("%v parseEvents err != %v, err: %v", i, nil, pErr)
+		}
+		if spilled != v.spilled || !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("%v want != out\nwant: %v %q\nout:  %v %q", i, v.spilled, v.want, spilled, out)
+		}
+		if name != "" {
+			if _, err = os.Stat(name); !os.IsNotExist(err) {
+				t.Fatalf("%v spill file %v is not removed. err: %v", i, name, err)
+			}
+		}
+		if stats := s.Stats(); stats.Transactions != 1 || len(stats.Rows) != 3 {
+			t.Fatalf("%v stats want: 1 3 out: %v %v", i, stats.Transactions, stats.Rows)
+		}
+	}
+}
+
+func TestStreamer_parseEventsSpillEventMiddleware(t *testing.T) {
+	m, err := NewMasker(MaskRule{Database: "vt_test_keyspace", Table: "vt_a", Column: "message",
+		Action: MaskPartial, MaskChar: '#'})
+	if err != nil {
+		t.Fatalf("NewMasker fail. err: %v", err)
+	}
+	s, err := NewStreamer(testDSN, testServerID, newMockMapper())
+	if err != nil {
+		t.Fatalf("NewStreamer err: %v", err)
+	}
+	s.SetBinlogPosition(testBinlogPosParseEvents)
+	s.SetTransactionMemoryLimit(1)
+	s.UseEventMiddleware(m.EventMiddleware())
+	dir, clean := testSpillDir(t)
+	defer clean()
+	s.SetSpillDir(dir)
+
+	var out []string
+	s.sendTransaction = func(tran *Transaction) error {
+		if !tran.Spilled() {
+			t.Fatalf("transaction want spilled")
+		}
+		//溢出文件中只有脱敏后的数据
+		data, err := ioutil.ReadFile(tran.spill.name)
+		if err != nil {
+			t.Fatalf("ReadFile fail. err: %v", err)
+		}
+		if strings.Contains(string(data), "abc") {
+			t.Fatalf("spill file has the original values: %q", data)
+		}
+		return tran.RangeEvents(func(e *StreamEvent) error {
+			for _, rows := range [][]*RowData{e.RowIdentifies, e.RowValues} {
+				for _, r := range rows {
+					out = append(out, string(r.Columns[1].Data))
+				}
+			}
+			return nil
+		})
+	}
+
+	events := make(chan replication.BinlogEvent)
+	go func(input []replication.BinlogEvent) {
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+	}(getInputData())
+
+	if _, pErr := s.parseEvents(context.Background(), events); pErr != nil {
+		t.Fatalf("parseEvents err != %v, err: %v", nil, pErr)
+	}
+	want := []string{"####", "###", "####", "###"}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, out)
+	}
+}
@@ -0,0 +1,370 @@
+package gobinlog
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//SQLConflictPolicy 行数据冲突时的处理方式，冲突是指插入时主键或者唯一键重复，
+//更新或者删除时目标库中没有对应的行
+type SQLConflictPolicy int
+
+//行数据冲突时的处理方式
+const (
+	SQLConflictFail      SQLConflictPolicy = iota //返回错误并回滚事务
+	SQLConflictSkip                               //忽略冲突的行
+	SQLConflictOverwrite                          //插入时覆盖已有的行，更新时插入更新后的行
+)
+
+var sqlConflictPolicyStrings = map[SQLConflictPolicy]string{
+	SQLConflictFail:      "fail",
+	SQLConflictSkip:      "skip",
+	SQLConflictOverwrite: "overwrite",
+}
+
+//String 打印
+func (p SQLConflictPolicy) String() string {
+	if s, ok := sqlConflictPolicyStrings[p]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+//UnsupportedStatementError SQLApplier无法执行的sql语句，如DDL以及statement格式的DML，
+//返回该错误时事务被回滚，检查点不会越过该语句
+type UnsupportedStatementError struct {
+	Type     StatementType //语句类型
+	Database string        //执行语句时的默认数据库
+	SQL      string        //原始的sql
+}
+
+//Error 错误信息
+func (e *UnsupportedStatementError) Error() string {
+	return fmt.Sprintf("unsupported %v statement, set a statement handler to apply it. database: %v, sql: %v",
+		e.Type, e.Database, e.SQL)
+}
+
+//defaultSQLCheckpointTable 默认的检查点表名
+const defaultSQLCheckpointTable = "gobinlog_checkpoint"
+
+//SQLApplier 将事务在目标库中执行，每个事务对应目标库中的一个事务，
+//检查点在同一个事务中写入检查点表，所以从检查点重新dump时不会重复执行也不会丢失事务，
+//Apply可以直接作为SendTransactionFunc注册到Streamer.Stream中，
+//sql语句(如DDL)需要通过SetStatementHandler处理，否则返回*UnsupportedStatementError，
+//目标库不是mysql时通过SetDialect设置方言，此时DDL会被翻译后执行，
+//更新和删除通过影响的行数判断冲突，mysql中go-sql-driver/mysql需要在dsn中设置clientFoundRows=true，
+//与SQLGenerator相同，有JSON列时Streamer需要设置JSONFormatRFC8259
+type SQLApplier struct {
+	db              *sql.DB
+	name            string
+	checkpointTable string
+	rename          func(name MysqlTableName) MysqlTableName
+	upsert          bool
+	conflict        SQLConflictPolicy
+	statement       func(tx *sql.Tx, s *StreamEvent) error
+	dialect         SQLDialect
+
+	checkpoint *Position
+}
+
+//NewSQLApplier 创建SQLApplier，name是检查点表中该同步任务的名称，多个任务可以共用检查点表
+func NewSQLApplier(db *sql.DB, name string) *SQLApplier {
+	return &SQLApplier{
+		db:              db,
+		name:            name,
+		checkpointTable: defaultSQLCheckpointTable,
+	}
+}
+
+//SetCheckpointTable 设置检查点表名，默认为gobinlog_checkpoint，可以带有数据库名，如db.checkpoint
+func (a *SQLApplier) SetCheckpointTable(table string) {
+	a.checkpointTable = table
+}
+
+//SetRename 设置表名的映射，用于修改目标库中的数据库名以及表名，为nil时不修改
+func (a *SQLApplier) SetRename(rename func(name MysqlTableName) MysqlTableName) {
+	a.rename = rename
+}
+
+//SetUpsert 设置是否使用幂等的upsert模式，插入使用INSERT ... ON DUPLICATE KEY UPDATE，
+//没有对应行的更新插入更新后的行，没有对应行的删除被忽略，此时冲突策略不再生效
+func (a *SQLApplier) SetUpsert(upsert bool) {
+	a.upsert = upsert
+}
+
+//SetConflictPolicy 设置行数据冲突时的处理方式，默认为SQLConflictFail
+func (a *SQLApplier) SetConflictPolicy(p SQLConflictPolicy) {
+	a.conflict = p
+}
+
+//SetStatementHandler 设置sql语句(如DDL)的处理函数，函数在事务tx中执行或者忽略该语句，
+//返回nil时检查点会随事务推进，返回错误时事务回滚，设置后所有sql语句都交给该函数处理，
+//未设置时sqlite以及postgresql中的DDL通过DDLTranslator翻译后执行，其他sql语句使Apply返回*UnsupportedStatementError
+func (a *SQLApplier) SetStatementHandler(handler func(tx *sql.Tx, s *StreamEvent) error) {
+	a.statement = handler
+}
+
+//SetDialect 设置目标库的sql方言，默认为SQLDialectMySQL，行数据变更与SQLGenerator.SetDialect相同，
+//检查点使用对应方言的upsert以及占位符，DDL通过DDLTranslator翻译后在同一个事务中执行，表名使用SetRename的映射，
+//无法翻译的DDL使Apply返回*UnsupportedDDLError，sqlite中没有设置SetRename时表名不带数据库名
+func (a *SQLApplier) SetDialect(d SQLDialect) {
+	a.dialect = d
+}
+
+//CreateCheckpointTable 在目标库中创建检查点表
+func (a *SQLApplier) CreateCheckpointTable() error {
+	g := a.generator()
+	query := "CREATE TABLE IF NOT EXISTS " + a.quotedCheckpointTable() + " (" +
+		g.quoteIdentifier("name") + " VARCHAR(255) NOT NULL PRIMARY KEY," +
+		g.quoteIdentifier("binlog_file") + " VARCHAR(255) NOT NULL," +
+		g.quoteIdentifier("binlog_pos") + " BIGINT NOT NULL," +
+		g.quoteIdentifier("gtid") + " VARCHAR(255) NOT NULL)"
+	if _, err := a.db.Exec(query); err != nil {
+		return newError(err).msgf("create checkpoint table %v fail.", a.checkpointTable)
+	}
+	return nil
+}
+
+//Checkpoint 读取检查点，即最后一个执行成功的事务的下一个位置，没有检查点时返回空的Position
+func (a *SQLApplier) Checkpoint() (Position, error) {
+	var pos Position
+	g := a.generator()
+	query := "SELECT " + g.quoteIdentifier("binlog_file") + "," + g.quoteIdentifier("binlog_pos") +
+		" FROM " + a.quotedCheckpointTable() + " WHERE " + g.quoteIdentifier("name") + "=" + a.placeholder(1)
+	err := a.db.QueryRow(query, a.name).Scan(&pos.Filename, &pos.Offset)
+	if err == sql.ErrNoRows {
+		return Position{}, nil
+	}
+	if err != nil {
+		return Position{}, newError(err).msgf("query checkpoint %v fail.", a.name)
+	}
+	return pos, nil
+}
+
+//Apply 在目标库的一个事务中执行事务中的所有行数据变更并写入检查点，
+//在检查点之前的事务会被忽略，失败时回滚
+func (a *SQLApplier) Apply(t *Transaction) error {
+	if a.checkpoint == nil {
+		pos, err := a.Checkpoint()
+		if err != nil {
+			return err
+		}
+		a.checkpoint = &pos
+	}
+	if !a.checkpoint.IsZero() && t.NextPosition.Compare(*a.checkpoint) <= 0 {
+		_log.Debugf("SQLApplier skip transaction %+v before checkpoint %+v", t.NextPosition, *a.checkpoint)
+		return nil
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return newError(err).msgf("begin fail.")
+	}
+	if err = a.applyTransaction(tx, t); err != nil {
+		tx.Rollback()
+		return newError(err).msgf("apply transaction %+v fail.", t.NowPosition)
+	}
+	if err = tx.Commit(); err != nil {
+		return newError(err).msgf("commit transaction %+v fail.", t.NowPosition)
+	}
+	pos := t.NextPosition
+	a.checkpoint = &pos
+	return nil
+}
+
+func (a *SQLApplier) applyTransaction(tx *sql.Tx, t *Transaction) error {
+	err := t.RangeEvents(func(s *StreamEvent) error {
+		return a.applyStreamEvent(tx, s)
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(a.saveCheckpointSQL(), a.name, t.NextPosition.Filename, t.NextPosition.Offset, t.GTID); err != nil {
+		return newError(err).msgf("save checkpoint fail.")
+	}
+	return nil
+}
+
+func (a *SQLApplier) applyStreamEvent(tx *sql.Tx, s *StreamEvent) error {
+	if s.Query.SQL != "" {
+		switch {
+		case a.statement != nil:
+			return a.statement(tx, s)
+		case a.dialect != SQLDialectMySQL && s.Type.IsDDL():
+			return a.applyDDL(tx, s)
+		}
+		return &UnsupportedStatementError{Type: s.Type, Database: s.Query.Database, SQL: s.Query.SQL}
+	}
+	table := s.Table
+	if a.rename != nil {
+		table = a.rename(table)
+	} else if a.dialect == SQLDialectSQLite {
+		//与DDLTranslator相同，sqlite中表名不带数据库名
+		table.DbName = ""
+	}
+	var err error
+	switch s.Type {
+	case StatementInsert:
+		err = a.insert(tx, table, s.RowValues)
+	case StatementUpdate:
+		for i, r := range s.RowValues {
+			if i >= len(s.RowIdentifies) {
+				err = fmt.Errorf("update row %v has no before image", i)
+				break
+			}
+			if err = a.update(tx, table, s.RowIdentifies[i], r); err != nil {
+				break
+			}
+		}
+	case StatementDelete:
+		for _, r := range s.RowIdentifies {
+			if err = a.delete(tx, table, r); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return newError(err).msgf("table %v apply fail.", table.String())
+	}
+	return nil
+}
+
+//applyDDL 将DDL翻译为目标库的方言后在事务中执行
+func (a *SQLApplier) applyDDL(tx *sql.Tx, s *StreamEvent) error {
+	t := NewDDLTranslator(a.dialect)
+	t.SetRename(a.rename)
+	queries, err := t.Translate(s)
+	if err != nil {
+		return err
+	}
+	for _, query := range queries {
+		if _, err = tx.Exec(query); err != nil {
+			return newError(err).msgf("exec ddl %v fail.", query)
+		}
+	}
+	return nil
+}
+
+func (a *SQLApplier) insert(tx *sql.Tx, table MysqlTableName, rows []*RowData) error {
+	g := a.generator()
+	switch {
+	case a.upsert:
+		g.SetInsertMode(SQLInsertOnDuplicateKeyUpdate)
+	case a.conflict == SQLConflictSkip:
+		g.SetInsertMode(SQLInsertIgnore)
+	case a.conflict == SQLConflictOverwrite:
+		g.SetInsertMode(SQLReplace)
+	}
+	query, err := g.insert(table, rows)
+	if err != nil || query == "" {
+		return err
+	}
+	_, err = tx.Exec(query)
+	return err
+}
+
+func (a *SQLApplier) update(tx *sql.Tx, table MysqlTableName, before, after *RowData) error {
+	query, err := a.generator().update(table, before, after)
+	if err != nil {
+		return err
+	}
+	affected, err := execAffected(tx, query)
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	policy := a.conflict
+	if a.upsert {
+		policy = SQLConflictOverwrite
+	}
+	switch policy {
+	case SQLConflictSkip:
+		_log.Infof("SQLApplier skip update without matched row: %v", query)
+		return nil
+	case SQLConflictOverwrite:
+		g := a.generator()
+		g.SetInsertMode(SQLReplace)
+		if a.upsert {
+			g.SetInsertMode(SQLInsertOnDuplicateKeyUpdate)
+		}
+		query, err = g.insert(table, []*RowData{mergeRow(after, before)})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(query)
+		return err
+	}
+	return fmt.Errorf("update conflict, no row matched: %v", query)
+}
+
+func (a *SQLApplier) delete(tx *sql.Tx, table MysqlTableName, before *RowData) error {
+	query, err := a.generator().delete(table, before)
+	if err != nil {
+		return err
+	}
+	affected, err := execAffected(tx, query)
+	if err != nil || affected > 0 {
+		return err
+	}
+	if a.upsert || a.conflict != SQLConflictFail {
+		_log.Infof("SQLApplier skip delete without matched row: %v", query)
+		return nil
+	}
+	return fmt.Errorf("delete conflict, no row matched: %v", query)
+}
+
+//generator 目标库方言的SQLGenerator
+func (a *SQLApplier) generator() *SQLGenerator {
+	g := NewSQLGenerator()
+	g.SetDialect(a.dialect)
+	return g
+}
+
+//quotedCheckpointTable 括起来的检查点表名
+func (a *SQLApplier) quotedCheckpointTable() string {
+	g := a.generator()
+	if i := strings.IndexByte(a.checkpointTable, '.'); i >= 0 {
+		return g.quoteTable(NewMysqlTableName(a.checkpointTable[:i], a.checkpointTable[i+1:]))
+	}
+	return g.quoteIdentifier(a.checkpointTable)
+}
+
+//placeholder 第n个参数的占位符，postgresql中为$n
+func (a *SQLApplier) placeholder(n int) string {
+	if a.dialect == SQLDialectPostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+//saveCheckpointSQL 写入检查点的sql，参数依次为名称，binlog文件名，binlog位置以及GTID
+func (a *SQLApplier) saveCheckpointSQL() string {
+	g := a.generator()
+	columns := []string{g.quoteIdentifier("name"), g.quoteIdentifier("binlog_file"),
+		g.quoteIdentifier("binlog_pos"), g.quoteIdentifier("gtid")}
+	var placeholders []string
+	for i := range columns {
+		placeholders = append(placeholders, a.placeholder(i+1))
+	}
+	values := " (" + strings.Join(columns, ",") + ") VALUES (" + strings.Join(placeholders, ",") + ")"
+	if a.dialect != SQLDialectPostgreSQL {
+		return "REPLACE INTO " + a.quotedCheckpointTable() + values
+	}
+	var sets []string
+	for _, c := range columns[1:] {
+		sets = append(sets, c+"=EXCLUDED."+c)
+	}
+	return "INSERT INTO " + a.quotedCheckpointTable() + values +
+		" ON CONFLICT (" + columns[0] + ") DO UPDATE SET " + strings.Join(sets, ",")
+}
+
+func execAffected(tx *sql.Tx, query string) (int64, error) {
+	result, err := tx.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
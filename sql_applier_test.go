@@ -0,0 +1,314 @@
+package gobinlog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//testSQLDriver 记录执行的sql的database/sql驱动，影响的行数由affected决定
+type testSQLDriver struct {
+	mu         sync.Mutex
+	affected   func(query string) int64
+	pending    []string
+	committed  []string
+	checkpoint []driver.Value
+}
+
+var (
+	testSQLDriverOnce sync.Once
+	testSQLDrivers    = make(map[string]*testSQLDriver)
+)
+
+func newTestSQLDB(t *testing.T, affected func(query string) int64) (*sql.DB, *testSQLDriver) {
+	testSQLDriverOnce.Do(func() {
+		sql.Register("gobinlog_test", testSQLConnector{})
+	})
+	d := &testSQLDriver{affected: affected}
+	testSQLDrivers[t.Name()] = d
+	db, err := sql.Open("gobinlog_test", t.Name())
+	if err != nil {
+		t.Fatalf("sql.Open fail. err: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	return db, d
+}
+
+type testSQLConnector struct{}
+
+func (testSQLConnector) Open(name string) (driver.Conn, error) {
+	return &testSQLConn{d: testSQLDrivers[name]}, nil
+}
+
+type testSQLConn struct {
+	d *testSQLDriver
+}
+
+func (c *testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare is not supported")
+}
+
+func (c *testSQLConn) Close() error {
+	return nil
+}
+
+func (c *testSQLConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *testSQLConn) Commit() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.committed = append(c.d.committed, c.d.pending...)
+	c.d.pending = nil
+	return nil
+}
+
+func (c *testSQLConn) Rollback() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.pending = nil
+	return nil
+}
+
+func (c *testSQLConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if len(args) > 0 {
+		var values []driver.Value
+		for _, a := range args {
+			values = append(values, a.Value)
+		}
+		c.d.checkpoint = values
+		query += fmt.Sprint(values)
+	}
+	c.d.pending = append(c.d.pending, query)
+	affected := int64(1)
+	if c.d.affected != nil {
+		affected = c.d.affected(query)
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (c *testSQLConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	rows := &testSQLRows{}
+	if c.d.checkpoint != nil {
+		rows.values = [][]driver.Value{{c.d.checkpoint[1], c.d.checkpoint[2]}}
+	}
+	return rows, nil
+}
+
+type testSQLRows struct {
+	values [][]driver.Value
+}
+
+func (r *testSQLRows) Columns() []string {
+	return []string{"binlog_file", "binlog_pos"}
+}
+
+func (r *testSQLRows) Close() error {
+	return nil
+}
+
+func (r *testSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func testSQLApplierTransaction() *Transaction {
+	table := MysqlTableName{DbName: "db", TableName: "t"}
+	row := func(id, name string) *RowData {
+		return &RowData{Columns: []*ColumnData{
+			{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte(id)},
+			{Filed: "name", Type: columnTypeVarchar, Data: []byte(name)},
+		}}
+	}
+	return &Transaction{
+		NowPosition:  Position{Filename: "binlog.000001", Offset: 4},
+		NextPosition: Position{Filename: "binlog.000001", Offset: 100},
+		GTID:         "uuid:1",
+		Events: []*StreamEvent{
+			{Type: StatementAlter, Query: replication.Query{SQL: "alter table t add column c int"}},
+			{Type: StatementInsert, Table: table, RowValues: []*RowData{row("1", "a")}},
+			{Type: StatementUpdate, Table: table,
+				RowIdentifies: []*RowData{row("1", "a")}, RowValues: []*RowData{row("1", "b")}},
+			{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{row("1", "b")}},
+		},
+	}
+}
+
+func TestSQLApplier_Apply(t *testing.T) {
+	db, d := newTestSQLDB(t, nil)
+	defer db.Close()
+
+	a := NewSQLApplier(db, "job")
+	a.SetCheckpointTable("meta.checkpoint")
+	a.SetRename(func(name MysqlTableName) MysqlTableName {
+		return MysqlTableName{DbName: name.DbName + "_copy", TableName: name.TableName}
+	})
+	tran := testSQLApplierTransaction()
+	err := a.Apply(tran)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Apply without statement handler want error out: %v", err)
+	}
+	if _, ok = e.Original().(*UnsupportedStatementError); !ok || len(d.committed) != 0 {
+		t.Fatalf("want *UnsupportedStatementError and rollback out: %q err: %v", d.committed, err)
+	}
+	if pos, err := a.Checkpoint(); err != nil || !pos.IsZero() {
+		t.Fatalf("checkpoint should not advance past the statement, out: %+v err: %v", pos, err)
+	}
+
+	a.SetStatementHandler(func(tx *sql.Tx, s *StreamEvent) error {
+		_, err := tx.Exec(s.Query.SQL)
+		return err
+	})
+	if err = a.Apply(tran); err != nil {
+		t.Fatalf("Apply fail. err: %v", err)
+	}
+	want := []string{
+		"alter table t add column c int",
+		"INSERT INTO `db_copy`.`t` (`id`,`name`) VALUES (1,'a')",
+		"UPDATE `db_copy`.`t` SET `id`=1,`name`='b' WHERE `id`=1",
+		"DELETE FROM `db_copy`.`t` WHERE `id`=1",
+		"REPLACE INTO `meta`.`checkpoint` (`name`,`binlog_file`,`binlog_pos`,`gtid`) VALUES (?,?,?,?)" +
+			"[job binlog.000001 100 uuid:1]",
+	}
+	if !reflect.DeepEqual(d.committed, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, d.committed)
+	}
+
+	pos, err := a.Checkpoint()
+	if err != nil || pos != tran.NextPosition {
+		t.Fatalf("Checkpoint want: %+v out: %+v err: %v", tran.NextPosition, pos, err)
+	}
+
+	a = NewSQLApplier(db, "job")
+	a.SetCheckpointTable("meta.checkpoint")
+	if err = a.Apply(tran); err != nil {
+		t.Fatalf("Apply fail. err: %v", err)
+	}
+	if len(d.committed) != len(want) {
+		t.Fatalf("transaction before checkpoint should be skipped, out: %q", d.committed)
+	}
+}
+
+func TestSQLApplier_Conflict(t *testing.T) {
+	testCases := []struct {
+		policy SQLConflictPolicy
+		upsert bool
+		fail   bool
+		want   []string
+	}{
+		{policy: SQLConflictFail, fail: true},
+		{
+			policy: SQLConflictSkip,
+			want: []string{
+				"INSERT IGNORE INTO `db`.`t` (`id`,`name`) VALUES (1,'a')",
+				"UPDATE `db`.`t` SET `id`=1,`name`='b' WHERE `id`=1",
+				"DELETE FROM `db`.`t` WHERE `id`=1",
+			},
+		},
+		{
+			policy: SQLConflictOverwrite,
+			want: []string{
+				"REPLACE INTO `db`.`t` (`id`,`name`) VALUES (1,'a')",
+				"UPDATE `db`.`t` SET `id`=1,`name`='b' WHERE `id`=1",
+				"REPLACE INTO `db`.`t` (`id`,`name`) VALUES (1,'b')",
+				"DELETE FROM `db`.`t` WHERE `id`=1",
+			},
+		},
+		{
+			policy: SQLConflictFail,
+			upsert: true,
+			want: []string{
+				"INSERT INTO `db`.`t` (`id`,`name`) VALUES (1,'a') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+				"UPDATE `db`.`t` SET `id`=1,`name`='b' WHERE `id`=1",
+				"INSERT INTO `db`.`t` (`id`,`name`) VALUES (1,'b') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+				"DELETE FROM `db`.`t` WHERE `id`=1",
+			},
+		},
+	}
+
+	for i, v := range testCases {
+		db, d := newTestSQLDB(t, func(query string) int64 {
+			if strings.HasPrefix(query, "UPDATE") || strings.HasPrefix(query, "DELETE") {
+				return 0
+			}
+			return 1
+		})
+		a := NewSQLApplier(db, "job")
+		a.SetConflictPolicy(v.policy)
+		a.SetUpsert(v.upsert)
+		a.SetStatementHandler(func(tx *sql.Tx, s *StreamEvent) error {
+			return nil
+		})
+		err := a.Apply(testSQLApplierTransaction())
+		db.Close()
+		if v.fail {
+			if err == nil || len(d.committed) != 0 {
+				t.Fatalf("%v want error and rollback out: %q err: %v", i, d.committed, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v Apply fail. err: %v", i, err)
+		}
+		if len(d.committed) != len(v.want)+1 || !reflect.DeepEqual(d.committed[:len(v.want)], v.want) {
+			t.Fatalf("%v want != out\nwant: %q\nout:  %q", i, v.want, d.committed)
+		}
+	}
+}
+
+func TestSQLApplier_Dialect(t *testing.T) {
+	db, d := newTestSQLDB(t, nil)
+	defer db.Close()
+
+	a := NewSQLApplier(db, "job")
+	a.SetDialect(SQLDialectPostgreSQL)
+	tran := testSQLApplierTransaction()
+	tran.Events[0].Query.Database = "db"
+	if err := a.Apply(tran); err != nil {
+		t.Fatalf("Apply fail. err: %v", err)
+	}
+	want := []string{
+		`ALTER TABLE "db"."t" ADD COLUMN "c" INTEGER`,
+		`INSERT INTO "db"."t" ("id","name") VALUES (1,'a')`,
+		`UPDATE "db"."t" SET "id"=1,"name"='b' WHERE "id"=1`,
+		`DELETE FROM "db"."t" WHERE "id"=1`,
+		`INSERT INTO "gobinlog_checkpoint" ("name","binlog_file","binlog_pos","gtid") VALUES ($1,$2,$3,$4) ` +
+			`ON CONFLICT ("name") DO UPDATE SET "binlog_file"=EXCLUDED."binlog_file",` +
+			`"binlog_pos"=EXCLUDED."binlog_pos","gtid"=EXCLUDED."gtid"[job binlog.000001 100 uuid:1]`,
+	}
+	if !reflect.DeepEqual(d.committed, want) {
+		t.Fatalf("want != out\nwant: %q\nout:  %q", want, d.committed)
+	}
+
+	//无法翻译的DDL回滚事务，检查点不会越过该语句
+	d.committed, d.checkpoint = nil, nil
+	a = NewSQLApplier(db, "job")
+	a.SetDialect(SQLDialectSQLite)
+	tran.Events[0].Query.SQL = "alter table t modify c bigint"
+	err := a.Apply(tran)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Apply want error out: %v", err)
+	}
+	if _, ok = e.Original().(*UnsupportedDDLError); !ok || len(d.committed) != 0 {
+		t.Fatalf("want *UnsupportedDDLError and rollback out: %q err: %v", d.committed, err)
+	}
+}
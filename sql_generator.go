@@ -0,0 +1,524 @@
+package gobinlog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+//SQLInsertMode 插入语句的生成方式
+type SQLInsertMode int
+
+//插入语句的生成方式
+const (
+	SQLInsert                     SQLInsertMode = iota //INSERT INTO
+	SQLReplace                                         //REPLACE INTO
+	SQLInsertIgnore                                    //INSERT IGNORE INTO
+	SQLInsertOnDuplicateKeyUpdate                      //INSERT INTO ... ON DUPLICATE KEY UPDATE
+)
+
+var sqlInsertModeStrings = map[SQLInsertMode]string{
+	SQLInsert:                     "insert",
+	SQLReplace:                    "replace",
+	SQLInsertIgnore:               "insertIgnore",
+	SQLInsertOnDuplicateKeyUpdate: "onDuplicateKeyUpdate",
+}
+
+//String 打印
+func (m SQLInsertMode) String() string {
+	if s, ok := sqlInsertModeStrings[m]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+//SQLGenerator 将语句还原为可以执行的sql，行数据生成INSERT，UPDATE以及DELETE语句，
+//UPDATE和DELETE的WHERE条件在有主键时只使用主键列，否则使用所有有值的列并加上LIMIT 1，
+//其他sql语句原样输出，生成的sql不以分号结尾。JSON列需要通过Streamer.SetJSONFormat设置JSONFormatRFC8259，
+//SQL表达式格式的JSON列会返回错误
+type SQLGenerator struct {
+	insertMode SQLInsertMode
+	dialect    SQLDialect
+}
+
+//NewSQLGenerator 创建SQLGenerator，默认生成INSERT INTO
+func NewSQLGenerator() *SQLGenerator {
+	return &SQLGenerator{}
+}
+
+//SetInsertMode 设置插入语句的生成方式
+func (g *SQLGenerator) SetInsertMode(m SQLInsertMode) {
+	g.insertMode = m
+}
+
+//SetDialect 设置生成的sql的方言，默认为SQLDialectMySQL，sqlite以及postgresql中：
+//标识符使用双引号，字符串使用标准sql的转义，非utf8的字符集转换为utf8，bit列为整数，
+//插入方式通过REPLACE INTO，INSERT OR IGNORE以及ON CONFLICT实现，ON CONFLICT需要主键，
+//没有主键时通过rowid(sqlite)以及ctid(postgresql)只修改一行，
+//DDL通过DDLTranslator翻译，sqlite中表名不带数据库名，其他sql语句以及json列的部分更新返回错误
+func (g *SQLGenerator) SetDialect(d SQLDialect) {
+	g.dialect = d
+}
+
+//GenerateTransaction 生成事务中所有语句的sql，不包含BEGIN和COMMIT
+func (g *SQLGenerator) GenerateTransaction(t *Transaction) ([]string, error) {
+	var sqls []string
+	err := t.RangeEvents(func(s *StreamEvent) error {
+		v, err := g.GenerateStreamEvent(s)
+		if err != nil {
+			return err
+		}
+		sqls = append(sqls, v...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sqls, nil
+}
+
+//GenerateStreamEvent 生成语句的sql，一个插入语句生成一条INSERT，更新和删除的每一行生成一条sql，
+//sql语句在有默认数据库时会先输出USE语句
+func (g *SQLGenerator) GenerateStreamEvent(s *StreamEvent) ([]string, error) {
+	if s.Query.SQL != "" {
+		return g.query(s)
+	}
+
+	table := s.Table
+	if g.dialect == SQLDialectSQLite {
+		table.DbName = ""
+	}
+	var sqls []string
+	var err error
+	switch s.Type {
+	case StatementInsert:
+		var sql string
+		sql, err = g.insert(table, s.RowValues)
+		if sql != "" {
+			sqls = append(sqls, sql)
+		}
+	case StatementUpdate:
+		for i, r := range s.RowValues {
+			if i >= len(s.RowIdentifies) {
+				err = fmt.Errorf("update row %v has no before image", i)
+				break
+			}
+			var sql string
+			if sql, err = g.update(table, s.RowIdentifies[i], r); err != nil {
+				break
+			}
+			sqls = append(sqls, sql)
+		}
+	case StatementDelete:
+		for _, r := range s.RowIdentifies {
+			var sql string
+			if sql, err = g.delete(table, r); err != nil {
+				break
+			}
+			sqls = append(sqls, sql)
+		}
+	}
+	if err != nil {
+		return nil, newError(err).msgf("table %v generate sql fail.", s.Table.String())
+	}
+	return sqls, nil
+}
+
+//query sql语句，mysql中有默认数据库时先输出USE语句，其他方言中只支持可以翻译的DDL
+func (g *SQLGenerator) query(s *StreamEvent) ([]string, error) {
+	if g.dialect == SQLDialectMySQL {
+		if s.Query.Database == "" {
+			return []string{s.Query.SQL}, nil
+		}
+		return []string{"USE " + quoteSQLIdentifier(s.Query.Database), s.Query.SQL}, nil
+	}
+	if !s.Type.IsDDL() {
+		return nil, fmt.Errorf("%v statement can not be generated for %v. sql: %v", s.Type, g.dialect, s.Query.SQL)
+	}
+	return NewDDLTranslator(g.dialect).Translate(s)
+}
+
+func (g *SQLGenerator) insert(table MysqlTableName, rows []*RowData) (string, error) {
+	if len(rows) == 0 {
+		return "", nil
+	}
+	var columns []*ColumnData
+	for _, c := range rows[0].Columns {
+		if !c.IsEmpty {
+			columns = append(columns, c)
+		}
+	}
+
+	var b strings.Builder
+	switch {
+	case g.insertMode == SQLReplace && g.dialect != SQLDialectPostgreSQL:
+		b.WriteString("REPLACE INTO ")
+	case g.insertMode == SQLInsertIgnore && g.dialect == SQLDialectMySQL:
+		b.WriteString("INSERT IGNORE INTO ")
+	case g.insertMode == SQLInsertIgnore && g.dialect == SQLDialectSQLite:
+		b.WriteString("INSERT OR IGNORE INTO ")
+	default:
+		b.WriteString("INSERT INTO ")
+	}
+	b.WriteString(g.quoteTable(table))
+	b.WriteString(" (")
+	for i, c := range columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(g.quoteIdentifier(c.Filed))
+	}
+	b.WriteString(") VALUES ")
+
+	for i, r := range rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		n := 0
+		for _, c := range r.Columns {
+			if c.IsEmpty {
+				continue
+			}
+			if n > 0 {
+				b.WriteByte(',')
+			}
+			v, err := g.value(c)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			n++
+		}
+		if n != len(columns) {
+			return "", fmt.Errorf("insert row %v has %v columns want %v", i, n, len(columns))
+		}
+		b.WriteByte(')')
+	}
+
+	if g.dialect != SQLDialectMySQL {
+		conflict, err := g.onConflict(columns)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(conflict)
+	} else if g.insertMode == SQLInsertOnDuplicateKeyUpdate {
+		b.WriteString(" ON DUPLICATE KEY UPDATE ")
+		hasKey := false
+		for _, c := range columns {
+			hasKey = hasKey || c.IsPrimaryKey
+		}
+		n := 0
+		for _, c := range columns {
+			if hasKey && c.IsPrimaryKey {
+				continue
+			}
+			if n > 0 {
+				b.WriteByte(',')
+			}
+			name := quoteSQLIdentifier(c.Filed)
+			b.WriteString(name + "=VALUES(" + name + ")")
+			n++
+		}
+	}
+	return b.String(), nil
+}
+
+//onConflict sqlite以及postgresql中的ON CONFLICT子句，更新时以主键作为冲突的条件
+func (g *SQLGenerator) onConflict(columns []*ColumnData) (string, error) {
+	switch {
+	case g.insertMode == SQLInsertIgnore && g.dialect == SQLDialectPostgreSQL:
+		return " ON CONFLICT DO NOTHING", nil
+	case g.insertMode == SQLInsertOnDuplicateKeyUpdate,
+		g.insertMode == SQLReplace && g.dialect == SQLDialectPostgreSQL:
+	default:
+		return "", nil
+	}
+
+	var keys, sets []string
+	for _, c := range columns {
+		name := g.quoteIdentifier(c.Filed)
+		if c.IsPrimaryKey {
+			keys = append(keys, name)
+		} else {
+			sets = append(sets, name+"=EXCLUDED."+name)
+		}
+	}
+	if keys == nil {
+		return "", fmt.Errorf("%v for %v needs primary key columns", g.insertMode, g.dialect)
+	}
+	if sets == nil {
+		return " ON CONFLICT (" + strings.Join(keys, ",") + ") DO NOTHING", nil
+	}
+	return " ON CONFLICT (" + strings.Join(keys, ",") + ") DO UPDATE SET " + strings.Join(sets, ","), nil
+}
+
+func (g *SQLGenerator) update(table MysqlTableName, before, after *RowData) (string, error) {
+	var b strings.Builder
+	b.WriteString("UPDATE ")
+	b.WriteString(g.quoteTable(table))
+	b.WriteString(" SET ")
+	n := 0
+	for _, c := range after.Columns {
+		if c.IsEmpty {
+			continue
+		}
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		v, err := g.value(c)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(g.quoteIdentifier(c.Filed) + "=" + v)
+		n++
+	}
+	if n == 0 {
+		return "", fmt.Errorf("update row has no columns")
+	}
+
+	where, err := g.where(table, before)
+	if err != nil {
+		return "", err
+	}
+	b.WriteString(where)
+	return b.String(), nil
+}
+
+func (g *SQLGenerator) delete(table MysqlTableName, before *RowData) (string, error) {
+	where, err := g.where(table, before)
+	if err != nil {
+		return "", err
+	}
+	return "DELETE FROM " + g.quoteTable(table) + where, nil
+}
+
+//where 通过更新前的行数据生成WHERE条件，没有主键时使用所有有值的列并限制只修改一行，
+//只有部分更新(JSONDiffs)的json列不知道完整的值，不作为条件
+func (g *SQLGenerator) where(table MysqlTableName, r *RowData) (string, error) {
+	hasKey := false
+	for _, c := range r.Columns {
+		hasKey = hasKey || (c.IsPrimaryKey && !c.IsEmpty && !c.isPartialJSON())
+	}
+
+	var conditions []string
+	for _, c := range r.Columns {
+		if c.IsEmpty || c.isPartialJSON() || (hasKey && !c.IsPrimaryKey) {
+			continue
+		}
+		if c.Data == nil {
+			conditions = append(conditions, g.quoteIdentifier(c.Filed)+" IS NULL")
+			continue
+		}
+		v, err := g.value(c)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, g.quoteIdentifier(c.Filed)+"="+v)
+	}
+	if conditions == nil {
+		return "", fmt.Errorf("row has no columns for where")
+	}
+
+	where := " WHERE " + strings.Join(conditions, " AND ")
+	switch {
+	case hasKey:
+		return where, nil
+	case g.dialect == SQLDialectSQLite:
+		return " WHERE rowid IN (SELECT rowid FROM " + g.quoteTable(table) + where + " LIMIT 1)", nil
+	case g.dialect == SQLDialectPostgreSQL:
+		return " WHERE ctid IN (SELECT ctid FROM " + g.quoteTable(table) + where + " LIMIT 1)", nil
+	}
+	return where + " LIMIT 1", nil
+}
+
+//value 列的值对应的sql字面量
+func (g *SQLGenerator) value(c *ColumnData) (string, error) {
+	if g.dialect == SQLDialectMySQL {
+		return sqlValue(c)
+	}
+	return dialectValue(g.dialect, c)
+}
+
+//quoteIdentifier 方言中括起来的标识符
+func (g *SQLGenerator) quoteIdentifier(name string) string {
+	if g.dialect == SQLDialectMySQL {
+		return quoteSQLIdentifier(name)
+	}
+	return quoteDDLIdentifier(name)
+}
+
+//quoteTable 方言中的表名，sqlite以及postgresql中数据库名为空时不带数据库名
+func (g *SQLGenerator) quoteTable(table MysqlTableName) string {
+	if g.dialect == SQLDialectMySQL {
+		return quoteSQLTable(table)
+	}
+	return quoteDDLTable(table)
+}
+
+//isPartialJSON 是否是只有部分更新(JSONDiffs)而没有完整值的json列
+func (c *ColumnData) isPartialJSON() bool {
+	return c.Data == nil && c.JSONDiffs != nil
+}
+
+//sqlValue 列的值对应的sql字面量，json列的部分更新生成JSON_REPLACE等函数
+func sqlValue(c *ColumnData) (string, error) {
+	if c.isPartialJSON() {
+		return sqlJSONDiffs(c)
+	}
+	switch {
+	case c.Data == nil:
+		return "NULL", nil
+	case c.Type.IsBit():
+		return "b'" + strconv.FormatUint(c.bitValue(), 2) + "'", nil
+	case (c.isNumber() || c.Type == columnTypeEnum || c.Type == columnTypeSet) && isJSONNumber(c.Data):
+		return string(c.Data), nil
+	case c.Type == columnTypeJSON:
+		return sqlJSONValue(c.Filed, c.Data)
+	case c.isBinary():
+		if len(c.Data) == 0 {
+			return "''", nil
+		}
+		return "0x" + hex.EncodeToString(c.Data), nil
+	}
+	if _, ok := charsetEncodings[c.Charset]; ok {
+		//非utf8的字符集使用字符集前缀加16进制，与连接的字符集无关
+		return "_" + c.Charset + " 0x" + hex.EncodeToString(c.Data), nil
+	}
+	return quoteSQLString(c.Data), nil
+}
+
+//dialectValue 列的值对应的sqlite以及postgresql的字面量
+func dialectValue(d SQLDialect, c *ColumnData) (string, error) {
+	switch {
+	case c.isPartialJSON():
+		return "", fmt.Errorf("column %v partial json update is not supported for %v", c.Filed, d)
+	case c.Data == nil:
+		return "NULL", nil
+	case c.Type.IsBit():
+		//与ddlTypes相同保存为64位整数
+		return strconv.FormatInt(int64(c.bitValue()), 10), nil
+	case (c.isNumber() || c.Type == columnTypeEnum || c.Type == columnTypeSet) && isJSONNumber(c.Data):
+		return string(c.Data), nil
+	case c.Type == columnTypeJSON:
+		if err := checkJSONText(c.Filed, c.Data); err != nil {
+			return "", err
+		}
+		if d == SQLDialectPostgreSQL {
+			return "CAST(" + quoteDDLString(string(c.Data)) + " AS JSON)", nil
+		}
+		return quoteDDLString(string(c.Data)), nil
+	case c.isBinary():
+		if d == SQLDialectPostgreSQL {
+			return `CAST('\x` + hex.EncodeToString(c.Data) + "' AS BYTEA)", nil
+		}
+		return "X'" + hex.EncodeToString(c.Data) + "'", nil
+	}
+	data, err := c.UTF8Data()
+	if err != nil {
+		return "", err
+	}
+	return quoteDDLString(string(data)), nil
+}
+
+//checkJSONText 只接受RFC 8259的json文本，SQL表达式(JSONFormatSQL)无法安全地还原
+func checkJSONText(field string, data []byte) error {
+	if !json.Valid(data) {
+		return fmt.Errorf("column %v json value is not RFC 8259 text, "+
+			"set JSONFormatRFC8259 by Streamer.SetJSONFormat", field)
+	}
+	return nil
+}
+
+//sqlJSONValue json列的值，转义后转换为json类型，只接受RFC 8259的json文本，
+//SQL表达式(JSONFormatSQL)无法安全地还原，返回错误
+func sqlJSONValue(field string, data []byte) (string, error) {
+	if err := checkJSONText(field, data); err != nil {
+		return "", err
+	}
+	return "CAST(" + quoteSQLString(data) + " AS JSON)", nil
+}
+
+//sqlJSONDiffValue JSONDiff的新值，有binlog中的二进制json时解析后输出，
+//否则(如经过json或protobuf编解码)使用Value
+func sqlJSONDiffValue(field string, d replication.JSONDiff) (string, error) {
+	v, err := d.JSONValue()
+	if err != nil {
+		return sqlJSONValue(field, d.Value)
+	}
+	b, err := replication.MarshalJSONValue(v)
+	if err != nil {
+		return "", fmt.Errorf("column %v MarshalJSONValue fail. err: %v", field, err)
+	}
+	return sqlJSONValue(field, b)
+}
+
+//sqlJSONDiffs 将json列的部分更新转换为对该列调用JSON_REPLACE，JSON_INSERT以及JSON_REMOVE
+func sqlJSONDiffs(c *ColumnData) (string, error) {
+	expr := quoteSQLIdentifier(c.Filed)
+	for _, d := range c.JSONDiffs {
+		path := quoteSQLString([]byte(d.Path))
+		switch d.Operation {
+		case replication.JSONDiffReplace, replication.JSONDiffInsert:
+			value, err := sqlJSONDiffValue(c.Filed, d)
+			if err != nil {
+				return "", err
+			}
+			fn := "JSON_REPLACE("
+			if d.Operation == replication.JSONDiffInsert {
+				fn = "JSON_INSERT("
+				if strings.HasSuffix(d.Path, "]") {
+					fn = "JSON_ARRAY_INSERT("
+				}
+			}
+			expr = fn + expr + "," + path + "," + value + ")"
+		case replication.JSONDiffRemove:
+			expr = "JSON_REMOVE(" + expr + "," + path + ")"
+		default:
+			return "", fmt.Errorf("column %v unknown json diff operation %v", c.Filed, d.Operation)
+		}
+	}
+	return expr, nil
+}
+
+//sqlStringEscapes 字符串中需要转义的字符，与mysql_real_escape_string相同
+var sqlStringEscapes = map[byte]string{
+	0:      `\0`,
+	'\n':   `\n`,
+	'\r':   `\r`,
+	'\\':   `\\`,
+	'\'':   `\'`,
+	'"':    `\"`,
+	'\x1a': `\Z`,
+}
+
+//quoteSQLString 转义后用单引号括起来的字符串
+func quoteSQLString(data []byte) string {
+	var b bytes.Buffer
+	b.Grow(len(data) + 2)
+	b.WriteByte('\'')
+	for _, c := range data {
+		if e, ok := sqlStringEscapes[c]; ok {
+			b.WriteString(e)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+//quoteSQLIdentifier 用反引号括起来的标识符
+func quoteSQLIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+//quoteSQLTable 带数据库名的表名
+func quoteSQLTable(table MysqlTableName) string {
+	return quoteSQLIdentifier(table.DbName) + "." + quoteSQLIdentifier(table.TableName)
+}
@@ -0,0 +1,266 @@
+package gobinlog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Breeze0806/gobinlog/replication"
+)
+
+func TestSQLGenerator_GenerateStreamEvent(t *testing.T) {
+	table := MysqlTableName{DbName: "db", TableName: "t`1"}
+	row := func(id string, name []byte) *RowData {
+		return &RowData{Columns: []*ColumnData{
+			{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte(id)},
+			{Filed: "name", Type: columnTypeVarchar, Data: name},
+		}}
+	}
+	noKey := &RowData{Columns: []*ColumnData{
+		{Filed: "a", Type: columnTypeDouble, Data: []byte("1.5")},
+		{Filed: "b", Type: columnTypeBlob, Charset: "binary", Data: []byte{0x00, 0xff}},
+		{Filed: "c", Type: columnTypeVarchar, Data: nil},
+		{Filed: "d", Type: columnTypeVarchar, IsEmpty: true},
+	}}
+
+	testCases := []struct {
+		mode SQLInsertMode
+		s    *StreamEvent
+		want []string
+	}{
+		{
+			mode: SQLInsert,
+			s: &StreamEvent{Type: StatementInsert, Table: table,
+				RowValues: []*RowData{row("1", []byte("it's\n")), row("2", nil)}},
+			want: []string{"INSERT INTO `db`.`t``1` (`id`,`name`) VALUES (1,'it\\'s\\n'),(2,NULL)"},
+		},
+		{
+			mode: SQLReplace,
+			s:    &StreamEvent{Type: StatementInsert, Table: table, RowValues: []*RowData{row("1", []byte("a"))}},
+			want: []string{"REPLACE INTO `db`.`t``1` (`id`,`name`) VALUES (1,'a')"},
+		},
+		{
+			mode: SQLInsertIgnore,
+			s:    &StreamEvent{Type: StatementInsert, Table: table, RowValues: []*RowData{row("1", []byte("a"))}},
+			want: []string{"INSERT IGNORE INTO `db`.`t``1` (`id`,`name`) VALUES (1,'a')"},
+		},
+		{
+			mode: SQLInsertOnDuplicateKeyUpdate,
+			s:    &StreamEvent{Type: StatementInsert, Table: table, RowValues: []*RowData{row("1", []byte("a"))}},
+			want: []string{"INSERT INTO `db`.`t``1` (`id`,`name`) VALUES (1,'a') " +
+				"ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)"},
+		},
+		{
+			s: &StreamEvent{Type: StatementUpdate, Table: table,
+				RowIdentifies: []*RowData{row("1", []byte("a")), row("2", []byte("b"))},
+				RowValues:     []*RowData{row("1", []byte("c")), row("3", nil)}},
+			want: []string{
+				"UPDATE `db`.`t``1` SET `id`=1,`name`='c' WHERE `id`=1",
+				"UPDATE `db`.`t``1` SET `id`=3,`name`=NULL WHERE `id`=2",
+			},
+		},
+		{
+			s: &StreamEvent{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{noKey}},
+			want: []string{
+				"DELETE FROM `db`.`t``1` WHERE `a`=1.5 AND `b`=0x00ff AND `c` IS NULL LIMIT 1",
+			},
+		},
+		{
+			//部分更新的json列没有完整的值，不作为条件
+			s: &StreamEvent{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{{Columns: []*ColumnData{
+				{Filed: "a", Type: columnTypeDouble, Data: []byte("1.5")},
+				{Filed: "doc", Type: columnTypeJSON, JSONDiffs: []replication.JSONDiff{
+					{Operation: replication.JSONDiffRemove, Path: "$.a"},
+				}},
+			}}}},
+			want: []string{"DELETE FROM `db`.`t``1` WHERE `a`=1.5 LIMIT 1"},
+		},
+		{
+			s: &StreamEvent{Type: StatementAlter,
+				Query: replication.Query{Database: "db", SQL: "alter table t add column c int"}},
+			want: []string{"USE `db`", "alter table t add column c int"},
+		},
+		{
+			s:    &StreamEvent{Type: StatementInsert, Table: table},
+			want: nil,
+		},
+	}
+
+	g := NewSQLGenerator()
+	for i, v := range testCases {
+		g.SetInsertMode(v.mode)
+		out, err := g.GenerateStreamEvent(v.s)
+		if err != nil {
+			t.Fatalf("%v GenerateStreamEvent fail. err: %v", i, err)
+		}
+		if !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("%v want != out\nwant: %q\nout:  %q", i, v.want, out)
+		}
+	}
+
+	_, err := g.GenerateStreamEvent(&StreamEvent{Type: StatementDelete, Table: table,
+		RowIdentifies: []*RowData{{Columns: []*ColumnData{{Filed: "a", IsEmpty: true}}}}})
+	if err == nil {
+		t.Fatalf("row without columns want error")
+	}
+}
+
+func TestSQLGenerator_Dialect(t *testing.T) {
+	table := MysqlTableName{DbName: "db", TableName: "t"}
+	row := &RowData{Columns: []*ColumnData{
+		{Filed: "id", Type: columnTypeLong, IsPrimaryKey: true, Data: []byte("1")},
+		{Filed: "name", Type: columnTypeVarchar, Charset: "latin1", Data: []byte("it's\\\xe9")},
+		{Filed: "b", Type: columnTypeBit, Data: []byte{0x01, 0x02}},
+		{Filed: "bin", Type: columnTypeBlob, Charset: "binary", Data: []byte{0x00, 0xff}},
+		{Filed: "doc", Type: columnTypeJSON, Data: []byte(`{"a":"it's"}`)},
+	}}
+	noKey := &RowData{Columns: []*ColumnData{{Filed: "a", Type: columnTypeEnum, Data: []byte("2")}}}
+	insert := &StreamEvent{Type: StatementInsert, Table: table, RowValues: []*RowData{row}}
+	ddl := &StreamEvent{Type: StatementAlter, Query: replication.Query{Database: "db", SQL: "alter table t add column c int"}}
+
+	testCases := []struct {
+		dialect SQLDialect
+		mode    SQLInsertMode
+		s       *StreamEvent
+		want    []string
+	}{
+		{
+			dialect: SQLDialectSQLite,
+			mode:    SQLInsertIgnore,
+			s:       insert,
+			want: []string{`INSERT OR IGNORE INTO "t" ("id","name","b","bin","doc") ` +
+				`VALUES (1,'it''s\é',258,X'00ff','{"a":"it''s"}')`},
+		},
+		{
+			dialect: SQLDialectSQLite,
+			mode:    SQLReplace,
+			s:       insert,
+			want: []string{`REPLACE INTO "t" ("id","name","b","bin","doc") ` +
+				`VALUES (1,'it''s\é',258,X'00ff','{"a":"it''s"}')`},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			mode:    SQLInsertIgnore,
+			s:       insert,
+			want: []string{`INSERT INTO "db"."t" ("id","name","b","bin","doc") VALUES (1,'it''s\é',258,` +
+				`CAST('\x00ff' AS BYTEA),CAST('{"a":"it''s"}' AS JSON)) ON CONFLICT DO NOTHING`},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			mode:    SQLReplace,
+			s:       insert,
+			want: []string{`INSERT INTO "db"."t" ("id","name","b","bin","doc") VALUES (1,'it''s\é',258,` +
+				`CAST('\x00ff' AS BYTEA),CAST('{"a":"it''s"}' AS JSON)) ` +
+				`ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name","b"=EXCLUDED."b",` +
+				`"bin"=EXCLUDED."bin","doc"=EXCLUDED."doc"`},
+		},
+		{
+			dialect: SQLDialectSQLite,
+			s:       &StreamEvent{Type: StatementDelete, Table: table, RowIdentifies: []*RowData{noKey}},
+			want:    []string{`DELETE FROM "t" WHERE rowid IN (SELECT rowid FROM "t" WHERE "a"=2 LIMIT 1)`},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			s: &StreamEvent{Type: StatementUpdate, Table: table,
+				RowIdentifies: []*RowData{noKey}, RowValues: []*RowData{noKey}},
+			want: []string{`UPDATE "db"."t" SET "a"=2 WHERE ctid IN (SELECT ctid FROM "db"."t" WHERE "a"=2 LIMIT 1)`},
+		},
+		{
+			dialect: SQLDialectPostgreSQL,
+			s:       ddl,
+			want:    []string{`ALTER TABLE "db"."t" ADD COLUMN "c" INTEGER`},
+		},
+	}
+
+	for i, v := range testCases {
+		g := NewSQLGenerator()
+		g.SetDialect(v.dialect)
+		g.SetInsertMode(v.mode)
+		out, err := g.GenerateStreamEvent(v.s)
+		if err != nil {
+			t.Fatalf("%v GenerateStreamEvent fail. err: %v", i, err)
+		}
+		if !reflect.DeepEqual(out, v.want) {
+			t.Fatalf("%v want != out\nwant: %q\nout:  %q", i, v.want, out)
+		}
+	}
+
+	errCases := []struct {
+		mode SQLInsertMode
+		s    *StreamEvent
+	}{
+		{SQLInsertOnDuplicateKeyUpdate, &StreamEvent{Type: StatementInsert, Table: table, RowValues: []*RowData{noKey}}},
+		{SQLInsert, &StreamEvent{Type: StatementInsert, Query: replication.Query{SQL: "insert into t values (1)"}}},
+		{SQLInsert, &StreamEvent{Type: StatementUpdate, Table: table,
+			RowIdentifies: []*RowData{row}, RowValues: []*RowData{{Columns: []*ColumnData{
+				{Filed: "doc", Type: columnTypeJSON, JSONDiffs: []replication.JSONDiff{
+					{Operation: replication.JSONDiffRemove, Path: "$.a"},
+				}},
+			}}}}},
+	}
+	for i, v := range errCases {
+		g := NewSQLGenerator()
+		g.SetDialect(SQLDialectPostgreSQL)
+		g.SetInsertMode(v.mode)
+		if out, err := g.GenerateStreamEvent(v.s); err == nil {
+			t.Fatalf("%v GenerateStreamEvent want error out: %q", i, out)
+		}
+	}
+}
+
+func TestSQLValue(t *testing.T) {
+	testCases := []struct {
+		c    *ColumnData
+		want string
+	}{
+		{&ColumnData{Type: columnTypeLong, Data: nil}, "NULL"},
+		{&ColumnData{Type: columnTypeLongLong, Data: []byte("18446744073709551615")}, "18446744073709551615"},
+		{&ColumnData{Type: columnTypeNewDecimal, Data: []byte("-0.50")}, "-0.50"},
+		{&ColumnData{Type: columnTypeYear, Data: []byte("0000")}, "'0000'"},
+		{&ColumnData{Type: columnTypeEnum, Data: []byte("2")}, "2"},
+		{&ColumnData{Type: columnTypeBit, Data: []byte{0x01, 0x02}}, "b'100000010'"},
+		{&ColumnData{Type: columnTypeDateTime2, Data: []byte("2019-08-04 19:02:48")}, "'2019-08-04 19:02:48'"},
+		{&ColumnData{Type: columnTypeVarchar, Data: []byte("a\\b\"\x00\x1a\r")}, `'a\\b\"\0\Z\r'`},
+		{&ColumnData{Type: columnTypeString, Charset: "binary", Data: []byte{}}, "''"},
+		{&ColumnData{Type: columnTypeVarchar, Charset: "gbk", Data: []byte{0xc4, 0xe3}}, "_gbk 0xc4e3"},
+		{&ColumnData{Type: columnTypeJSON, Data: []byte(`{"a": "it's"}`)}, `CAST('{\"a\": \"it\'s\"}' AS JSON)`},
+		{&ColumnData{Filed: "j", Type: columnTypeJSON, JSONDiffs: []replication.JSONDiff{
+			{Operation: replication.JSONDiffReplace, Path: "$.a", Value: []byte("1")},
+			{Operation: replication.JSONDiffInsert, Path: "$.b[0]", Value: []byte(`"x"`)},
+			{Operation: replication.JSONDiffRemove, Path: "$.c"},
+		}}, "JSON_REMOVE(JSON_ARRAY_INSERT(JSON_REPLACE(`j`,'$.a',CAST('1' AS JSON))," +
+			`'$.b[0]',CAST('\"x\"' AS JSON)),'$.c')`},
+	}
+	for i, v := range testCases {
+		out, err := sqlValue(v.c)
+		if err != nil {
+			t.Fatalf("%v sqlValue fail. err: %v", i, err)
+		}
+		if out != v.want {
+			t.Fatalf("%v want != out\nwant: %v\nout:  %v", i, v.want, out)
+		}
+	}
+
+	//binlog中的部分更新使用二进制json，替换$.a为"it's"
+	diffs, _, err := replication.JSONDiffs([]byte{12, 0, 0, 0, 0, 3, '$', '.', 'a', 6, 12, 4, 'i', 't', '\'', 's'}, 0, 4)
+	if err != nil {
+		t.Fatalf("JSONDiffs fail. err: %v", err)
+	}
+	want := "JSON_REPLACE(`j`,'$.a',CAST('\\\"it\\'s\\\"' AS JSON))"
+	if out, err := sqlValue(&ColumnData{Filed: "j", Type: columnTypeJSON, JSONDiffs: diffs}); err != nil || out != want {
+		t.Fatalf("binary json diff want != out\nwant: %v\nout:  %v err: %v", want, out, err)
+	}
+
+	//SQL表达式(JSONFormatSQL)不会原样输出
+	errCases := []*ColumnData{
+		{Filed: "j", Type: columnTypeJSON, Data: []byte(`JSON_OBJECT('a',1)`)},
+		{Filed: "j", Type: columnTypeJSON, Data: []byte(`'x'); DROP TABLE t; --`)},
+		{Filed: "j", Type: columnTypeJSON, JSONDiffs: []replication.JSONDiff{
+			{Operation: replication.JSONDiffReplace, Path: "$.a", Value: []byte(`'"it\'s"'`)},
+		}},
+	}
+	for i, c := range errCases {
+		if out, err := sqlValue(c); err == nil {
+			t.Fatalf("%v sqlValue want error out: %v", i, out)
+		}
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/Breeze0806/gobinlog/replication"
-	"github.com/Breeze0806/mysql"
 )
 
 //MysqlTableMapper 用于获取表信息的接口
@@ -17,6 +17,11 @@ type MysqlTableMapper interface {
 //Streamer 从github.com/youtube/vitess/go/vt/binlog/binlog_streamer.go的基础上移植过来
 //专门用来RowStreamer解析row模式的binlog event，将其变为对应的事务
 type Streamer struct {
+	//以下统计字段使用atomic读写，需要64位对齐，所以放在最前面
+	payloadEvents       uint64 //解析过的TRANSACTION_PAYLOAD_EVENT个数
+	payloadCompressed   uint64 //TRANSACTION_PAYLOAD_EVENT压缩后的总字节数
+	payloadUncompressed uint64 //TRANSACTION_PAYLOAD_EVENT解压后的总字节数
+
 	dsn             string
 	serverID        uint32
 	nowPos          atomic.Value
@@ -24,6 +29,23 @@ type Streamer struct {
 	sendTransaction SendTransactionFunc
 	errChan         <-chan *Error
 	ctx             context.Context
+	metrics         streamerMetrics
+	middlewares     []TransactionMiddleware
+	eventMiddleware []StreamEventMiddleware //解析后立即执行的语句中间件，早于缓存以及溢出到磁盘
+	incremental     IncrementalHandler //不为nil时增量投递事务
+	batchSize       int                //增量投递时每批的语句数
+	memoryLimit     int64              //一个事务在内存中缓存的语句的最大字节数，超过后溢出到磁盘
+	spillDir        string             //溢出文件的目录
+	failoverDSNs    []string           //连接出错时可以切换的候选数据库
+	failoverRetries int                //没有处理新的事务时连续切换的最大次数
+	failoverBackoff time.Duration      //第一次切换之前的等待时间，之后每次加倍
+	gtidSet         atomic.Value       //已经处理完的事务的GTID集合，类型为replication.Mysql56GTIDSet
+	gtidDump        bool               //当前连接是否使用COM_BINLOG_DUMP_GTID
+	jsonFormat      JSONFormat         //JSON列的输出格式
+	convertToUTF8   bool               //是否将字符列转换为utf8
+
+	dial         func(ctx context.Context, dsn string) (dumpConn, error) //默认是newMysqlDumpConn
+	gtidExecuted func(ctx context.Context, dsn string) (string, error)   //获取数据库的@@GLOBAL.gtid_executed
 }
 
 //SendTransactionFunc 处理事务信息函数，你可以将一个chan注册到这个函数中如
@@ -35,8 +57,23 @@ type Streamer struct {
 type SendTransactionFunc func(*Transaction) error
 
 type tableCache struct {
-	tableMap *replication.TableMap
-	table    MysqlTable
+	tableMap    *replication.TableMap
+	table       MysqlTable
+	primaryKeys []bool     //每一列是否是主键列
+	charsets    []string   //每一列的字符集，非字符列或者未知时为空
+	jsonFormat  JSONFormat //JSON列的输出格式
+	toUTF8      bool       //是否将字符列转换为utf8
+}
+
+func (t *tableCache) isPrimaryKey(c int) bool {
+	return c < len(t.primaryKeys) && t.primaryKeys[c]
+}
+
+func (t *tableCache) charset(c int) string {
+	if c < len(t.charsets) {
+		return t.charsets[c]
+	}
+	return ""
 }
 
 //NewStreamer dsn是mysql数据库的信息，serverID是标识该数据库的信息
@@ -46,6 +83,12 @@ func NewStreamer(dsn string, serverID uint32,
 		dsn:         dsn,
 		serverID:    serverID,
 		tableMapper: tableMapper,
+		dial: func(ctx context.Context, dsn string) (dumpConn, error) {
+			return newMysqlDumpConn(ctx, dsn)
+		},
+		gtidExecuted:    queryGTIDExecuted,
+		failoverRetries: defaultFailoverRetries,
+		failoverBackoff: defaultFailoverBackoff,
 	}, nil
 }
 
@@ -55,33 +98,118 @@ func (s *Streamer) SetBinlogPosition(startPos Position) {
 }
 
 func (s *Streamer) binlogPosition() Position {
-	return s.nowPos.Load().(Position)
+	pos, _ := s.nowPos.Load().(Position)
+	return pos
+}
+
+//Use 注册事务中间件，事务按照注册的顺序依次经过各个中间件，最后交给Stream中的SendTransactionFunc，
+//需要在Stream之前调用
+func (s *Streamer) Use(middlewares ...TransactionMiddleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+//UseEventMiddleware 注册语句中间件，每个语句在解析后立即按照注册的顺序依次经过各个中间件，
+//早于在内存中缓存、溢出到磁盘以及Use注册的中间件，所以溢出文件中是经过这些中间件之后的语句，
+//脱敏等不能将原始数据写入磁盘的处理需要通过该方法注册，需要在Stream之前调用
+func (s *Streamer) UseEventMiddleware(middlewares ...StreamEventMiddleware) {
+	s.eventMiddleware = append(s.eventMiddleware, middlewares...)
 }
 
 //Stream 注册一个处理事务信息函数到Stream中
 func (s *Streamer) Stream(ctx context.Context, sendTransaction SendTransactionFunc) error {
+	s.sendTransaction = ChainMiddlewares(sendTransaction, s.middlewares...)
+	s.incremental = nil
+	return s.stream(ctx)
+}
+
+func (s *Streamer) stream(ctx context.Context) error {
 	s.ctx = ctx
+	//没有设置binlog位置但是设置了GTID集合时使用GTID集合开始dump
+	_, ok := s.nowPos.Load().(Position)
+	_, gtid := s.loadGTIDSet()
+	gtid = gtid && !ok
+	if len(s.failoverDSNs) != 0 {
+		return s.streamWithFailover(ctx, gtid)
+	}
+	if _, err := s.streamFrom(ctx, s.dsn, gtid); err != nil {
+		return err
+	}
+	return nil
+}
+
+//streamFrom 连接dsn并dump binlog，gtid为true时使用COM_BINLOG_DUMP_GTID从GTID集合之后开始dump，
+//connFailed表示错误是否来自建立连接以及发送dump命令
+func (s *Streamer) streamFrom(ctx context.Context, dsn string, gtid bool) (connFailed bool, err *Error) {
 	conn, err := newSlaveConnection(func() (conn dumpConn, e error) {
-		return mysql.NewDumpConn(s.dsn, ctx)
+		return s.dial(ctx, dsn)
 	})
 	if err != nil {
-		return err.msgf("newMysqlConn fail.")
+		return true, err.msgf("newMysqlConn fail.")
 	}
 	defer conn.close()
-	s.sendTransaction = sendTransaction
 	var events <-chan replication.BinlogEvent
 	var pos Position
-	events, err = conn.startDumpFromBinlogPosition(ctx, s.serverID, s.binlogPosition())
-	if err != nil {
-		return err.msgf("startDumpFromBinlogPosition fail in pos: %+v", s.nowPos)
+	if gtid {
+		set, _ := s.loadGTIDSet()
+		if events, err = conn.startDumpFromGTIDSet(ctx, s.serverID, set); err != nil {
+			return err.Original() != errGTIDDumpUnsupported,
+				err.msgf("startDumpFromGTIDSet fail in gtid set: %v", set)
+		}
+	} else {
+		if events, err = conn.startDumpFromBinlogPosition(ctx, s.serverID, s.binlogPosition()); err != nil {
+			return true, err.msgf("startDumpFromBinlogPosition fail in pos: %+v", s.nowPos)
+		}
 	}
+	s.gtidDump = gtid
 	s.errChan = conn.errChan
 	pos, err = s.parseEvents(ctx, events)
 	s.SetBinlogPosition(pos)
 	if err != nil {
-		return err.msgf("parseEvents fail in pos: %+v", err)
+		return false, err.msgf("parseEvents fail in pos: %+v", err)
+	}
+	return false, nil
+}
+
+//CompressionStats 压缩事务(TRANSACTION_PAYLOAD_EVENT)的统计信息，
+//mysql 8.0.20+开启binlog_transaction_compression后事务会被压缩
+type CompressionStats struct {
+	Events            uint64 //压缩事务的个数
+	CompressedBytes   uint64 //压缩后的总字节数
+	UncompressedBytes uint64 //解压后的总字节数
+}
+
+//Ratio 压缩比，即解压后的字节数/压缩后的字节数，没有压缩事务时返回0
+func (c CompressionStats) Ratio() float64 {
+	if c.CompressedBytes == 0 {
+		return 0
+	}
+	return float64(c.UncompressedBytes) / float64(c.CompressedBytes)
+}
+
+//CompressionStats 获取压缩事务的统计信息，可以在Stream的同时调用
+func (s *Streamer) CompressionStats() CompressionStats {
+	return CompressionStats{
+		Events:            atomic.LoadUint64(&s.payloadEvents),
+		CompressedBytes:   atomic.LoadUint64(&s.payloadCompressed),
+		UncompressedBytes: atomic.LoadUint64(&s.payloadUncompressed),
 	}
-	return nil
+}
+
+func (s *Streamer) addCompressionStats(payload *replication.TransactionPayload) {
+	atomic.AddUint64(&s.payloadEvents, 1)
+	atomic.AddUint64(&s.payloadCompressed, payload.CompressedSize)
+	atomic.AddUint64(&s.payloadUncompressed, payload.UncompressedSize)
+}
+
+//Stats 获取Streamer的统计信息快照，可以在Stream的同时调用，多次Stream时统计信息会累加，
+//还没有发送事务时Position为开始的binlog位置
+func (s *Streamer) Stats() StreamerStats {
+	stats := s.metrics.stats()
+	stats.Compression = s.CompressionStats()
+	if pos, ok := s.nowPos.Load().(Position); ok && stats.Position.IsZero() {
+		stats.Position = pos
+	}
+	return stats
 }
 
 //Error 每次使用Stream后需要检测Error
@@ -110,88 +238,207 @@ func (s *Streamer) parseEvents(ctx context.Context, events <-chan replication.Bi
 	pos := s.binlogPosition()
 	tablesMaps := make(map[uint64]*tableCache)
 	autocommit := true
+	var gtid string
+	var gtidEvent replication.GTID //当前事务的GTID，用于更新GTID集合
+	var clock replication.LogicalTimestamp
+	sendFailed := false  //错误是否来自sendTransaction，不计入解析失败的次数
+	begun := false       //增量投递时当前事务是否已经调用了Begin
+	var spill *spillFile //当前事务溢出到磁盘的语句
+	var tranSize int64   //当前事务中语句的估算大小
+
+	dropSpill := func() {
+		if spill != nil {
+			spill.release()
+			spill = nil
+		}
+		tranSize = 0
+	}
+	defer dropSpill()
+
+	newTran := func(ev replication.BinlogEvent, now, next Position, events []*StreamEvent) *Transaction {
+		tran := newTransaction(now, next, int64(ev.Timestamp()), events)
+		tran.GTID = gtid
+		tran.LastCommitted = clock.LastCommitted
+		tran.SequenceNumber = clock.SequenceNumber
+		tran.ServerID = ev.ServerID()
+		return tran
+	}
 
-	begin := func() {
-		if tranEvents != nil {
-			// If this happened, it would be a legitimate error.
-			_log.Errorf("parseEvents BEGIN in binlog stream while still in another transaction; dropping %d transactionEvents: %+v", len(tranEvents), tranEvents)
+	//flush 增量投递时将已经解析的语句交给IncrementalHandler，第一次调用时先调用Begin
+	flush := func(ev replication.BinlogEvent) error {
+		if !begun {
+			if err := s.incremental.Begin(newTran(ev, pos, Position{}, nil)); err != nil {
+				sendFailed = true
+				return fmt.Errorf("Begin error: %v", err)
+			}
+			begun = true
 		}
+		if len(tranEvents) == 0 {
+			return nil
+		}
+		batch, err := s.applyMiddlewares(newTran(ev, pos, Position{}, tranEvents))
+		if err == nil && len(batch) > 0 {
+			err = s.incremental.Events(newTran(ev, pos, Position{}, nil), batch)
+		}
+		if err != nil {
+			sendFailed = true
+			return fmt.Errorf("Events error: %v", err)
+		}
+		s.metrics.addRows(tranEvents)
 		tranEvents = make([]*StreamEvent, 0, 10)
-		autocommit = false
+		return nil
 	}
 
-	commit := func(ev replication.BinlogEvent) error {
-		now := pos
-		pos.Offset = ev.NextPosition()
-		next := pos
-		tran := newTransaction(now, next, int64(ev.Timestamp()), tranEvents)
-		if err = s.sendTransaction(tran); err != nil {
-			return fmt.Errorf("sendTransaction error: %v", err)
+	//spillEvent 事务中语句的估算大小超过memoryLimit后，将已经缓存的语句以及之后的语句写入溢出文件
+	spillEvent := func(e *StreamEvent) error {
+		tranSize += e.memSize()
+		if spill != nil {
+			s.metrics.addRows([]*StreamEvent{e})
+			return spill.write(e)
+		}
+		tranEvents = append(tranEvents, e)
+		if tranSize <= s.memoryLimit {
+			return nil
+		}
+		f, err := newSpillFile(s.spillDir)
+		if err != nil {
+			return err
 		}
+		spill = f
+		_log.Infof("parseEvents transaction in pos: %+v exceeds memory limit %v, spill %d events to %v",
+			pos, s.memoryLimit, len(tranEvents), f.name)
+		s.metrics.addRows(tranEvents)
+		events := tranEvents
+		tranEvents = make([]*StreamEvent, 0, 10)
+		return spill.write(events...)
+	}
+
+	//appendEvent 语句经过UseEventMiddleware注册的中间件后缓存，增量投递时语句数达到batchSize后交给IncrementalHandler
+	appendEvent := func(ev replication.BinlogEvent, e *StreamEvent) error {
+		events, err := transformEvent(e, s.eventMiddleware)
+		if err != nil {
+			sendFailed = true
+			return fmt.Errorf("event middleware error: %v", err)
+		}
+		for _, e := range events {
+			if s.incremental == nil && s.memoryLimit > 0 {
+				if err = spillEvent(e); err != nil {
+					return err
+				}
+				continue
+			}
+			tranEvents = append(tranEvents, e)
+			if s.incremental != nil && len(tranEvents) >= s.incrementalBatchSize() {
+				if err = flush(ev); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	reset := func() {
 		tranEvents = nil
 		autocommit = true
-		return nil
+		gtid = ""
+		gtidEvent = nil
+		clock = replication.LogicalTimestamp{}
+		begun = false
+		dropSpill()
 	}
 
-	for {
-		var ev replication.BinlogEvent
-		var ok bool
-		select {
-		case ev, ok = <-events:
-			if !ok {
-				_log.Infof("parseEvents reached end of binlog event stream")
-				return pos, nil
+	//rollback 增量投递时回滚已经投递的语句，next为空时表示事务被丢弃，不更新位置
+	rollback := func(ev replication.BinlogEvent, next Position) error {
+		tranEvents = nil
+		if !begun {
+			if err := flush(ev); err != nil {
+				return err
 			}
-		case <-ctx.Done():
-			_log.Infof("parseEvents stopping early due to binlog Streamer service shutdown or client disconnect")
-			return pos, nil
 		}
-
-		// Validate the buffer before reading fields from it.
-		if !ev.IsValid() {
-			return pos, newError(fmt.Errorf("invalid data: %+v", ev)).
-				msgf("parseEvents can't parse binlog event.")
+		start := time.Now()
+		tran := newTran(ev, pos, next, nil)
+		if err := s.incremental.Rollback(tran); err != nil {
+			sendFailed = true
+			return fmt.Errorf("Rollback error: %v", err)
+		}
+		if !next.IsZero() {
+			s.metrics.addTransaction(tran, time.Since(start))
+			s.addGTID(gtidEvent)
 		}
+		return nil
+	}
 
-		// We need to keep checking for FORMAT_DESCRIPTION_EVENT even after we've
-		// seen one, because another one might come along (e.g. on _log rotate due to
-		// binlog settings change) that changes the format.
-		if ev.IsFormatDescription() {
-			format, err = ev.Format()
-			if err != nil {
-				return pos, newError(err).
-					msgf("parseEvents can't parse FORMAT_DESCRIPTION_EVENT event data: %+v", ev)
+	begin := func(ev replication.BinlogEvent) error {
+		if tranEvents != nil {
+			// If this happened, it would be a legitimate error.
+			_log.Errorf("parseEvents BEGIN in binlog stream while still in another transaction; dropping %d transactionEvents: %+v", len(tranEvents), tranEvents)
+			dropSpill()
+			if s.incremental != nil && begun {
+				if err := rollback(ev, Position{}); err != nil {
+					return err
+				}
+				begun = false
 			}
-			_log.Debugf("parseEvents pos: %+v binlog event is a format description event:%+v",
-				ev.NextPosition(), format)
-			continue
 		}
+		tranEvents = make([]*StreamEvent, 0, 10)
+		autocommit = false
+		return nil
+	}
 
-		// We can't parse anything until we get a FORMAT_DESCRIPTION_EVENT that
-		// tells us the size of the event header.
-		if format.IsZero() {
-			// The only thing that should come before the FORMAT_DESCRIPTION_EVENT
-			// is a fake ROTATE_EVENT, which the master sends to tell us the name
-			// of the current binlog file.
-			if ev.IsRotate() {
-				continue
+	commit := func(ev replication.BinlogEvent, offset int64) error {
+		if s.incremental != nil {
+			if err := flush(ev); err != nil {
+				return err
 			}
-			return pos, newError(fmt.
-				Errorf("parseEvents got a real event before FORMAT_DESCRIPTION_EVENT: %+v", ev))
 		}
-
-		// Strip the checksum, if any. We don't actually verify the checksum, so discard it.
-		ev, _, err = ev.StripChecksum(format)
+		//投递成功之后才推进位置，投递失败时从该事务重新dump
+		now := pos
+		next := Position{Filename: pos.Filename, Offset: offset}
+		var tran *Transaction
+		if s.incremental != nil {
+			tran = newTran(ev, now, next, nil)
+		} else {
+			tran = newTran(ev, now, next, tranEvents)
+		}
+		if spill != nil {
+			if err = spill.finish(); err != nil {
+				return err
+			}
+			tran.Events = nil
+			tran.spill = spill
+		}
+		if ev.IsXID() {
+			if tran.XID, err = ev.XID(format); err != nil {
+				return fmt.Errorf("XID error: %v", err)
+			}
+		}
+		start := time.Now()
+		if s.incremental != nil {
+			err = s.incremental.Commit(tran)
+		} else {
+			err = s.sendTransaction(tran)
+		}
 		if err != nil {
-			return pos, newError(err).msgf(
-				"parseEvents can't strip checksum from binlog event, event data: %+v", ev)
+			sendFailed = true
+			return fmt.Errorf("sendTransaction error: %v", err)
 		}
+		pos = next
+		s.metrics.addTransaction(tran, time.Since(start))
+		s.addGTID(gtidEvent)
+		reset()
+		return nil
+	}
 
+	//handleEvent 处理一个binlog event，next是该event之后的位置，
+	//TRANSACTION_PAYLOAD_EVENT中的event没有自己的位置，使用TRANSACTION_PAYLOAD_EVENT之后的位置
+	var handleEvent func(ev replication.BinlogEvent, next int64) *Error
+	handleEvent = func(ev replication.BinlogEvent, next int64) *Error {
+		s.metrics.addEvent(ev)
 		switch {
 		case ev.IsXID(): // XID_EVENT (equivalent to COMMIT)
 			_log.Debugf("parseEvents pos: %+v binlog event is a xid event: %v:", pos, ev)
-			if err = commit(ev); err != nil {
-				return pos, newError(err).msgf("parseEvents commit fail in XID event")
+			if err = commit(ev, next); err != nil {
+				return newError(err).msgf("parseEvents commit fail in XID event")
 			}
 
 		case ev.IsRotate():
@@ -199,14 +446,14 @@ func (s *Streamer) parseEvents(ctx context.Context, events <-chan replication.Bi
 			var filename string
 			var offset int64
 			if filename, offset, err = ev.Rotate(format); err != nil {
-				return pos, newError(err).msgf("parseEvents Rotate fail.")
+				return newError(err).msgf("parseEvents Rotate fail.")
 			}
 			pos.Filename = filename
 			pos.Offset = offset
 		case ev.IsQuery():
 			q, err := ev.Query(format)
 			if err != nil {
-				return pos, newError(err).msgf(
+				return newError(err).msgf(
 					"parseEvents can't get query from binlog event. event data: %+v", ev)
 			}
 			typ := GetStatementCategory(q.SQL)
@@ -215,35 +462,50 @@ func (s *Streamer) parseEvents(ctx context.Context, events <-chan replication.Bi
 
 			switch typ {
 			case StatementBegin:
-				begin()
+				if err = begin(ev); err != nil {
+					return newError(err).msgf("parseEvents begin fail in Query event")
+				}
 			case StatementCreate, StatementAlter, StatementDrop, StatementRename, StatementTruncate, StatementSet:
-				tranEvents = append(tranEvents, &StreamEvent{
+				if err = appendEvent(ev, &StreamEvent{
 					Type:      typ,
 					Query:     q,
 					Timestamp: int64(ev.Timestamp()),
-				})
+				}); err != nil {
+					return newError(err).msgf("parseEvents append fail in Query event")
+				}
 				if autocommit {
-					if err = commit(ev); err != nil {
-						return pos, newError(err).msgf("parseEvents commit fail in Query event")
+					if err = commit(ev, next); err != nil {
+						return newError(err).msgf("parseEvents commit fail in Query event")
 					}
 				}
 			case StatementDelete, StatementInsert, StatementUpdate:
-				tranEvents = append(tranEvents, &StreamEvent{
+				if err = appendEvent(ev, &StreamEvent{
 					Type:      typ,
 					Query:     q,
 					Timestamp: int64(ev.Timestamp()),
-				})
+				}); err != nil {
+					return newError(err).msgf("parseEvents append fail in Query event")
+				}
 				if autocommit {
-					if err = commit(ev); err != nil {
-						return pos, newError(err).msgf("parseEvents commit fail in Query event")
+					if err = commit(ev, next); err != nil {
+						return newError(err).msgf("parseEvents commit fail in Query event")
 					}
 				}
 			case StatementRollback:
+				if s.incremental != nil {
+					if err = rollback(ev, Position{Filename: pos.Filename, Offset: next}); err != nil {
+						return newError(err).msgf("parseEvents rollback fail in Query event")
+					}
+					pos.Offset = next
+					reset()
+					break
+				}
 				tranEvents = nil
+				dropSpill()
 				fallthrough
 			case StatementCommit:
-				if err = commit(ev); err != nil {
-					return pos, newError(err).msgf("parseEvents commit fail in Query event")
+				if err = commit(ev, next); err != nil {
+					return newError(err).msgf("parseEvents commit fail in Query event")
 				}
 			default:
 				_log.Errorf("parseEvents we have a sql in binlog position: %+v error: %v", pos,
@@ -256,60 +518,68 @@ func (s *Streamer) parseEvents(ctx context.Context, events <-chan replication.Bi
 			tm, err := ev.TableMap(format)
 
 			if err != nil {
-				return pos, newError(err).msgf("parseEvents TableMap fail. event data: %v", ev)
+				return newError(err).msgf("parseEvents TableMap fail. event data: %v", ev)
 			}
 			_log.Debugf("parseEvents pos: %+v binlog event is a table map event, tableID: %v table map: %+v",
 				pos, tableID, *tm)
 
-			if _, ok = tablesMaps[tableID]; ok {
+			if _, ok := tablesMaps[tableID]; ok {
 				tablesMaps[tableID].tableMap = tm
-				continue
+				return nil
 			}
 
 			tc := &tableCache{
-				tableMap: tm,
+				tableMap:   tm,
+				jsonFormat: s.jsonFormat,
+				toUTF8:     s.convertToUTF8,
 			}
 
 			name := NewMysqlTableName(tm.Database, tm.Name)
 
 			var info MysqlTable
 			if info, err = s.tableMapper.MysqlTable(name); err != nil {
-				return pos, newError(err).msgf("parseEvents MysqlTable fail. table: %v", err)
+				return newError(err).msgf("parseEvents MysqlTable fail. table: %v", err)
 			}
 
 			if len(info.Columns()) != tm.CanBeNull.Count() {
-				return Position{},
-					newError(fmt.Errorf("parseEvents the length of column in tableMap(%d) "+
-						"did not equal to the length of column in table info(%d)", tm.CanBeNull.Count(),
-						len(info.Columns())))
+				return newError(fmt.Errorf("parseEvents the length of column in tableMap(%d) "+
+					"did not equal to the length of column in table info(%d)", tm.CanBeNull.Count(),
+					len(info.Columns())))
 			}
 			tc.table = info
+			tc.primaryKeys = make([]bool, len(info.Columns()))
+			for _, c := range PrimaryKeyColumns(info) {
+				tc.primaryKeys[c] = true
+			}
+			tc.charsets = columnCharsets(tm, info)
 			tablesMaps[tableID] = tc
 
 		case ev.IsWriteRows():
 			tableID := ev.TableID(format)
 			tc, ok := tablesMaps[tableID]
 			if !ok {
-				return pos, newError(fmt.Errorf("parseEvents unknown tableID %v in WriteRows event", tableID))
+				return newError(fmt.Errorf("parseEvents unknown tableID %v in WriteRows event", tableID))
 			}
 			_log.Debugf("parseEvents pos: %+v binlog event is a write rows event, tableID: %v tc.tableMap: %+v",
 				pos, tableID, tc.tableMap)
 			rows, err := ev.Rows(format, tc.tableMap)
 			if err != nil {
-				return pos, newError(err).msgf("Rows fail in WriteRows event. event data: %v", ev)
+				return newError(err).msgf("Rows fail in WriteRows event. event data: %v", ev)
 			}
 			_log.Debugf("parseEvents pos: %+v binlog event is a write rows event, tableID: %v rows: %+v",
 				pos, tableID, rows)
 
 			tranEvent, err := appendInsertEventFromRows(tc, &rows, int64(ev.Timestamp()))
 			if err != nil {
-				return pos, newError(err)
+				return newError(err)
 			}
 
-			tranEvents = append(tranEvents, tranEvent)
+			if err = appendEvent(ev, tranEvent); err != nil {
+				return newError(err).msgf("parseEvents append fail in rows event")
+			}
 			if autocommit {
-				if err = commit(ev); err != nil {
-					return pos, newError(err).msgf("parseEvents commit fail in WriteRows event")
+				if err = commit(ev, next); err != nil {
+					return newError(err).msgf("parseEvents commit fail in WriteRows event")
 				}
 			}
 
@@ -317,13 +587,13 @@ func (s *Streamer) parseEvents(ctx context.Context, events <-chan replication.Bi
 			tableID := ev.TableID(format)
 			tc, ok := tablesMaps[tableID]
 			if !ok {
-				return pos, newError(fmt.Errorf("parseEvents unknown tableID %v in UpdateRows event", tableID))
+				return newError(fmt.Errorf("parseEvents unknown tableID %v in UpdateRows event", tableID))
 			}
 			_log.Debugf("parseEvents pos: %+v binlog event is a update rows event, tableID: %v tc.tableMap: %+v",
 				pos, tableID, tc.tableMap)
 			rows, err := ev.Rows(format, tc.tableMap)
 			if err != nil {
-				return pos, newError(err).msgf("Rows fail in UpdateRows event. event data: %v", ev)
+				return newError(err).msgf("Rows fail in UpdateRows event. event data: %v", ev)
 			}
 
 			_log.Debugf("parseEvents pos: %+v binlog event is a update rows event, tableID: %v rows: %+v",
@@ -331,19 +601,21 @@ func (s *Streamer) parseEvents(ctx context.Context, events <-chan replication.Bi
 
 			tranEvent, err := appendUpdateEventFromRows(tc, &rows, int64(ev.Timestamp()))
 			if err != nil {
-				return pos, newError(err)
+				return newError(err)
+			}
+			if err = appendEvent(ev, tranEvent); err != nil {
+				return newError(err).msgf("parseEvents append fail in rows event")
 			}
-			tranEvents = append(tranEvents, tranEvent)
 			if autocommit {
-				if err = commit(ev); err != nil {
-					return pos, newError(err).msgf("parseEvents commit fail in UpdateRows event")
+				if err = commit(ev, next); err != nil {
+					return newError(err).msgf("parseEvents commit fail in UpdateRows event")
 				}
 			}
 		case ev.IsDeleteRows():
 			tableID := ev.TableID(format)
 			tc, ok := tablesMaps[tableID]
 			if !ok {
-				return pos, newError(fmt.Errorf("parseEvents unknown tableID %v in DeleteRows event", tableID))
+				return newError(fmt.Errorf("parseEvents unknown tableID %v in DeleteRows event", tableID))
 			}
 
 			_log.Debugf("parseEvents pos: %+v binlog event is a delete rows event, tableID: %v tc.tableMap: %+v",
@@ -351,36 +623,149 @@ func (s *Streamer) parseEvents(ctx context.Context, events <-chan replication.Bi
 
 			rows, err := ev.Rows(format, tc.tableMap)
 			if err != nil {
-				return pos, newError(err).msgf("Rows fail in DeleteRows event. event data: %v", ev)
+				return newError(err).msgf("Rows fail in DeleteRows event. event data: %v", ev)
 			}
 
 			_log.Debugf("parseEvents pos: %+v", "binlog event is a delete rows event, tableID: %v rows: %+v",
 				pos, tableID, rows)
 			tranEvent, err := appendDeleteEventFromRows(tc, &rows, int64(ev.Timestamp()))
 			if err != nil {
-				return pos, newError(err)
+				return newError(err)
 			}
 
-			tranEvents = append(tranEvents, tranEvent)
+			if err = appendEvent(ev, tranEvent); err != nil {
+				return newError(err).msgf("parseEvents append fail in rows event")
+			}
 			if autocommit {
-				if err = commit(ev); err != nil {
-					return pos, newError(err).msgf("parseEvents commit fail in DeleteRows event")
+				if err = commit(ev, next); err != nil {
+					return newError(err).msgf("parseEvents commit fail in DeleteRows event")
+				}
+			}
+		case ev.IsTransactionPayload():
+			payload, err := ev.TransactionPayload(format)
+			if err != nil {
+				return newError(err).msgf("parseEvents TransactionPayload fail. event data: %v", ev)
+			}
+			inner, err := payload.Events(format)
+			if err != nil {
+				return newError(err).msgf("parseEvents TransactionPayload Events fail. event data: %v", ev)
+			}
+			s.addCompressionStats(payload)
+			_log.Debugf("parseEvents pos: %+v binlog event is a transaction payload event, "+
+				"compression type: %v compressed size: %v uncompressed size: %v events: %v",
+				pos, payload.CompressionType, payload.CompressedSize, payload.UncompressedSize, len(inner))
+			for _, e := range inner {
+				if err := handleEvent(e, next); err != nil {
+					return err
 				}
 			}
 		case ev.IsPreviousGTIDs():
 			_log.Debugf("parseEvents pos: %+v binlog event is a PreviousGTIDs event: %+v", pos, ev)
-		case ev.IsGTID():
+		case ev.IsGTID(), ev.IsAnonymousGTID():
 			_log.Debugf("parseEvents pos: %+v binlog event is a GTID event: %+v", pos, ev)
+			if ev.IsGTID() {
+				var g replication.GTID
+				if g, _, err = ev.GTID(format); err != nil {
+					return newError(err).msgf("parseEvents GTID fail. event data: %v", ev)
+				}
+				gtid = g.String()
+				gtidEvent = g
+			}
+			if clock, _, err = ev.LogicalTimestamp(format); err != nil {
+				return newError(err).msgf("parseEvents LogicalTimestamp fail. event data: %v", ev)
+			}
 
 		case ev.IsRand():
 			//todo deal with the Rand error
-			return pos, newError(fmt.Errorf("binlog event is a Rand event: %+v", ev))
+			return newError(fmt.Errorf("binlog event is a Rand event: %+v", ev))
 		case ev.IsIntVar():
 			//todo deal with the IntVar error
-			return pos, newError(fmt.Errorf("binlog event is a IntVar event: %+v", ev))
+			return newError(fmt.Errorf("binlog event is a IntVar event: %+v", ev))
 		case ev.IsRowsQuery():
 			//todo deal with the RowsQuery error
-			return pos, newError(fmt.Errorf("binlog event is a RowsQuery event: %+v", ev))
+			return newError(fmt.Errorf("binlog event is a RowsQuery event: %+v", ev))
+		}
+		return nil
+	}
+
+	var fakeRotate replication.BinlogEvent //FORMAT_DESCRIPTION_EVENT之前的ROTATE_EVENT
+	for {
+		var ev replication.BinlogEvent
+		var ok bool
+		select {
+		case ev, ok = <-events:
+			if !ok {
+				_log.Infof("parseEvents reached end of binlog event stream")
+				return pos, nil
+			}
+		case <-ctx.Done():
+			_log.Infof("parseEvents stopping early due to binlog Streamer service shutdown or client disconnect")
+			return pos, nil
+		}
+
+		// Validate the buffer before reading fields from it.
+		if !ev.IsValid() {
+			s.metrics.addDecodeError()
+			return pos, newError(fmt.Errorf("invalid data: %+v", ev)).
+				msgf("parseEvents can't parse binlog event.")
+		}
+
+		// We need to keep checking for FORMAT_DESCRIPTION_EVENT even after we've
+		// seen one, because another one might come along (e.g. on _log rotate due to
+		// binlog settings change) that changes the format.
+		s.metrics.addBytes(len(ev.Bytes()))
+		if ev.IsFormatDescription() {
+			s.metrics.addEvent(ev)
+			format, err = ev.Format()
+			if err != nil {
+				s.metrics.addDecodeError()
+				return pos, newError(err).
+					msgf("parseEvents can't parse FORMAT_DESCRIPTION_EVENT event data: %+v", ev)
+			}
+			_log.Debugf("parseEvents pos: %+v binlog event is a format description event:%+v",
+				ev.NextPosition(), format)
+			//使用COM_BINLOG_DUMP_GTID时由主库决定开始的binlog文件，只能从假的ROTATE_EVENT中获取
+			if s.gtidDump && fakeRotate != nil {
+				if fakeRotate, _, err = fakeRotate.StripChecksum(format); err == nil {
+					pos.Filename, pos.Offset, err = fakeRotate.Rotate(format)
+				}
+				if err != nil {
+					s.metrics.addDecodeError()
+					return pos, newError(err).msgf("parseEvents can't parse fake ROTATE_EVENT event data: %+v",
+						fakeRotate)
+				}
+				fakeRotate = nil
+			}
+			continue
+		}
+
+		// We can't parse anything until we get a FORMAT_DESCRIPTION_EVENT that
+		// tells us the size of the event header.
+		if format.IsZero() {
+			// The only thing that should come before the FORMAT_DESCRIPTION_EVENT
+			// is a fake ROTATE_EVENT, which the master sends to tell us the name
+			// of the current binlog file.
+			if ev.IsRotate() {
+				fakeRotate = ev
+				continue
+			}
+			return pos, newError(fmt.
+				Errorf("parseEvents got a real event before FORMAT_DESCRIPTION_EVENT: %+v", ev))
+		}
+
+		// Strip the checksum, if any. We don't actually verify the checksum, so discard it.
+		ev, _, err = ev.StripChecksum(format)
+		if err != nil {
+			s.metrics.addDecodeError()
+			return pos, newError(err).msgf(
+				"parseEvents can't strip checksum from binlog event, event data: %+v", ev)
+		}
+
+		if err := handleEvent(ev, ev.NextPosition()); err != nil {
+			if !sendFailed {
+				s.metrics.addDecodeError()
+			}
+			return pos, err
 		}
 	}
 }
@@ -442,6 +827,8 @@ func getValuesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*RowD
 	for c := 0; c < rs.DataColumns.Count(); c++ {
 		column := newColumnData(tc.table.Columns()[c].Field(), ColumnType(tc.tableMap.Types[c]),
 			false)
+		column.IsPrimaryKey = tc.isPrimaryKey(c)
+		column.Charset = tc.charset(c)
 
 		if !rs.DataColumns.Bit(c) {
 			column.IsEmpty = true
@@ -459,12 +846,20 @@ func getValuesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*RowD
 		var l int
 		var err error
 
-		column.Data, l, err = replication.CellBytes(data, pos, tc.tableMap.Types[c], tc.tableMap.Metadata[c],
-			tc.table.Columns()[c].IsUnSignedInt())
+		if rs.Rows[rowIndex].IsPartialJSON(tc.tableMap, c) {
+			column.JSONDiffs, column.Data, l, err = getJSONDiffsFromRow(tc, rs, rowIndex, c, pos)
+		} else {
+			column.Data, l, err = cellBytes(tc, data, pos, c)
+		}
 
 		if err != nil {
 			return nil, err
 		}
+		if tc.toUTF8 {
+			if err = column.toUTF8(); err != nil {
+				return nil, err
+			}
+		}
 
 		values.Columns = append(values.Columns, column)
 
@@ -475,6 +870,27 @@ func getValuesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*RowD
 	return values, nil
 }
 
+//getJSONDiffsFromRow 获取JSON列部分更新的JSONDiffs，before image中有该列时还原出完整的after值
+func getJSONDiffsFromRow(tc *tableCache, rs *replication.Rows, rowIndex, c, pos int) (
+	[]replication.JSONDiff, []byte, int, error) {
+	metadata := tc.tableMap.Metadata[c]
+	diffs, l, err := replication.JSONDiffs(rs.Rows[rowIndex].Data, pos, metadata)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	before, ok, err := rs.IdentifyCell(tc.tableMap, rowIndex, c)
+	if err != nil || !ok {
+		return diffs, nil, l, err
+	}
+
+	after, err := applyJSONDiffs(before, metadata, diffs, tc.jsonFormat)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return diffs, after, l, nil
+}
+
 func getIdentifiesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*RowData, error) {
 	data := rs.Rows[rowIndex].Identify
 	identifyIndex := 0
@@ -488,6 +904,8 @@ func getIdentifiesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*
 
 		column := newColumnData(tc.table.Columns()[c].Field(), ColumnType(tc.tableMap.Types[c]),
 			false)
+		column.IsPrimaryKey = tc.isPrimaryKey(c)
+		column.Charset = tc.charset(c)
 		if !rs.IdentifyColumns.Bit(c) {
 			column.IsEmpty = true
 			identifies.Columns = append(identifies.Columns, column)
@@ -504,11 +922,15 @@ func getIdentifiesFromRow(tc *tableCache, rs *replication.Rows, rowIndex int) (*
 		var l int
 		var err error
 
-		column.Data, l, err = replication.CellBytes(data, pos, tc.tableMap.Types[c], tc.tableMap.Metadata[c],
-			tc.table.Columns()[c].IsUnSignedInt())
+		column.Data, l, err = cellBytes(tc, data, pos, c)
 		if err != nil {
 			return nil, err
 		}
+		if tc.toUTF8 {
+			if err = column.toUTF8(); err != nil {
+				return nil, err
+			}
+		}
 
 		identifies.Columns = append(identifies.Columns, column)
 
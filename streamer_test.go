@@ -18,6 +18,7 @@ const (
 type mysqlColumnAttribute struct {
 	field string //列名
 	typ   string //列类型
+	key   string //PRI代表主键
 }
 
 func (m *mysqlColumnAttribute) Field() string {
@@ -28,6 +29,14 @@ func (m *mysqlColumnAttribute) IsUnSignedInt() bool {
 	return strings.Contains(m.typ, mysqlUnsigned)
 }
 
+func (m *mysqlColumnAttribute) IsPrimaryKey() bool {
+	return m.key == "PRI"
+}
+
+func (m *mysqlColumnAttribute) Type() string {
+	return m.typ
+}
+
 type mysqlTableInfo struct {
 	name    MysqlTableName
 	columns []MysqlColumn
@@ -51,6 +60,7 @@ var (
 			&mysqlColumnAttribute{
 				field: "id",
 				typ:   "int(11)",
+				key:   "PRI",
 			},
 			&mysqlColumnAttribute{
 				field: "message",
@@ -173,7 +183,7 @@ func getInputData() []replication.BinlogEvent {
 		replication.NewWriteRowsEvent(f, s, tableID, insertRows),
 		replication.NewUpdateRowsEvent(f, s, tableID, updateRows),
 		replication.NewDeleteRowsEvent(f, s, tableID, deleteRows),
-		replication.NewXIDEvent(f, s),
+		replication.NewXIDEventWithID(f, s, 940752),
 	}
 }
 
@@ -257,6 +267,10 @@ func checkColumnDataEqual(c *ColumnData, right *ColumnData) error {
 		return fmt.Errorf("isEmpty is not equal. left: %v, right: %v", c.IsEmpty, right.IsEmpty)
 	}
 
+	if c.IsPrimaryKey != right.IsPrimaryKey {
+		return fmt.Errorf("isPrimaryKey is not equal. left: %v, right: %v", c.IsPrimaryKey, right.IsPrimaryKey)
+	}
+
 	if bytes.Compare(c.Data, right.Data) != 0 {
 		return fmt.Errorf("data is not equal. left: %v, right: %v", string(c.Data), string(right.Data))
 	}
@@ -283,9 +297,10 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 					{
 						Columns: []*ColumnData{
 							{
-								Filed: "id",
-								Data:  []byte("1076895760"),
-								Type:  columnTypeLong,
+								Filed:        "id",
+								Data:         []byte("1076895760"),
+								Type:         columnTypeLong,
+								IsPrimaryKey: true,
 							},
 							{
 								Filed: "message",
@@ -304,9 +319,10 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 					{
 						Columns: []*ColumnData{
 							{
-								Filed: "id",
-								Data:  []byte("1076895760"),
-								Type:  columnTypeLong,
+								Filed:        "id",
+								Data:         []byte("1076895760"),
+								Type:         columnTypeLong,
+								IsPrimaryKey: true,
 							},
 							{
 								Filed: "message",
@@ -320,9 +336,10 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 					{
 						Columns: []*ColumnData{
 							{
-								Filed: "id",
-								Data:  []byte("1076895760"),
-								Type:  columnTypeLong,
+								Filed:        "id",
+								Data:         []byte("1076895760"),
+								Type:         columnTypeLong,
+								IsPrimaryKey: true,
 							},
 							{
 								Filed: "message",
@@ -341,9 +358,10 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 					{
 						Columns: []*ColumnData{
 							{
-								Filed: "id",
-								Data:  []byte("1076895760"),
-								Type:  columnTypeLong,
+								Filed:        "id",
+								Data:         []byte("1076895760"),
+								Type:         columnTypeLong,
+								IsPrimaryKey: true,
 							},
 							{
 								Filed: "message",
@@ -391,6 +409,163 @@ func TestRowStreamer_parseEvents(t *testing.T) {
 	if err := checkTransactionEqual(out, want); err != nil {
 		t.Fatalf("NowPosition want != out, err: %v", err)
 	}
+	if out.XID != 940752 {
+		t.Fatalf("XID want: 940752 out: %v", out.XID)
+	}
+}
+
+func TestRowStreamer_parseEventsGTID(t *testing.T) {
+	f := replication.NewMySQL56BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	gtid := replication.Mysql56GTID{
+		Server:   replication.SID{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		Sequence: 56789,
+	}
+	clock := replication.LogicalTimestamp{LastCommitted: 3, SequenceNumber: 5}
+	s.ServerID = 62344
+	input := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, uint64(testBinlogPosParseEvents.Offset), testBinlogPosParseEvents.Filename),
+		replication.NewFormatDescriptionEvent(f, s),
+		replication.NewMySQL57GTIDEvent(f, s, gtid, clock),
+		replication.NewQueryEvent(f, s, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "create table vt_b(id int)"}),
+		replication.NewAnonymousGTIDEvent(f, s, replication.LogicalTimestamp{LastCommitted: 5, SequenceNumber: 6}),
+		replication.NewQueryEvent(f, s, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "drop table vt_b"}),
+	}
+
+	m := newMockMapper()
+	st, err := NewStreamer(testDSN, testServerID, m)
+	if err != nil {
+		t.Fatalf("NewStreamer err: %v", err)
+	}
+	st.SetBinlogPosition(testBinlogPosParseEvents)
+
+	var out []*Transaction
+	st.sendTransaction = func(tran *Transaction) error {
+		out = append(out, tran)
+		return nil
+	}
+
+	events := make(chan replication.BinlogEvent)
+	go func() {
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+	}()
+
+	if _, pErr := st.parseEvents(context.Background(), events); pErr != nil {
+		t.Fatalf("parseEvents err != %v, err: %v", nil, pErr)
+	}
+
+	if len(out) != 2 {
+		t.Fatalf("len of transactions want: 2 out: %v", len(out))
+	}
+	if out[0].GTID != gtid.String() || out[0].LastCommitted != 3 || out[0].SequenceNumber != 5 ||
+		out[0].ServerID != s.ServerID {
+		t.Fatalf("first transaction want: %v 3 5 %v out: %v %v %v %v", gtid.String(), s.ServerID,
+			out[0].GTID, out[0].LastCommitted, out[0].SequenceNumber, out[0].ServerID)
+	}
+	if out[1].GTID != "" || out[1].LastCommitted != 5 || out[1].SequenceNumber != 6 {
+		t.Fatalf("second transaction want: \"\" 5 6 out: %v %v %v",
+			out[1].GTID, out[1].LastCommitted, out[1].SequenceNumber)
+	}
+}
+
+func TestRowStreamer_parseEventsPayload(t *testing.T) {
+	f := replication.NewMySQL80BinlogFormat()
+	s := replication.NewFakeBinlogStream()
+	//压缩事务中的event没有checksum
+	inner := f
+	inner.ChecksumAlgorithm = replication.BinlogChecksumAlgOff
+
+	tableID := uint64(0x102030405060)
+	tm := &replication.TableMap{
+		Database: "vt_test_keyspace",
+		Name:     "vt_a",
+		Types: []byte{
+			replication.TypeLong,
+			replication.TypeVarchar,
+		},
+		CanBeNull: replication.NewServerBitmap(2),
+		Metadata: []uint16{
+			0,
+			384,
+		},
+	}
+	insertRows := replication.Rows{
+		DataColumns: replication.NewServerBitmap(2),
+		Rows: []replication.Row{
+			{
+				NullColumns: replication.NewServerBitmap(2),
+				Data: []byte{
+					0x10, 0x20, 0x30, 0x40, // long
+					0x04, 0x00, // len('abcd')
+					'a', 'b', 'c', 'd', // 'abcd'
+				},
+			},
+		},
+	}
+	insertRows.DataColumns.Set(0, true)
+	insertRows.DataColumns.Set(1, true)
+
+	payload := replication.NewTransactionPayloadEvent(f, s, replication.PayloadCompressionZstd,
+		replication.NewQueryEvent(inner, s, replication.Query{
+			Database: "vt_test_keyspace",
+			SQL:      "BEGIN"}),
+		replication.NewTableMapEvent(inner, s, tableID, tm),
+		replication.NewWriteRowsEvent(inner, s, tableID, insertRows),
+		replication.NewXIDEvent(inner, s),
+	)
+	input := []replication.BinlogEvent{
+		replication.NewRotateEvent(f, s, uint64(testBinlogPosParseEvents.Offset), testBinlogPosParseEvents.Filename),
+		replication.NewFormatDescriptionEvent(f, s),
+		payload,
+	}
+
+	m := newMockMapper()
+	st, err := NewStreamer(testDSN, testServerID, m)
+	if err != nil {
+		t.Fatalf("NewStreamer err: %v", err)
+	}
+	st.SetBinlogPosition(testBinlogPosParseEvents)
+
+	var out []*Transaction
+	st.sendTransaction = func(tran *Transaction) error {
+		out = append(out, tran)
+		return nil
+	}
+
+	events := make(chan replication.BinlogEvent)
+	go func() {
+		for i := range input {
+			events <- input[i]
+		}
+		close(events)
+	}()
+
+	if _, pErr := st.parseEvents(context.Background(), events); pErr != nil {
+		t.Fatalf("parseEvents err != %v, err: %v", nil, pErr)
+	}
+
+	if len(out) != 1 || len(out[0].Events) != 1 || len(out[0].Events[0].RowValues) != 1 {
+		t.Fatalf("transactions want one insert out: %+v", out)
+	}
+	if out[0].NextPosition.Offset != payload.NextPosition() {
+		t.Fatalf("next position want: %v out: %v", payload.NextPosition(), out[0].NextPosition.Offset)
+	}
+	columns := out[0].Events[0].RowValues[0].Columns
+	if string(columns[0].Data) != "1076895760" || string(columns[1].Data) != "abcd" {
+		t.Fatalf("row want: 1076895760 abcd out: %s %s", columns[0].Data, columns[1].Data)
+	}
+
+	stats := st.CompressionStats()
+	if stats.Events != 1 || stats.CompressedBytes == 0 || stats.UncompressedBytes == 0 || stats.Ratio() <= 0 {
+		t.Fatalf("bad compression stats: %+v", stats)
+	}
 }
 
 func TestRowStreamer_SetStartBinlogPosition(t *testing.T) {
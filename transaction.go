@@ -1,18 +1,22 @@
 package gobinlog
 
-import (
-	"encoding/json"
-	"time"
-
-	"github.com/Breeze0806/gobinlog/replication"
-)
+import "github.com/Breeze0806/gobinlog/replication"
 
 //Transaction 代表一组有事务的binlog evnet
 type Transaction struct {
-	NowPosition  Position       //在binlog中的当前位置
-	NextPosition Position       //在binlog中的下一个位置
-	Timestamp    int64          //执行时间
-	Events       []*StreamEvent //一组有事务的binlog evnet
+	NowPosition    Position       //在binlog中的当前位置
+	NextPosition   Position       //在binlog中的下一个位置
+	Timestamp      int64          //执行时间
+	GTID           string         //事务的GTID，未开启GTID时为空
+	LastCommitted  int64          //逻辑时钟中该事务依赖的最后一个事务的序号，0表示没有逻辑时钟
+	SequenceNumber int64          //逻辑时钟中该事务的序号，0表示没有逻辑时钟
+	ServerID       uint32         //写入该事务的mysql的server_id
+	XID            uint64         //事务的xid，只有以XID_EVENT提交的事务才有
+	Source         string         //事务来源的标识，只有StreamerGroup中的事务才有
+	Events         []*StreamEvent //一组有事务的binlog evnet，溢出到磁盘时为空
+
+	spill      *spillFile              //溢出到磁盘的语句，为nil时语句都在Events中
+	transforms []StreamEventMiddleware //读取溢出的语句时依次经过的语句中间件
 }
 
 //newTransaction 创建Transaction
@@ -26,20 +30,14 @@ func newTransaction(now, next Position, timestamp int64,
 	}
 }
 
-//MarshalJSON 实现Transaction的json序列化
+//MarshalJSON 实现Transaction的json序列化，与默认配置的JSONEncoder相同
 func (t *Transaction) MarshalJSON() ([]byte, error) {
-	tJSON := struct {
-		NowPosition  Position       `json:"nowPosition"`
-		NextPosition Position       `json:"nextPosition"`
-		Timestamp    string         `json:"timestamp"`
-		Events       []*StreamEvent `json:"events"`
-	}{
-		NowPosition:  t.NowPosition,
-		NextPosition: t.NextPosition,
-		Timestamp:    time.Unix(t.Timestamp, 0).Local().String(),
-		Events:       t.Events,
-	}
-	return json.Marshal(tJSON)
+	return NewJSONEncoder().EncodeTransaction(t)
+}
+
+//UnmarshalJSON 实现Transaction的json反序列化，支持JSONEncoder的所有配置的输出
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	return decodeTransaction(data, t)
 }
 
 //StreamEvent means a SQL or a rows in binlog
@@ -65,39 +63,15 @@ func newStreamEvent(tranType StatementType,
 	}
 }
 
-type baseStreamEventJSON struct {
-	Table     MysqlTableName `json:"name"`
-	Type      string         `json:"type"`
-	Timestamp string         `json:"timestamp"`
+//MarshalJSON 实现StreamEvent的json序列化，与默认配置的JSONEncoder相同
+func (s *StreamEvent) MarshalJSON() ([]byte, error) {
+	return NewJSONEncoder().EncodeStreamEvent(s)
 }
 
-//MarshalJSON 实现StreamEvent的json序列化
-func (s *StreamEvent) MarshalJSON() ([]byte, error) {
-	b := baseStreamEventJSON{
-		Table:     s.Table,
-		Type:      s.Type.String(),
-		Timestamp: time.Unix(s.Timestamp, 0).Local().String(),
-	}
-	if s.Query.SQL != "" {
-		sqlJSON := struct {
-			baseStreamEventJSON
-			SQL string `json:"sql"`
-		}{
-			baseStreamEventJSON: b,
-			SQL:                 s.Query.SQL,
-		}
-		return json.Marshal(sqlJSON)
-	}
-	RowJSON := struct {
-		baseStreamEventJSON
-		RowValues     []*RowData `json:"rowValues"`
-		RowIdentifies []*RowData `json:"rowIdentifies"`
-	}{
-		baseStreamEventJSON: b,
-		RowValues:           s.RowValues,
-		RowIdentifies:       s.RowIdentifies,
-	}
-	return json.Marshal(RowJSON)
+//UnmarshalJSON 实现StreamEvent的json反序列化，支持JSONEncoder的所有配置的输出，
+//语句只还原Query.SQL以及v2结构中的Query.Database，不还原Query.Charset
+func (s *StreamEvent) UnmarshalJSON(data []byte) error {
+	return decodeStreamEvent(data, s)
 }
 
 //RowData 行数据
@@ -114,10 +88,15 @@ func newRowData(cnt int) *RowData {
 
 //ColumnData 单个列的信息
 type ColumnData struct {
-	Filed   string     // 字段信息
-	Type    ColumnType // binlog中的列类型
-	IsEmpty bool       // data is empty,即该列没有变化
-	Data    []byte     // the data
+	Filed        string     // 字段信息
+	Type         ColumnType // binlog中的列类型
+	IsEmpty      bool       // data is empty,即该列没有变化
+	IsPrimaryKey bool       // 是否是主键列
+	Charset      string     // 字符列的字符集，非字符列或者未知时为空
+	Data         []byte     // the data
+	//JSONDiffs JSON列的部分更新(binlog_row_value_options=PARTIAL_JSON)，
+	//before image中有该列时Data是还原后的完整值，否则Data为nil
+	JSONDiffs []replication.JSONDiff
 }
 
 //newColumnData 创建ColumnData
@@ -129,30 +108,13 @@ func newColumnData(filed string, typ ColumnType, isEmpty bool) *ColumnData {
 	}
 }
 
-type baseColumnJSON struct {
-	Filed   string `json:"filed"`
-	Type    string `json:"type"`
-	IsEmpty bool   `json:"isEmpty"`
-}
-
-//MarshalJSON 实现ColumnData的json序列化
+//MarshalJSON 实现ColumnData的json序列化，与默认配置的JSONEncoder相同
 func (c *ColumnData) MarshalJSON() ([]byte, error) {
-	b := baseColumnJSON{
-		Filed:   c.Filed,
-		Type:    c.Type.String(),
-		IsEmpty: c.IsEmpty,
-	}
+	return NewJSONEncoder().EncodeColumnData(c)
+}
 
-	var i interface{} = string(c.Data)
-	if c.Data == nil {
-		i = nil
-	}
-	notNullJSON := struct {
-		baseColumnJSON
-		Data interface{} `json:"data"`
-	}{
-		baseColumnJSON: b,
-		Data:           i,
-	}
-	return json.Marshal(notNullJSON)
+//UnmarshalJSON 实现ColumnData的json反序列化，支持JSONEncoder的所有配置的输出，
+//不是合法utf8的二进制数据需要使用v2结构以及base64或hex编码才能还原，几何列只有在GeometryFormatRaw时可以还原
+func (c *ColumnData) UnmarshalJSON(data []byte) error {
+	return decodeColumnData(data, c)
 }
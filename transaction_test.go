@@ -103,3 +103,43 @@ func TestTransaction_MarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestColumnData_MarshalJSON(t *testing.T) {
+	testCases := []struct {
+		input *ColumnData
+		want  string
+	}{
+		{
+			input: &ColumnData{
+				Filed:        "id",
+				Type:         columnTypeLong,
+				IsPrimaryKey: true,
+				Data:         []byte("1"),
+			},
+			want: `{"filed":"id","type":"Long","isEmpty":false,"isPrimaryKey":true,"data":"1"}`,
+		},
+		{
+			input: &ColumnData{
+				Filed: "doc",
+				Type:  columnTypeJSON,
+				Data:  []byte("JSON_OBJECT('a',2)"),
+				JSONDiffs: []replication.JSONDiff{
+					{Operation: replication.JSONDiffReplace, Path: "$.a", Value: []byte("'2'")},
+					{Operation: replication.JSONDiffRemove, Path: "$.b"},
+				},
+			},
+			want: `{"filed":"doc","type":"JSON","isEmpty":false,"jsonDiffs":[{"op":"replace","path":"$.a","value":"'2'"},` +
+				`{"op":"remove","path":"$.b"}],"data":"JSON_OBJECT('a',2)"}`,
+		},
+	}
+
+	for _, v := range testCases {
+		out, err := v.input.MarshalJSON()
+		if err != nil {
+			t.Fatalf("MarshalJSON fail. err: %v", err)
+		}
+		if string(out) != v.want {
+			t.Fatalf("want != out want: %v out: %s", v.want, out)
+		}
+	}
+}